@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/virus-evolution/gofasta/pkg/amplicons"
+	"github.com/virus-evolution/gofasta/pkg/gfio"
+	"github.com/virus-evolution/gofasta/pkg/sam"
+)
+
+var ampliconsReference string
+var ampliconsBed string
+var ampliconsQuery string
+var ampliconsSamfile string
+var ampliconsOutfile string
+var ampliconsSummary string
+var ampliconsThreads int
+
+func init() {
+	rootCmd.AddCommand(ampliconsCmd)
+
+	ampliconsCmd.Flags().StringVarP(&ampliconsReference, "reference", "r", "", "Reference sequence, in fasta format")
+	ampliconsCmd.Flags().StringVarP(&ampliconsBed, "bed", "b", "", "Primer scheme, in BED format")
+	ampliconsCmd.Flags().StringVarP(&ampliconsQuery, "query", "q", "stdin", "Alignment of sequences to the reference, in fasta format")
+	ampliconsCmd.Flags().StringVarP(&ampliconsSamfile, "samfile", "s", "", "SAM file of sequences aligned to the reference, to use instead of --query")
+	ampliconsCmd.Flags().StringVarP(&ampliconsOutfile, "outfile", "o", "stdout", "CSV-format file of each sequence's amplicon coverage and primer mutations to write")
+	ampliconsCmd.Flags().StringVarP(&ampliconsSummary, "summary", "", "", "CSV-format file of each amplicon's coverage across all the sequences to write")
+	ampliconsCmd.Flags().IntVarP(&ampliconsThreads, "threads", "t", 1, "Number of threads to use")
+
+	ampliconsCmd.Flags().SortFlags = false
+}
+
+var ampliconsCmd = &cobra.Command{
+	Use:   "amplicons",
+	Short: "Find amplicon dropouts and mutations in primer binding sites",
+	Long: `Find amplicon dropouts and mutations in primer binding sites
+
+Example usage:
+	gofasta amplicons -r reference.fasta -b scheme.primer.bed -q alignment.fasta -o amplicons.csv --summary dropouts.csv
+	minimap2 -a -x asm20 --score-N=0 reference.fasta unaligned.fasta | gofasta amplicons -r reference.fasta -b scheme.primer.bed -s stdin
+
+--bed is a primer scheme in BED format (e.g. ARTIC's scheme.primer.bed), with the columns chrom, start, end, name and
+pool. Primers are grouped into amplicons by their names, which must contain _LEFT or _RIGHT (e.g. SARS-CoV-2_1_LEFT,
+SARS-CoV-2_1_RIGHT_alt1). The sequences are either an alignment to the reference (--query), which must be the same
+width as it, or a SAM file (--samfile), which is converted to an alignment as by gofasta sam toMultiAlign --pad.
+
+The coverage of an amplicon is the fraction of the sites between its primers that are A, T, G or C in a sequence.
+It is full if all of them are, has dropped out if none of them are, and is partial otherwise.
+
+--outfile has one row per sequence, with the columns: query,full,partial,dropout,primer_mutations. full, partial
+and dropout are "|"-delimited lists of amplicons, and primer_mutations is a "|"-delimited list of SNPs, deletions and
+insertions relative to --reference that are in primer binding sites, with the primer they are in (e.g.
+C21T:SARS-CoV-2_1_LEFT, del:21:2:SARS-CoV-2_1_LEFT, ins:21:3:SARS-CoV-2_1_LEFT). Gaps at the ends of a sequence are
+missing data, not deletions. Insertions can only be found in a --query alignment whose --reference is aligned with it,
+with gaps in the insertion columns (--samfile alignments don't keep insertions).
+
+--summary has one row per amplicon, with its pool, the (1-based, inclusive) coordinates of the sequence between its
+primers, the number of sequences in which it is full, partial and has dropped out, its dropout frequency, its mean
+coverage, and the number of sequences with a mutation in any of its primers.
+`,
+
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		if cmd.Flag("query").Changed && cmd.Flag("samfile").Changed {
+			return errors.New("use one of --query and --samfile")
+		}
+
+		scheme, err := gfio.OpenIn(*cmd.Flag("bed"))
+		if err != nil {
+			return err
+		}
+		defer scheme.Close()
+
+		ref, err := gfio.OpenIn(*cmd.Flag("reference"))
+		if err != nil {
+			return err
+		}
+		defer ref.Close()
+
+		var alignment io.Reader
+
+		if cmd.Flag("samfile").Changed {
+			samIn, err := gfio.OpenIn(*cmd.Flag("samfile"))
+			if err != nil {
+				return err
+			}
+			defer samIn.Close()
+
+			pr, pw := io.Pipe()
+			// if amplicons.Dropouts returns before reading all of the alignment, closing the pipe stops
+			// sam.ToMultiAlign from blocking on its writes
+			defer pr.Close()
+			go func() {
+				opts := sam.DefaultMultiAlignOptions()
+				opts.Pad = true
+				opts.Threads = ampliconsThreads
+				pw.CloseWithError(sam.ToMultiAlign(samIn, pw, opts))
+			}()
+			alignment = pr
+		} else {
+			query, err := gfio.OpenIn(*cmd.Flag("query"))
+			if err != nil {
+				return err
+			}
+			defer query.Close()
+			alignment = query
+		}
+
+		out, err := gfio.OpenOut(*cmd.Flag("outfile"))
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		var summary io.Writer
+		if cmd.Flag("summary").Changed {
+			f, err := gfio.OpenOut(*cmd.Flag("summary"))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			summary = f
+		}
+
+		err = amplicons.Dropouts(scheme, ref, alignment, out, summary, ampliconsThreads)
+
+		return err
+	},
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/virus-evolution/gofasta/pkg/clock"
+	"github.com/virus-evolution/gofasta/pkg/gfio"
+	"github.com/virus-evolution/gofasta/pkg/metadata"
+)
+
+var clockMSA string
+var clockReference string
+var clockMeasure string
+var clockMetadata string
+var clockDateColumn string
+var clockDateRegex string
+var clockOutlierIQR float64
+var clockOutfile string
+var clockSummary string
+var clockThreads int
+
+func init() {
+	rootCmd.AddCommand(clockCmd)
+
+	clockCmd.Flags().StringVarP(&clockMSA, "msa", "", "stdin", "Multiple sequence alignment in fasta format")
+	clockCmd.Flags().StringVarP(&clockReference, "reference", "r", "", "The ID of the reference record in the msa, which is treated as the root")
+	clockCmd.Flags().StringVarP(&clockMeasure, "measure", "m", "snp", "Which distance measure to use (snp or tn93)")
+	clockCmd.Flags().StringVarP(&clockMetadata, "metadata", "", "", "CSV-format file (with a header) of sampling dates. The first column must be the sequence ID")
+	clockCmd.Flags().StringVarP(&clockDateColumn, "date-column", "", "date", "The column in --metadata to take dates from")
+	clockCmd.Flags().StringVarP(&clockDateRegex, "date-regex", "", "", "Regular expression to parse dates from sequence IDs, instead of --metadata. The first capture group (or else the whole match) is the date")
+	clockCmd.Flags().Float64VarP(&clockOutlierIQR, "outlier-iqr", "", 3.0, "Flag sequences whose residuals are more than this many interquartile ranges outside the quartiles of all residuals")
+	clockCmd.Flags().StringVarP(&clockOutfile, "outfile", "o", "stdout", "CSV-format file of per-sequence distances and residuals to write")
+	clockCmd.Flags().StringVarP(&clockSummary, "summary", "", "", "CSV-format file of regression statistics to write (Default: stderr)")
+	clockCmd.Flags().IntVarP(&clockThreads, "threads", "t", 1, "Number of threads to use")
+
+	clockCmd.Flags().SortFlags = false
+}
+
+var clockCmd = &cobra.Command{
+	Use:   "clock",
+	Short: "Root-to-tip regression of distance against sampling date for a multiple sequence alignment in fasta format",
+	Long: `Root-to-tip regression of distance against sampling date for a multiple sequence alignment in fasta format
+
+Example usage:
+
+	gofasta clock --msa alignment.fasta -r MN908947.3 --metadata metadata.csv -o rtt.csv
+	gofasta clock --msa alignment.fasta -r MN908947.3 --measure tn93 --date-regex '\|(\d{4}-\d{2}-\d{2})$' -o rtt.csv --summary clock.csv
+
+The distance of each sequence in --msa from --reference (the root) is calculated, either as the number of SNPs (--measure snp,
+the default) or as Tamura and Nei's 1993 evolutionary distance (--measure tn93), as for gofasta closest. If --msa is stdin,
+--reference must be its first record. Distances are regressed on sampling dates by least squares, which gives the rate (the
+slope, in SNPs or substitutions per site per year), the tMRCA (the x-intercept, i.e. the date at which the distance is zero)
+and R² (a measure of how clock-like the data are).
+
+Dates are taken from the --date-column of --metadata (a CSV-format file whose first column is the sequence ID), or parsed from
+sequence IDs using --date-regex. Dates must be complete (YYYY-MM-DD) or decimal years (e.g. 2020.2351). Sequences without a
+usable date, and sequences whose distance can't be calculated, are reported but are not included in the regression.
+
+--outfile is a CSV-format file with the header: sequence,date,decimal_date,distance,predicted,residual,outlier. residual is
+the observed minus the predicted distance. Sequences whose residuals are more than --outlier-iqr interquartile ranges below the
+lower quartile or above the upper quartile of all the residuals are flagged as outliers: these may be bad sequences (too far
+from the root) or have the wrong dates.
+
+--summary is a CSV-format file with the header statistic,value, which includes the number of sequences, the number in the
+regression, the rate, the intercept, the tMRCA (as a decimal year and a date), R², the limits on residuals for outliers and the
+number of outliers.
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		if clockReference == "" {
+			return errors.New("you must provide a --reference")
+		}
+
+		var measure string
+		switch strings.ToLower(clockMeasure) {
+		case "snp":
+			measure = "snp"
+		case "tn93":
+			measure = "tn93"
+		default:
+			return errors.New("Couldn't tell which distance --measure / -m to use (choose one of \"snp\" or \"tn93\")")
+		}
+
+		if (clockMetadata == "") == (clockDateRegex == "") {
+			return errors.New("you must provide one of --metadata or --date-regex")
+		}
+
+		msa, err := gfio.OpenIn(*cmd.Flag("msa"))
+		if err != nil {
+			return err
+		}
+		defer msa.Close()
+
+		stdin := false
+		if clockMSA == "stdin" {
+			stdin = true
+		}
+
+		var dates map[string]string
+		if clockMetadata != "" {
+			m, err := gfio.OpenIn(*cmd.Flag("metadata"))
+			if err != nil {
+				return err
+			}
+			defer m.Close()
+			table, err := metadata.Read(m, "")
+			if err != nil {
+				return err
+			}
+			dates, err = table.Column(clockDateColumn)
+			if err != nil {
+				return err
+			}
+		}
+
+		out, err := gfio.OpenOut(*cmd.Flag("outfile"))
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		var summary io.Writer = os.Stderr
+		if clockSummary != "" {
+			s, err := gfio.OpenOut(*cmd.Flag("summary"))
+			if err != nil {
+				return err
+			}
+			defer s.Close()
+			summary = s
+		}
+
+		err = clock.RootToTip(msa, stdin, clockReference, measure, dates, clockDateRegex, clockOutlierIQR, out, summary, clockThreads)
+
+		return
+	},
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"errors"
+	"os"
 	"strconv"
 	"strings"
 
@@ -19,6 +20,8 @@ var closestN int
 var closestDist string
 var closestMeasure string
 var closestTable bool
+var closestPrivate bool
+var closestReference string
 
 func init() {
 	rootCmd.AddCommand(closestCmd)
@@ -31,6 +34,10 @@ func init() {
 	closestCmd.Flags().StringVarP(&closestDist, "max-dist", "d", "", "(Optional) return all sequences less than or equal to this distance away")
 	closestCmd.Flags().StringVarP(&closestOutfile, "outfile", "o", "stdout", "The output file to write")
 	closestCmd.Flags().BoolVarP(&closestTable, "table", "", false, "Write a long-form table of the output")
+	closestCmd.Flags().BoolVarP(&closestPrivate, "private", "", false, "Write a long-form table of the output including the SNPs that are private to the query and to each target")
+	closestCmd.Flags().StringVarP(&closestReference, "reference", "r", "", "Reference sequence, in fasta format, aligned to the query and target (required by --private)")
+
+	closestCmd.Flags().Lookup("private").NoOptDefVal = "true"
 
 	closestCmd.Flags().SortFlags = false
 }
@@ -69,6 +76,15 @@ of nucleotide changes in total (snp), or Tamura and Nei's 1993 evolutionary dist
 
 Use --table in combination with the -n and/or -d flags to write a long-form output including the distance
 between every pair.
+
+Use --private (with or without -n and/or -d) to write a long-form output with the headers query, target, distance,
+query_snps, target_snps, masked_sites. query_snps are SNPs relative to --reference that are in the query but not the
+target, and target_snps are SNPs that are in the target but not the query. masked_sites are the positions of SNPs in
+either sequence that are at ambiguous (or gapped) sites in the other, so can't be compared. SNPs are formatted as in
+gofasta updown list (e.g. C3037T), and all positions are in (degapped) reference coordinates, as for updown list, so
+columns that are gaps in the reference are skipped. --reference must be aligned to the query and target alignments:
+
+	gofasta closest -t 2 -n 5 --private -r WH04.fasta --query query.fasta --target target.fasta -o closest.private.csv
 `,
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 
@@ -110,8 +126,24 @@ between every pair.
 		}
 		defer closestOut.Close()
 
+		var refIn *os.File
+		if closestPrivate {
+			if closestReference == "" {
+				return errors.New("--private requires a --reference")
+			}
+			refIn, err = gfio.OpenIn(*cmd.Flag("reference"))
+			if err != nil {
+				return err
+			}
+			defer refIn.Close()
+			// --private on its own means just the single closest target
+			if closestN == 0 && dist == -1.0 {
+				closestN = 1
+			}
+		}
+
 		if closestN > 0 || dist != -1.0 {
-			err = closest.ClosestN(closestN, dist, queryIn, targetIn, measure, closestOut, closestTable, closestThreads)
+			err = closest.ClosestN(closestN, dist, queryIn, targetIn, measure, closestOut, closestTable, closestPrivate, refIn, closestThreads)
 		} else {
 			err = closest.Closest(queryIn, targetIn, measure, closestOut, closestThreads)
 		}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/virus-evolution/gofasta/pkg/gfio"
+	"github.com/virus-evolution/gofasta/pkg/updown"
+)
+
+var clusterQuery string
+var clusterReference string
+var clusterThreshold int
+var clusterThresholdPair float32
+var clusterMinSize int
+var clusterPreviousClusters string
+var clusterPreviousEdges string
+var clusterOutfile string
+var clusterEdges string
+var clusterThreads int
+
+func init() {
+	rootCmd.AddCommand(clusterCmd)
+
+	clusterCmd.Flags().StringVarP(&clusterQuery, "query", "q", "", "File of sequences to cluster. Either the CSV output of gofasta updown list, or an alignment in fasta format")
+	clusterCmd.Flags().StringVarP(&clusterReference, "reference", "r", "", "Reference sequence, in fasta format - only required if --query is a fasta file")
+	clusterCmd.Flags().IntVarP(&clusterThreshold, "threshold", "", 0, "Link sequences that are this many SNPs or fewer apart")
+	clusterCmd.Flags().Float32VarP(&clusterThresholdPair, "threshold-pair", "", 0.1, "Up to this proportion of consequential sites is allowed to be ambiguous in either sequence for each pairwise comparison")
+	clusterCmd.Flags().IntVarP(&clusterMinSize, "min-size", "", 2, "Only report clusters with at least this many sequences")
+	clusterCmd.Flags().StringVarP(&clusterPreviousClusters, "previous-clusters", "", "", "(Optional) --outfile from a previous run, to carry cluster IDs over from")
+	clusterCmd.Flags().StringVarP(&clusterPreviousEdges, "previous-edges", "", "", "(Optional) --edges from a previous run, so that distances between sequences in the previous run aren't recalculated")
+	clusterCmd.Flags().StringVarP(&clusterOutfile, "outfile", "o", "stdout", "TSV-format file of cluster membership to write")
+	clusterCmd.Flags().StringVarP(&clusterEdges, "edges", "", "", "(Optional) TSV-format file of links between sequences to write")
+	clusterCmd.Flags().IntVarP(&clusterThreads, "threads", "t", 1, "Number of threads to use")
+
+	clusterCmd.Flags().SortFlags = false
+}
+
+var clusterCmd = &cobra.Command{
+	Use:   "cluster",
+	Short: "Cluster sequences that are within a SNP-distance threshold of each other",
+	Long: `Cluster sequences that are within a SNP-distance threshold of each other
+
+Example usage:
+
+	gofasta cluster -r reference.fasta -q alignment.fasta --threshold 2 -o clusters.tsv --edges edges.tsv
+	gofasta cluster -q mutationlist.csv --threshold 2 --previous-clusters clusters.tsv --previous-edges edges.tsv -o clusters.new.tsv --edges edges.new.tsv
+
+--query can either be an alignment in fasta format or the CSV output of gofasta updown list, and must have file extension
+.csv .fasta or .fa . If it is an alignment, you must provide --reference.
+
+Two sequences are linked if the SNP-distance between them is less than or equal to --threshold, and clusters are made by
+single-linkage, so every sequence in a cluster is within --threshold SNPs of at least one other sequence in it. The distance
+is the number of sites where both sequences have certain nucleotides (A, C, G or T) that are different, so ambiguities and
+gaps don't count towards it. Pairs where more than --threshold-pair of the consequential sites are ambiguous aren't linked,
+as for gofasta updown topranking. Every pair of sequences is compared, so this scales with the square of the number of
+sequences in --query.
+
+--outfile is a TSV-format file with the columns: sequence, cluster, previous_cluster, with a row for every sequence in --query.
+cluster is empty for sequences that aren't in a cluster with at least --min-size members. --edges is a TSV-format file with
+the columns: sequence1, sequence2, distance, with a row for every pair of linked sequences, after a first line that records
+--threshold and --threshold-pair (tab-separated, e.g. #threshold=2 and threshold-pair=0.1).
+
+For incremental updates, run the program on all the sequences (old and new), with the --outfile from the previous run as
+--previous-clusters. Each cluster keeps the ID of the previous cluster that it shares the most members with (so when clusters
+merge, the ID of the bigger one is kept), and clusters that are new get new IDs, so IDs are stable across runs. previous_cluster
+is the sequence's cluster in --previous-clusters. If you also provide the --edges from the previous run as --previous-edges,
+sequences that were in the previous run aren't compared to each other again, only to new sequences, which is much faster. In
+that case --threshold must not be larger than it was in the previous run, and --threshold-pair must be the same as it was
+(otherwise it is an error).
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		var qtype string
+
+		switch filepath.Ext(clusterQuery) {
+		case ".csv":
+			qtype = "csv"
+		case ".fasta":
+			qtype = "fasta"
+		case ".fa":
+			qtype = "fasta"
+		default:
+			return errors.New("couldn't tell if --query was a .csv or a .fasta file")
+		}
+
+		if qtype == "fasta" && len(clusterReference) == 0 {
+			return errors.New("if --query is a fasta file, you must provide a --reference")
+		}
+
+		query, err := gfio.OpenIn(*cmd.Flag("query"))
+		if err != nil {
+			return err
+		}
+		defer query.Close()
+
+		var ref io.ReadCloser
+		if qtype == "fasta" {
+			ref, err = gfio.OpenIn(*cmd.Flag("reference"))
+			if err != nil {
+				return err
+			}
+			defer ref.Close()
+		}
+
+		var previousClusters, previousEdges io.Reader
+		if clusterPreviousClusters != "" {
+			f, err := gfio.OpenIn(*cmd.Flag("previous-clusters"))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			previousClusters = f
+		}
+		if clusterPreviousEdges != "" {
+			f, err := gfio.OpenIn(*cmd.Flag("previous-edges"))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			previousEdges = f
+		}
+
+		out, err := gfio.OpenOut(*cmd.Flag("outfile"))
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		var edges io.Writer
+		if clusterEdges != "" {
+			f, err := gfio.OpenOut(*cmd.Flag("edges"))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			edges = f
+		}
+
+		err = updown.Cluster(query, ref, qtype, clusterThreshold, clusterThresholdPair, clusterMinSize, previousClusters, previousEdges, out, edges, clusterThreads)
+
+		return
+	},
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/virus-evolution/gofasta/pkg/convert"
+	"github.com/virus-evolution/gofasta/pkg/gfio"
+	"github.com/virus-evolution/gofasta/pkg/metadata"
+)
+
+var convertMSA string
+var convertFormat string
+var convertTaxa bool
+var convertDates string
+var convertDateColumn string
+var convertOutfile string
+var convertWrap int
+
+func init() {
+	rootCmd.AddCommand(convertCmd)
+
+	convertCmd.Flags().StringVarP(&convertMSA, "msa", "", "stdin", "Multiple sequence alignment in fasta format")
+	convertCmd.Flags().StringVarP(&convertFormat, "format", "f", "", "Output alignment format. One of: fasta, phylip, phylip-relaxed, nexus, clustal, stockholm")
+	convertCmd.Flags().BoolVarP(&convertTaxa, "taxa", "", false, "Include a TAXA block in nexus output")
+	convertCmd.Flags().StringVarP(&convertDates, "dates", "", "", "CSV-format file (with a header) of sequence dates, to append to the taxon labels in nexus output (as name|date). The first column must be the sequence ID")
+	convertCmd.Flags().StringVarP(&convertDateColumn, "date-column", "", "date", "The column in --dates to take dates from")
+	convertCmd.Flags().StringVarP(&convertOutfile, "outfile", "o", "stdout", "Where to write the converted alignment")
+	convertCmd.Flags().IntVarP(&convertWrap, "wrap", "w", -1, "Wrap the output alignment to this number of characters wide (fasta, clustal and stockholm only)")
+
+	convertCmd.Flags().Lookup("taxa").NoOptDefVal = "true"
+
+	convertCmd.Flags().SortFlags = false
+}
+
+var convertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "Convert a multiple sequence alignment in fasta format to another alignment format",
+	Long: `Convert a multiple sequence alignment in fasta format to another alignment format
+
+Example usage:
+
+	gofasta convert --msa alignment.fasta --format phylip-relaxed -o alignment.phy
+	gofasta convert --msa alignment.fasta --format nexus --taxa --dates dates.csv -o alignment.nex
+
+Available formats are phylip (strict: names are truncated or padded to 10 characters), phylip-relaxed,
+nexus, clustal and stockholm (as well as fasta, which can be used to (un)wrap an alignment).
+
+For nexus output, --taxa adds a TAXA block, and --dates appends each sequence's date to its taxon label after a '|',
+e.g. 'seq1|2020-01-01'. This is the scheme BEAUti's "Guess dates" option (split on '|', take the last field) reads
+when setting up tip dates for BEAST. The file passed to --dates must be a csv file with a header line, whose first
+column is the sequence ID. Dates are taken from the column named by --date-column. Every sequence in the alignment
+must have a date.
+
+The whole alignment is held in memory, because all the formats apart from fasta need to know its dimensions
+before anything is written.
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		if convertFormat == "" {
+			return errors.New("you must provide a --format")
+		}
+
+		msa, err := gfio.OpenIn(*cmd.Flag("msa"))
+		if err != nil {
+			return err
+		}
+		defer msa.Close()
+
+		var dates map[string]string
+		if convertDates != "" {
+			datesIn, err := gfio.OpenIn(*cmd.Flag("dates"))
+			if err != nil {
+				return err
+			}
+			defer datesIn.Close()
+			table, err := metadata.Read(datesIn, "")
+			if err != nil {
+				return err
+			}
+			dates, err = table.Column(convertDateColumn)
+			if err != nil {
+				return err
+			}
+		}
+
+		out, err := gfio.OpenOut(*cmd.Flag("outfile"))
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		err = convert.Convert(msa, out, convertFormat, convertWrap, convertTaxa, dates)
+
+		return
+	},
+}
@@ -0,0 +1,117 @@
+package cmd
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/virus-evolution/gofasta/pkg/gfio"
+	"github.com/virus-evolution/gofasta/pkg/lineage"
+)
+
+var lineageMSA string
+var lineageReference string
+var lineageAnnotation string
+var lineageDefinitions string
+var lineageMaxMissing int
+var lineageOutfile string
+var lineageThreads int
+
+func init() {
+	rootCmd.AddCommand(lineageCmd)
+
+	lineageCmd.Flags().StringVarP(&lineageMSA, "msa", "", "stdin", "Multiple sequence alignment in fasta format")
+	lineageCmd.Flags().StringVarP(&lineageReference, "reference", "r", "", "The ID of the reference record in the msa")
+	lineageCmd.Flags().StringVarP(&lineageAnnotation, "annotation", "a", "", "Genbank or GFF3 format annotation file. Must have suffix .gb or .gff")
+	lineageCmd.Flags().StringVarP(&lineageDefinitions, "definitions", "d", "", "CSV-format file of lineage definitions")
+	lineageCmd.Flags().IntVarP(&lineageMaxMissing, "max-missing", "", 0, "Maximum number of a lineage's required mutations that can be missing from a sequence for it to be assigned that lineage")
+	lineageCmd.Flags().StringVarP(&lineageOutfile, "outfile", "o", "stdout", "Name of the file of lineage assignments to write")
+	lineageCmd.Flags().IntVarP(&lineageThreads, "threads", "t", 1, "Number of threads to use")
+
+	lineageCmd.Flags().SortFlags = false
+}
+
+var lineageCmd = &cobra.Command{
+	Use:   "lineage",
+	Short: "Assign sequences in a multiple sequence alignment in fasta format to lineages from their defining mutations",
+	Long: `Assign sequences in a multiple sequence alignment in fasta format to lineages from their defining mutations
+
+Example usage:
+
+	gofasta lineage --msa alignment.fasta --annotation MN908947.gb --reference MN908947.3 --definitions lineages.csv > lineages.csv
+
+--definitions is a CSV-format file with the header: lineage,parent,required,optional. required and optional are
+"|"-delimited lists of mutations in the same format as the output of gofasta variants, e.g. nuc:C3037T, aa:S:D614G,
+del:11288:9 or ins:22204:9. parent is the name of another lineage in the file, or is empty for a root lineage. Each
+lineage inherits the required and optional mutations of its ancestors, so you only need to list the mutations that
+are new in each lineage.
+
+Mutations are annotated for each sequence as for gofasta variants, and then each lineage's required mutations are
+classified as matched (present in the sequence), ambiguous (the sequence has ambiguous nucleotides or gaps at the
+mutation's site(s)), or missing (otherwise). A lineage can be assigned to a sequence if no more than --max-missing of its
+required mutations are missing. The best lineage has the most matched required mutations, then the fewest missing, then
+the most matched optional mutations, then is the most specific (deepest in the hierarchy), then is first in the file.
+Sequences that can't be assigned to any lineage are "unassigned".
+
+The output is a CSV-format file with the header: query,lineage,required,matched,missing,ambiguous,optional_matched,missing_mutations.
+The counts are of the assigned lineage's required mutations (and optional mutations for optional_matched), and
+missing_mutations is a "|"-delimited list of its missing required mutations.
+
+As for gofasta variants, if you provide a --reference, it must be in the --msa (and if you are reading the --msa from stdin,
+it must be the first record). If you don't, the reference is taken from the --annotation, and the --msa must be in the same
+coordinates.
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		if lineageAnnotation == "" {
+			return errors.New("you must provide an --annotation")
+		}
+		if lineageDefinitions == "" {
+			return errors.New("you must provide lineage --definitions")
+		}
+
+		msa, err := gfio.OpenIn(*cmd.Flag("msa"))
+		if err != nil {
+			return err
+		}
+		defer msa.Close()
+
+		stdin := false
+		if lineageMSA == "stdin" {
+			stdin = true
+		}
+
+		var annoSuffix string
+		switch filepath.Ext(lineageAnnotation) {
+		case ".gb":
+			annoSuffix = "gb"
+		case ".gff":
+			annoSuffix = "gff"
+		default:
+			return errors.New("couldn't tell if --annotation was a .gb or a .gff file")
+		}
+
+		anno, err := gfio.OpenIn(*cmd.Flag("annotation"))
+		if err != nil {
+			return err
+		}
+		defer anno.Close()
+
+		defs, err := gfio.OpenIn(*cmd.Flag("definitions"))
+		if err != nil {
+			return err
+		}
+		defer defs.Close()
+
+		out, err := gfio.OpenOut(*cmd.Flag("outfile"))
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		err = lineage.Assign(msa, stdin, lineageReference, anno, annoSuffix, defs, lineageMaxMissing, out, lineageThreads)
+
+		return
+	},
+}
@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/virus-evolution/gofasta/pkg/closest"
+	"github.com/virus-evolution/gofasta/pkg/gfio"
+	"github.com/virus-evolution/gofasta/pkg/metadata"
+)
+
+var networkMSA string
+var networkMethod string
+var networkEpsilon int
+var networkCounts string
+var networkCountColumn string
+var networkFormat string
+var networkOutfile string
+var networkThreads int
+
+func init() {
+	rootCmd.AddCommand(networkCmd)
+
+	networkCmd.Flags().StringVarP(&networkMSA, "msa", "", "stdin", "Multiple sequence alignment in fasta format")
+	networkCmd.Flags().StringVarP(&networkMethod, "method", "m", "mst", "Which network to build: minimum spanning tree (mst) or minimum spanning network (msn)")
+	networkCmd.Flags().IntVarP(&networkEpsilon, "epsilon", "e", 0, "For --method msn, also link haplotypes that are joined only by links that are up to this many SNPs shorter")
+	networkCmd.Flags().StringVarP(&networkCounts, "counts", "", "", "(Optional) csv file (with a header) of the number of samples that each sequence in --msa represents")
+	networkCmd.Flags().StringVarP(&networkCountColumn, "count-column", "", "count", "The column in --counts to take counts from")
+	networkCmd.Flags().StringVarP(&networkFormat, "format", "f", "json", "Output format: json, graphml or dot")
+	networkCmd.Flags().StringVarP(&networkOutfile, "outfile", "o", "stdout", "The output file to write")
+	networkCmd.Flags().IntVarP(&networkThreads, "threads", "t", 1, "Number of threads to use")
+
+	networkCmd.Flags().SortFlags = false
+}
+
+var networkCmd = &cobra.Command{
+	Use:   "network",
+	Short: "Build a haplotype network from a multiple sequence alignment in fasta format",
+	Long: `Build a haplotype network from a multiple sequence alignment in fasta format
+
+Example usage:
+
+	gofasta network --msa alignment.fasta -o network.json
+	gofasta network --msa alignment.fasta --method msn --epsilon 1 --format graphml -o network.graphml
+	gofasta network --msa haplotypes.fasta --counts counts.csv --format dot -o network.dot
+
+Sequences in --msa that are 0 SNPs apart are collapsed into haplotypes, and the SNP-distance between every pair of haplotypes
+is calculated, as for gofasta closest --measure snp (ambiguities and gaps don't count as differences, so sequences that only
+differ at them share a haplotype). A sequence that is 0 SNPs from more than one haplotype joins the first one in --msa. Then either a minimum
+spanning tree (--method mst) is built, or a minimum spanning network (--method msn), which is the union of all the possible
+minimum spanning trees. With --epsilon, the minimum spanning network is relaxed as in the first step of median-joining, so
+that more alternative links are shown. Median vectors (inferred haplotypes that weren't sampled) are not added.
+
+Each node in the output is a haplotype, which is named after its first sequence in --msa. Its size is the number of sequences
+in it, and its members are its sequences' IDs. If --msa is already a set of unique haplotypes, you can provide the number of
+samples each represents with --counts, a csv file whose first column is the sequence ID, and then node sizes are the sum of
+their members' counts (sequences that aren't in --counts count as 1). Each edge is weighted by the SNP-distance between the two
+haplotypes it links.
+
+--format json writes an object with "nodes" (id, size, members) and "edges" (source, target, weight). --format graphml and
+--format dot write undirected graphs with the same attributes (in dot, members is ";"-delimited and each edge is labelled with
+its weight).
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		method := strings.ToLower(networkMethod)
+		if method != "mst" && method != "msn" {
+			return errors.New("couldn't tell which network --method to use (choose one of \"mst\" or \"msn\")")
+		}
+
+		msa, err := gfio.OpenIn(*cmd.Flag("msa"))
+		if err != nil {
+			return err
+		}
+		defer msa.Close()
+
+		var counts map[string]string
+		if networkCounts != "" {
+			c, err := gfio.OpenIn(*cmd.Flag("counts"))
+			if err != nil {
+				return err
+			}
+			defer c.Close()
+			table, err := metadata.Read(c, "")
+			if err != nil {
+				return err
+			}
+			counts, err = table.Column(networkCountColumn)
+			if err != nil {
+				return err
+			}
+		}
+
+		out, err := gfio.OpenOut(*cmd.Flag("outfile"))
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		err = closest.Network(msa, counts, method, networkEpsilon, strings.ToLower(networkFormat), out, networkThreads)
+
+		return
+	},
+}
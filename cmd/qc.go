@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/virus-evolution/gofasta/pkg/gfio"
+	"github.com/virus-evolution/gofasta/pkg/qc"
+)
+
+var qcMSA string
+var qcReference string
+var qcAnnotation string
+var qcNeighbours string
+var qcConfig string
+var qcOutfile string
+var qcThreads int
+
+func init() {
+	rootCmd.AddCommand(qcCmd)
+
+	qcCmd.Flags().StringVarP(&qcMSA, "msa", "", "stdin", "Multiple sequence alignment in fasta format")
+	qcCmd.Flags().StringVarP(&qcReference, "reference", "r", "", "The ID of the reference record in the msa")
+	qcCmd.Flags().StringVarP(&qcAnnotation, "annotation", "a", "", "Genbank or GFF3 format annotation file. Must have suffix .gb or .gff")
+	qcCmd.Flags().StringVarP(&qcNeighbours, "neighbours", "", "", "Alignment of sequences to find each sequence's nearest neighbour in, in fasta format (default: the other sequences in --msa)")
+	qcCmd.Flags().StringVarP(&qcConfig, "config", "c", "", "JSON file of qc rules (default: Nextclade's rules for SARS-CoV-2)")
+	qcCmd.Flags().StringVarP(&qcOutfile, "outfile", "o", "stdout", "CSV-format file of qc results to write")
+	qcCmd.Flags().IntVarP(&qcThreads, "threads", "t", 1, "Number of threads to use")
+
+	qcCmd.Flags().SortFlags = false
+}
+
+var qcCmd = &cobra.Command{
+	Use:   "qc",
+	Short: "Score the quality of each sequence in an alignment",
+	Long: `Score the quality of each sequence in an alignment
+
+Example usage:
+	gofasta qc --msa alignment.fasta -r MN908947.3 -a MN908947.gb -o qc.csv
+	gofasta qc --msa alignment.fasta -r MN908947.3 -a MN908947.gb --neighbours background.fasta -c rules.json -o qc.csv
+
+Each sequence is scored by rules that are comparable to Nextclade's quality control. A score of 100 is bad:
+
+	missingData      - the number of Ns (and gaps at the ends of the sequence) above scoreBias, as a percentage of threshold
+	mixedSites       - the number of ambiguous nucleotides other than N, as a percentage of threshold
+	privateMutations - the number of snps that aren't in the sequence's nearest neighbour (by snp distance) above
+	                   typical, as a percentage of cutoff
+	snpClusters      - scoreWeight for each cluster of more than clusterCutOff private snps within windowSize nucleotides
+	frameShifts      - scoreWeight for each indel in a CDS whose length isn't a multiple of three
+	stopCodons       - scoreWeight for each premature stop codon in a CDS, other than ignoredStopCodons
+
+The overall score is the sum of the squares of the rules' scores divided by 100. A sequence passes if this is below
+warn (30), fails if it is at least fail (100), and gets a warning otherwise.
+
+--reference and --annotation work as for gofasta variants. If --neighbours isn't given, each sequence's nearest
+neighbour is found among the other sequences in --msa. The alignment is held in memory.
+
+--config is a JSON file that changes any of the thresholds from their defaults, or disables rules. Anything that isn't
+in it keeps its default value:
+
+	{
+	  "warn": 30,
+	  "fail": 100,
+	  "missingData": {"enabled": true, "scoreBias": 300, "threshold": 2700},
+	  "mixedSites": {"enabled": true, "threshold": 10},
+	  "privateMutations": {"enabled": true, "typical": 8, "cutoff": 24},
+	  "snpClusters": {"enabled": true, "windowSize": 100, "clusterCutOff": 6, "scoreWeight": 50},
+	  "frameShifts": {"enabled": true, "scoreWeight": 75},
+	  "stopCodons": {"enabled": true, "scoreWeight": 75, "ignoredStopCodons": ["ORF8:27"]}
+	}
+
+--outfile has one row per sequence, with the metric and score for each rule (the score is empty if the rule is
+disabled), the overall score, and the status (pass, warn or fail). The frameshifts and stop_codons columns list each
+frame-shifting indel and premature stop codon in the formats of gofasta variants --cds-flags (e.g. frameshift:ORF8:-1
+and stop:ORF8:27:26).
+`,
+
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		msa, err := gfio.OpenIn(*cmd.Flag("msa"))
+		if err != nil {
+			return err
+		}
+		defer msa.Close()
+
+		anno, err := gfio.OpenIn(*cmd.Flag("annotation"))
+		if err != nil {
+			return err
+		}
+		defer anno.Close()
+
+		var annoSuffix string
+		switch filepath.Ext(qcAnnotation) {
+		case ".gb":
+			annoSuffix = "gb"
+		case ".gff":
+			annoSuffix = "gff"
+		default:
+			return errors.New("couldn't tell if --annotation was a .gb or a .gff file")
+		}
+
+		var neighbours io.Reader
+		if cmd.Flag("neighbours").Changed {
+			f, err := gfio.OpenIn(*cmd.Flag("neighbours"))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			neighbours = f
+		}
+
+		config := qc.DefaultConfig()
+		if cmd.Flag("config").Changed {
+			f, err := gfio.OpenIn(*cmd.Flag("config"))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			config, err = qc.ReadConfig(f)
+			if err != nil {
+				return err
+			}
+		}
+
+		out, err := gfio.OpenOut(*cmd.Flag("outfile"))
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		err = qc.QC(msa, qcReference, anno, annoSuffix, neighbours, config, out, qcThreads)
+
+		return err
+	},
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/virus-evolution/gofasta/pkg/closest"
+	"github.com/virus-evolution/gofasta/pkg/gfio"
+)
+
+var recombinationThreads int
+var recombinationQuery string
+var recombinationTarget string
+var recombinationOutfile string
+var recombinationWindow int
+var recombinationStep int
+var recombinationCandidates int
+
+func init() {
+	rootCmd.AddCommand(recombinationCmd)
+
+	recombinationCmd.Flags().IntVarP(&recombinationThreads, "threads", "t", 0, "Number of CPUs to use (Default: all available CPUs)")
+	recombinationCmd.Flags().StringVarP(&recombinationQuery, "query", "", "", "Alignment of sequences to screen for recombination, in fasta format")
+	recombinationCmd.Flags().StringVarP(&recombinationTarget, "target", "", "", "Alignment of sequences to search for parents in, in fasta format")
+	recombinationCmd.Flags().IntVarP(&recombinationWindow, "window", "", 1000, "Width (in alignment columns) of the windows in which the closest target is kept as a candidate parent")
+	recombinationCmd.Flags().IntVarP(&recombinationStep, "step", "", 500, "Distance between the starts of consecutive windows")
+	recombinationCmd.Flags().IntVarP(&recombinationCandidates, "candidates", "n", 10, "Number of closest targets over the whole genome to keep as candidate parents")
+	recombinationCmd.Flags().StringVarP(&recombinationOutfile, "outfile", "o", "stdout", "The output file to write")
+
+	recombinationCmd.Flags().SortFlags = false
+}
+
+var recombinationCmd = &cobra.Command{
+	Use:   "recombination",
+	Short: "Screen query sequences for mosaic ancestry from two parents",
+	Long: `Screen query sequences for mosaic ancestry from two parents
+
+Example usage:
+
+	gofasta recombination -t 4 --query query.fasta --target target.fasta -o recombination.csv
+
+As for gofasta closest, the query alignment is read into memory and the target alignment is streamed
+from disk and iterated over once. For each query, a pool of candidate parents is kept: the closest
+--candidates targets by SNP-distance over the whole genome, plus the closest target in each window of
+--window alignment columns (windows start every --step columns).
+
+Every ordered pair of candidate parents is then tested with a triplet test after 3SEQ. Informative sites
+are those where the two parents differ and the query matches one of them. The statistic is the maximum
+descent of the walk along the informative sites (up if the query matches parent1, down if it matches
+parent2), and its p-value is exact. The most significant mosaic is reported for each query.
+
+The output is a CSV format file with the headers:
+
+	query, closest, closest_distance: the single closest target and its SNP-distance
+	parent1, parent2: the parents of the best mosaic. parent2 is the parent of the segment inside the descent
+	breakpoints: "|"-delimited intervals (1-based, inclusive) between the informative sites that each breakpoint lies in
+	informative_sites: the number of informative sites for this pair of parents
+	parent1_sites, parent2_sites: the number of informative sites that support each parent in its segment(s)
+	conflicting_sites: the number of informative sites that support the other parent in each segment
+	max_descent, p_value: the test statistic and its p-value
+	p_adjusted: the p-value with a Bonferroni correction for the number of pairs of parents tested
+	pairs_tested: the number of pairs of parents tested
+
+If no pair of candidates gives a mosaic, the parent columns are empty.
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		queryIn, err := gfio.OpenIn(*cmd.Flag("query"))
+		if err != nil {
+			return err
+		}
+		defer queryIn.Close()
+
+		targetIn, err := gfio.OpenIn(*cmd.Flag("target"))
+		if err != nil {
+			return err
+		}
+		defer targetIn.Close()
+
+		out, err := gfio.OpenOut(*cmd.Flag("outfile"))
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		err = closest.Recombination(queryIn, targetIn, recombinationWindow, recombinationStep, recombinationCandidates, out, recombinationThreads)
+
+		return
+	},
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/virus-evolution/gofasta/pkg/gfio"
+	"github.com/virus-evolution/gofasta/pkg/sam"
+)
+
+var fromMultiAlignMSA string
+var fromMultiAlignReference string
+var fromMultiAlignOutfile string
+var fromMultiAlignOutFormat string
+var fromMultiAlignSkipNs int
+
+func init() {
+	samCmd.AddCommand(fromMultiAlignCmd)
+
+	fromMultiAlignCmd.Flags().StringVarP(&fromMultiAlignMSA, "msa", "", "stdin", "Multiple sequence alignment in fasta format")
+	fromMultiAlignCmd.Flags().StringVarP(&fromMultiAlignReference, "reference", "r", "", "The ID of the reference record in the msa")
+	fromMultiAlignCmd.Flags().StringVarP(&fromMultiAlignOutfile, "outfile", "o", "stdout", "Where to write the SAM or BAM file")
+	fromMultiAlignCmd.Flags().StringVarP(&fromMultiAlignOutFormat, "out-format", "", "sam", "Output format. One of: sam, bam")
+	fromMultiAlignCmd.Flags().IntVarP(&fromMultiAlignSkipNs, "skip-ns", "", 0, "Write internal runs of at least this many Ns in a query as skipped regions (N in the cigar) instead of matches. 0 means never")
+
+	fromMultiAlignCmd.Flags().SortFlags = false
+}
+
+var fromMultiAlignCmd = &cobra.Command{
+	Use:     "fromMultiAlign",
+	Aliases: []string{"frommultialign", "fromma"},
+	Short:   "convert a fasta format alignment to a SAM or BAM file",
+	Long: `convert a fasta format alignment to a SAM or BAM file
+
+This is the reverse of gofasta sam toMultiAlign. The alignment must contain the reference, which is found by its ID
+(--reference). Every other record is written as a SAM record aligned to it, with a cigar (M/I/D/N/S) and NM and MD
+tags calculated from the columns of the alignment:
+
+	gofasta sam fromMultiAlign --msa alignment.fasta -r MN908947.3 -o aligned.sam
+	gofasta sam fromMultiAlign --msa alignment.fasta -r MN908947.3 --out-format bam -o aligned.bam
+
+Columns where the reference is a gap are insertions in the query, and columns where the query is a gap are deletions.
+Query bases before the first or after the last column where the query and the reference both have a base are soft
+clipped. Queries with no bases aligned to the reference are written as unmapped records. MAPQ is 255 (unavailable).
+
+Internal runs of Ns in a query, such as the padding that toMultiAlign writes for skipped regions, can be written as
+skipped regions with --skip-ns. If --msa is stdin, --reference must be the first record in it.`,
+
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		msa, err := gfio.OpenIn(*cmd.Flag("msa"))
+		if err != nil {
+			return err
+		}
+		defer msa.Close()
+
+		stdin := false
+		if fromMultiAlignMSA == "stdin" {
+			stdin = true
+		}
+
+		out, err := gfio.OpenOut(*cmd.Flag("outfile"))
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		err = sam.FromMultiAlign(msa, stdin, fromMultiAlignReference, out, fromMultiAlignOutFormat, fromMultiAlignSkipNs, samThreads)
+
+		return err
+	},
+}
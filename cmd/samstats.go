@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+
+	"github.com/virus-evolution/gofasta/pkg/gfio"
+	"github.com/virus-evolution/gofasta/pkg/sam"
+)
+
+var samStatsOutfile string
+
+func init() {
+	samCmd.AddCommand(samStatsCmd)
+
+	samStatsCmd.Flags().StringVarP(&samStatsOutfile, "outfile", "o", "stdout", "Where to write the stats (csv format)")
+
+	samStatsCmd.Flags().SortFlags = false
+}
+
+var samStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Summarise the alignment of each sequence in a SAM file",
+	Long: `Summarise the alignment of each sequence in a SAM file
+
+Example usage:
+	gofasta sam stats -s aligned.sam -o stats.csv
+
+If input sam and output csv files are not specified, the behaviour is to read the sam from stdin and write
+the stats to stdout, e.g.:
+	minimap2 -a -x asm20 --score-N=0 reference.fasta unaligned.fasta | gofasta sam stats > stats.csv
+
+Output is one line per sequence, with the columns:
+
+	query - the sequence's name
+	primary, supplementary, secondary, unmapped - the number of records of each kind
+	ref_start, ref_end - the first and last (1-based) reference positions in any alignment
+	ref_covered - the number of reference positions in any alignment (including deletions)
+	uncovered_fraction - the fraction of the reference that isn't in any alignment
+	soft_clip_start, soft_clip_end - the total number of soft-clipped bases at the start and end of the alignments,
+		in reference orientation
+	largest_deletion, largest_insertion - the length of the largest deletion and insertion relative to the reference
+	mapq - the mapping quality of the primary alignment
+	nm - the total edit distance (NM tags) of the alignments
+
+Everything after the record counts is calculated from the primary and supplementary alignments only.
+`,
+
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		samIn, err := gfio.OpenIn(*cmd.Flag("samfile"))
+		if err != nil {
+			return err
+		}
+		defer samIn.Close()
+
+		out, err := gfio.OpenOut(*cmd.Flag("outfile"))
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		err = sam.Stats(samIn, out, samThreads)
+
+		return err
+	},
+}
@@ -14,6 +14,14 @@ var toMultiAlignStart int
 var toMultiAlignEnd int
 var toMultiAlignPad bool
 var toMultiAlignWrap int
+var toMultiAlignFormat string
+var toMultiAlignInsertions bool
+var toMultiAlignColumnMap string
+var toMultiAlignMerge string
+var toMultiAlignMergeReport string
+var toMultiAlignRescueClips bool
+var toMultiAlignRescueMismatch float64
+var toMultiAlignRescueReport string
 
 // junk:
 var toMultiAlignTrim bool
@@ -28,6 +36,16 @@ func init() {
 	toMultiAlignCmd.Flags().BoolVarP(&toMultiAlignPad, "pad", "", false, "If --start and/or --end, replace the trimmed-out regions with Ns, else replace external deletions with Ns")
 	toMultiAlignCmd.Flags().StringVarP(&toMultiAlignOutfile, "fasta-out", "o", "stdout", "Where to write the alignment")
 	toMultiAlignCmd.Flags().IntVarP(&toMultiAlignWrap, "wrap", "w", -1, "Wrap the output alignment to this number of nucleotides wide. Omit this option not to wrap the output.")
+	toMultiAlignCmd.Flags().StringVarP(&toMultiAlignFormat, "format", "", "fasta", "Output alignment format. One of: fasta, phylip, phylip-relaxed, nexus, clustal, stockholm")
+	toMultiAlignCmd.Flags().BoolVarP(&toMultiAlignInsertions, "insertions", "", false, "Keep insertions relative to the reference, by adding alignment columns for them. Sequences without an insertion are padded with gaps")
+	toMultiAlignCmd.Flags().Lookup("insertions").NoOptDefVal = "true"
+	toMultiAlignCmd.Flags().StringVarP(&toMultiAlignColumnMap, "column-map", "", "", "If --insertions, write the reference position of each alignment column to this file (csv format)")
+	toMultiAlignCmd.Flags().StringVarP(&toMultiAlignMerge, "merge", "", "n", "How to combine each sequence's primary and supplementary alignments. One of: n, primary, mapq, score")
+	toMultiAlignCmd.Flags().StringVarP(&toMultiAlignMergeReport, "merge-report", "", "", "Write a report of how many alignments were merged for each sequence, and where they conflicted, to this file (csv format)")
+	toMultiAlignCmd.Flags().BoolVarP(&toMultiAlignRescueClips, "rescue-clips", "", false, "Project soft-clipped bases onto the reference beyond the ends of each alignment. Requires --reference")
+	toMultiAlignCmd.Flags().Lookup("rescue-clips").NoOptDefVal = "true"
+	toMultiAlignCmd.Flags().Float64VarP(&toMultiAlignRescueMismatch, "rescue-max-mismatch", "", 0.1, "If --rescue-clips, the largest fraction of a soft clip's bases that can differ from the reference for it to be rescued")
+	toMultiAlignCmd.Flags().StringVarP(&toMultiAlignRescueReport, "rescue-report", "", "", "If --rescue-clips, write a report of each soft clip, and whether it was rescued, to this file (csv format)")
 
 	toMultiAlignCmd.Flags().BoolVarP(&toMultiAlignTrim, "trim", "", false, "Trim the alignment")
 	toMultiAlignCmd.Flags().IntVarP(&toMultiAlignTrimStart, "trimstart", "", -1, "Start coordinate for trimming (0-based, half open)")
@@ -45,7 +63,7 @@ var toMultiAlignCmd = &cobra.Command{
 	Short:   "Convert a SAM file to a multiple alignment in fasta format",
 	Long: `Convert a SAM file to a multiple alignment in fasta format
 
-Insertions relative to the reference are omitted, so all sequences in the output are the same ( = reference) length.
+By default, insertions relative to the reference are omitted, so all sequences in the output are the same ( = reference) length.
 
 Example usage:
 	gofasta sam toMultiAlign -s aligned.sam -o aligned.fasta
@@ -53,6 +71,35 @@ Example usage:
 If you want, you can trim (and optionally pad) the output alignment to coordinates of your choosing:
 	gofasta sam toMultiAlign -s aligned.sam --start 266 --end 29674 --pad -o aligned.fasta
 
+If you want to keep insertions relative to the reference, use --insertions. Every insertion found in any sequence
+gets its own columns (as many as the longest insertion at that site), and the other sequences are padded with gaps there.
+You can write a map from alignment column to reference position using --column-map:
+	gofasta sam toMultiAlign -s aligned.sam --insertions --column-map columns.csv -o aligned.fasta
+
+In the column map, insertion columns are given the position of the reference base that they follow, and their
+(1-based) offset into the insertion. Reference columns have an insertion_offset of 0.
+--start and --end are in reference coordinates, and retain any insertions between them.
+
+Sequences that are rearranged or chimeric relative to the reference can have supplementary alignments as well as a
+primary alignment. Use --merge to choose how these are combined:
+	n       - use every alignment, and replace sites where they have different nucleotides with N (the default)
+	primary - use only the primary alignment
+	mapq    - use every alignment, taking each site from the alignment with the highest mapping quality
+	score   - as for mapq, but using the alignment score (the AS tag)
+and --merge-report to write the number of alignments merged and the regions where they conflicted for each sequence:
+	gofasta sam toMultiAlign -s aligned.sam --merge mapq --merge-report merges.csv -o aligned.fasta
+
+Sequence at the ends of a genome can be soft clipped by the aligner if it doesn't match the reference well. Use
+--rescue-clips (with the --reference that the sam file was made with) to project soft-clipped bases onto the reference
+positions beyond the ends of each alignment, where the reference extends that far. A soft clip is kept if no more than
+--rescue-max-mismatch of its bases (ignoring ambiguities) differ from the reference. Only soft clips at the ends of each
+sequence are rescued, so clipped bases that another of its alignments aligns (e.g. in a chimeric sequence) are left alone.
+You can write what happened to each soft clip using --rescue-report:
+	gofasta sam toMultiAlign -s aligned.sam -r reference.fasta --rescue-clips --rescue-report rescued.csv -o aligned.fasta
+
+You can write the alignment in a format other than fasta using --format, e.g.:
+	gofasta sam toMultiAlign -s aligned.sam --format nexus -o aligned.nex
+
 If input and output files are not specified, the behaviour is to read the sam file from stdin and write
 the fasta file to stdout, e.g.:
 	minimap2 -a -x asm20 --score-N=0 reference.fasta unaligned.fasta | gofasta sam toMultiAlign > aligned.fasta`,
@@ -95,7 +142,68 @@ Note the change of coordinate system if moving from old to new flags.
 		}
 		defer out.Close()
 
-		err = sam.ToMultiAlign(samIn, out, toMultiAlignWrap, toMultiAlignStart, toMultiAlignEnd, toMultiAlignPad, samThreads)
+		if cmd.Flag("column-map").Changed && !toMultiAlignInsertions {
+			return errors.New("--column-map requires --insertions")
+		}
+
+		opts := sam.MultiAlignOptions{
+			Format:      toMultiAlignFormat,
+			Wrap:        toMultiAlignWrap,
+			TrimStart:   toMultiAlignStart,
+			TrimEnd:     toMultiAlignEnd,
+			Pad:         toMultiAlignPad,
+			Policy:      toMultiAlignMerge,
+			MaxMismatch: toMultiAlignRescueMismatch,
+			Threads:     samThreads,
+		}
+
+		if cmd.Flag("merge-report").Changed {
+			f, err := gfio.OpenOut(*cmd.Flag("merge-report"))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			opts.Report = f
+		}
+
+		if (cmd.Flag("rescue-max-mismatch").Changed || cmd.Flag("rescue-report").Changed) && !toMultiAlignRescueClips {
+			return errors.New("--rescue-max-mismatch and --rescue-report require --rescue-clips")
+		}
+
+		if toMultiAlignRescueClips {
+			if samReference == "" {
+				return errors.New("--rescue-clips requires --reference")
+			}
+			ref, err := gfio.OpenIn(*cmd.Flag("reference"))
+			if err != nil {
+				return err
+			}
+			defer ref.Close()
+			opts.RescueRef = ref
+			if cmd.Flag("rescue-report").Changed {
+				f, err := gfio.OpenOut(*cmd.Flag("rescue-report"))
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				opts.RescueReport = f
+			}
+		}
+
+		if toMultiAlignInsertions {
+			if cmd.Flag("column-map").Changed {
+				f, err := gfio.OpenOut(*cmd.Flag("column-map"))
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				opts.ColumnMap = f
+			}
+			err = sam.ToMultiAlignInsertions(samIn, out, opts)
+			return
+		}
+
+		err = sam.ToMultiAlign(samIn, out, opts)
 
 		return
 	},
@@ -13,19 +13,24 @@ var toPairAlignSkipInsertions bool
 var toPairAlignStart int
 var toPairAlignEnd int
 var toPairAlignWrap int
+var toPairAlignQueryOrientation bool
+var toPairAlignOutFormat string
 
 func init() {
 	samCmd.AddCommand(toPairAlignCmd)
 
-	toPairAlignCmd.Flags().StringVarP(&toPairAlignOutpath, "outpath", "o", "", "Output path where fasta files will be written")
+	toPairAlignCmd.Flags().StringVarP(&toPairAlignOutpath, "outpath", "o", "stdout", "Output path where fasta file(s) will be written")
+	toPairAlignCmd.Flags().StringVarP(&toPairAlignOutFormat, "out-format", "", "", "How to write the alignments. One of: dir, fasta, tar, tar.gz, zip (default dir if --outpath is given, else fasta)")
 	toPairAlignCmd.Flags().BoolVarP(&toPairAlignOmitReference, "omit-reference", "", false, "Omit the reference sequences from the output alignments")
 	toPairAlignCmd.Flags().BoolVarP(&toPairAlignSkipInsertions, "skip-insertions", "", false, "Skip insertions relative to the reference from the output alignments")
 	toPairAlignCmd.Flags().IntVarP(&toPairAlignStart, "start", "", -1, "1-based first nucleotide position (in reference coordinates) to retain in the output. Bases before this position are omitted")
 	toPairAlignCmd.Flags().IntVarP(&toPairAlignEnd, "end", "", -1, "1-based last nucleotide position (in reference coordinates) to retain in the output. Bases after this position are omitted")
 	toPairAlignCmd.Flags().IntVarP(&toPairAlignWrap, "wrap", "w", -1, "Wrap the output alignment to this number of nucleotides wide. Omit this option not to wrap the output.")
+	toPairAlignCmd.Flags().BoolVarP(&toPairAlignQueryOrientation, "query-orientation", "", false, "Write each alignment in its query's original orientation (reverse complementing both sequences if the query mapped to the reverse strand), and report the strand and the aligned query coordinates in the fasta headers")
 
 	toPairAlignCmd.Flags().Lookup("omit-reference").NoOptDefVal = "true"
 	toPairAlignCmd.Flags().Lookup("skip-insertions").NoOptDefVal = "true"
+	toPairAlignCmd.Flags().Lookup("query-orientation").NoOptDefVal = "true"
 
 	toPairAlignCmd.Flags().SortFlags = false
 }
@@ -34,7 +39,18 @@ var toPairAlignCmd = &cobra.Command{
 	Use:     "toPairAlign",
 	Aliases: []string{"topairalign", "topa"},
 	Short:   "convert a SAM file to pairwise alignments in fasta format",
-	Long:    `convert a SAM file to pairwise alignments in fasta format`,
+	Long: `convert a SAM file to pairwise alignments in fasta format
+
+By default, if --outpath is given, one fasta file per query is written to the directory --outpath:
+	gofasta sam toPairAlign -s aligned.sam -r reference.fasta -o pairwise_alignments/
+
+With many queries, you can write all the alignments to a single file instead, using --out-format. fasta writes one
+fasta file with the pairwise alignments one after the other, and tar, tar.gz and zip write an archive with one fasta
+file per query in it (with the same names as in the directory):
+	gofasta sam toPairAlign -s aligned.sam -r reference.fasta --out-format fasta -o pairwise_alignments.fasta
+	gofasta sam toPairAlign -s aligned.sam -r reference.fasta --out-format tar.gz -o pairwise_alignments.tar.gz
+
+If --outpath is not given, the alignments are written to stdout (as fasta, unless --out-format is an archive format).`,
 
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 
@@ -50,7 +66,16 @@ var toPairAlignCmd = &cobra.Command{
 		}
 		defer ref.Close()
 
-		err = sam.ToPairAlign(samIn, ref, toPairAlignOutpath, toPairAlignWrap, toPairAlignStart, toPairAlignEnd, toPairAlignOmitReference, toPairAlignSkipInsertions, samThreads)
+		outFormat := toPairAlignOutFormat
+		if outFormat == "" {
+			if toPairAlignOutpath == "stdout" {
+				outFormat = "fasta"
+			} else {
+				outFormat = "dir"
+			}
+		}
+
+		err = sam.ToPairAlign(samIn, ref, toPairAlignOutpath, outFormat, toPairAlignWrap, toPairAlignStart, toPairAlignEnd, toPairAlignOmitReference, toPairAlignSkipInsertions, toPairAlignQueryOrientation, samThreads)
 
 		return err
 	},
@@ -18,6 +18,8 @@ var samVariantsThreshold float64
 var samVariantsAppendSNP bool
 var samVariantsStart int
 var samVariantsEnd int
+var samVariantsStrand bool
+var samVariantsCDSFlags bool
 
 // for backwards compatibility:
 var samVariantsGenbank string
@@ -33,8 +35,13 @@ func init() {
 	samVariantsCmd.Flags().Float64VarP(&samVariantsThreshold, "threshold", "", 0.0, "If --aggregate, only report changes with a freq greater than or equal to this value")
 	samVariantsCmd.Flags().BoolVarP(&samVariantsAppendSNP, "append-snps", "", false, "Report the codon's SNPs in parenthesis after each amino acid mutation")
 
+	samVariantsCmd.Flags().BoolVarP(&samVariantsStrand, "strand", "", false, "Report the strand of the reference that each sequence aligned to (+, -, or mixed if its alignments are on both)")
+	samVariantsCmd.Flags().BoolVarP(&samVariantsCDSFlags, "cds-flags", "", false, "Report premature stop codons, stop codon losses and frameshifts in each CDS, in their own column")
+
 	samVariantsCmd.Flags().Lookup("aggregate").NoOptDefVal = "true"
 	samVariantsCmd.Flags().Lookup("append-snps").NoOptDefVal = "true"
+	samVariantsCmd.Flags().Lookup("strand").NoOptDefVal = "true"
+	samVariantsCmd.Flags().Lookup("cds-flags").NoOptDefVal = "true"
 
 	samVariantsCmd.Flags().SortFlags = false
 
@@ -69,6 +76,18 @@ isn't in a codon that is represented by an amino acid change). The formats are:
 	nuc:C3037T - the nucleotide at (1-based) position 3037 is a C in the reference and a T in this sequence
 
 Frame-shifting mutations in coding sequence are reported as indels but are ignored for subsequent amino-acids in the alignment.
+
+Use --strand to add a column with the strand of the reference that each sequence aligned to. Sequences that were submitted
+in the reverse orientation are "-", and sequences with alignments on both strands (e.g. because of an inversion) are "mixed".
+
+Use --cds-flags to add a column with changes to the structure of each CDS, whose formats are:
+
+	stop:ORF8:27:26 - the first premature stop codon in ORF8 is at residue 27, which truncates the protein to 26 amino acids
+	stoploss:ORF8:122 - the stop codon of ORF8 (residue 122) is lost, by a change to an amino acid or a deletion
+	frameshift:ORF8:-1 - the net length of the insertions and deletions in ORF8 is -1, which shifts its frame
+
+Flags are restricted to --start and --end, like the mutations. Stop codons that are only read in the shifted frame
+downstream of an indel are not flagged (the frameshift is).
 `,
 
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
@@ -124,7 +143,7 @@ Frame-shifting mutations in coding sequence are reported as indels but are ignor
 		}
 		defer out.Close()
 
-		err = sam.Variants(samIn, ref, refFromFile, anno, annoSuffix, out, samVariantsStart, samVariantsEnd, samVariantsAggregate, samVariantsThreshold, samVariantsAppendSNP, samThreads)
+		err = sam.Variants(samIn, ref, refFromFile, anno, annoSuffix, out, samVariantsStart, samVariantsEnd, samVariantsAggregate, samVariantsThreshold, samVariantsAppendSNP, samVariantsStrand, samVariantsCDSFlags, samThreads)
 
 		return err
 	},
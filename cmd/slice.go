@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/virus-evolution/gofasta/pkg/gfio"
+	"github.com/virus-evolution/gofasta/pkg/slice"
+)
+
+var sliceMSA string
+var sliceReference string
+var sliceAnnotation string
+var sliceFeature string
+var sliceStart int
+var sliceEnd int
+var sliceTranslate bool
+var sliceOutfile string
+var sliceWrap int
+var sliceThreads int
+
+func init() {
+	rootCmd.AddCommand(sliceCmd)
+
+	sliceCmd.Flags().StringVarP(&sliceMSA, "msa", "", "stdin", "Multiple sequence alignment in fasta format")
+	sliceCmd.Flags().StringVarP(&sliceReference, "reference", "r", "", "The ID of the reference record in the msa")
+	sliceCmd.Flags().StringVarP(&sliceAnnotation, "annotation", "a", "", "Genbank or GFF3 format annotation file. Must have suffix .gb or .gff")
+	sliceCmd.Flags().StringVarP(&sliceFeature, "feature", "f", "", "Name of a protein-coding feature in --annotation to slice the alignment to. Overrides --start and --end")
+	sliceCmd.Flags().IntVarP(&sliceStart, "start", "", -1, "1-based first nucleotide position (in reference coordinates) to retain in the output")
+	sliceCmd.Flags().IntVarP(&sliceEnd, "end", "", -1, "1-based last nucleotide position (in reference coordinates) to retain in the output")
+	sliceCmd.Flags().BoolVarP(&sliceTranslate, "translate", "", false, "Translate the slice to protein")
+	sliceCmd.Flags().StringVarP(&sliceOutfile, "outfile", "o", "stdout", "Where to write the sliced alignment")
+	sliceCmd.Flags().IntVarP(&sliceWrap, "wrap", "w", -1, "Wrap the output alignment to this number of characters wide. Omit this option not to wrap the output.")
+	sliceCmd.Flags().IntVarP(&sliceThreads, "threads", "t", 1, "Number of threads to use")
+
+	sliceCmd.Flags().Lookup("translate").NoOptDefVal = "true"
+
+	sliceCmd.Flags().SortFlags = false
+}
+
+var sliceCmd = &cobra.Command{
+	Use:   "slice",
+	Short: "Slice a multiple sequence alignment in fasta format to a region of the reference",
+	Long: `Slice a multiple sequence alignment in fasta format to a region of the reference
+
+Example usage:
+
+	gofasta slice --msa alignment.fasta --reference MN908947.3 --start 21563 --end 25384 > spike.fasta
+	gofasta slice --msa alignment.fasta --reference MN908947.3 --annotation MN908947.gb --feature S > spike.fasta
+	gofasta slice --msa alignment.fasta --reference MN908947.3 --annotation MN908947.gb --feature S --translate > spike.aa.fasta
+
+--start and --end are 1-based and inclusive, and they are in reference coordinates. If you provide a --reference
+(the ID of the reference record in --msa), gaps in the reference sequence are accounted for, so that you get the
+columns that correspond to the reference region regardless of insertions elsewhere in the alignment, and any insertions
+inside the region are retained. If you are reading the --msa from stdin, the reference must be the first sequence.
+If you don't provide a --reference, the --msa must be in the same coordinates as the reference (and the annotation).
+
+Use --feature with the name of a protein-coding feature from --annotation to slice the alignment to that feature.
+
+Use --translate to write a protein alignment of the slice instead. Features are translated respecting joins and
+the strand they are on. Insertions relative to the reference are ignored, codons with alignment gaps in them are
+translated as "-", and codons that are ambiguous are translated as "X".
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		msa, err := gfio.OpenIn(*cmd.Flag("msa"))
+		if err != nil {
+			return err
+		}
+		defer msa.Close()
+
+		stdin := false
+		if sliceMSA == "stdin" {
+			stdin = true
+		}
+
+		if sliceFeature != "" && sliceAnnotation == "" {
+			return errors.New("you must provide an --annotation to use --feature")
+		}
+
+		var anno *os.File
+		var annoSuffix string
+		if sliceAnnotation != "" {
+			anno, err = gfio.OpenIn(*cmd.Flag("annotation"))
+			if err != nil {
+				return err
+			}
+			switch filepath.Ext(sliceAnnotation) {
+			case ".gb":
+				annoSuffix = "gb"
+			case ".gff":
+				annoSuffix = "gff"
+			default:
+				return errors.New("couldn't tell if --annotation was a .gb or a .gff file")
+			}
+		}
+		defer anno.Close()
+
+		out, err := gfio.OpenOut(*cmd.Flag("outfile"))
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		err = slice.Slice(msa, stdin, sliceReference, anno, annoSuffix, sliceFeature, sliceStart, sliceEnd, sliceTranslate, out, sliceWrap, sliceThreads)
+
+		return
+	},
+}
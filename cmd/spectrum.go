@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"errors"
+
+	"github.com/spf13/cobra"
+
+	"github.com/virus-evolution/gofasta/pkg/gfio"
+	"github.com/virus-evolution/gofasta/pkg/metadata"
+	"github.com/virus-evolution/gofasta/pkg/snps"
+)
+
+var spectrumReference string
+var spectrumQuery string
+var spectrumContext bool
+var spectrumPerSequence bool
+var spectrumMetadata string
+var spectrumIDColumn string
+var spectrumGroupColumn string
+var spectrumNormalise bool
+var spectrumOutfile string
+var spectrumThreads int
+
+func init() {
+	rootCmd.AddCommand(spectrumCmd)
+
+	spectrumCmd.Flags().StringVarP(&spectrumReference, "reference", "r", "", "Reference sequence, in fasta format")
+	spectrumCmd.Flags().StringVarP(&spectrumQuery, "query", "q", "stdin", "Alignment of sequences to count substitutions in, in fasta format")
+	spectrumCmd.Flags().BoolVarP(&spectrumContext, "context", "", false, "Count the 192 substitution types with trinucleotide context from the reference, instead of the 12 directional types")
+	spectrumCmd.Flags().BoolVarP(&spectrumPerSequence, "per-sequence", "", false, "Report the counts for each sequence, instead of summing them")
+	spectrumCmd.Flags().StringVarP(&spectrumMetadata, "metadata", "m", "", "csv file (with a header) of metadata, to group sequences by")
+	spectrumCmd.Flags().StringVarP(&spectrumIDColumn, "id-column", "", "", "Column in --metadata that contains the sequence IDs (default: the first column)")
+	spectrumCmd.Flags().StringVarP(&spectrumGroupColumn, "group-column", "g", "", "Column in --metadata to group sequences by")
+	spectrumCmd.Flags().BoolVarP(&spectrumNormalise, "normalise", "", false, "Also report the counts normalised by the frequency of each base (or trinucleotide) in the reference")
+	spectrumCmd.Flags().StringVarP(&spectrumOutfile, "outfile", "o", "stdout", "Output to write")
+	spectrumCmd.Flags().IntVarP(&spectrumThreads, "threads", "t", 1, "Number of threads to use")
+
+	spectrumCmd.Flags().Lookup("context").NoOptDefVal = "true"
+	spectrumCmd.Flags().Lookup("per-sequence").NoOptDefVal = "true"
+	spectrumCmd.Flags().Lookup("normalise").NoOptDefVal = "true"
+
+	spectrumCmd.Flags().SortFlags = false
+}
+
+var spectrumCmd = &cobra.Command{
+	Use:   "spectrum",
+	Short: "Count substitutions relative to a reference by type",
+	Long: `Count substitutions relative to a reference by type
+
+Example usage:
+	gofasta spectrum -r reference.fasta -q alignment.fasta -o spectrum.tsv
+	gofasta spectrum -r reference.fasta -q alignment.fasta --context --normalise -m metadata.csv -g lineage -o spectrum.tsv
+
+reference.fasta and alignment.fasta must be the same width.
+
+Substitutions are counted in the direction reference > query, either as the 12 directional types (e.g. C>T), or
+with --context as the 192 types with trinucleotide context from the reference (e.g. A[C>T]G). Only substitutions
+between unambiguous nucleotides are counted. With --context, the context is the nearest ungapped reference base on
+each side, and substitutions next to an ambiguous reference base are ignored.
+
+The output is a tab-separated file with one line per substitution type, with columns 'substitution' and 'count'. By
+default the counts are summed over the whole alignment. With --per-sequence there is one set of lines per sequence,
+with an extra column 'query'. If you provide --metadata and a --group-column, counts are summed within each group,
+with an extra column 'group' (sequences that are missing from the metadata are in the group "NA").
+
+With --normalise, two extra columns are written: 'sites' is the number of positions in the reference where that
+substitution type could occur (the number of times its reference base or trinucleotide occurs), and 'normalised' is
+count / sites.`,
+
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		if spectrumMetadata != "" && spectrumGroupColumn == "" {
+			return errors.New("you must provide a --group-column to use --metadata")
+		}
+		if spectrumGroupColumn != "" && spectrumMetadata == "" {
+			return errors.New("you must provide --metadata to use --group-column")
+		}
+
+		query, err := gfio.OpenIn(*cmd.Flag("query"))
+		if err != nil {
+			return err
+		}
+		defer query.Close()
+
+		ref, err := gfio.OpenIn(*cmd.Flag("reference"))
+		if err != nil {
+			return err
+		}
+		defer ref.Close()
+
+		var groups map[string]string
+		if spectrumMetadata != "" {
+			m, err := gfio.OpenIn(*cmd.Flag("metadata"))
+			if err != nil {
+				return err
+			}
+			defer m.Close()
+			table, err := metadata.Read(m, spectrumIDColumn)
+			if err != nil {
+				return err
+			}
+			groups, err = table.Column(spectrumGroupColumn)
+			if err != nil {
+				return err
+			}
+		}
+
+		out, err := gfio.OpenOut(*cmd.Flag("outfile"))
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		err = snps.Spectrum(ref, query, spectrumContext, spectrumPerSequence, groups, spectrumNormalise, out, spectrumThreads)
+
+		return
+	},
+}
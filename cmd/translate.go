@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"errors"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/virus-evolution/gofasta/pkg/gfio"
+	"github.com/virus-evolution/gofasta/pkg/translate"
+)
+
+var translateMSA string
+var translateReference string
+var translateAnnotation string
+var translateOutpath string
+var translateConcatenate bool
+var translateOutfile string
+var translateWrap int
+var translateThreads int
+
+func init() {
+	rootCmd.AddCommand(translateCmd)
+
+	translateCmd.Flags().StringVarP(&translateMSA, "msa", "", "stdin", "Multiple sequence alignment in fasta format")
+	translateCmd.Flags().StringVarP(&translateReference, "reference", "r", "", "The ID of the reference record in the msa")
+	translateCmd.Flags().StringVarP(&translateAnnotation, "annotation", "a", "", "Genbank or GFF3 format annotation file. Must have suffix .gb or .gff")
+	translateCmd.Flags().StringVarP(&translateOutpath, "outpath", "", "", "Directory to write one protein alignment per CDS to")
+	translateCmd.Flags().BoolVarP(&translateConcatenate, "concatenate", "", false, "Write a single alignment of all the CDS translations joined together to --outfile, instead of one per CDS")
+	translateCmd.Flags().StringVarP(&translateOutfile, "outfile", "o", "stdout", "Where to write the concatenated alignment (with --concatenate)")
+	translateCmd.Flags().IntVarP(&translateWrap, "wrap", "w", -1, "Wrap the output alignment(s) to this number of characters wide. Omit this option not to wrap the output.")
+	translateCmd.Flags().IntVarP(&translateThreads, "threads", "t", 1, "Number of threads to use")
+
+	translateCmd.Flags().Lookup("concatenate").NoOptDefVal = "true"
+
+	translateCmd.Flags().SortFlags = false
+}
+
+var translateCmd = &cobra.Command{
+	Use:   "translate",
+	Short: "Translate a multiple sequence alignment in fasta format to protein alignments, one per CDS",
+	Long: `Translate a multiple sequence alignment in fasta format to protein alignments, one per CDS
+
+Example usage:
+
+	gofasta translate --msa alignment.fasta --reference MN908947.3 --annotation MN908947.gb --outpath proteins/
+	gofasta translate --msa alignment.fasta --annotation MN908947.gb --concatenate > proteins.fasta
+
+Every CDS in --annotation is translated for every record in --msa. By default, one protein alignment is
+written per CDS, to a file named after the CDS in the directory --outpath. Use --concatenate to join the
+translations of all the CDS together (in the order they are in the annotation) and write them as a single
+alignment to --outfile instead.
+
+If you provide a --reference (the ID of the reference record in --msa), gaps in the reference sequence are
+accounted for. If you are reading the --msa from stdin, the reference must be the first sequence. If you don't
+provide a --reference, the --msa must be in the same coordinates as the annotation.
+
+CDS are translated respecting joins and the strand they are on. Insertions relative to the reference are ignored,
+codons with alignment gaps in them are translated as "-", and codons that are ambiguous are translated as "X".
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		if translateAnnotation == "" {
+			return errors.New("you must provide an --annotation")
+		}
+		if !translateConcatenate && translateOutpath == "" {
+			return errors.New("you must provide an --outpath (or use --concatenate)")
+		}
+
+		msa, err := gfio.OpenIn(*cmd.Flag("msa"))
+		if err != nil {
+			return err
+		}
+		defer msa.Close()
+
+		stdin := false
+		if translateMSA == "stdin" {
+			stdin = true
+		}
+
+		var annoSuffix string
+		anno, err := gfio.OpenIn(*cmd.Flag("annotation"))
+		if err != nil {
+			return err
+		}
+		defer anno.Close()
+		switch filepath.Ext(translateAnnotation) {
+		case ".gb":
+			annoSuffix = "gb"
+		case ".gff":
+			annoSuffix = "gff"
+		default:
+			return errors.New("couldn't tell if --annotation was a .gb or a .gff file")
+		}
+
+		out, err := gfio.OpenOut(*cmd.Flag("outfile"))
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		err = translate.Translate(msa, stdin, translateReference, anno, annoSuffix, out, translateOutpath, translateConcatenate, translateWrap, translateThreads)
+
+		return
+	},
+}
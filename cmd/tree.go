@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/virus-evolution/gofasta/pkg/gfio"
+	"github.com/virus-evolution/gofasta/pkg/tree"
+)
+
+var treeMSA string
+var treeMeasure string
+var treeMethod string
+var treeRoot string
+var treeOutfile string
+var treeThreads int
+
+func init() {
+	rootCmd.AddCommand(treeCmd)
+
+	treeCmd.Flags().StringVarP(&treeMSA, "msa", "", "stdin", "Multiple sequence alignment in fasta format")
+	treeCmd.Flags().StringVarP(&treeMeasure, "measure", "m", "raw", "Which distance measure to use (raw, snp or tn93)")
+	treeCmd.Flags().StringVarP(&treeMethod, "method", "", "nj", "Which tree-building method to use (nj or bionj)")
+	treeCmd.Flags().StringVarP(&treeRoot, "root", "r", "", "(Optional) the ID of a sequence in --msa to root the tree on")
+	treeCmd.Flags().StringVarP(&treeOutfile, "outfile", "o", "stdout", "The output file to write")
+	treeCmd.Flags().IntVarP(&treeThreads, "threads", "t", 0, "Number of CPUs to use for calculating distances (Default: all available CPUs)")
+
+	treeCmd.Flags().SortFlags = false
+}
+
+var treeCmd = &cobra.Command{
+	Use:   "tree",
+	Short: "Build a neighbour-joining tree from a multiple sequence alignment in fasta format",
+	Long: `Build a neighbour-joining tree from a multiple sequence alignment in fasta format
+
+Example usage:
+
+	gofasta tree --msa alignment.fasta -o tree.nwk
+	gofasta tree -t 8 --msa alignment.fasta --measure tn93 --method bionj --root WH04 -o tree.nwk
+
+The genetic distance between every pair of sequences in --msa is calculated, in parallel, using any of the measures
+that gofasta closest uses: raw number of nucleotide changes per site (the default, raw), raw number of nucleotide changes
+in total (snp), or Tamura and Nei's 1993 evolutionary distance (tn93). A tree is built from the distances by neighbour-joining
+(--method nj, the default) or by BIONJ (--method bionj), and written in Newick format. Negative branch lengths are set to zero.
+
+If --root is the ID of a sequence in --msa, the tree is rooted on it: the root is placed at the tip of that sequence, which
+hangs off it with a branch length of zero. Otherwise the tree is unrooted, and is drawn from an arbitrary internal node.
+
+Every pair of sequences is compared and the tree is built in memory, so this is intended for thousands, not millions, of
+sequences.
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		var measure string
+		switch strings.ToLower(treeMeasure) {
+		case "raw":
+			measure = "raw"
+		case "snp":
+			measure = "snp"
+		case "tn93":
+			measure = "tn93"
+		default:
+			return errors.New("Couldn't tell which distance --measure / -m to use (choose one of \"raw\", \"snp\" or \"tn93\")")
+		}
+
+		method := strings.ToLower(treeMethod)
+		if method != "nj" && method != "bionj" {
+			return errors.New("couldn't tell which tree --method to use (choose one of \"nj\" or \"bionj\")")
+		}
+
+		msa, err := gfio.OpenIn(*cmd.Flag("msa"))
+		if err != nil {
+			return err
+		}
+		defer msa.Close()
+
+		out, err := gfio.OpenOut(*cmd.Flag("outfile"))
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		err = tree.Tree(msa, measure, method, treeRoot, out, treeThreads)
+
+		return
+	},
+}
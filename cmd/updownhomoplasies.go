@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/virus-evolution/gofasta/pkg/gfio"
+	"github.com/virus-evolution/gofasta/pkg/updown"
+)
+
+var UDHomoplasiesQuery string
+var UDHomoplasiesOutfile string
+var UDHomoplasiesThreshold float32
+var UDHomoplasiesMinCount int
+var UDHomoplasiesMinOrigins int
+var UDHomoplasiesThreads int
+
+func init() {
+	updownCmd.AddCommand(updownHomoplasiesCmd)
+
+	updownHomoplasiesCmd.Flags().StringVarP(&UDHomoplasiesQuery, "query", "q", "", "File of sequences to look for recurrent mutations in. Either the CSV output of gofasta updown list, or an alignment in fasta format")
+	updownHomoplasiesCmd.Flags().StringVarP(&UDHomoplasiesOutfile, "outfile", "o", "stdout", "CSV-format file of recurrent mutations to write")
+	updownHomoplasiesCmd.Flags().Float32VarP(&UDHomoplasiesThreshold, "threshold-pair", "", 0.1, "Up to this proportion of consequential sites is allowed to be ambiguous in either sequence for each pairwise comparison")
+	updownHomoplasiesCmd.Flags().IntVarP(&UDHomoplasiesMinCount, "min-count", "", 2, "Only consider SNPs that are present in at least this many sequences")
+	updownHomoplasiesCmd.Flags().IntVarP(&UDHomoplasiesMinOrigins, "min-origins", "", 2, "Only report SNPs with at least this many estimated independent origins")
+	updownHomoplasiesCmd.Flags().IntVarP(&UDHomoplasiesThreads, "threads", "t", 1, "Number of threads to use")
+
+	updownHomoplasiesCmd.Flags().SortFlags = false
+}
+
+var updownHomoplasiesCmd = &cobra.Command{
+	Use:   "homoplasies",
+	Short: "Find mutations that have likely arisen more than once, using pseudo-tree relationships",
+	Long: `Find mutations that have likely arisen more than once, using pseudo-tree relationships
+
+Example usage:
+
+	gofasta updown homoplasies -r reference.fasta -q alignment.fasta -o homoplasies.csv
+	gofasta updown homoplasies -q mutationlist.csv --min-origins 3 -t 8 -o homoplasies.csv
+
+--query can either be an alignment in fasta format or the CSV output of gofasta updown list, and must have file
+extension .csv .fasta or .fa . If it is an alignment, you must provide --reference, which is treated as the root of the
+imaginary tree.
+
+Each sequence's likely direct ancestors among the other sequences are found as for gofasta updown topranking (the "up" bin).
+If a SNP arose only once, the SNPs of the ancestors of any sequence that carries it, which don't carry it themselves, should
+be present in every other sequence that carries it. Carriers of each SNP are grouped (most ancestral first) into origins whose
+members are all consistent with each other in this way, which gives an estimate of the number of independent origins of the SNP.
+Sites that are ambiguous in a sequence are treated as consistent. Every pair of sequences is compared, so this scales with the
+square of the number of sequences in --query.
+
+--outfile is a CSV-format file with the columns: SNP,count,origins,sizes,examples. count is the number of sequences that carry
+the SNP, origins is the estimated number of independent origins, sizes is a "|"-delimited list of the number of sequences in
+each origin, and examples is a "|"-delimited list of one sequence (the most ancestral) from each origin. SNPs are sorted by
+number of origins (most first) then by position.
+`,
+
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		var qtype string
+
+		switch filepath.Ext(UDHomoplasiesQuery) {
+		case ".csv":
+			qtype = "csv"
+		case ".fasta":
+			qtype = "fasta"
+		case ".fa":
+			qtype = "fasta"
+		default:
+			return errors.New("couldn't tell if --query was a .csv or a .fasta file")
+		}
+
+		if qtype == "fasta" && len(udReference) == 0 {
+			return errors.New("if --query is a fasta file, you must provide a --reference")
+		}
+
+		query, err := gfio.OpenIn(*cmd.Flag("query"))
+		if err != nil {
+			return err
+		}
+		defer query.Close()
+
+		var ref io.ReadCloser
+		if qtype == "fasta" {
+			ref, err = gfio.OpenIn(*cmd.Flag("reference"))
+			if err != nil {
+				return err
+			}
+			defer ref.Close()
+		}
+
+		out, err := gfio.OpenOut(*cmd.Flag("outfile"))
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		err = updown.Homoplasies(query, ref, out, qtype, UDHomoplasiesThreshold, UDHomoplasiesMinCount, UDHomoplasiesMinOrigins, UDHomoplasiesThreads)
+
+		return
+	},
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/spf13/cobra"
 
 	"github.com/virus-evolution/gofasta/pkg/gfio"
+	"github.com/virus-evolution/gofasta/pkg/metadata"
 	"github.com/virus-evolution/gofasta/pkg/updown"
 )
 
@@ -17,6 +18,7 @@ var TRtarget string
 var TRignore string
 var TRoutfile string
 var TRtable bool
+var TRprivate bool
 
 var TRsizetotal int
 var TRsizeup int
@@ -36,6 +38,16 @@ var TRdistpush int
 var TRthresholdpair float32
 var TRthresholdtarget int
 
+var TRmetadata string
+var TRdatecolumn string
+var TRdatefrom string
+var TRdateto string
+var TRinclude []string
+var TRexclude []string
+var TRtiebreak []string
+var TRquotacolumn string
+var TRquota int
+
 func init() {
 	updownCmd.AddCommand(toprankingCmd)
 
@@ -43,9 +55,20 @@ func init() {
 	toprankingCmd.Flags().StringVarP(&TRtarget, "target", "t", "", "File of sequences to look for neighbours in. Either the CSV output of gofasta updown list, or an alignment in fasta format")
 	toprankingCmd.Flags().StringVarP(&TRoutfile, "outfile", "o", "stdout", "CSV-format file of closest neighbours to write")
 	toprankingCmd.Flags().BoolVarP(&TRtable, "table", "", false, "Write a long-form table of the output")
+	toprankingCmd.Flags().BoolVarP(&TRprivate, "private", "", false, "Write a long-form table of the output including the SNPs that are private to the query and to each neighbour")
 	toprankingCmd.Flags().StringVarP(&udReference, "reference", "r", "", "Reference sequence, in fasta format - only required if --query and --target are fasta files")
 	toprankingCmd.Flags().StringVarP(&TRignore, "ignore", "", "", "Optional plain text file of IDs to ignore in the target file when searching for neighbours")
 
+	toprankingCmd.Flags().StringVarP(&TRmetadata, "metadata", "", "", "Optional CSV-format file (with a header) of target metadata. The first column must be the target ID")
+	toprankingCmd.Flags().StringVarP(&TRdatecolumn, "date-column", "", "date", "The column in --metadata that has sampling dates (YYYY-MM-DD)")
+	toprankingCmd.Flags().StringVarP(&TRdatefrom, "date-from", "", "", "Only consider targets sampled on or after this date (YYYY-MM-DD)")
+	toprankingCmd.Flags().StringVarP(&TRdateto, "date-to", "", "", "Only consider targets sampled on or before this date (YYYY-MM-DD)")
+	toprankingCmd.Flags().StringArrayVarP(&TRinclude, "include", "", []string{}, "Only consider targets with one of these values in a --metadata column, e.g. country=UK,Ireland. Can be used more than once")
+	toprankingCmd.Flags().StringArrayVarP(&TRexclude, "exclude", "", []string{}, "Don't consider targets with any of these values in a --metadata column, e.g. country=UK. Can be used more than once")
+	toprankingCmd.Flags().StringArrayVarP(&TRtiebreak, "tie-break", "", []string{}, "Break ties for SNP-distance by a --metadata column, e.g. date:desc, or for one bin, e.g. up=date:desc. Can be used more than once")
+	toprankingCmd.Flags().StringVarP(&TRquotacolumn, "quota-column", "", "", "The column in --metadata to apply --quota to")
+	toprankingCmd.Flags().IntVarP(&TRquota, "quota", "", 0, "Include at most this many targets with each value of --quota-column in each bin")
+
 	toprankingCmd.Flags().IntVarP(&TRdistall, "dist-all", "", 0, "Maximum allowed SNP-distance between target and query sequence in any direction. Overrides the settings below")
 	toprankingCmd.Flags().IntVarP(&TRdistup, "dist-up", "", 0, "Maximum allowed SNP-distance from query for sequences in the parent bin")
 	toprankingCmd.Flags().IntVarP(&TRdistdown, "dist-down", "", 0, "Maximum allowed SNP-distance from query for sequences in the child bin")
@@ -64,6 +87,7 @@ func init() {
 	toprankingCmd.Flags().BoolVarP(&TRnofill, "no-fill", "", false, "Don't make up for a shortfall in any of --size-up, -down, -side or -same by increasing the count for other bins")
 
 	toprankingCmd.Flags().Lookup("table").NoOptDefVal = "true"
+	toprankingCmd.Flags().Lookup("private").NoOptDefVal = "true"
 	toprankingCmd.Flags().Lookup("no-fill").NoOptDefVal = "true"
 
 	toprankingCmd.Flags().SortFlags = false
@@ -100,6 +124,25 @@ by increasing the count of the other bins where possible, unless --no-fill.
 
 You can combine the two types of flag (size and dist), to return only the closest n sequences under a set distance (as long as
 you haven't also invoked --dist-push).
+
+Use --private to write a long-form table with the headers query, direction, distance, target, query_snps, target_snps,
+masked_sites. query_snps are the SNPs that are in the query but not the neighbour, target_snps are the SNPs that are in
+the neighbour but not the query, and masked_sites are the positions of SNPs in either sequence that fall within an
+ambiguity in the other - these are the bins of the table that is used to assign the neighbour's direction.
+
+Use --metadata to choose neighbours using information about the targets, in a CSV-format file whose first column is the target
+ID. Targets can be filtered on a range of sampling dates (--date-from and --date-to, using --date-column) and on the values of
+any column (--include and --exclude), and targets that don't pass (including those that aren't in --metadata) are never
+neighbours. --ignore is the same as --exclude on the first (ID) column of --metadata, and can be used without --metadata.
+For example:
+
+	gofasta updown topranking -q query.csv -t targets.csv --metadata metadata.csv --date-from 2021-06-01 --include country=UK --size-total 1000
+
+Within each bin, ties for SNP-distance are broken by any --tie-break columns, in the order they are given, then by number of
+ambiguities. Add :desc to a column to prefer larger values (e.g. --tie-break date:desc prefers more recent targets), and prefix
+it with a bin to use it for that bin only (e.g. --tie-break side=qc_score:desc). Values are compared as numbers if they are
+numbers, and targets with missing values come last. --quota limits each bin to at most --quota targets with the same value of
+--quota-column (e.g. --quota-column country --quota 10), so that the best targets from other categories fill the rest of it.
 `,
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 
@@ -150,6 +193,24 @@ you haven't also invoked --dist-push).
 			}
 		}
 
+		var table metadata.Table
+		if TRmetadata != "" {
+			m, err := gfio.OpenIn(*cmd.Flag("metadata"))
+			if err != nil {
+				return err
+			}
+			defer m.Close()
+			table, err = metadata.Read(m, "")
+			if err != nil {
+				return err
+			}
+		}
+
+		rules, err := updown.NewTargetRules(table, ignoreArray, TRdatecolumn, TRdatefrom, TRdateto, TRinclude, TRexclude, TRtiebreak, TRquotacolumn, TRquota)
+		if err != nil {
+			return err
+		}
+
 		query, err := gfio.OpenIn(*cmd.Flag("query"))
 		if err != nil {
 			return err
@@ -177,8 +238,8 @@ you haven't also invoked --dist-push).
 		}
 		defer out.Close()
 
-		err = updown.TopRanking(query, target, ref, out, TRtable,
-			qtype, ttype, ignoreArray,
+		err = updown.TopRanking(query, target, ref, out, TRtable, TRprivate,
+			qtype, ttype, rules,
 			TRsizetotal, TRsizeup, TRsizedown, TRsizeside, TRsizesame,
 			TRdistall, TRdistup, TRdistdown, TRdistside,
 			TRthresholdpair, TRthresholdtarget, TRnofill, TRdistpush)
@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+
+	"github.com/virus-evolution/gofasta/pkg/gfio"
+	"github.com/virus-evolution/gofasta/pkg/updown"
+)
+
+var UDTreeQuery string
+var UDTreeOutfile string
+
+func init() {
+	updownCmd.AddCommand(updownTreeCmd)
+
+	updownTreeCmd.Flags().StringVarP(&UDTreeQuery, "query", "q", "", "File of sequences to build a pseudo-tree from. Either the CSV output of gofasta updown list, or an alignment in fasta format")
+	updownTreeCmd.Flags().StringVarP(&UDTreeOutfile, "outfile", "o", "stdout", "Newick-format file to write")
+
+	updownTreeCmd.Flags().SortFlags = false
+}
+
+var updownTreeCmd = &cobra.Command{
+	Use:   "tree",
+	Short: "Assemble sequences into an approximate tree, using pseudo-tree relationships",
+	Long: `Assemble sequences into an approximate tree, using pseudo-tree relationships
+
+Example usage:
+
+	gofasta updown tree -r reference.fasta -q alignment.fasta -o pseudotree.nwk
+	gofasta updown tree -q mutationlist.csv -o pseudotree.nwk
+
+--query can either be an alignment in fasta format or the CSV output of gofasta updown list, and must have file
+extension .csv .fasta or .fa . If it is an alignment, you must provide --reference, which is treated as the root of the
+imaginary tree.
+
+Sequences with the same SNPs are collapsed into one node, which is named after the first of them, and the others hang off
+it with branch lengths of zero, so identical sequences are in polytomies. Each node is placed as a child of a sequence whose
+SNPs are all in it (its likely direct ancestor, as in the "up" bin of gofasta updown topranking), or of the reference if there
+isn't one, so sampled ancestors are named internal nodes. Branch lengths are numbers of SNPs. Sequences with no SNPs are
+placed at the root.
+
+Each sequence is placed by greedy descent from the root, which makes this fast enough to use on millions of sequences,
+but it means that a sequence can be placed under a less derived ancestor than the best one available. Ambiguous sites
+are treated as the reference allele. So the output is only a pseudo-phylogeny, for quick browsing, and not a substitute
+for a real one.
+`,
+
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+
+		var qtype string
+
+		switch filepath.Ext(UDTreeQuery) {
+		case ".csv":
+			qtype = "csv"
+		case ".fasta":
+			qtype = "fasta"
+		case ".fa":
+			qtype = "fasta"
+		default:
+			return errors.New("couldn't tell if --query was a .csv or a .fasta file")
+		}
+
+		if qtype == "fasta" && len(udReference) == 0 {
+			return errors.New("if --query is a fasta file, you must provide a --reference")
+		}
+
+		query, err := gfio.OpenIn(*cmd.Flag("query"))
+		if err != nil {
+			return err
+		}
+		defer query.Close()
+
+		var ref io.ReadCloser
+		if qtype == "fasta" {
+			ref, err = gfio.OpenIn(*cmd.Flag("reference"))
+			if err != nil {
+				return err
+			}
+			defer ref.Close()
+		}
+
+		out, err := gfio.OpenOut(*cmd.Flag("outfile"))
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+
+		err = updown.PseudoTree(query, ref, out, qtype)
+
+		return
+	},
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"errors"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -19,8 +20,10 @@ var variantsThreads int
 var variantsAggregate bool
 var variantsThreshold float64
 var variantsAppendSNP bool
+var variantsCDSFlags bool
 var variantsStart int
 var variantsEnd int
+var variantsConstellations []string
 
 // for backwards compatibility:
 var variantsGenbank string
@@ -37,10 +40,13 @@ func init() {
 	variantsCmd.Flags().BoolVarP(&variantsAggregate, "aggregate", "", false, "Report the proportions of each change")
 	variantsCmd.Flags().Float64VarP(&variantsThreshold, "threshold", "", 0.0, "If --aggregate, only report changes with a freq greater than or equal to this value")
 	variantsCmd.Flags().BoolVarP(&variantsAppendSNP, "append-snps", "", false, "Report the codon's SNPs in parenthesis after each amino acid mutation")
+	variantsCmd.Flags().BoolVarP(&variantsCDSFlags, "cds-flags", "", false, "Report premature stop codons, stop codon losses and frameshifts in each CDS, in their own column")
+	variantsCmd.Flags().StringSliceVarP(&variantsConstellations, "constellations", "", []string{}, "Scorpio-style constellation json file(s). If provided, report how well each query matches each constellation instead of its mutations")
 	variantsCmd.Flags().IntVarP(&variantsThreads, "threads", "t", 1, "Number of threads to use")
 
 	variantsCmd.Flags().Lookup("aggregate").NoOptDefVal = "true"
 	variantsCmd.Flags().Lookup("append-snps").NoOptDefVal = "true"
+	variantsCmd.Flags().Lookup("cds-flags").NoOptDefVal = "true"
 
 	variantsCmd.Flags().StringVarP(&variantsGenbank, "genbank", "", "", "Genbank format annotation")
 	variantsCmd.Flags().MarkHidden("genbank")
@@ -80,6 +86,26 @@ isn't in a codon that is represented by an amino acid change). The formats are:
 	nuc:C3037T - the nucleotide at (1-based) position 3037 in reference coordinates is a C in the reference and a T in this sequence
 
 Frame-shifting mutations in coding sequence are reported as indels but are ignored for subsequent amino-acids in the alignment.	
+
+Use --cds-flags to add a column with changes to the structure of each CDS, whose formats are:
+
+	stop:ORF8:27:26 - the first premature stop codon in ORF8 is at residue 27, which truncates the protein to 26 amino acids
+	stoploss:ORF8:122 - the stop codon of ORF8 (residue 122) is lost, by a change to an amino acid or a deletion
+	frameshift:ORF8:-1 - the net length of the insertions and deletions in ORF8 is -1, which shifts its frame
+
+Flags are restricted to --start and --end, like the mutations. Stop codons that are only read in the shifted frame
+downstream of an indel are not flagged (the frameshift is).
+
+You can use --constellations to provide one or more scorpio-style constellation json files (comma-separated, or by
+repeating the flag). Each file is one constellation object, or an array of them, with a "label" and a list of "sites".
+Sites are mutations in the formats above (including indels), or scorpio's shorthand for amino acid changes (s:N501Y),
+amino acid deletions (s:HV69-, which is the deletion of the codons of H69 and V70: del:21767:6) and snps (snp:C3267T).
+Instead of the mutations, the output is then a csv file with the header:
+query,constellation,sites,alt,ref,ambiguous,other,matched_fraction, with a row for each query and each constellation.
+alt, ref, ambiguous and other are the numbers of the constellation's sites where the query has the mutation, the reference
+allele, ambiguous or missing data, or something else, and matched_fraction is alt / sites.
+
+	./gofasta variants --msa alignment.fasta --annotation MN908947.gb --reference MN908947.3 --constellations c1.json,c2.json > constellations.csv
 `,
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 
@@ -128,7 +154,27 @@ Frame-shifting mutations in coding sequence are reported as indels but are ignor
 		}
 		defer out.Close()
 
-		err = variants.Variants(msa, stdin, variantsReference, anno, annoSuffix, out, variantsStart, variantsEnd, variantsAggregate, variantsThreshold, variantsAppendSNP, variantsThreads)
+		if len(variantsConstellations) > 0 {
+			if variantsAggregate {
+				return errors.New("--constellations can't be used with --aggregate")
+			}
+			if variantsCDSFlags {
+				return errors.New("--constellations can't be used with --cds-flags")
+			}
+			constellations := make([]io.Reader, 0, len(variantsConstellations))
+			for _, path := range variantsConstellations {
+				f, err := os.Open(path)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				constellations = append(constellations, f)
+			}
+			err = variants.Constellations(msa, stdin, variantsReference, anno, annoSuffix, constellations, out, variantsThreads)
+			return
+		}
+
+		err = variants.Variants(msa, stdin, variantsReference, anno, annoSuffix, out, variantsStart, variantsEnd, variantsAggregate, variantsThreshold, variantsAppendSNP, variantsCDSFlags, variantsThreads)
 
 		return
 	},
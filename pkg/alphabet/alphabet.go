@@ -4,6 +4,7 @@ package alphabet
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/virus-evolution/gofasta/pkg/encoding"
 )
@@ -42,6 +43,25 @@ func Translate(nuc string, strict bool) (string, error) {
 	return translation, nil
 }
 
+// TranslateAligned translates an aligned nucleotide sequence to a protein sequence.
+// Codons that contain an alignment gap are translated as '-', and codons that can't be
+// resolved to a single amino acid are translated as 'X'. Any trailing partial codon is ignored
+func TranslateAligned(nuc string) string {
+	CD := MakeCodonDict()
+	translation := make([]byte, 0, len(nuc)/3)
+	for i := 0; i+3 <= len(nuc); i += 3 {
+		codon := strings.ToUpper(nuc[i : i+3])
+		if strings.Contains(codon, "-") {
+			translation = append(translation, '-')
+		} else if t, ok := CD[codon]; ok {
+			translation = append(translation, t...)
+		} else {
+			translation = append(translation, 'X')
+		}
+	}
+	return string(translation)
+}
+
 func Complement(nuc string) string {
 	CA := MakeCompArray()
 	ba := make([]byte, len(nuc))
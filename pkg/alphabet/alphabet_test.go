@@ -54,6 +54,14 @@ func TestTranslate(t *testing.T) {
 	}
 }
 
+func TestTranslateAligned(t *testing.T) {
+	nuc := "ATGat-NNNTAAATYG"
+	if TranslateAligned(nuc) != "M-X*I" {
+		t.Errorf("problem in TestTranslateAligned()")
+		fmt.Println(TranslateAligned(nuc))
+	}
+}
+
 func TestCodonDict(t *testing.T) {
 
 	spike_nuc_gb := SpaceMap(temp_spike_nuc_gb)
@@ -0,0 +1,329 @@
+/*
+Package amplicons implements functions to find amplicon dropouts in sequences from tiled amplicon schemes (such as
+ARTIC's), and mutations in the primer binding sites of the scheme.
+
+The coverage of each amplicon in a sequence is the fraction of the sites in its insert (the part between its
+primers) that are A, T, G or C. Anything else, including gaps, is missing, as for the ambiguity tracts of gofasta
+updown list. An amplicon is full if all of its insert is covered, dropped out if none of it is, and partial otherwise.
+
+The mutations in primer binding sites are SNPs, deletions and insertions relative to the reference. Gaps at either end of
+a sequence are missing data rather than deletions. The reference can have gaps in the columns where sequences have
+insertions relative to it (scheme coordinates are in the ungapped reference), and an insertion is in a primer if the
+reference positions on both sides of it are.
+*/
+package amplicons
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/virus-evolution/gofasta/pkg/encoding"
+	"github.com/virus-evolution/gofasta/pkg/fasta"
+)
+
+// ampliconLine is one sequence's coverage of each amplicon in the scheme, and the mutations in its primer binding sites
+type ampliconLine struct {
+	id               string
+	idx              int
+	coverage         []float64 // in scheme order
+	primerMutations  []string  // e.g. C21T:SARS-CoV-2_1_LEFT, del:21:2:SARS-CoV-2_1_LEFT or ins:21:3:SARS-CoV-2_1_LEFT
+	mutatedAmplicons []bool    // whether each amplicon has a mutation in any of its primers
+}
+
+// ampliconSummary is the coverage of one amplicon across every sequence
+type ampliconSummary struct {
+	samples         int
+	full            int
+	partial         int
+	dropout         int
+	coverage        float64 // summed over samples
+	primerMutations int     // samples with a mutation in any of the amplicon's primers
+}
+
+// primerSite is a primer that binds to a site, and the index of its amplicon in the scheme
+type primerSite struct {
+	name     string
+	amplicon int
+}
+
+// status returns whether an amplicon with this coverage is full, partial or has dropped out
+func status(coverage float64) string {
+	switch coverage {
+	case 1:
+		return "full"
+	case 0:
+		return "dropout"
+	default:
+		return "partial"
+	}
+}
+
+// getPrimerSites returns the primers that bind to each (0-based) reference position
+func getPrimerSites(scheme []amplicon, refLen int) [][]primerSite {
+	sites := make([][]primerSite, refLen)
+	for i, a := range scheme {
+		for _, p := range append(append([]primer{}, a.left...), a.right...) {
+			for pos := p.start - 1; pos < p.end; pos++ {
+				sites[pos] = append(sites[pos], primerSite{name: p.name, amplicon: i})
+			}
+		}
+	}
+	return sites
+}
+
+// getColumns returns the alignment column of each (0-based) position in a reference that can have gaps in it
+func getColumns(refSeq []byte) []int {
+	columns := make([]int, 0, len(refSeq))
+	for i, nuc := range refSeq {
+		if nuc != 244 {
+			columns = append(columns, i)
+		}
+	}
+	return columns
+}
+
+// addPrimerMutation adds a mutation to each of primers that it hasn't already been added to
+func addPrimerMutation(AL *ampliconLine, mutation string, primers []primerSite, seen map[string]bool) {
+	for _, p := range primers {
+		if seen[p.name] {
+			continue
+		}
+		seen[p.name] = true
+		AL.primerMutations = append(AL.primerMutations, mutation+":"+p.name)
+		AL.mutatedAmplicons[p.amplicon] = true
+	}
+}
+
+// getAmpliconLines gets the amplicon coverage and primer mutations of each fasta record from a channel. columns is the
+// alignment column of each reference position
+func getAmpliconLines(refSeq []byte, columns []int, scheme []amplicon, primerSites [][]primerSite, cFR chan fasta.EncodedRecord, cLines chan ampliconLine, cErr chan error) {
+
+	DA := encoding.MakeDecodingArray()
+
+	for FR := range cFR {
+		if len(FR.Seq) != len(refSeq) {
+			rl := strconv.Itoa(len(refSeq))
+			ql := strconv.Itoa(len(FR.Seq))
+			cErr <- errors.New("Reference sequence (" + rl + " bases) and " + FR.ID + " (" + ql + " bases) are different lengths")
+			break
+		}
+
+		AL := ampliconLine{id: FR.ID, idx: FR.Idx, coverage: make([]float64, len(scheme)), primerMutations: make([]string, 0), mutatedAmplicons: make([]bool, len(scheme))}
+
+		for i, a := range scheme {
+			covered := 0
+			for pos := a.start - 1; pos < a.end; pos++ {
+				if FR.Seq[columns[pos]]&8 == 8 {
+					covered++
+				}
+			}
+			AL.coverage[i] = float64(covered) / float64(a.end-a.start+1)
+		}
+
+		// gaps outside these columns are missing data, not deletions
+		first, last := 0, len(FR.Seq)-1
+		for first <= last && FR.Seq[first] == 244 {
+			first++
+		}
+		for last >= first && FR.Seq[last] == 244 {
+			last--
+		}
+
+		for pos := 0; pos < len(columns); pos++ {
+			col := columns[pos]
+
+			// an insertion before this position, in the columns since the last one
+			if pos > 0 && col-columns[pos-1] > 1 {
+				length := 0
+				for c := columns[pos-1] + 1; c < col; c++ {
+					if FR.Seq[c] != 244 {
+						length++
+					}
+				}
+				if length > 0 {
+					inBoth := make([]primerSite, 0)
+					for _, p := range primerSites[pos] {
+						for _, q := range primerSites[pos-1] {
+							if p.name == q.name {
+								inBoth = append(inBoth, p)
+							}
+						}
+					}
+					addPrimerMutation(&AL, "ins:"+strconv.Itoa(pos)+":"+strconv.Itoa(length), inBoth, make(map[string]bool))
+				}
+			}
+
+			if len(primerSites[pos]) == 0 && FR.Seq[col] != 244 {
+				continue
+			}
+
+			nuc := FR.Seq[col]
+			switch {
+			case nuc == 244 && col > first && col < last:
+				// a deletion, which is reported once for each primer that any of it is in
+				length := 1
+				for pos+length < len(columns) && FR.Seq[columns[pos+length]] == 244 && columns[pos+length] < last {
+					length++
+				}
+				seen := make(map[string]bool)
+				for p := pos; p < pos+length; p++ {
+					addPrimerMutation(&AL, "del:"+strconv.Itoa(pos+1)+":"+strconv.Itoa(length), primerSites[p], seen)
+				}
+				pos += length - 1
+			case nuc&8 == 8 && refSeq[col]&8 == 8 && refSeq[col]&nuc < 16:
+				addPrimerMutation(&AL, DA[refSeq[col]]+strconv.Itoa(pos+1)+DA[nuc], primerSites[pos], make(map[string]bool))
+			}
+		}
+
+		cLines <- AL
+	}
+}
+
+// writeAmpliconLines writes each sequence's amplicon coverage to stdout or a file as it arrives, in input order, and
+// adds it to the per-amplicon summary
+func writeAmpliconLines(w io.Writer, scheme []amplicon, summary []ampliconSummary, cLines chan ampliconLine, cErr chan error, cWriteDone chan bool) {
+
+	_, err := w.Write([]byte("query,full,partial,dropout,primer_mutations\n"))
+	if err != nil {
+		cErr <- err
+		return
+	}
+
+	outputMap := make(map[int]ampliconLine)
+	counter := 0
+
+	for line := range cLines {
+		outputMap[line.idx] = line
+		for {
+			AL, ok := outputMap[counter]
+			if !ok {
+				break
+			}
+
+			byStatus := map[string][]string{"full": {}, "partial": {}, "dropout": {}}
+			for i, a := range scheme {
+				s := status(AL.coverage[i])
+				byStatus[s] = append(byStatus[s], a.name)
+
+				summary[i].samples++
+				summary[i].coverage += AL.coverage[i]
+				switch s {
+				case "full":
+					summary[i].full++
+				case "partial":
+					summary[i].partial++
+				case "dropout":
+					summary[i].dropout++
+				}
+				if AL.mutatedAmplicons[i] {
+					summary[i].primerMutations++
+				}
+			}
+
+			_, err = w.Write([]byte(AL.id + "," + strings.Join(byStatus["full"], "|") + "," + strings.Join(byStatus["partial"], "|") + "," +
+				strings.Join(byStatus["dropout"], "|") + "," + strings.Join(AL.primerMutations, "|") + "\n"))
+			if err != nil {
+				cErr <- err
+				return
+			}
+			delete(outputMap, counter)
+			counter++
+		}
+	}
+
+	cWriteDone <- true
+}
+
+// writeSummary writes the coverage of each amplicon across all the sequences
+func writeSummary(w io.Writer, scheme []amplicon, summary []ampliconSummary) error {
+
+	_, err := w.Write([]byte("amplicon,pool,insert_start,insert_end,samples,full,partial,dropout,dropout_frequency,mean_coverage,primer_mutations\n"))
+	if err != nil {
+		return err
+	}
+
+	for i, a := range scheme {
+		s := summary[i]
+		dropoutFrequency, meanCoverage := 0.0, 0.0
+		if s.samples > 0 {
+			dropoutFrequency = float64(s.dropout) / float64(s.samples)
+			meanCoverage = s.coverage / float64(s.samples)
+		}
+		_, err = w.Write([]byte(a.name + "," + a.pool + "," + strconv.Itoa(a.start) + "," + strconv.Itoa(a.end) + "," +
+			strconv.Itoa(s.samples) + "," + strconv.Itoa(s.full) + "," + strconv.Itoa(s.partial) + "," + strconv.Itoa(s.dropout) + "," +
+			strconv.FormatFloat(dropoutFrequency, 'f', 6, 64) + "," + strconv.FormatFloat(meanCoverage, 'f', 6, 64) + "," +
+			strconv.Itoa(s.primerMutations) + "\n"))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Dropouts finds which amplicons in a primer scheme (in BED format) are fully covered, partially covered or have
+// dropped out in each sequence in a fasta-format alignment to reference, and the SNPs and indels in each sequence that
+// are in primer binding sites, and writes them to out. If summary is not nil, the number of sequences in which each
+// amplicon is full, partial or dropped out, its dropout frequency and its mean coverage are written to it
+func Dropouts(scheme, reference, alignment io.Reader, out, summary io.Writer, threads int) error {
+
+	amplicons, err := readScheme(scheme)
+	if err != nil {
+		return err
+	}
+
+	temp, err := fasta.LoadEncodeAlignment(reference, false, false, false)
+	if err != nil {
+		return err
+	}
+	if len(temp) > 1 {
+		return errors.New("More than one record in --reference")
+	}
+	refSeq := temp[0].Seq
+	columns := getColumns(refSeq)
+
+	for _, a := range amplicons {
+		for _, p := range append(append([]primer{}, a.left...), a.right...) {
+			if p.end > len(columns) {
+				return errors.New("primer " + p.name + " is outside the reference")
+			}
+		}
+	}
+
+	primerSites := getPrimerSites(amplicons, len(columns))
+	ampliconSummaries := make([]ampliconSummary, len(amplicons))
+
+	cErr := make(chan error)
+
+	cFR := make(chan fasta.EncodedRecord, threads+50)
+	cFRDone := make(chan bool)
+
+	cLines := make(chan ampliconLine, threads+50)
+
+	cWriteDone := make(chan bool)
+
+	go fasta.StreamEncodeAlignment(alignment, cFR, cErr, cFRDone, false, false, false)
+
+	go writeAmpliconLines(out, amplicons, ampliconSummaries, cLines, cErr, cWriteDone)
+
+	cLinesDone := fasta.StartWorkers(threads, func() {
+		getAmpliconLines(refSeq, columns, amplicons, primerSites, cFR, cLines, cErr)
+	})
+
+	err = fasta.WaitStages(cErr,
+		fasta.Stage{Done: cFRDone, Close: func() { close(cFR) }},
+		fasta.Stage{Done: cLinesDone, Close: func() { close(cLines) }},
+		fasta.Stage{Done: cWriteDone},
+	)
+	if err != nil {
+		return err
+	}
+
+	if summary != nil {
+		return writeSummary(summary, amplicons, ampliconSummaries)
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+package amplicons
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+var schemeData = []byte(`ref	0	4	S_1_LEFT	1	+
+ref	14	18	S_1_RIGHT	1	-
+ref	10	14	S_2_LEFT	2	+
+ref	24	28	S_2_RIGHT	2	-
+ref	11	15	S_2_LEFT_alt1	2	+
+ref	20	24	S_3_LEFT	1	+
+ref	36	40	S_3_RIGHT	1	-
+`)
+
+func TestReadScheme(t *testing.T) {
+	scheme, err := readScheme(bytes.NewReader(schemeData))
+	if err != nil {
+		t.Error(err)
+	}
+
+	desiredResult := []amplicon{
+		{name: "S_1", pool: "1", left: []primer{{"S_1_LEFT", 1, 4}}, right: []primer{{"S_1_RIGHT", 15, 18}}, start: 5, end: 14},
+		{name: "S_2", pool: "2", left: []primer{{"S_2_LEFT", 11, 14}, {"S_2_LEFT_alt1", 12, 15}}, right: []primer{{"S_2_RIGHT", 25, 28}}, start: 16, end: 24},
+		{name: "S_3", pool: "1", left: []primer{{"S_3_LEFT", 21, 24}}, right: []primer{{"S_3_RIGHT", 37, 40}}, start: 25, end: 36},
+	}
+
+	if fmt.Sprint(scheme) != fmt.Sprint(desiredResult) {
+		t.Errorf("problem in TestReadScheme()")
+		fmt.Println(scheme)
+	}
+
+	_, err = readScheme(bytes.NewReader([]byte("ref\t0\t4\tS_1_LEFT\t1\t+\n")))
+	if err == nil {
+		t.Errorf("problem in TestReadScheme(): expected an error for an amplicon without a RIGHT primer")
+	}
+}
+
+func TestDropouts(t *testing.T) {
+	refData := []byte(`>ref
+ACGTACGTACGTACGTACGTACGTACGTACGTACGTACGT
+`)
+	alignmentData := []byte(`>q1
+ACGAACGTACGTACGTACGTACGTACGTACGTACGTACGT
+>q2
+ACGTACNNNNNNNNNNNNNNNNNNNNNNNNNNNNNNACGT
+>q3
+---TACGTANNNACGTACGTACGTACGTACGTACGTACG-
+`)
+
+	out := new(bytes.Buffer)
+	summary := new(bytes.Buffer)
+
+	err := Dropouts(bytes.NewReader(schemeData), bytes.NewReader(refData), bytes.NewReader(alignmentData), out, summary, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `query,full,partial,dropout,primer_mutations
+q1,S_1|S_2|S_3,,,T4A:S_1_LEFT
+q2,,S_1,S_2|S_3,
+q3,S_2|S_3,S_1,,
+` {
+		t.Errorf("problem in TestDropouts()")
+		fmt.Println(out.String())
+	}
+
+	if summary.String() != `amplicon,pool,insert_start,insert_end,samples,full,partial,dropout,dropout_frequency,mean_coverage,primer_mutations
+S_1,1,5,14,3,1,2,0,0.000000,0.633333,1
+S_2,2,16,24,3,2,0,1,0.333333,0.666667,0
+S_3,1,25,36,3,2,0,1,0.333333,0.666667,0
+` {
+		t.Errorf("problem in TestDropouts()")
+		fmt.Println(summary.String())
+	}
+}
+
+func TestDropoutsIndels(t *testing.T) {
+	// the reference has gaps where q1 has an insertion in S_1_LEFT
+	refData := []byte(`>ref
+AC--GTACGTACGTACGTACGTACGTACGTACGTACGTACGT
+`)
+	alignmentData := []byte(`>q1
+ACTTGTACGTACGTACGTACGTACGTACGTACGTACGTACGT
+>q2
+AC--GTACGTACG--CGTACGTACGTACGTACGTACGTACGT
+>q3
+-----TACGTACGTACGTACGTACGTACGTACGTACGTACGT
+`)
+
+	out := new(bytes.Buffer)
+
+	err := Dropouts(bytes.NewReader(schemeData), bytes.NewReader(refData), bytes.NewReader(alignmentData), out, nil, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	// q3's leading gaps are missing data, not a deletion
+	if out.String() != `query,full,partial,dropout,primer_mutations
+q1,S_1|S_2|S_3,,,ins:2:2:S_1_LEFT
+q2,S_2|S_3,S_1,,del:12:2:S_2_LEFT|del:12:2:S_2_LEFT_alt1
+q3,S_1|S_2|S_3,,,
+` {
+		t.Errorf("problem in TestDropoutsIndels()")
+		fmt.Println(out.String())
+	}
+}
@@ -0,0 +1,124 @@
+package amplicons
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// primer is one primer in a scheme. start and end are the 1-based inclusive reference positions that it binds to
+type primer struct {
+	name  string
+	start int
+	end   int
+}
+
+// amplicon is a pair of (sets of alternative) left and right primers. start and end are the 1-based inclusive
+// reference positions of the insert between the primers, which is the part of the amplicon that is sequenced
+type amplicon struct {
+	name  string
+	pool  string
+	left  []primer
+	right []primer
+	start int
+	end   int
+}
+
+// parsePrimerName splits a primer's name into the name of its amplicon and its direction, which is the last
+// _LEFT or _RIGHT field in the name, e.g. SARS-CoV-2_1_LEFT, SARS-CoV-2_1_LEFT_alt1 and SARS-CoV-2_400_1_RIGHT_1
+func parsePrimerName(name string) (string, string, error) {
+	fields := strings.Split(name, "_")
+	for i := len(fields) - 1; i > 0; i-- {
+		if fields[i] == "LEFT" || fields[i] == "RIGHT" {
+			return strings.Join(fields[:i], "_"), fields[i], nil
+		}
+	}
+	return "", "", errors.New("couldn't find LEFT or RIGHT in primer name: " + name)
+}
+
+// readScheme reads a primer scheme in BED format (chrom, start, end, name, pool[, strand]), whose coordinates are
+// 0-based and half-open, and returns its amplicons in the order they first appear
+func readScheme(in io.Reader) ([]amplicon, error) {
+
+	amplicons := make([]amplicon, 0)
+	index := make(map[string]int)
+
+	s := bufio.NewScanner(in)
+	lineNumber := 0
+
+	for s.Scan() {
+		lineNumber++
+		line := s.Text()
+		if len(strings.TrimSpace(line)) == 0 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "track") || strings.HasPrefix(line, "browser") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) < 5 {
+			return []amplicon{}, errors.New("line " + strconv.Itoa(lineNumber) + " of the primer scheme has fewer than 5 columns")
+		}
+
+		start, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return []amplicon{}, errors.New("couldn't parse the start of the primer on line " + strconv.Itoa(lineNumber) + " of the primer scheme")
+		}
+		end, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return []amplicon{}, errors.New("couldn't parse the end of the primer on line " + strconv.Itoa(lineNumber) + " of the primer scheme")
+		}
+		if start < 0 || end <= start {
+			return []amplicon{}, errors.New("bad coordinates for the primer on line " + strconv.Itoa(lineNumber) + " of the primer scheme")
+		}
+
+		name, direction, err := parsePrimerName(fields[3])
+		if err != nil {
+			return []amplicon{}, err
+		}
+
+		i, ok := index[name]
+		if !ok {
+			i = len(amplicons)
+			index[name] = i
+			amplicons = append(amplicons, amplicon{name: name, pool: fields[4]})
+		}
+
+		p := primer{name: fields[3], start: start + 1, end: end}
+		if direction == "LEFT" {
+			amplicons[i].left = append(amplicons[i].left, p)
+		} else {
+			amplicons[i].right = append(amplicons[i].right, p)
+		}
+	}
+	if err := s.Err(); err != nil {
+		return []amplicon{}, err
+	}
+
+	if len(amplicons) == 0 {
+		return []amplicon{}, errors.New("no primers in the primer scheme")
+	}
+
+	// the insert is between the innermost ends of the (alternative) primers
+	for i, a := range amplicons {
+		if len(a.left) == 0 || len(a.right) == 0 {
+			return []amplicon{}, errors.New("amplicon " + a.name + " needs at least one LEFT and one RIGHT primer")
+		}
+		for _, p := range a.left {
+			if p.end+1 > amplicons[i].start {
+				amplicons[i].start = p.end + 1
+			}
+		}
+		amplicons[i].end = a.right[0].start - 1
+		for _, p := range a.right {
+			if p.start-1 < amplicons[i].end {
+				amplicons[i].end = p.start - 1
+			}
+		}
+		if amplicons[i].start > amplicons[i].end {
+			return []amplicon{}, errors.New("amplicon " + a.name + " has no insert between its primers")
+		}
+	}
+
+	return amplicons, nil
+}
@@ -0,0 +1,358 @@
+/*
+Package clock implements functionality to assess the temporal signal in a
+multiple sequence alignment in fasta format, by regressing each sequence's
+genetic distance from a reference (the root) against its sampling date.
+*/
+package clock
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/virus-evolution/gofasta/pkg/closest"
+	"github.com/virus-evolution/gofasta/pkg/fasta"
+	"github.com/virus-evolution/gofasta/pkg/variants"
+)
+
+// rttResult is one sequence's root-to-tip distance and, if it has a usable date, its place in the regression
+type rttResult struct {
+	id        string
+	idx       int
+	date      string  // the date as it was provided
+	decimal   float64 // the date as a decimal year
+	dated     bool    // whether the date could be parsed
+	distance  float64
+	finite    bool // whether the distance could be calculated
+	predicted float64
+	residual  float64
+	outlier   bool
+}
+
+// regression is the result of a least-squares fit of distance against date
+type regression struct {
+	n         int
+	rate      float64 // the slope, in distance units per year
+	intercept float64 // the distance at year 0
+	tmrca     float64 // the x-intercept, as a decimal year
+	r2        float64
+	lower     float64 // residuals below this are outliers
+	upper     float64 // residuals above this are outliers
+	outliers  int
+}
+
+// toDecimalYear converts a time to a decimal year, taking the middle of the day
+func toDecimalYear(t time.Time) float64 {
+	days := 365.0
+	if time.Date(t.Year(), time.December, 31, 0, 0, 0, 0, time.UTC).YearDay() == 366 {
+		days = 366.0
+	}
+	return float64(t.Year()) + (float64(t.YearDay())-0.5)/days
+}
+
+// fromDecimalYear converts a decimal year to a date in YYYY-MM-DD format
+func fromDecimalYear(d float64) string {
+	year := int(math.Floor(d))
+	days := float64(time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay())
+	day := int((d - float64(year)) * days)
+	return time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC).AddDate(0, 0, day).Format("2006-01-02")
+}
+
+// parseDate converts a complete date (YYYY-MM-DD) or a decimal year (which must contain a decimal point) to a decimal
+// year. Incomplete dates like YYYY-MM can't be placed precisely enough, so it returns false for them
+func parseDate(s string) (float64, bool) {
+	s = strings.TrimSpace(s)
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return toDecimalYear(t), true
+	}
+	if strings.Contains(s, ".") {
+		if f, err := strconv.ParseFloat(s, 64); err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f, true
+		}
+	}
+	return 0, false
+}
+
+// dateFromID extracts a date from a sequence ID using a regular expression. The first capture group is used if there is
+// one, otherwise the whole match
+func dateFromID(re *regexp.Regexp, id string) string {
+	m := re.FindStringSubmatch(id)
+	switch {
+	case m == nil:
+		return ""
+	case len(m) > 1:
+		return m[1]
+	default:
+		return m[0]
+	}
+}
+
+// quantile returns the q'th quantile of sorted, interpolating linearly between data points
+func quantile(sorted []float64, q float64) float64 {
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return sorted[lo] + (pos-float64(lo))*(sorted[hi]-sorted[lo])
+}
+
+// fitRootToTip regresses distance on date for the results that have both, by least squares, and fills in their
+// predicted distances and residuals. Residuals more than iqr interquartile ranges below the lower quartile or above the
+// upper quartile of all the residuals are flagged as outliers
+func fitRootToTip(results []rttResult, iqr float64) (regression, error) {
+
+	var reg regression
+	var sumX, sumY float64
+	for _, r := range results {
+		if r.dated && r.finite {
+			reg.n++
+			sumX += r.decimal
+			sumY += r.distance
+		}
+	}
+	if reg.n < 3 {
+		return reg, errors.New("need at least three sequences with dates and distances to fit a regression")
+	}
+	meanX := sumX / float64(reg.n)
+	meanY := sumY / float64(reg.n)
+
+	var sxx, sxy, syy float64
+	for _, r := range results {
+		if r.dated && r.finite {
+			sxx += (r.decimal - meanX) * (r.decimal - meanX)
+			sxy += (r.decimal - meanX) * (r.distance - meanY)
+			syy += (r.distance - meanY) * (r.distance - meanY)
+		}
+	}
+	if sxx == 0 {
+		return reg, errors.New("all the sequences have the same date, so there is no regression to fit")
+	}
+
+	reg.rate = sxy / sxx
+	reg.intercept = meanY - reg.rate*meanX
+	reg.tmrca = math.NaN()
+	if reg.rate != 0 {
+		reg.tmrca = -reg.intercept / reg.rate
+	}
+	reg.r2 = math.NaN()
+	if syy != 0 {
+		reg.r2 = sxy * sxy / (sxx * syy)
+	}
+
+	residuals := make([]float64, 0, reg.n)
+	for i := range results {
+		r := &results[i]
+		if r.dated && r.finite {
+			r.predicted = reg.intercept + reg.rate*r.decimal
+			r.residual = r.distance - r.predicted
+			residuals = append(residuals, r.residual)
+		}
+	}
+
+	sort.Float64s(residuals)
+	q1 := quantile(residuals, 0.25)
+	q3 := quantile(residuals, 0.75)
+	reg.lower = q1 - iqr*(q3-q1)
+	reg.upper = q3 + iqr*(q3-q1)
+
+	for i := range results {
+		r := &results[i]
+		if r.dated && r.finite && (r.residual < reg.lower || r.residual > reg.upper) {
+			r.outlier = true
+			reg.outliers++
+		}
+	}
+
+	return reg, nil
+}
+
+// formatFloat formats a number for output, writing NA if it isn't finite
+func formatFloat(f float64, prec int) string {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "NA"
+	}
+	return strconv.FormatFloat(f, 'f', prec, 64)
+}
+
+// writeRootToTip writes one line per sequence, in input order, with its date, distance from the root, and (if it
+// was in the regression) its predicted distance, residual and whether it is an outlier
+func writeRootToTip(w io.Writer, results []rttResult, measure string) error {
+
+	prec := 9
+	if measure == "snp" {
+		prec = 4
+	}
+
+	_, err := w.Write([]byte("sequence,date,decimal_date,distance,predicted,residual,outlier\n"))
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		line := []string{r.id, r.date, "", "", "", "", ""}
+		if r.dated {
+			line[2] = formatFloat(r.decimal, 4)
+		}
+		if r.finite {
+			if measure == "snp" {
+				line[3] = strconv.Itoa(int(r.distance))
+			} else {
+				line[3] = formatFloat(r.distance, prec)
+			}
+		}
+		if r.dated && r.finite {
+			line[4] = formatFloat(r.predicted, prec)
+			line[5] = formatFloat(r.residual, prec)
+			line[6] = strconv.FormatBool(r.outlier)
+		}
+		_, err = w.Write([]byte(strings.Join(line, ",") + "\n"))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeSummary writes the statistics of the regression, one per line
+func writeSummary(w io.Writer, reg regression, total int) error {
+	tmrcaDate := "NA"
+	if !math.IsNaN(reg.tmrca) && !math.IsInf(reg.tmrca, 0) {
+		tmrcaDate = fromDecimalYear(reg.tmrca)
+	}
+	lines := [][2]string{
+		{"sequences", strconv.Itoa(total)},
+		{"regressed", strconv.Itoa(reg.n)},
+		{"rate", formatFloat(reg.rate, -1)},
+		{"intercept", formatFloat(reg.intercept, 6)},
+		{"tmrca", formatFloat(reg.tmrca, 4)},
+		{"tmrca_date", tmrcaDate},
+		{"r_squared", formatFloat(reg.r2, 6)},
+		{"residual_lower", formatFloat(reg.lower, 6)},
+		{"residual_upper", formatFloat(reg.upper, 6)},
+		{"outliers", strconv.Itoa(reg.outliers)},
+	}
+	_, err := w.Write([]byte("statistic,value\n"))
+	if err != nil {
+		return err
+	}
+	for _, l := range lines {
+		_, err = w.Write([]byte(l[0] + "," + l[1] + "\n"))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// getDistances calculates the distance from the reference to each record it receives
+func getDistances(ref fasta.EncodedRecord, refID string, distance func(query, target fasta.EncodedRecord) float64, dates map[string]string, re *regexp.Regexp, cMSA chan fasta.EncodedRecord, cResults chan rttResult, cErr chan error) {
+	for record := range cMSA {
+		if record.ID == refID {
+			continue
+		}
+		if len(record.Seq) != len(ref.Seq) {
+			cErr <- errors.New("sequence " + record.ID + " is not the same length as the reference")
+			return
+		}
+		r := rttResult{id: record.ID, idx: record.Idx}
+		if re != nil {
+			r.date = dateFromID(re, record.ID)
+		} else {
+			r.date = dates[record.ID]
+		}
+		r.decimal, r.dated = parseDate(r.date)
+		r.distance = distance(ref, record)
+		r.finite = !math.IsNaN(r.distance) && !math.IsInf(r.distance, 0)
+		cResults <- r
+	}
+}
+
+// RootToTip regresses the genetic distance (by measure: snp or tn93) of each sequence in a fasta-format alignment
+// from the reference (the root) against its date, which either comes from dates (keyed by sequence ID), or is parsed
+// from each ID using dateRegex. Dates must be complete (YYYY-MM-DD) or decimal years, and sequences without one are not
+// included in the regression. It writes the distance, predicted distance and residual of every sequence to out, flagging
+// sequences whose residual is more than iqr interquartile ranges outside the quartiles of all the residuals as outliers,
+// and the rate (the slope), the tMRCA (the x-intercept) and R² to summary
+func RootToTip(msaIn io.Reader, stdin bool, refID string, measure string, dates map[string]string, dateRegex string, iqr float64, out, summary io.Writer, threads int) error {
+
+	if measure != "snp" && measure != "tn93" {
+		return errors.New("unknown distance measure: " + measure + " (choose one of snp or tn93)")
+	}
+	distance, err := closest.Measure(measure)
+	if err != nil {
+		return err
+	}
+
+	if iqr < 0 {
+		return errors.New("--outlier-iqr must be >= 0")
+	}
+
+	var re *regexp.Regexp
+	if dateRegex != "" {
+		re, err = regexp.Compile(dateRegex)
+		if err != nil {
+			return err
+		}
+	}
+
+	cMSA := make(chan fasta.EncodedRecord, 50+threads)
+	cErr := make(chan error)
+	cMSADone := make(chan bool)
+
+	// If we're reading from stdin, the reference has to be the first record
+	ref, _, err := variants.StreamWithReference(msaIn, stdin, refID, true, cMSA, cErr, cMSADone)
+	if err != nil {
+		return err
+	}
+
+	ref.CalculateBaseContent()
+
+	cResults := make(chan rttResult, 50+threads)
+	cCollectDone := make(chan bool)
+
+	results := make([]rttResult, 0)
+	go func() {
+		for r := range cResults {
+			results = append(results, r)
+		}
+		cCollectDone <- true
+	}()
+
+	cDistDone := fasta.StartWorkers(threads, func() {
+		getDistances(ref, refID, distance, dates, re, cMSA, cResults, cErr)
+	})
+
+	err = fasta.WaitStages(cErr,
+		fasta.Stage{Done: cMSADone, Close: func() { close(cMSA) }},
+		fasta.Stage{Done: cDistDone, Close: func() { close(cResults) }},
+		fasta.Stage{Done: cCollectDone},
+	)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(results, func(i, j int) bool { return results[i].idx < results[j].idx })
+
+	reg, err := fitRootToTip(results, iqr)
+	if err != nil {
+		return err
+	}
+
+	if reg.rate <= 0 {
+		fmt.Fprintf(os.Stderr, "warning: the rate is not positive, so there is no temporal signal\n")
+	}
+
+	err = writeRootToTip(out, results, measure)
+	if err != nil {
+		return err
+	}
+
+	return writeSummary(summary, reg, len(results))
+}
@@ -0,0 +1,113 @@
+package clock
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+var clockData = []byte(`>ref
+ACGTACGTACGTACGTACGT
+>a|2020.5
+TCGTACGTACGTACGTACGT
+>b|2021.5
+TTGTACGTACGTACGTACGT
+>c|2022.5
+TTTTACGTACGTACGTACGT
+>d|2023.5
+TTTAACGTACGTACGTACGT
+>e|2024.5
+TTTATCGTACGTACGTACGT
+>f|2022.0
+TTTATTTTTTTTACGTACGT
+>g|2022-07
+TTTATTTTTTTTACGTACGT
+`)
+
+func TestRootToTip(t *testing.T) {
+	out := new(bytes.Buffer)
+	summary := new(bytes.Buffer)
+
+	err := RootToTip(bytes.NewReader(clockData), false, "ref", "snp", nil, `\|(.+)$`, 1.5, out, summary, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `sequence,date,decimal_date,distance,predicted,residual,outlier
+a|2020.5,2020.5,2020.5000,1,2.8367,-1.8367,false
+b|2021.5,2021.5,2021.5000,2,3.5306,-1.5306,false
+c|2022.5,2022.5,2022.5000,3,4.2245,-1.2245,false
+d|2023.5,2023.5,2023.5000,4,4.9184,-0.9184,false
+e|2024.5,2024.5,2024.5000,5,5.6122,-0.6122,false
+f|2022.0,2022.0,2022.0000,10,3.8776,6.1224,true
+g|2022-07,2022-07,,10,,,
+` {
+		t.Errorf("problem in TestRootToTip()")
+		fmt.Println(out.String())
+	}
+
+	if summary.String() != `statistic,value
+sequences,7
+regressed,6
+rate,0.6938775510204082
+intercept,-1399.142857
+tmrca,2016.4118
+tmrca_date,2016-05-30
+r_squared,0.096688
+residual_lower,-2.602041
+residual_upper,0.459184
+outliers,1
+` {
+		t.Errorf("problem in TestRootToTip() (summary)")
+		fmt.Println(summary.String())
+	}
+}
+
+func TestRootToTipMetadata(t *testing.T) {
+	data := []byte(`>ref
+ACGTACGTACGTACGTACGT
+>a
+TCGTACGTACGTACGTACGT
+>b
+TTGTACGTACGTACGTACGT
+>c
+TTTTACGTACGTACGTACGT
+>d
+ACGTACGTACGTACGTACGT
+`)
+	dates := map[string]string{"a": "2020.0", "b": "2020.5", "c": "2021.0"}
+
+	out := new(bytes.Buffer)
+	summary := new(bytes.Buffer)
+
+	err := RootToTip(bytes.NewReader(data), true, "ref", "snp", dates, "", 3, out, summary, 1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `sequence,date,decimal_date,distance,predicted,residual,outlier
+a,2020.0,2020.0000,1,1.0000,0.0000,false
+b,2020.5,2020.5000,2,2.0000,0.0000,false
+c,2021.0,2021.0000,3,3.0000,0.0000,false
+d,,,0,,,
+` {
+		t.Errorf("problem in TestRootToTipMetadata()")
+		fmt.Println(out.String())
+	}
+
+	if summary.String() != `statistic,value
+sequences,4
+regressed,3
+rate,2
+intercept,-4039.000000
+tmrca,2019.5000
+tmrca_date,2019-07-02
+r_squared,1.000000
+residual_lower,0.000000
+residual_upper,0.000000
+outliers,0
+` {
+		t.Errorf("problem in TestRootToTipMetadata() (summary)")
+		fmt.Println(summary.String())
+	}
+}
@@ -28,6 +28,7 @@ type resultsStruct struct {
 	completeness int64
 	distance     float64
 	snps         []string
+	tseq         []byte // the target's sequence, only kept if its private snps are going to be written
 }
 
 func rawDistance(query, target fasta.EncodedRecord) float64 {
@@ -22,6 +22,7 @@ import (
 // 	completeness int64
 // 	distance float64
 // 	snps []string
+// 	tseq []byte
 // }
 
 // catchmentStruct contains information about the closest sequences to a particular query
@@ -45,8 +46,9 @@ func rearrangeCatchment(nS *catchmentStruct, catchmentSize int) {
 	nS.furthestCompleteness = nS.catchment[catchmentSize-1].completeness
 }
 
-// findClosestN finds the closest sequences by genetic distance to single a query sequence
-func findClosestN(query fasta.EncodedRecord, catchmentSize int, maxdist float64, measure string, cIn chan fasta.EncodedRecord, cOut chan catchmentStruct) {
+// findClosestN finds the closest sequences by genetic distance to single a query sequence. If keepSeqs is true, the
+// sequences of the closest targets are kept in the output
+func findClosestN(query fasta.EncodedRecord, catchmentSize int, maxdist float64, measure string, keepSeqs bool, cIn chan fasta.EncodedRecord, cOut chan catchmentStruct) {
 
 	neighbours := catchmentStruct{qname: query.ID, qidx: query.Idx}
 	neighbours.catchment = make([]resultsStruct, 0)
@@ -74,6 +76,9 @@ func findClosestN(query fasta.EncodedRecord, catchmentSize int, maxdist float64,
 
 		if len(neighbours.catchment) < catchmentSize {
 			rs = resultsStruct{tname: target.ID, completeness: target.Score, distance: distance}
+			if keepSeqs {
+				rs.tseq = target.Seq
+			}
 			neighbours.catchment = append(neighbours.catchment, rs)
 
 			if len(neighbours.catchment) == catchmentSize {
@@ -82,11 +87,17 @@ func findClosestN(query fasta.EncodedRecord, catchmentSize int, maxdist float64,
 
 		} else if distance < neighbours.furthestDistance {
 			rs = resultsStruct{tname: target.ID, completeness: target.Score, distance: distance}
+			if keepSeqs {
+				rs.tseq = target.Seq
+			}
 			neighbours.catchment = append(neighbours.catchment, rs)
 			rearrangeCatchment(&neighbours, catchmentSize)
 
 		} else if distance == neighbours.furthestDistance && target.Score > neighbours.furthestCompleteness {
 			rs = resultsStruct{tname: target.ID, completeness: target.Score, distance: distance}
+			if keepSeqs {
+				rs.tseq = target.Seq
+			}
 			neighbours.catchment = append(neighbours.catchment, rs)
 			rearrangeCatchment(&neighbours, catchmentSize)
 		}
@@ -103,7 +114,7 @@ func findClosestN(query fasta.EncodedRecord, catchmentSize int, maxdist float64,
 }
 
 // splitInputN fans out target sequences over an array of query sequences, so that each target is passed over each query.
-func splitInputN(queries []fasta.EncodedRecord, catchmentSize int, maxdist float64, measure string, cIn chan fasta.EncodedRecord, cOut chan catchmentStruct, cErr chan error, cSplitDone chan bool) {
+func splitInputN(queries []fasta.EncodedRecord, catchmentSize int, maxdist float64, measure string, keepSeqs bool, cIn chan fasta.EncodedRecord, cOut chan catchmentStruct, cErr chan error, cSplitDone chan bool) {
 
 	nQ := len(queries)
 
@@ -114,7 +125,7 @@ func splitInputN(queries []fasta.EncodedRecord, catchmentSize int, maxdist float
 	}
 
 	for i, q := range queries {
-		go findClosestN(q, catchmentSize, maxdist, measure, QChanArray[i], cOut)
+		go findClosestN(q, catchmentSize, maxdist, measure, keepSeqs, QChanArray[i], cOut)
 	}
 
 	targetCounter := 0
@@ -189,8 +200,10 @@ func writeClosestNTable(results []catchmentStruct, w io.Writer, measure string)
 }
 
 // ClosestN finds the closest sequence(s) by genetic distance to a query/queries. It writes the results
-// to stdout or to file. Ties for distance are broken by genome completeness.
-func ClosestN(catchmentSize int, maxdist float64, query, target io.Reader, measure string, out io.Writer, table bool, threads int) error {
+// to stdout or to file. Ties for distance are broken by genome completeness. If private is true, it writes
+// a long-form table including the snps relative to reference that are private to the query and to each
+// target, and the sites where snps are masked by ambiguities.
+func ClosestN(catchmentSize int, maxdist float64, query, target io.Reader, measure string, out io.Writer, table bool, private bool, reference io.Reader, threads int) error {
 
 	if threads == 0 {
 		threads = runtime.NumCPU()
@@ -209,6 +222,21 @@ func ClosestN(catchmentSize int, maxdist float64, query, target io.Reader, measu
 
 	nQ := len(queries)
 
+	var refSeq []byte
+	if private {
+		refs, err := fasta.LoadEncodeAlignment(reference, false, false, false)
+		if err != nil {
+			return err
+		}
+		if len(refs) != 1 {
+			return errors.New("there must be exactly one record in --reference")
+		}
+		refSeq = refs[0].Seq
+		if nQ > 0 && len(refSeq) != len(queries[0].Seq) {
+			return errors.New("reference and query alignment are not the same width")
+		}
+	}
+
 	fmt.Fprintf(os.Stderr, "number of sequences in query alignment: %d\n", nQ)
 
 	QResultsArray := make([]catchmentStruct, nQ)
@@ -222,7 +250,7 @@ func ClosestN(catchmentSize int, maxdist float64, query, target io.Reader, measu
 
 	go fasta.StreamEncodeAlignment(target, cTEFR, cErr, cTEFRdone, false, true, true)
 
-	go splitInputN(queries, catchmentSize, maxdist, measure, cTEFR, cResults, cErr, cSplitDone)
+	go splitInputN(queries, catchmentSize, maxdist, measure, private, cTEFR, cResults, cErr, cSplitDone)
 
 	for n := 1; n > 0; {
 		select {
@@ -248,10 +276,16 @@ func ClosestN(catchmentSize int, maxdist float64, query, target io.Reader, measu
 		QResultsArray[result.qidx] = result
 	}
 
-	switch table {
-	case true:
+	switch {
+	case private:
+		querySeqs := make([][]byte, nQ)
+		for _, q := range queries {
+			querySeqs[q.Idx] = q.Seq
+		}
+		err = writeClosestNPrivate(QResultsArray, querySeqs, refSeq, out, measure)
+	case table:
 		err = writeClosestNTable(QResultsArray, out, measure)
-	case false:
+	default:
 		err = writeClosestN(QResultsArray, out)
 	}
 	if err != nil {
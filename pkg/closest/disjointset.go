@@ -0,0 +1,37 @@
+package closest
+
+// A DisjointSet is a union-find forest over the indices 0 to n-1
+type DisjointSet []int
+
+// NewDisjointSet returns a DisjointSet of n singletons
+func NewDisjointSet(n int) DisjointSet {
+	s := make(DisjointSet, n)
+	for i := range s {
+		s[i] = i
+	}
+	return s
+}
+
+// Find returns the root of i's set
+func (s DisjointSet) Find(i int) int {
+	for s[i] != i {
+		s[i] = s[s[i]]
+		i = s[i]
+	}
+	return i
+}
+
+// Union joins the sets containing i and j, and reports whether they were separate. The smaller index is kept as the
+// root, so that each set's root is its first member in input order
+func (s DisjointSet) Union(i, j int) bool {
+	ri, rj := s.Find(i), s.Find(j)
+	if ri == rj {
+		return false
+	}
+	if ri < rj {
+		s[rj] = ri
+	} else {
+		s[ri] = rj
+	}
+	return true
+}
@@ -0,0 +1,72 @@
+package closest
+
+import (
+	"errors"
+	"math"
+	"runtime"
+
+	"github.com/virus-evolution/gofasta/pkg/fasta"
+)
+
+// Measure returns the function that calculates the genetic distance between two records by measure (raw, snp
+// or tn93). For tn93, the records must have been loaded with their base counts (atgc = true)
+func Measure(measure string) (func(query, target fasta.EncodedRecord) float64, error) {
+	switch measure {
+	case "raw":
+		return rawDistance, nil
+	case "snp":
+		return snpDistance, nil
+	case "tn93":
+		return tn93Distance, nil
+	}
+	return nil, errors.New("unknown distance measure: " + measure)
+}
+
+// DistanceMatrix returns the genetic distance between every pair of records by measure (raw, snp or tn93), calculating
+// the rows in parallel. For tn93, the records must have been loaded with their base counts (atgc = true). It returns
+// an error if any distance isn't a finite number, which happens if a pair has no comparable sites or is saturated
+func DistanceMatrix(records []fasta.EncodedRecord, measure string, threads int) ([][]float64, error) {
+
+	distance, err := Measure(measure)
+	if err != nil {
+		return nil, err
+	}
+
+	if threads == 0 {
+		threads = runtime.NumCPU()
+	}
+
+	n := len(records)
+	d := make([][]float64, n)
+	for i := range d {
+		d[i] = make([]float64, n)
+	}
+
+	cIdx := make(chan int, threads)
+
+	cDone := fasta.StartWorkers(threads, func() {
+		for i := range cIdx {
+			for j := i + 1; j < n; j++ {
+				d[i][j] = distance(records[i], records[j])
+			}
+		}
+	})
+
+	for i := 0; i < n; i++ {
+		cIdx <- i
+	}
+	close(cIdx)
+
+	<-cDone
+
+	for i := 0; i < n; i++ {
+		for j := i + 1; j < n; j++ {
+			if math.IsNaN(d[i][j]) || math.IsInf(d[i][j], 0) {
+				return nil, errors.New("couldn't calculate the " + measure + " distance between " + records[i].ID + " and " + records[j].ID)
+			}
+			d[j][i] = d[i][j]
+		}
+	}
+
+	return d, nil
+}
@@ -0,0 +1,350 @@
+package closest
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/virus-evolution/gofasta/pkg/fasta"
+)
+
+/*
+Haplotype networks
+
+Sequences that are 0 SNPs apart are collapsed into haplotypes, so sequences that only differ at ambiguities or gaps share
+a node, and the SNP-distance (snpDistance) between every pair of haplotypes is taken from DistanceMatrix. A minimum spanning tree (mst) is built by Kruskal's algorithm, with ties broken by input order. A minimum
+spanning network (msn) is the union of all the possible minimum spanning trees, which is found by adding every link of
+each length that joins two components of the network made from the shorter links. As in the first step of median-joining
+(Bandelt HJ, Forster P, Röhl A. Median-joining networks for inferring intraspecific phylogenies. Mol Biol Evol. 1999
+Jan;16(1):37-48. doi: 10.1093/oxfordjournals.molbev.a026036), epsilon relaxes this, so that a link is added if its two
+haplotypes are not joined by links that are more than epsilon shorter than it. Median vectors (inferred, unsampled
+haplotypes) are not added.
+*/
+
+// haplotype is a set of sequences in the alignment that are 0 SNPs apart
+type haplotype struct {
+	seq     fasta.EncodedRecord
+	members []string
+	size    int
+}
+
+// networkEdge is a link between two haplotypes (indices into an array of haplotypes), weighted by the SNP-distance
+// between them
+type networkEdge struct {
+	i, j     int
+	distance int
+}
+
+// networkNode and networkLink are for writing the network as json
+type networkNode struct {
+	ID      string   `json:"id"`
+	Size    int      `json:"size"`
+	Members []string `json:"members"`
+}
+
+type networkLink struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Weight int    `json:"weight"`
+}
+
+type networkJSON struct {
+	Nodes []networkNode `json:"nodes"`
+	Edges []networkLink `json:"edges"`
+}
+
+// collapseHaplotypes groups sequences that are 0 SNPs apart (i.e. that only differ at ambiguities or gaps), in order of
+// first appearance, and returns the SNP-distances between the haplotypes too. Because sequences with ambiguities can be 0
+// SNPs from sequences that aren't 0 SNPs from each other, each sequence joins the first haplotype whose first member it
+// matches. Each haplotype is named after its first member, and its size is the sum of its members' counts (1 for any
+// sequence that isn't in counts)
+func collapseHaplotypes(records []fasta.EncodedRecord, counts map[string]string, threads int) ([]haplotype, [][]float64, error) {
+
+	sizes := make([]int, len(records))
+	for i, record := range records {
+		sizes[i] = 1
+		if s, ok := counts[record.ID]; ok {
+			c, err := strconv.Atoi(s)
+			if err != nil || c < 1 {
+				return nil, nil, errors.New("bad count for " + record.ID + ": " + s)
+			}
+			sizes[i] = c
+		}
+	}
+
+	// identical sequences are grouped first, so that only the distinct ones need comparing
+	groups := make([][]int, 0)
+	index := make(map[string]int)
+	for i, record := range records {
+		key := string(record.Seq)
+		if g, ok := index[key]; ok {
+			groups[g] = append(groups[g], i)
+			continue
+		}
+		index[key] = len(groups)
+		groups = append(groups, []int{i})
+	}
+
+	distinct := make([]fasta.EncodedRecord, len(groups))
+	for g, members := range groups {
+		distinct[g] = records[members[0]]
+	}
+	d, err := DistanceMatrix(distinct, "snp", threads)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// kept is the group that is the first member of each haplotype
+	kept := make([]int, 0)
+	members := make([][]int, 0)
+	for g := range groups {
+		joined := false
+		for h, k := range kept {
+			if d[k][g] == 0 {
+				members[h] = append(members[h], groups[g]...)
+				joined = true
+				break
+			}
+		}
+		if !joined {
+			kept = append(kept, g)
+			members = append(members, append([]int{}, groups[g]...))
+		}
+	}
+
+	haplotypes := make([]haplotype, len(kept))
+	distances := make([][]float64, len(kept))
+	for h, k := range kept {
+		sort.Ints(members[h])
+		haplotypes[h] = haplotype{seq: distinct[k], members: make([]string, len(members[h]))}
+		for m, i := range members[h] {
+			haplotypes[h].members[m] = records[i].ID
+			haplotypes[h].size += sizes[i]
+		}
+		distances[h] = make([]float64, len(kept))
+		for h2, k2 := range kept {
+			distances[h][h2] = d[k][k2]
+		}
+	}
+
+	return haplotypes, distances, nil
+}
+
+// pairwiseDistances returns a link between every pair of haplotypes, given the matrix of SNP-distances between them,
+// sorted by distance then by input order
+func pairwiseDistances(distances [][]float64) []networkEdge {
+	pairs := make([]networkEdge, 0)
+	for i := range distances {
+		for j := i + 1; j < len(distances); j++ {
+			pairs = append(pairs, networkEdge{i: i, j: j, distance: int(distances[i][j])})
+		}
+	}
+
+	sort.SliceStable(pairs, func(a, b int) bool {
+		return pairs[a].distance < pairs[b].distance
+	})
+
+	return pairs
+}
+
+// minimumSpanningTree returns the links of a minimum spanning tree, given all the pairwise links sorted by distance
+func minimumSpanningTree(n int, pairs []networkEdge) []networkEdge {
+	components := NewDisjointSet(n)
+	edges := make([]networkEdge, 0, n)
+	for _, p := range pairs {
+		if !components.Union(p.i, p.j) {
+			continue
+		}
+		edges = append(edges, p)
+		if len(edges) == n-1 {
+			break
+		}
+	}
+	return edges
+}
+
+// minimumSpanningNetwork returns the links of a minimum spanning network with relaxation epsilon, given all the pairwise
+// links sorted by distance
+func minimumSpanningNetwork(n int, pairs []networkEdge, epsilon int) []networkEdge {
+	edges := make([]networkEdge, 0)
+	for start := 0; start < len(pairs); {
+		d := pairs[start].distance
+		end := start
+		for end < len(pairs) && pairs[end].distance == d {
+			end++
+		}
+
+		// the components of the network made from the links so far that are more than epsilon shorter than this class
+		shorter := NewDisjointSet(n)
+		components := n
+		for _, e := range edges {
+			if e.distance >= d-epsilon {
+				continue
+			}
+			if shorter.Union(e.i, e.j) {
+				components--
+			}
+		}
+
+		for _, p := range pairs[start:end] {
+			if shorter.Find(p.i) != shorter.Find(p.j) {
+				edges = append(edges, p)
+			}
+		}
+
+		// once the shorter links join everything, no longer links can be added
+		if components == 1 {
+			break
+		}
+
+		start = end
+	}
+	return edges
+}
+
+// writeNetworkJSON writes the network as json, with a list of nodes and a list of edges
+func writeNetworkJSON(w io.Writer, haplotypes []haplotype, edges []networkEdge) error {
+	network := networkJSON{Nodes: make([]networkNode, len(haplotypes)), Edges: make([]networkLink, len(edges))}
+	for i, h := range haplotypes {
+		network.Nodes[i] = networkNode{ID: h.members[0], Size: h.size, Members: h.members}
+	}
+	for i, e := range edges {
+		network.Edges[i] = networkLink{Source: haplotypes[e.i].members[0], Target: haplotypes[e.j].members[0], Weight: e.distance}
+	}
+	b, err := json.MarshalIndent(network, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(b, '\n'))
+	return err
+}
+
+// xmlEscape escapes a string for use in GraphML
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// writeNetworkGraphML writes the network as an undirected graph in GraphML format
+func writeNetworkGraphML(w io.Writer, haplotypes []haplotype, edges []networkEdge) error {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+	b.WriteString(`<graphml xmlns="http://graphml.graphdrawing.org/xmlns">` + "\n")
+	b.WriteString(`  <key id="size" for="node" attr.name="size" attr.type="int"/>` + "\n")
+	b.WriteString(`  <key id="members" for="node" attr.name="members" attr.type="string"/>` + "\n")
+	b.WriteString(`  <key id="weight" for="edge" attr.name="weight" attr.type="int"/>` + "\n")
+	b.WriteString(`  <graph id="network" edgedefault="undirected">` + "\n")
+	for _, h := range haplotypes {
+		b.WriteString(`    <node id="` + xmlEscape(h.members[0]) + `">` + "\n")
+		b.WriteString(`      <data key="size">` + strconv.Itoa(h.size) + `</data>` + "\n")
+		b.WriteString(`      <data key="members">` + xmlEscape(strings.Join(h.members, ";")) + `</data>` + "\n")
+		b.WriteString(`    </node>` + "\n")
+	}
+	for _, e := range edges {
+		b.WriteString(`    <edge source="` + xmlEscape(haplotypes[e.i].members[0]) + `" target="` + xmlEscape(haplotypes[e.j].members[0]) + `">` + "\n")
+		b.WriteString(`      <data key="weight">` + strconv.Itoa(e.distance) + `</data>` + "\n")
+		b.WriteString(`    </edge>` + "\n")
+	}
+	b.WriteString(`  </graph>` + "\n")
+	b.WriteString(`</graphml>` + "\n")
+	_, err := w.Write([]byte(b.String()))
+	return err
+}
+
+// dotQuote quotes a string for use as an ID in DOT
+func dotQuote(s string) string {
+	return `"` + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), `"`, `\"`) + `"`
+}
+
+// writeNetworkDOT writes the network as an undirected graph in graphviz's DOT format
+func writeNetworkDOT(w io.Writer, haplotypes []haplotype, edges []networkEdge) error {
+	var b strings.Builder
+	b.WriteString("graph network {\n")
+	for _, h := range haplotypes {
+		b.WriteString("\t" + dotQuote(h.members[0]) + " [size=" + strconv.Itoa(h.size) + ", members=" + dotQuote(strings.Join(h.members, ";")) + "];\n")
+	}
+	for _, e := range edges {
+		d := strconv.Itoa(e.distance)
+		b.WriteString("\t" + dotQuote(haplotypes[e.i].members[0]) + " -- " + dotQuote(haplotypes[e.j].members[0]) + " [weight=" + d + ", label=" + dotQuote(d) + "];\n")
+	}
+	b.WriteString("}\n")
+	_, err := w.Write([]byte(b.String()))
+	return err
+}
+
+// Network collapses the sequences in a fasta-format alignment into haplotypes and builds a minimum spanning tree (method
+// "mst") or a minimum spanning network with relaxation epsilon (method "msn") between them, using SNP-distances. It writes
+// the network in format "json", "graphml" or "dot", where each node is a haplotype, named after its first sequence, whose
+// size is the number of sequences in it (or the sum of their counts, if they are in counts), and each edge is weighted by the
+// SNP-distance between the haplotypes that it links
+func Network(msaIn io.Reader, counts map[string]string, method string, epsilon int, format string, out io.Writer, threads int) error {
+
+	if threads == 0 {
+		threads = runtime.NumCPU()
+	}
+
+	switch format {
+	case "json", "graphml", "dot":
+	default:
+		return errors.New("unknown network --format: " + format + " (choose one of json, graphml or dot)")
+	}
+
+	switch method {
+	case "mst", "msn":
+	default:
+		return errors.New("unknown network --method: " + method + " (choose one of mst or msn)")
+	}
+
+	if epsilon < 0 {
+		return errors.New("--epsilon must be >= 0")
+	}
+
+	records, err := fasta.LoadEncodeAlignment(msaIn, false, false, false)
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return errors.New("no sequences in --msa")
+	}
+	for _, record := range records {
+		if len(record.Seq) != len(records[0].Seq) {
+			return errors.New("sequences in --msa are not all the same length: is it an alignment?")
+		}
+	}
+
+	haplotypes, distances, err := collapseHaplotypes(records, counts, threads)
+	if err != nil {
+		return err
+	}
+
+	pairs := pairwiseDistances(distances)
+
+	var edges []networkEdge
+	switch method {
+	case "mst":
+		edges = minimumSpanningTree(len(haplotypes), pairs)
+	case "msn":
+		edges = minimumSpanningNetwork(len(haplotypes), pairs, epsilon)
+	}
+
+	sort.SliceStable(edges, func(a, b int) bool {
+		return edges[a].i < edges[b].i || (edges[a].i == edges[b].i && edges[a].j < edges[b].j)
+	})
+
+	switch format {
+	case "json":
+		err = writeNetworkJSON(out, haplotypes, edges)
+	case "graphml":
+		err = writeNetworkGraphML(out, haplotypes, edges)
+	case "dot":
+		err = writeNetworkDOT(out, haplotypes, edges)
+	}
+
+	return err
+}
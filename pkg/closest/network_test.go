@@ -0,0 +1,190 @@
+package closest
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+var networkData = []byte(`>a
+ACGTACGTAC
+>b
+ACGTACGTAC
+>c
+ACGTACGTAA
+>d
+ACGTACGTTA
+>e
+ACGTACGTTC
+`)
+
+func TestNetworkMST(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := Network(bytes.NewReader(networkData), map[string]string{"e": "3"}, "mst", 0, "json", out, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `{
+  "nodes": [
+    {
+      "id": "a",
+      "size": 2,
+      "members": [
+        "a",
+        "b"
+      ]
+    },
+    {
+      "id": "c",
+      "size": 1,
+      "members": [
+        "c"
+      ]
+    },
+    {
+      "id": "d",
+      "size": 1,
+      "members": [
+        "d"
+      ]
+    },
+    {
+      "id": "e",
+      "size": 3,
+      "members": [
+        "e"
+      ]
+    }
+  ],
+  "edges": [
+    {
+      "source": "a",
+      "target": "c",
+      "weight": 1
+    },
+    {
+      "source": "a",
+      "target": "e",
+      "weight": 1
+    },
+    {
+      "source": "c",
+      "target": "d",
+      "weight": 1
+    }
+  ]
+}
+` {
+		t.Errorf("problem in TestNetworkMST()")
+		fmt.Println(out.String())
+	}
+}
+
+func TestNetworkMSN(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := Network(bytes.NewReader(networkData), nil, "msn", 0, "dot", out, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `graph network {
+	"a" [size=2, members="a;b"];
+	"c" [size=1, members="c"];
+	"d" [size=1, members="d"];
+	"e" [size=1, members="e"];
+	"a" -- "c" [weight=1, label="1"];
+	"a" -- "e" [weight=1, label="1"];
+	"c" -- "d" [weight=1, label="1"];
+	"d" -- "e" [weight=1, label="1"];
+}
+` {
+		t.Errorf("problem in TestNetworkMSN()")
+		fmt.Println(out.String())
+	}
+
+	// with epsilon = 1, the length-2 links are added too, because the length-1 links aren't more than 1 shorter
+	out = new(bytes.Buffer)
+	err = Network(bytes.NewReader(networkData), nil, "msn", 1, "graphml", out, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `<?xml version="1.0" encoding="UTF-8"?>
+<graphml xmlns="http://graphml.graphdrawing.org/xmlns">
+  <key id="size" for="node" attr.name="size" attr.type="int"/>
+  <key id="members" for="node" attr.name="members" attr.type="string"/>
+  <key id="weight" for="edge" attr.name="weight" attr.type="int"/>
+  <graph id="network" edgedefault="undirected">
+    <node id="a">
+      <data key="size">2</data>
+      <data key="members">a;b</data>
+    </node>
+    <node id="c">
+      <data key="size">1</data>
+      <data key="members">c</data>
+    </node>
+    <node id="d">
+      <data key="size">1</data>
+      <data key="members">d</data>
+    </node>
+    <node id="e">
+      <data key="size">1</data>
+      <data key="members">e</data>
+    </node>
+    <edge source="a" target="c">
+      <data key="weight">1</data>
+    </edge>
+    <edge source="a" target="d">
+      <data key="weight">2</data>
+    </edge>
+    <edge source="a" target="e">
+      <data key="weight">1</data>
+    </edge>
+    <edge source="c" target="d">
+      <data key="weight">1</data>
+    </edge>
+    <edge source="c" target="e">
+      <data key="weight">2</data>
+    </edge>
+    <edge source="d" target="e">
+      <data key="weight">1</data>
+    </edge>
+  </graph>
+</graphml>
+` {
+		t.Errorf("problem in TestNetworkMSN() (epsilon 1)")
+		fmt.Println(out.String())
+	}
+}
+
+func TestNetworkAmbiguities(t *testing.T) {
+	data := []byte(`>a
+ACGTACGTAC
+>b
+ACGTNCGTAC
+>c
+ACGTACGTAA
+>d
+ACGTACGTAC
+>e
+ACGTACGTAN
+`)
+
+	// b is 0 SNPs from a and d, and e is 0 SNPs from both a and c, so it joins a, which comes first
+	out := new(bytes.Buffer)
+	err := Network(bytes.NewReader(data), nil, "mst", 0, "dot", out, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `graph network {
+	"a" [size=4, members="a;b;d;e"];
+	"c" [size=1, members="c"];
+	"a" -- "c" [weight=1, label="1"];
+}
+` {
+		t.Errorf("problem in TestNetworkAmbiguities()")
+		fmt.Println(out.String())
+	}
+}
@@ -0,0 +1,43 @@
+package closest
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/virus-evolution/gofasta/pkg/snps"
+)
+
+// writeClosestNPrivate writes a long-form table of the output, like writeClosestNTable, with the snps that are private to
+// the query and to the target, and the sites where snps are masked by ambiguities, for each query-target pair
+func writeClosestNPrivate(results []catchmentStruct, querySeqs [][]byte, ref []byte, w io.Writer, measure string) error {
+
+	_, err := w.Write([]byte("query,target,distance,query_snps,target_snps,masked_sites\n"))
+	if err != nil {
+		return err
+	}
+
+	for _, result := range results {
+		for _, hit := range result.catchment {
+			var distance string
+			switch measure {
+			case "snp":
+				distance = strconv.Itoa(int(hit.distance))
+			default:
+				distance = strconv.FormatFloat(hit.distance, 'f', 9, 64)
+			}
+			qOnly, tOnly, masked := snps.Private(snps.ProfileFromAlignment(ref, querySeqs[result.qidx]), snps.ProfileFromAlignment(ref, hit.tseq))
+			maskedStrings := make([]string, len(masked))
+			for i := range masked {
+				maskedStrings[i] = strconv.Itoa(masked[i])
+			}
+			_, err = w.Write([]byte(result.qname + "," + hit.tname + "," + distance + "," + strings.Join(qOnly.SNPs, "|") + "," +
+				strings.Join(tOnly.SNPs, "|") + "," + strings.Join(maskedStrings, "|") + "\n"))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,451 @@
+package closest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/virus-evolution/gofasta/pkg/fasta"
+)
+
+/*
+Recombination screening
+
+For each query, the targets are streamed past once (as for ClosestN), and a pool of candidate parents is kept: the
+closest targets over the whole genome by SNP-distance, plus the closest target in each sliding window. Then every
+ordered pair of candidates (P, Q) is tested as the parents of a mosaic, after the triplet test in 3SEQ (Boni MF, Posada D,
+Feldman MW. An exact nonparametric method for inferring mosaic structure in sequence triplets. Genetics. 2007
+Jun;176(2):1035-47. doi: 10.1534/genetics.106.068874):
+
+Informative sites are those where the two parents differ, and the query matches one of them (all three bases must be
+certain). Walking along the genome, each site is a step up if the query matches P, and a step down if it matches Q.
+The statistic is the maximum descent of the walk, which is large if there is a run of Q-like sites between two
+P-like stretches (or either side of one). Its p-value is exact under the null hypothesis that every arrangement of
+the up and down steps is equally likely. The start and end of the maximum descent are the breakpoints, each of
+which lies somewhere between two consecutive informative sites.
+*/
+
+// recombinationStruct is the best two-parent mosaic for one query sequence
+type recombinationStruct struct {
+	qname       string
+	qidx        int
+	closest     resultsStruct // the best single parent over the whole genome
+	parent1     string        // the parent whose sites are the up steps (outside the descent)
+	parent2     string        // the parent whose sites are the down steps (inside the descent)
+	breakpoints []string      // intervals that the breakpoints lie in
+	informative int           // number of informative sites
+	sites1      int           // number of informative sites that support parent1 in its segments
+	sites2      int           // number of informative sites that support parent2 in its segment
+	conflicting int           // number of informative sites that support the other parent in each segment
+	descent     int           // maximum descent of the walk
+	p           float64       // uncorrected p-value
+	pAdjusted   float64       // p-value with Bonferroni correction for the number of pairs tested
+	tested      int           // number of parent pairs that were tested
+	mosaicFound bool
+}
+
+// windowDiffs returns the number of differences between query and target in each window
+func windowDiffs(query, target fasta.EncodedRecord, window, step int) []int {
+	n := numWindows(len(query.Seq), window, step)
+	diffs := make([]int, n)
+	for w := 0; w < n; w++ {
+		start := w * step
+		end := start + window
+		if end > len(query.Seq) {
+			end = len(query.Seq)
+		}
+		for i := start; i < end; i++ {
+			if (query.Seq[i] & target.Seq[i]) < 16 {
+				diffs[w]++
+			}
+		}
+	}
+	return diffs
+}
+
+// numWindows returns the number of windows of width window, step apart, that are needed to cover length sites
+func numWindows(length, window, step int) int {
+	if length <= window {
+		return 1
+	}
+	return int(math.Ceil(float64(length-window)/float64(step))) + 1
+}
+
+// maxDescentPValue returns the probability that a walk of m up steps and n down steps, arranged uniformly at random,
+// has a maximum descent of at least k. It is calculated exactly by dynamic programming over the current descent
+// (running maximum minus current height) of the walk
+func maxDescentPValue(m, n, k int) float64 {
+	if k <= 0 {
+		return 1.0
+	}
+	if k > n {
+		return 0.0
+	}
+
+	// prob[i][h] is the probability of having taken i up steps (and some number of down steps, which is implied by
+	// the step number) with current descent h < k, and never having reached a descent of k
+	prob := make([][]float64, m+1)
+	for i := range prob {
+		prob[i] = make([]float64, k)
+	}
+	prob[0][0] = 1.0
+
+	for s := 0; s < m+n; s++ {
+		next := make([][]float64, m+1)
+		for i := range next {
+			next[i] = make([]float64, k)
+		}
+		for i := 0; i <= m && i <= s; i++ {
+			j := s - i
+			if j > n {
+				continue
+			}
+			remaining := float64(m + n - s)
+			for h := 0; h < k; h++ {
+				p := prob[i][h]
+				if p == 0.0 {
+					continue
+				}
+				if i < m {
+					hUp := h - 1
+					if hUp < 0 {
+						hUp = 0
+					}
+					next[i+1][hUp] += p * float64(m-i) / remaining
+				}
+				if j < n && h+1 < k {
+					next[i][h+1] += p * float64(n-j) / remaining
+				}
+			}
+		}
+		prob = next
+	}
+
+	survive := 0.0
+	for h := 0; h < k; h++ {
+		survive += prob[m][h]
+	}
+
+	p := 1.0 - survive
+	if p < 0.0 {
+		p = 0.0
+	}
+	return p
+}
+
+// informativeSites returns the (0-based) positions where p1 and p2 certainly differ and query certainly matches
+// one of them, and for each one, whether it matches p1
+func informativeSites(query, p1, p2 fasta.EncodedRecord) ([]int, []bool) {
+	positions := make([]int, 0)
+	matchesP1 := make([]bool, 0)
+	for i, qNuc := range query.Seq {
+		a := p1.Seq[i]
+		b := p2.Seq[i]
+		if qNuc&8 != 8 || a&8 != 8 || b&8 != 8 || a == b {
+			continue
+		}
+		if qNuc == a {
+			positions = append(positions, i)
+			matchesP1 = append(matchesP1, true)
+		} else if qNuc == b {
+			positions = append(positions, i)
+			matchesP1 = append(matchesP1, false)
+		}
+	}
+	return positions, matchesP1
+}
+
+// maxDescent returns the maximum descent of the walk defined by steps (true = up), and the indices of the last
+// step before the descent starts (-1 if it starts at the beginning) and of the last step of the descent
+func maxDescent(steps []bool) (int, int, int) {
+	height := 0
+	maxHeight := 0
+	maxAt := -1
+	best, bestStart, bestEnd := 0, -1, -1
+	for i, up := range steps {
+		if up {
+			height++
+		} else {
+			height--
+		}
+		if height > maxHeight {
+			maxHeight = height
+			maxAt = i
+		}
+		if maxHeight-height > best {
+			best = maxHeight - height
+			bestStart = maxAt
+			bestEnd = i
+		}
+	}
+	return best, bestStart, bestEnd
+}
+
+// testTriplet tests whether query is a mosaic of p1 (outside) and p2 (inside the maximum descent)
+func testTriplet(query, p1, p2 fasta.EncodedRecord) recombinationStruct {
+
+	positions, steps := informativeSites(query, p1, p2)
+
+	rs := recombinationStruct{parent1: p1.ID, parent2: p2.ID, informative: len(positions), breakpoints: make([]string, 0)}
+
+	m := 0
+	for _, up := range steps {
+		if up {
+			m++
+		}
+	}
+	n := len(steps) - m
+
+	descent, start, end := maxDescent(steps)
+	rs.descent = descent
+	rs.p = maxDescentPValue(m, n, descent)
+
+	if descent == 0 {
+		return rs
+	}
+
+	// breakpoints lie between the informative sites either side of the start and end of the descent (1-based positions)
+	if start >= 0 {
+		rs.breakpoints = append(rs.breakpoints, strconv.Itoa(positions[start]+1)+"-"+strconv.Itoa(positions[start+1]+1))
+	}
+	if end < len(positions)-1 {
+		rs.breakpoints = append(rs.breakpoints, strconv.Itoa(positions[end]+1)+"-"+strconv.Itoa(positions[end+1]+1))
+	}
+
+	for i, up := range steps {
+		inside := i > start && i <= end
+		switch {
+		case inside && !up:
+			rs.sites2++
+		case !inside && up:
+			rs.sites1++
+		default:
+			rs.conflicting++
+		}
+	}
+
+	return rs
+}
+
+// bestMosaic tests every ordered pair of candidate parents and returns the most significant mosaic
+func bestMosaic(query fasta.EncodedRecord, candidates []fasta.EncodedRecord) recombinationStruct {
+
+	best := recombinationStruct{p: 1.0, pAdjusted: 1.0}
+	tested := 0
+
+	for i := range candidates {
+		for j := range candidates {
+			if i == j {
+				continue
+			}
+			tested++
+			rs := testTriplet(query, candidates[i], candidates[j])
+			// the descent must not cover the whole walk, otherwise parent2 is the only parent
+			if rs.descent == 0 || len(rs.breakpoints) == 0 {
+				continue
+			}
+			if !best.mosaicFound || rs.p < best.p || (rs.p == best.p && rs.descent > best.descent) {
+				rs.mosaicFound = true
+				best = rs
+			}
+		}
+	}
+
+	best.tested = tested
+	best.pAdjusted = math.Min(1.0, best.p*float64(tested))
+
+	return best
+}
+
+// findRecombination keeps a pool of candidate parents for a single query sequence from the targets it is passed,
+// then finds the best two-parent mosaic among them
+func findRecombination(query fasta.EncodedRecord, window, step, poolSize int, cIn chan fasta.EncodedRecord, cOut chan recombinationStruct) {
+
+	nW := numWindows(len(query.Seq), window, step)
+
+	// the closest targets over the whole genome
+	pool := make([]resultsStruct, 0)
+	poolRecords := make(map[string]fasta.EncodedRecord)
+
+	// the closest target in each window
+	windowBest := make([]fasta.EncodedRecord, nW)
+	windowBestDiffs := make([]int, nW)
+	for w := range windowBestDiffs {
+		windowBestDiffs[w] = -1
+	}
+
+	for target := range cIn {
+		if target.ID == query.ID {
+			continue
+		}
+
+		distance := snpDistance(query, target)
+		if len(pool) < poolSize || distance < pool[len(pool)-1].distance || (distance == pool[len(pool)-1].distance && target.Score > pool[len(pool)-1].completeness) {
+			pool = append(pool, resultsStruct{tname: target.ID, completeness: target.Score, distance: distance})
+			poolRecords[target.ID] = target
+			sort.SliceStable(pool, func(i, j int) bool {
+				return pool[i].distance < pool[j].distance || (pool[i].distance == pool[j].distance && pool[i].completeness > pool[j].completeness)
+			})
+			if len(pool) > poolSize {
+				delete(poolRecords, pool[len(pool)-1].tname)
+				pool = pool[:poolSize]
+			}
+		}
+
+		for w, d := range windowDiffs(query, target, window, step) {
+			if windowBestDiffs[w] == -1 || d < windowBestDiffs[w] || (d == windowBestDiffs[w] && target.Score > windowBest[w].Score) {
+				windowBestDiffs[w] = d
+				windowBest[w] = target
+			}
+		}
+	}
+
+	candidates := make([]fasta.EncodedRecord, 0)
+	seen := make(map[string]bool)
+	for _, r := range pool {
+		candidates = append(candidates, poolRecords[r.tname])
+		seen[r.tname] = true
+	}
+	for w := range windowBest {
+		if windowBestDiffs[w] != -1 && !seen[windowBest[w].ID] {
+			candidates = append(candidates, windowBest[w])
+			seen[windowBest[w].ID] = true
+		}
+	}
+
+	result := bestMosaic(query, candidates)
+	result.qname = query.ID
+	result.qidx = query.Idx
+	if len(pool) > 0 {
+		result.closest = pool[0]
+	}
+
+	cOut <- result
+}
+
+// splitInputRecombination fans out target sequences over an array of query sequences, so that each target is passed over each query.
+func splitInputRecombination(queries []fasta.EncodedRecord, window, step, poolSize int, cIn chan fasta.EncodedRecord, cOut chan recombinationStruct, cErr chan error, cSplitDone chan bool) {
+
+	nQ := len(queries)
+
+	// make an array of channels, one for each query
+	QChanArray := make([]chan fasta.EncodedRecord, nQ)
+	for i := 0; i < nQ; i++ {
+		QChanArray[i] = make(chan fasta.EncodedRecord)
+	}
+
+	for i, q := range queries {
+		go findRecombination(q, window, step, poolSize, QChanArray[i], cOut)
+	}
+
+	targetCounter := 0
+	for EFR := range cIn {
+		if len(EFR.Seq) != len(queries[0].Seq) {
+			cErr <- errors.New("query and target alignments are not the same width")
+			return
+		}
+		targetCounter++
+
+		for i := range QChanArray {
+			QChanArray[i] <- EFR
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "number of sequences in target alignment: %d\n", targetCounter)
+
+	for i := range QChanArray {
+		close(QChanArray[i])
+	}
+
+	cSplitDone <- true
+}
+
+// writeRecombination parses an array of recombinationStructs in order to write them, usually to stdout or file
+func writeRecombination(results []recombinationStruct, w io.Writer) error {
+
+	_, err := w.Write([]byte("query,closest,closest_distance,parent1,parent2,breakpoints,informative_sites,parent1_sites,parent2_sites,conflicting_sites,max_descent,p_value,p_adjusted,pairs_tested\n"))
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		fields := []string{r.qname, r.closest.tname, strconv.Itoa(int(r.closest.distance))}
+		if r.mosaicFound {
+			fields = append(fields, r.parent1, r.parent2, strings.Join(r.breakpoints, "|"),
+				strconv.Itoa(r.informative), strconv.Itoa(r.sites1), strconv.Itoa(r.sites2), strconv.Itoa(r.conflicting),
+				strconv.Itoa(r.descent), strconv.FormatFloat(r.p, 'g', 6, 64), strconv.FormatFloat(r.pAdjusted, 'g', 6, 64))
+		} else {
+			fields = append(fields, "", "", "", "", "", "", "", "0", "1", "1")
+		}
+		fields = append(fields, strconv.Itoa(r.tested))
+		_, err = w.Write([]byte(strings.Join(fields, ",") + "\n"))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Recombination screens each query sequence for mosaic ancestry from two parents among the target sequences. Candidate
+// parents are the poolSize closest targets by SNP-distance, plus the closest target in each window of width window,
+// step apart. Every pair of candidates is then tested with a 3SEQ-style triplet test, and the most significant
+// mosaic for each query is written to stdout or to file.
+func Recombination(query, target io.Reader, window, step, poolSize int, out io.Writer, threads int) error {
+
+	if window < 1 || step < 1 || poolSize < 1 {
+		return errors.New("--window, --step and --candidates must be > 0")
+	}
+
+	if threads == 0 {
+		threads = runtime.NumCPU()
+	} else if threads < runtime.NumCPU() {
+		runtime.GOMAXPROCS(threads)
+	}
+
+	queries, err := fasta.LoadEncodeAlignment(query, false, false, false)
+	if err != nil {
+		return err
+	}
+
+	nQ := len(queries)
+	if nQ == 0 {
+		return errors.New("no sequences in --query")
+	}
+
+	fmt.Fprintf(os.Stderr, "number of sequences in query alignment: %d\n", nQ)
+
+	QResultsArray := make([]recombinationStruct, nQ)
+
+	cErr := make(chan error)
+
+	cTEFR := make(chan fasta.EncodedRecord, runtime.NumCPU())
+	cTEFRdone := make(chan bool)
+	cSplitDone := make(chan bool)
+	cResults := make(chan recombinationStruct)
+
+	go fasta.StreamEncodeAlignment(target, cTEFR, cErr, cTEFRdone, false, false, true)
+
+	go splitInputRecombination(queries, window, step, poolSize, cTEFR, cResults, cErr, cSplitDone)
+
+	err = fasta.WaitStages(cErr,
+		fasta.Stage{Done: cTEFRdone, Close: func() { close(cTEFR) }},
+		fasta.Stage{Done: cSplitDone},
+	)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < nQ; i++ {
+		result := <-cResults
+		QResultsArray[result.qidx] = result
+	}
+
+	return writeRecombination(QResultsArray, out)
+}
@@ -0,0 +1,61 @@
+package closest
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestMaxDescentPValue(t *testing.T) {
+	if maxDescentPValue(1, 1, 1) != 1.0 {
+		t.Errorf("problem in TestMaxDescentPValue() (1, 1, 1)")
+	}
+	if math.Abs(maxDescentPValue(2, 2, 2)-0.5) > 1e-12 {
+		t.Errorf("problem in TestMaxDescentPValue() (2, 2, 2)")
+		fmt.Println(maxDescentPValue(2, 2, 2))
+	}
+	if maxDescentPValue(3, 2, 3) != 0.0 {
+		t.Errorf("problem in TestMaxDescentPValue() (3, 2, 3)")
+	}
+}
+
+func TestMaxDescent(t *testing.T) {
+	descent, start, end := maxDescent([]bool{true, true, false, false, false, true})
+	if descent != 3 || start != 1 || end != 4 {
+		t.Errorf("problem in TestMaxDescent()")
+		fmt.Println(descent, start, end)
+	}
+}
+
+func TestRecombination(t *testing.T) {
+	queryData := []byte(`>recombinant
+AAAAAAAAAAAAAAAAAAAACCCCCCCCCCCCCCCCCCCC
+>notrecombinant
+AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAT
+`)
+	targetData := []byte(`>parentA
+AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA
+>parentC
+CCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCCC
+>other
+GGGGGGGGGGGGGGGGGGGGGGGGGGGGGGGGGGGGGGGG
+`)
+
+	query := bytes.NewReader(queryData)
+	target := bytes.NewReader(targetData)
+	out := new(bytes.Buffer)
+
+	err := Recombination(query, target, 10, 10, 10, out, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `query,closest,closest_distance,parent1,parent2,breakpoints,informative_sites,parent1_sites,parent2_sites,conflicting_sites,max_descent,p_value,p_adjusted,pairs_tested
+recombinant,parentA,20,parentA,parentC,20-21,40,20,20,0,20,1.52344e-10,9.14061e-10,6
+notrecombinant,parentA,1,,,,,,,,0,1,1,6
+` {
+		t.Errorf("problem in TestRecombination()")
+		fmt.Println(out.String())
+	}
+}
@@ -0,0 +1,36 @@
+/*
+Package convert implements functionality to convert a multiple sequence
+alignment in fasta format to other alignment formats.
+*/
+package convert
+
+import (
+	"io"
+
+	"github.com/virus-evolution/gofasta/pkg/fasta"
+)
+
+// Convert reads a fasta-format alignment and writes it in format, which is one of fasta.Formats.
+// nexusTaxa and dates control the optional blocks in nexus output, and wrap sets the block width
+// for the formats that are wrapped or interleaved
+func Convert(msaIn io.Reader, out io.Writer, format string, wrap int, nexusTaxa bool, dates map[string]string) error {
+
+	err := fasta.CheckFormat(format)
+	if err != nil {
+		return err
+	}
+
+	cFR := make(chan fasta.Record)
+	cErr := make(chan error)
+	cReadDone := make(chan bool)
+	cWriteDone := make(chan bool)
+
+	go fasta.StreamAlignment(msaIn, cFR, cErr, cReadDone)
+
+	go fasta.WriteAlignmentFormat(cFR, out, format, wrap, nexusTaxa, dates, cErr, cWriteDone)
+
+	return fasta.WaitStages(cErr,
+		fasta.Stage{Done: cReadDone, Close: func() { close(cFR) }},
+		fasta.Stage{Done: cWriteDone},
+	)
+}
@@ -0,0 +1,320 @@
+package fasta
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Formats are the alignment formats that WriteAlignmentFormat can write
+var Formats = []string{"fasta", "phylip", "phylip-relaxed", "nexus", "clustal", "stockholm"}
+
+// CheckFormat returns an error if format isn't one of Formats
+func CheckFormat(format string) error {
+	for _, f := range Formats {
+		if f == format {
+			return nil
+		}
+	}
+	return errors.New("unknown alignment format: " + format + " (choose from: " + strings.Join(Formats, ", ") + ")")
+}
+
+// WriteAlignmentFormat reads Records from a channel and writes them to file or stdout in the alignment
+// format, format, in the order in which they are present in the input file. Apart from fasta, these
+// formats need to know the dimensions of the alignment before anything is written (or else are interleaved),
+// so all the records are held in memory until the channel is closed. wrap is the width of the sequence
+// blocks for fasta, clustal and stockholm. nexusTaxa adds a TAXA block to nexus output, and if dates
+// is not empty, each taxon's date is appended to its nexus label (name|date).
+// It passes a true to a done channel when the channel of fasta records is empty
+func WriteAlignmentFormat(cR chan Record, w io.Writer, format string, wrap int, nexusTaxa bool, dates map[string]string, cErr chan error, cDone chan bool) {
+
+	switch format {
+	case "fasta":
+		if wrap > 0 {
+			WriteWrapAlignment(cR, w, wrap, cErr, cDone)
+		} else {
+			WriteAlignment(cR, w, cErr, cDone)
+		}
+		return
+	}
+
+	outputMap := make(map[int]Record)
+	records := make([]Record, 0)
+	counter := 0
+	for FR := range cR {
+		outputMap[FR.Idx] = FR
+		for {
+			if record, ok := outputMap[counter]; ok {
+				records = append(records, record)
+				delete(outputMap, counter)
+				counter++
+			} else {
+				break
+			}
+		}
+	}
+
+	var err error
+
+	switch format {
+	case "phylip":
+		err = WritePhylip(records, w, false)
+	case "phylip-relaxed":
+		err = WritePhylip(records, w, true)
+	case "nexus":
+		err = WriteNexus(records, w, nexusTaxa, dates)
+	case "clustal":
+		err = WriteClustal(records, w, wrap)
+	case "stockholm":
+		err = WriteStockholm(records, w, wrap)
+	default:
+		err = CheckFormat(format)
+	}
+	if err != nil {
+		cErr <- err
+		return
+	}
+
+	cDone <- true
+}
+
+// checkAligned returns the width of an alignment, or an error if the records are not all the same length
+func checkAligned(records []Record) (int, error) {
+	if len(records) == 0 {
+		return 0, errors.New("no records in alignment")
+	}
+	width := len(records[0].Seq)
+	for _, record := range records {
+		if len(record.Seq) != width {
+			return 0, errors.New("sequences are not all the same length: " + record.ID + " has length " + strconv.Itoa(len(record.Seq)) + ", but the alignment is " + strconv.Itoa(width) + " wide")
+		}
+	}
+	return width, nil
+}
+
+// maxIDLength returns the length of the longest ID in records
+func maxIDLength(records []Record) int {
+	max := 0
+	for _, record := range records {
+		if len(record.ID) > max {
+			max = len(record.ID)
+		}
+	}
+	return max
+}
+
+// WritePhylip writes records in sequential PHYLIP format. Strict PHYLIP names are truncated or padded to
+// exactly 10 characters, and must still be unique afterwards. In relaxed PHYLIP, names can be any length
+// but can't contain whitespace, and are separated from the sequence by a single space.
+func WritePhylip(records []Record, w io.Writer, relaxed bool) error {
+	width, err := checkAligned(records)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write([]byte(strconv.Itoa(len(records)) + " " + strconv.Itoa(width) + "\n"))
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	for _, record := range records {
+		var name string
+		if relaxed {
+			if strings.ContainsAny(record.ID, " \t") {
+				return errors.New("relaxed phylip names can't contain whitespace: " + record.ID)
+			}
+			name = record.ID + " "
+		} else {
+			name = fmt.Sprintf("%-10.10s", record.ID)
+		}
+		if seen[name] {
+			return errors.New("duplicate phylip name (strict phylip names are truncated to 10 characters): " + strings.TrimSpace(name))
+		}
+		seen[name] = true
+		_, err = w.Write([]byte(name + record.Seq + "\n"))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+var nexusPlainName = regexp.MustCompile(`^[A-Za-z0-9_.]+$`)
+
+// nexusName returns a name that is safe to use as a NEXUS token, single-quoting it if necessary
+func nexusName(name string) string {
+	if nexusPlainName.MatchString(name) {
+		return name
+	}
+	return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+}
+
+// nexusDataType guesses the NEXUS datatype of an alignment from its characters
+func nexusDataType(records []Record) string {
+	for _, record := range records {
+		for _, c := range record.Seq {
+			if !strings.ContainsRune("ACGTURYSWKMBDHVNacgturyswkmbdhvn-?", c) {
+				return "PROTEIN"
+			}
+		}
+	}
+	return "DNA"
+}
+
+// WriteNexus writes records in NEXUS format. If taxa is true, a TAXA block is written and the sequences
+// go in a CHARACTERS block, otherwise they go in a DATA block. If dates is not empty, every taxon must have a
+// date, which is appended to its label after a '|' (name|date), as BEAUti's "Guess dates" option reads it.
+func WriteNexus(records []Record, w io.Writer, taxa bool, dates map[string]string) error {
+	width, err := checkAligned(records)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, len(records))
+	pad := 0
+	for i, record := range records {
+		label := record.ID
+		if len(dates) > 0 {
+			date, ok := dates[record.ID]
+			if !ok {
+				return errors.New("no date for " + record.ID)
+			}
+			label += "|" + date
+		}
+		names[i] = nexusName(label)
+		if len(names[i]) > pad {
+			pad = len(names[i])
+		}
+	}
+
+	var sb strings.Builder
+
+	sb.WriteString("#NEXUS\n\n")
+
+	if taxa {
+		sb.WriteString("BEGIN TAXA;\n")
+		sb.WriteString("\tDIMENSIONS NTAX=" + strconv.Itoa(len(records)) + ";\n")
+		sb.WriteString("\tTAXLABELS\n")
+		for _, name := range names {
+			sb.WriteString("\t\t" + name + "\n")
+		}
+		sb.WriteString("\t;\nEND;\n\n")
+		sb.WriteString("BEGIN CHARACTERS;\n")
+		sb.WriteString("\tDIMENSIONS NCHAR=" + strconv.Itoa(width) + ";\n")
+	} else {
+		sb.WriteString("BEGIN DATA;\n")
+		sb.WriteString("\tDIMENSIONS NTAX=" + strconv.Itoa(len(records)) + " NCHAR=" + strconv.Itoa(width) + ";\n")
+	}
+	sb.WriteString("\tFORMAT DATATYPE=" + nexusDataType(records) + " MISSING=? GAP=-;\n")
+	sb.WriteString("\tMATRIX\n")
+
+	for i, record := range records {
+		sb.WriteString(fmt.Sprintf("\t\t%-*s %s\n", pad, names[i], record.Seq))
+	}
+	sb.WriteString("\t;\nEND;\n")
+
+	_, err = w.Write([]byte(sb.String()))
+
+	return err
+}
+
+// WriteClustal writes records in Clustal format, in blocks that are wrap characters wide (60 if wrap <= 0).
+// Each block ends with a conservation line, in which fully conserved columns are marked with '*'
+func WriteClustal(records []Record, w io.Writer, wrap int) error {
+	width, err := checkAligned(records)
+	if err != nil {
+		return err
+	}
+	if wrap <= 0 {
+		wrap = 60
+	}
+
+	for _, record := range records {
+		if strings.ContainsAny(record.ID, " \t") {
+			return errors.New("clustal names can't contain whitespace: " + record.ID)
+		}
+	}
+
+	pad := maxIDLength(records) + 6
+
+	var sb strings.Builder
+
+	sb.WriteString("CLUSTAL W multiple sequence alignment\n\n")
+
+	for start := 0; start < width; start += wrap {
+		end := start + wrap
+		if end > width {
+			end = width
+		}
+		sb.WriteString("\n")
+		for _, record := range records {
+			sb.WriteString(fmt.Sprintf("%-*s%s\n", pad, record.ID, record.Seq[start:end]))
+		}
+		conservation := make([]byte, end-start)
+		for i := start; i < end; i++ {
+			conservation[i-start] = '*'
+			c := strings.ToUpper(records[0].Seq[i : i+1])
+			if c == "-" {
+				conservation[i-start] = ' '
+				continue
+			}
+			for _, record := range records[1:] {
+				if strings.ToUpper(record.Seq[i:i+1]) != c {
+					conservation[i-start] = ' '
+					break
+				}
+			}
+		}
+		sb.WriteString(strings.Repeat(" ", pad) + string(conservation) + "\n")
+	}
+
+	_, err = w.Write([]byte(sb.String()))
+
+	return err
+}
+
+// WriteStockholm writes records in Stockholm format. If wrap > 0 the alignment is written in blocks that
+// are wrap characters wide, otherwise each sequence is written on a single line
+func WriteStockholm(records []Record, w io.Writer, wrap int) error {
+	width, err := checkAligned(records)
+	if err != nil {
+		return err
+	}
+	if wrap <= 0 {
+		wrap = width
+	}
+
+	for _, record := range records {
+		if strings.ContainsAny(record.ID, " \t") {
+			return errors.New("stockholm names can't contain whitespace: " + record.ID)
+		}
+	}
+
+	pad := maxIDLength(records) + 1
+
+	var sb strings.Builder
+
+	sb.WriteString("# STOCKHOLM 1.0\n")
+
+	for start := 0; start < width; start += wrap {
+		end := start + wrap
+		if end > width {
+			end = width
+		}
+		sb.WriteString("\n")
+		for _, record := range records {
+			sb.WriteString(fmt.Sprintf("%-*s%s\n", pad, record.ID, record.Seq[start:end]))
+		}
+	}
+
+	sb.WriteString("//\n")
+
+	_, err = w.Write([]byte(sb.String()))
+
+	return err
+}
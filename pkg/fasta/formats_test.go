@@ -0,0 +1,182 @@
+package fasta
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+var formatRecords = []Record{
+	{ID: "seq1", Seq: "ACGTACGT"},
+	{ID: "sequence/2", Seq: "ACGAACG-"},
+	{ID: "seq3", Seq: "ACGTACGN"},
+}
+
+func TestWritePhylip(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := WritePhylip(formatRecords, out, false)
+	if err != nil {
+		t.Error(err)
+	}
+	if out.String() != `3 8
+seq1      ACGTACGT
+sequence/2ACGAACG-
+seq3      ACGTACGN
+` {
+		t.Errorf("problem in TestWritePhylip()")
+		fmt.Println(out.String())
+	}
+
+	out = new(bytes.Buffer)
+	err = WritePhylip(formatRecords, out, true)
+	if err != nil {
+		t.Error(err)
+	}
+	if out.String() != `3 8
+seq1 ACGTACGT
+sequence/2 ACGAACG-
+seq3 ACGTACGN
+` {
+		t.Errorf("problem in TestWritePhylip() (relaxed)")
+		fmt.Println(out.String())
+	}
+
+	records := []Record{{ID: "sequence_001", Seq: "A"}, {ID: "sequence_002", Seq: "A"}}
+	err = WritePhylip(records, new(bytes.Buffer), false)
+	if err == nil {
+		t.Errorf("expected an error in TestWritePhylip() (duplicate truncated names)")
+	}
+}
+
+func TestWriteNexus(t *testing.T) {
+	out := new(bytes.Buffer)
+	dates := map[string]string{"seq1": "2020-01-01", "sequence/2": "2020-02-01", "seq3": "2020-03-01"}
+	err := WriteNexus(formatRecords, out, true, dates)
+	if err != nil {
+		t.Error(err)
+	}
+	if out.String() != `#NEXUS
+
+BEGIN TAXA;
+	DIMENSIONS NTAX=3;
+	TAXLABELS
+		'seq1|2020-01-01'
+		'sequence/2|2020-02-01'
+		'seq3|2020-03-01'
+	;
+END;
+
+BEGIN CHARACTERS;
+	DIMENSIONS NCHAR=8;
+	FORMAT DATATYPE=DNA MISSING=? GAP=-;
+	MATRIX
+		'seq1|2020-01-01'       ACGTACGT
+		'sequence/2|2020-02-01' ACGAACG-
+		'seq3|2020-03-01'       ACGTACGN
+	;
+END;
+` {
+		t.Errorf("problem in TestWriteNexus()")
+		fmt.Println(out.String())
+	}
+
+	out = new(bytes.Buffer)
+	err = WriteNexus([]Record{{ID: "p1", Seq: "MLE*"}}, out, false, nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if out.String() != `#NEXUS
+
+BEGIN DATA;
+	DIMENSIONS NTAX=1 NCHAR=4;
+	FORMAT DATATYPE=PROTEIN MISSING=? GAP=-;
+	MATRIX
+		p1 MLE*
+	;
+END;
+` {
+		t.Errorf("problem in TestWriteNexus() (protein)")
+		fmt.Println(out.String())
+	}
+
+	err = WriteNexus(formatRecords, new(bytes.Buffer), false, map[string]string{"seq1": "2020-01-01"})
+	if err == nil {
+		t.Errorf("expected an error in TestWriteNexus() (missing date)")
+	}
+}
+
+func TestWriteClustal(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := WriteClustal(formatRecords, out, 5)
+	if err != nil {
+		t.Error(err)
+	}
+	if out.String() != `CLUSTAL W multiple sequence alignment
+
+
+seq1            ACGTA
+sequence/2      ACGAA
+seq3            ACGTA
+                *** *
+
+seq1            CGT
+sequence/2      CG-
+seq3            CGN
+                ** 
+` {
+		t.Errorf("problem in TestWriteClustal()")
+		fmt.Println(out.String())
+	}
+}
+
+func TestWriteStockholm(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := WriteStockholm(formatRecords, out, -1)
+	if err != nil {
+		t.Error(err)
+	}
+	if out.String() != `# STOCKHOLM 1.0
+
+seq1       ACGTACGT
+sequence/2 ACGAACG-
+seq3       ACGTACGN
+//
+` {
+		t.Errorf("problem in TestWriteStockholm()")
+		fmt.Println(out.String())
+	}
+}
+
+func TestWriteAlignmentFormat(t *testing.T) {
+	cR := make(chan Record, 3)
+	cErr := make(chan error)
+	cDone := make(chan bool)
+
+	// out of order, to check the records are written in input order
+	cR <- Record{ID: "seq3", Seq: "ACGT", Idx: 2}
+	cR <- Record{ID: "seq1", Seq: "ACGT", Idx: 0}
+	cR <- Record{ID: "seq2", Seq: "ACGA", Idx: 1}
+	close(cR)
+
+	out := new(bytes.Buffer)
+	go WriteAlignmentFormat(cR, out, "phylip-relaxed", -1, false, nil, cErr, cDone)
+
+	select {
+	case err := <-cErr:
+		t.Error(err)
+	case <-cDone:
+	}
+
+	if out.String() != `3 4
+seq1 ACGT
+seq2 ACGA
+seq3 ACGT
+` {
+		t.Errorf("problem in TestWriteAlignmentFormat()")
+		fmt.Println(out.String())
+	}
+
+	if CheckFormat("genbank") == nil {
+		t.Errorf("expected an error in TestWriteAlignmentFormat() (unknown format)")
+	}
+}
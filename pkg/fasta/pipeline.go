@@ -0,0 +1,88 @@
+package fasta
+
+import (
+	"errors"
+	"sync"
+)
+
+// FirstRecord returns the first record of an alignment that is being streamed to cR by StreamAlignment or
+// StreamEncodeAlignment, so that it can be used (as a reference, for example) before the rest are processed
+func FirstRecord[T Record | EncodedRecord](cR chan T, cErr chan error, cDone chan bool) (T, error) {
+	var first T
+	select {
+	case first = <-cR:
+		return first, nil
+	case err := <-cErr:
+		return first, err
+	case <-cDone:
+		return first, errors.New("is the pipe to --msa empty?")
+	}
+}
+
+// Requeue sends a record that was taken from a stream by FirstRecord back to it, so that it is processed along with
+// the rest of the records. It returns a channel that is signalled once the record has been sent
+func Requeue[T Record | EncodedRecord](cR chan T, record T) chan bool {
+	cSent := make(chan bool, 1)
+	go func() {
+		cR <- record
+		cSent <- true
+	}()
+	return cSent
+}
+
+// StartWorkers runs threads copies of work concurrently, and returns a channel that is signalled once they
+// have all returned
+func StartWorkers(threads int, work func()) chan bool {
+	cDone := make(chan bool)
+
+	var wg sync.WaitGroup
+	wg.Add(threads)
+
+	for n := 0; n < threads; n++ {
+		go func() {
+			work()
+			wg.Done()
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		cDone <- true
+	}()
+
+	return cDone
+}
+
+// AllDone returns a channel that is signalled once each of cs has been
+func AllDone(cs ...chan bool) chan bool {
+	cDone := make(chan bool)
+	go func() {
+		for _, c := range cs {
+			<-c
+		}
+		cDone <- true
+	}()
+	return cDone
+}
+
+// A Stage is one step in a pipeline of goroutines. Done is signalled when it has finished, after which Close
+// (if it isn't nil) is called to close the channel that it was passing its output to
+type Stage struct {
+	Done  chan bool
+	Close func()
+}
+
+// WaitStages waits for each stage of a pipeline to finish in turn, and returns the first error that is sent to cErr
+func WaitStages(cErr chan error, stages ...Stage) error {
+	for _, s := range stages {
+		select {
+		case err := <-cErr:
+			return err
+		case <-s.Done:
+			if s.Close != nil {
+				s.Close()
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,319 @@
+/*
+Package lineage implements functionality to assign each record in a multiple
+sequence alignment in fasta format to a lineage, given a file of lineage
+definitions, each of which is a set of mutations relative to a reference.
+*/
+package lineage
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/virus-evolution/gofasta/pkg/fasta"
+	"github.com/virus-evolution/gofasta/pkg/variants"
+)
+
+// lineageDef is one lineage's definition. required and optional include the mutations that are inherited from
+// its ancestors
+type lineageDef struct {
+	name     string
+	parent   string
+	required []variants.NamedMutation
+	optional []variants.NamedMutation
+	depth    int
+}
+
+// assignment is the best-matching lineage for one query
+type assignment struct {
+	queryname       string
+	idx             int
+	lineage         string
+	required        int
+	matched         int
+	missing         int
+	ambiguous       int
+	optionalMatched int
+	missingMuts     []string
+}
+
+// splitMutations splits a "|"-delimited list of mutations
+func splitMutations(s string) []string {
+	if len(strings.TrimSpace(s)) == 0 {
+		return []string{}
+	}
+	muts := strings.Split(s, "|")
+	for i := range muts {
+		muts[i] = strings.TrimSpace(muts[i])
+	}
+	return muts
+}
+
+// readDefinitions reads a csv file of lineage definitions with the header lineage,parent,required,optional. required and
+// optional are "|"-delimited lists of mutations in the format returned by variants.FormatVariant. Each lineage inherits
+// the required and optional mutations of its parent, which must also be defined in the file (or be empty, for a root).
+// Inherited required mutations stay required if a descendant lists them as optional
+func readDefinitions(in io.Reader, cdsregions []variants.Region) ([]lineageDef, error) {
+
+	type rawDef struct {
+		name, parent       string
+		required, optional []string
+	}
+
+	raw := make([]rawDef, 0)
+	index := make(map[string]int)
+
+	r := csv.NewReader(in)
+	header := true
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header {
+			if len(record) != 4 || record[0] != "lineage" || record[1] != "parent" || record[2] != "required" || record[3] != "optional" {
+				return nil, errors.New("bad header when parsing lineage definitions: expected lineage,parent,required,optional")
+			}
+			header = false
+			continue
+		}
+		if _, ok := index[record[0]]; ok {
+			return nil, errors.New("duplicate lineage in definitions: " + record[0])
+		}
+		index[record[0]] = len(raw)
+		raw = append(raw, rawDef{name: record[0], parent: record[1], required: splitMutations(record[2]), optional: splitMutations(record[3])})
+	}
+
+	parsed := make(map[string]variants.NamedMutation)
+	parse := func(muts []string) ([]variants.NamedMutation, error) {
+		nms := make([]variants.NamedMutation, 0, len(muts))
+		for _, m := range muts {
+			nm, ok := parsed[m]
+			if !ok {
+				var err error
+				nm, err = variants.ParseMutation(m, cdsregions)
+				if err != nil {
+					return nil, err
+				}
+				parsed[m] = nm
+			}
+			nms = append(nms, nm)
+		}
+		return nms, nil
+	}
+
+	defs := make([]lineageDef, len(raw))
+	for i, rd := range raw {
+		// walk up the hierarchy to find the lineages whose mutations are inherited
+		chain := make([]int, 0)
+		for j, visited := i, make(map[int]bool); ; {
+			if visited[j] {
+				return nil, errors.New("lineage hierarchy has a cycle involving: " + rd.name)
+			}
+			visited[j] = true
+			chain = append(chain, j)
+			if raw[j].parent == "" {
+				break
+			}
+			p, ok := index[raw[j].parent]
+			if !ok {
+				return nil, errors.New("parent lineage " + raw[j].parent + " of " + raw[j].name + " is not defined")
+			}
+			j = p
+		}
+		depth := len(chain) - 1
+		// a mutation that any lineage in the chain requires is required, even if another lists it as optional
+		required := make([]string, 0)
+		optional := make([]string, 0)
+		seen := make(map[string]bool)
+		for _, j := range chain {
+			for _, m := range raw[j].required {
+				if !seen[m] {
+					seen[m] = true
+					required = append(required, m)
+				}
+			}
+		}
+		for _, j := range chain {
+			for _, m := range raw[j].optional {
+				if !seen[m] {
+					seen[m] = true
+					optional = append(optional, m)
+				}
+			}
+		}
+		req, err := parse(required)
+		if err != nil {
+			return nil, err
+		}
+		opt, err := parse(optional)
+		if err != nil {
+			return nil, err
+		}
+		defs[i] = lineageDef{name: rd.name, parent: rd.parent, required: req, optional: opt, depth: depth}
+	}
+
+	return defs, nil
+}
+
+// assign finds the best-matching lineage for one query. A lineage is a candidate if at most maxMissing of its required
+// mutations are definitely absent from the query. The best candidate has the most matched required mutations, then the
+// fewest missing, then the most matched optional mutations, then is the deepest in the hierarchy, then is first in the file
+func assign(defs []lineageDef, set map[string]bool, ref, query []byte, refToMSA []int, maxMissing int) assignment {
+
+	best := assignment{lineage: "unassigned", missingMuts: []string{}}
+	bestDepth := -1
+	found := false
+
+	for _, def := range defs {
+		a := assignment{lineage: def.name, required: len(def.required), missingMuts: make([]string, 0)}
+		for _, nm := range def.required {
+			switch variants.CallMutation(nm, set, ref, query, refToMSA) {
+			case variants.MutationAlt:
+				a.matched++
+			case variants.MutationAmbiguous:
+				a.ambiguous++
+			default:
+				a.missing++
+				a.missingMuts = append(a.missingMuts, nm.Representation)
+			}
+		}
+		if a.missing > maxMissing {
+			continue
+		}
+		for _, nm := range def.optional {
+			if variants.CallMutation(nm, set, ref, query, refToMSA) == variants.MutationAlt {
+				a.optionalMatched++
+			}
+		}
+		better := !found ||
+			a.matched > best.matched ||
+			(a.matched == best.matched && a.missing < best.missing) ||
+			(a.matched == best.matched && a.missing == best.missing && a.optionalMatched > best.optionalMatched) ||
+			(a.matched == best.matched && a.missing == best.missing && a.optionalMatched == best.optionalMatched && def.depth > bestDepth)
+		if better {
+			best = a
+			bestDepth = def.depth
+			found = true
+		}
+	}
+
+	return best
+}
+
+// assignRecords is a worker function that annotates the mutations in each fasta record from a channel and assigns it to a
+// lineage, and passes the result to another channel
+func assignRecords(ref fasta.EncodedRecord, defs []lineageDef, cdsregions []variants.Region, intregions []int, refToMSA, MSAToRef []int, maxMissing int, cMSA chan fasta.EncodedRecord, cAssignments chan assignment, cErr chan error) {
+	for record := range cMSA {
+		if len(record.Seq) != len(ref.Seq) {
+			cErr <- errors.New("Gapped reference sequence and alignment are not the same width")
+			break
+		}
+		AS, err := variants.GetVariantsPair(ref.Seq, record.Seq, ref.ID, record.ID, record.Idx, cdsregions, intregions, refToMSA, MSAToRef)
+		if err != nil {
+			cErr <- err
+			break
+		}
+		set, err := variants.VariantSet(AS)
+		if err != nil {
+			cErr <- err
+			break
+		}
+		a := assign(defs, set, ref.Seq, record.Seq, refToMSA, maxMissing)
+		a.queryname = record.ID
+		a.idx = record.Idx
+		cAssignments <- a
+	}
+}
+
+// writeAssignments writes the lineage assignments to file or stdout, in the same order as the input alignment. The
+// reference is not written
+func writeAssignments(w io.Writer, refID string, firstmissing bool, cAssignments chan assignment, cWriteDone chan bool, cErr chan error) {
+
+	outputMap := make(map[int]assignment)
+	counter := 0
+	if firstmissing {
+		counter = 1
+	}
+
+	_, err := w.Write([]byte("query,lineage,required,matched,missing,ambiguous,optional_matched,missing_mutations\n"))
+	if err != nil {
+		cErr <- err
+		return
+	}
+
+	for a := range cAssignments {
+		outputMap[a.idx] = a
+		for {
+			if A, ok := outputMap[counter]; ok {
+				if A.queryname != refID {
+					_, err = w.Write([]byte(A.queryname + "," + A.lineage + "," + strconv.Itoa(A.required) + "," + strconv.Itoa(A.matched) + "," +
+						strconv.Itoa(A.missing) + "," + strconv.Itoa(A.ambiguous) + "," + strconv.Itoa(A.optionalMatched) + "," + strings.Join(A.missingMuts, "|") + "\n"))
+					if err != nil {
+						cErr <- err
+						return
+					}
+				}
+				delete(outputMap, counter)
+				counter++
+			} else {
+				break
+			}
+		}
+	}
+
+	cWriteDone <- true
+}
+
+// Assign assigns each record in a fasta-format alignment to the best-matching lineage from a csv file of lineage definitions,
+// given a genbank or gff version 3 format annotation of the reference. It writes, for each record, the lineage and the number of
+// its required mutations that are matched, missing (definitely absent) and ambiguous in the record, as well as the number of
+// optional mutations that are matched. Records that don't match any lineage with at most maxMissing missing mutations are
+// "unassigned"
+func Assign(msaIn io.Reader, stdin bool, refID string, annoIn io.Reader, annoSuffix string, defsIn io.Reader, maxMissing int, out io.Writer, threads int) error {
+
+	cMSA := make(chan fasta.EncodedRecord, 50+threads)
+	cErr := make(chan error)
+	cMSADone := make(chan bool)
+
+	// If we're reading from stdin, the reference has to be the first record (and it doesn't go to the output)
+	ref, firstmissing, err := variants.StreamWithReference(msaIn, stdin, refID, false, cMSA, cErr, cMSADone)
+	if err != nil {
+		return err
+	}
+
+	ref, cdsregions, intregions, err := variants.RegionsFromAnnotation(annoIn, annoSuffix, ref)
+	if err != nil {
+		return err
+	}
+
+	refToMSA, MSAToRef := variants.GetMSAOffsets(ref.Seq)
+
+	defs, err := readDefinitions(defsIn, cdsregions)
+	if err != nil {
+		return err
+	}
+	if len(defs) == 0 {
+		return errors.New("no lineages in --definitions")
+	}
+
+	cAssignments := make(chan assignment, 50+threads)
+	cWriteDone := make(chan bool)
+
+	go writeAssignments(out, refID, firstmissing, cAssignments, cWriteDone, cErr)
+
+	cAssignDone := fasta.StartWorkers(threads, func() {
+		assignRecords(ref, defs, cdsregions, intregions, refToMSA, MSAToRef, maxMissing, cMSA, cAssignments, cErr)
+	})
+
+	return fasta.WaitStages(cErr,
+		fasta.Stage{Done: cMSADone, Close: func() { close(cMSA) }},
+		fasta.Stage{Done: cAssignDone, Close: func() { close(cAssignments) }},
+		fasta.Stage{Done: cWriteDone},
+	)
+}
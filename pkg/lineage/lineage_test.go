@@ -0,0 +1,140 @@
+package lineage
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestAssign(t *testing.T) {
+	msaData := []byte(`>reference
+ACGTAATGATGATGTAGAAAAAA
+>q1
+ACGTAATGCTGATGTAGAATAAA
+>q2
+ACGTAATGATGATGTAGAATAAA
+>q3
+ACGTAATGNTGATGTAGAATAAA
+>q4
+ACCTAATGATGATGTAGAAAAAA
+>q5
+ACGTAATGATGATGTAGAAAAAA
+>q6
+ACGAAATGATGATGTAGAAAAAA
+`)
+
+	defsData := []byte(`lineage,parent,required,optional
+A,,,
+B,A,nuc:A20T,
+B.1,B,aa:gene1:M2L,nuc:A22G
+C,A,nuc:G3C,
+C.1,C,nuc:T4A,
+`)
+
+	msa := bytes.NewReader(msaData)
+	anno := bytes.NewReader(genbankData)
+	defs := bytes.NewReader(defsData)
+	out := new(bytes.Buffer)
+
+	err := Assign(msa, false, "reference", anno, "gb", defs, 0, out, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `query,lineage,required,matched,missing,ambiguous,optional_matched,missing_mutations
+q1,B.1,2,2,0,0,0,
+q2,B,1,1,0,0,0,
+q3,B.1,2,1,0,1,0,
+q4,C,1,1,0,0,0,
+q5,A,0,0,0,0,0,
+q6,A,0,0,0,0,0,
+` {
+		t.Errorf("problem in TestAssign()")
+		fmt.Println(out.String())
+	}
+
+	// allowing one missing mutation
+	msa = bytes.NewReader(msaData)
+	anno = bytes.NewReader(genbankData)
+	defs = bytes.NewReader(defsData)
+	out = new(bytes.Buffer)
+
+	err = Assign(msa, false, "reference", anno, "gb", defs, 1, out, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `query,lineage,required,matched,missing,ambiguous,optional_matched,missing_mutations
+q1,B.1,2,2,0,0,0,
+q2,B,1,1,0,0,0,
+q3,B.1,2,1,0,1,0,
+q4,C,1,1,0,0,0,
+q5,A,0,0,0,0,0,
+q6,C.1,2,1,1,0,0,nuc:G3C
+` {
+		t.Errorf("problem in TestAssign() (max missing 1)")
+		fmt.Println(out.String())
+	}
+}
+
+func TestReadDefinitions(t *testing.T) {
+	_, err := readDefinitions(bytes.NewReader([]byte(`lineage,parent,required,optional
+B,A,nuc:A20T,
+`)), nil)
+	if err == nil {
+		t.Errorf("expected an error in TestReadDefinitions() (undefined parent)")
+	}
+
+	_, err = readDefinitions(bytes.NewReader([]byte(`lineage,parent,required,optional
+A,B,,
+B,A,,
+`)), nil)
+	if err == nil {
+		t.Errorf("expected an error in TestReadDefinitions() (cycle)")
+	}
+
+	defs, err := readDefinitions(bytes.NewReader([]byte(`lineage,parent,required,optional
+A,,nuc:A1T,
+B,A,del:5:3|nuc:A1T,
+`)), nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(defs[1].required) != 2 || defs[1].depth != 1 || defs[1].required[0].Representation != "del:5:3" {
+		t.Errorf("problem in TestReadDefinitions() (inheritance)")
+	}
+
+	// a mutation that the parent requires stays required when the child lists it as optional
+	defs, err = readDefinitions(bytes.NewReader([]byte(`lineage,parent,required,optional
+A,,nuc:A1T,
+B,A,del:5:3,nuc:A1T|nuc:A2T
+`)), nil)
+	if err != nil {
+		t.Error(err)
+	}
+	if len(defs[1].required) != 2 || defs[1].required[1].Representation != "nuc:A1T" ||
+		len(defs[1].optional) != 1 || defs[1].optional[0].Representation != "nuc:A2T" {
+		t.Errorf("problem in TestReadDefinitions() (inherited required)")
+		fmt.Println(defs[1])
+	}
+}
+
+var genbankData []byte
+
+func init() {
+	genbankData = []byte(`LOCUS       TEST               23 bp ss-RNA     linear   VRL 21-MAR-1987
+FEATURES             Location/Qualifiers
+		source          1..23
+						/organism="Not a real organism"
+		5'UTR           1..5
+		gene            6..17
+						/gene="gene1"
+		CDS             6..17
+						/gene="gene1"
+						/codon_start=1
+						/translation="MMM"
+		3'UTR           18..23
+ORIGIN
+		1 acgtaatgat gatgtagaaa aaa
+`)
+}
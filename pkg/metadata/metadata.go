@@ -0,0 +1,96 @@
+/*
+Package metadata implements functionality to read per-sequence metadata from
+a csv file with a header line, for use by other packages.
+*/
+package metadata
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+)
+
+// Table is the contents of a metadata file. Rows are keyed by sequence ID and then by column name,
+// and IDs retains the order of the rows in the file
+type Table struct {
+	Header []string
+	IDs    []string
+	Rows   map[string]map[string]string
+}
+
+// Read reads a csv file with a header line into a Table. idColumn is the name of the column that
+// contains the sequence IDs. If it is "", the first column is used
+func Read(in io.Reader, idColumn string) (Table, error) {
+
+	t := Table{IDs: make([]string, 0), Rows: make(map[string]map[string]string)}
+
+	r := csv.NewReader(in)
+
+	idIdx := 0
+	header := true
+
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return Table{}, err
+		}
+		if header {
+			t.Header = record
+			if idColumn != "" {
+				idIdx = -1
+				for i, name := range record {
+					if name == idColumn {
+						idIdx = i
+						break
+					}
+				}
+				if idIdx == -1 {
+					return Table{}, errors.New("couldn't find ID column \"" + idColumn + "\" in metadata header")
+				}
+			}
+			header = false
+			continue
+		}
+		id := record[idIdx]
+		if _, ok := t.Rows[id]; ok {
+			return Table{}, errors.New("duplicate ID in metadata: " + id)
+		}
+		row := make(map[string]string, len(record))
+		for i, name := range t.Header {
+			row[name] = record[i]
+		}
+		t.IDs = append(t.IDs, id)
+		t.Rows[id] = row
+	}
+
+	if header {
+		return Table{}, errors.New("metadata file is empty")
+	}
+
+	return t, nil
+}
+
+// HasColumn returns true if the Table has a column called name
+func (t Table) HasColumn(name string) bool {
+	for _, h := range t.Header {
+		if h == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Column returns a map of sequence ID to the value of the column called name
+func (t Table) Column(name string) (map[string]string, error) {
+	if !t.HasColumn(name) {
+		return nil, errors.New("couldn't find column \"" + name + "\" in metadata header")
+	}
+	m := make(map[string]string, len(t.IDs))
+	for _, id := range t.IDs {
+		m[id] = t.Rows[id][name]
+	}
+	return m, nil
+}
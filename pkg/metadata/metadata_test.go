@@ -0,0 +1,46 @@
+package metadata
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	metadataData := []byte(`sample,date,lineage
+seq1,2020-01-01,B.1
+seq2,2020-02-01,B.1.1.7
+`)
+
+	table, err := Read(bytes.NewReader(metadataData), "")
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(table.IDs, []string{"seq1", "seq2"}) {
+		t.Errorf("problem in TestRead() (IDs)")
+	}
+	lineages, err := table.Column("lineage")
+	if err != nil {
+		t.Error(err)
+	}
+	if !reflect.DeepEqual(lineages, map[string]string{"seq1": "B.1", "seq2": "B.1.1.7"}) {
+		t.Errorf("problem in TestRead() (Column)")
+	}
+	_, err = table.Column("country")
+	if err == nil {
+		t.Errorf("expected an error in TestRead() (missing column)")
+	}
+
+	table, err = Read(bytes.NewReader(metadataData), "lineage")
+	if err != nil {
+		t.Error(err)
+	}
+	if table.Rows["B.1"]["sample"] != "seq1" {
+		t.Errorf("problem in TestRead() (id column)")
+	}
+
+	_, err = Read(bytes.NewReader([]byte("sample,lineage\nseq1,A\nseq1,B\n")), "")
+	if err == nil {
+		t.Errorf("expected an error in TestRead() (duplicate ID)")
+	}
+}
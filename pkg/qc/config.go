@@ -0,0 +1,124 @@
+package qc
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// MissingDataRule scores the number of missing sites (Ns, and gaps at the ends of the sequence) above scoreBias,
+// relative to threshold
+type MissingDataRule struct {
+	Enabled   bool    `json:"enabled"`
+	ScoreBias float64 `json:"scoreBias"`
+	Threshold float64 `json:"threshold"`
+}
+
+// MixedSitesRule scores the number of ambiguous nucleotides (other than N) relative to threshold
+type MixedSitesRule struct {
+	Enabled   bool    `json:"enabled"`
+	Threshold float64 `json:"threshold"`
+}
+
+// PrivateMutationsRule scores the number of snps relative to the nearest neighbour above typical, relative to cutoff
+type PrivateMutationsRule struct {
+	Enabled bool    `json:"enabled"`
+	Typical float64 `json:"typical"`
+	Cutoff  float64 `json:"cutoff"`
+}
+
+// SNPClustersRule scores the number of clusters of more than clusterCutOff private snps within windowSize nucleotides
+type SNPClustersRule struct {
+	Enabled       bool    `json:"enabled"`
+	WindowSize    int     `json:"windowSize"`
+	ClusterCutOff int     `json:"clusterCutOff"`
+	ScoreWeight   float64 `json:"scoreWeight"`
+}
+
+// FrameShiftsRule scores the number of indels in coding sequence whose length isn't a multiple of three
+type FrameShiftsRule struct {
+	Enabled     bool    `json:"enabled"`
+	ScoreWeight float64 `json:"scoreWeight"`
+}
+
+// StopCodonsRule scores the number of premature stop codons in coding sequence. IgnoredStopCodons are known stops to
+// ignore, in the format CDS:residue, e.g. "ORF8:27"
+type StopCodonsRule struct {
+	Enabled           bool     `json:"enabled"`
+	ScoreWeight       float64  `json:"scoreWeight"`
+	IgnoredStopCodons []string `json:"ignoredStopCodons"`
+}
+
+// Config is the set of qc rules, and the overall scores at which a sequence gets a warning or fails
+type Config struct {
+	Warn             float64              `json:"warn"`
+	Fail             float64              `json:"fail"`
+	MissingData      MissingDataRule      `json:"missingData"`
+	MixedSites       MixedSitesRule       `json:"mixedSites"`
+	PrivateMutations PrivateMutationsRule `json:"privateMutations"`
+	SNPClusters      SNPClustersRule      `json:"snpClusters"`
+	FrameShifts      FrameShiftsRule      `json:"frameShifts"`
+	StopCodons       StopCodonsRule       `json:"stopCodons"`
+}
+
+// DefaultConfig returns the default qc rules, which are Nextclade's for SARS-CoV-2
+func DefaultConfig() Config {
+	return Config{
+		Warn:             30,
+		Fail:             100,
+		MissingData:      MissingDataRule{Enabled: true, ScoreBias: 300, Threshold: 2700},
+		MixedSites:       MixedSitesRule{Enabled: true, Threshold: 10},
+		PrivateMutations: PrivateMutationsRule{Enabled: true, Typical: 8, Cutoff: 24},
+		SNPClusters:      SNPClustersRule{Enabled: true, WindowSize: 100, ClusterCutOff: 6, ScoreWeight: 50},
+		FrameShifts:      FrameShiftsRule{Enabled: true, ScoreWeight: 75},
+		StopCodons:       StopCodonsRule{Enabled: true, ScoreWeight: 75, IgnoredStopCodons: []string{}},
+	}
+}
+
+// ReadConfig reads qc rules in json format. Anything that isn't in the file keeps its value from DefaultConfig
+func ReadConfig(in io.Reader) (Config, error) {
+
+	config := DefaultConfig()
+
+	d := json.NewDecoder(in)
+	d.DisallowUnknownFields()
+	err := d.Decode(&config)
+	if err != nil {
+		return Config{}, errors.New("couldn't parse the qc config: " + err.Error())
+	}
+
+	err = config.check()
+	if err != nil {
+		return Config{}, err
+	}
+
+	return config, nil
+}
+
+// check returns an error if any of the rules' thresholds don't make sense
+func (c Config) check() error {
+	switch {
+	case c.Warn <= 0 || c.Fail < c.Warn:
+		return errors.New("qc config: warn must be > 0 and fail must be >= warn")
+	case c.MissingData.Enabled && c.MissingData.Threshold <= 0:
+		return errors.New("qc config: missingData threshold must be > 0")
+	case c.MixedSites.Enabled && c.MixedSites.Threshold <= 0:
+		return errors.New("qc config: mixedSites threshold must be > 0")
+	case c.PrivateMutations.Enabled && c.PrivateMutations.Cutoff <= 0:
+		return errors.New("qc config: privateMutations cutoff must be > 0")
+	case c.SNPClusters.Enabled && (c.SNPClusters.WindowSize <= 0 || c.SNPClusters.ClusterCutOff <= 0):
+		return errors.New("qc config: snpClusters windowSize and clusterCutOff must be > 0")
+	}
+	for _, s := range c.StopCodons.IgnoredStopCodons {
+		fields := strings.Split(s, ":")
+		if len(fields) != 2 {
+			return errors.New("qc config: couldn't parse ignored stop codon " + s + " (should be CDS:residue)")
+		}
+		if _, err := strconv.Atoi(fields[1]); err != nil {
+			return errors.New("qc config: couldn't parse ignored stop codon " + s + " (should be CDS:residue)")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,372 @@
+/*
+Package qc implements functions to score the quality of each sequence in a fasta format alignment to a reference,
+with rules that are comparable to Nextclade's quality control.
+
+Each rule gives a sequence a score, where 100 is bad:
+
+	missingData      - Ns (and gaps at the ends of the sequence), above scoreBias, as a percentage of threshold
+	mixedSites       - ambiguous nucleotides other than N, as a percentage of threshold
+	privateMutations - snps that aren't in the sequence's nearest neighbour, above typical, as a percentage of cutoff
+	snpClusters      - scoreWeight for each cluster of more than clusterCutOff private snps within windowSize nucleotides
+	frameShifts      - scoreWeight for each indel in coding sequence whose length isn't a multiple of three
+	stopCodons       - scoreWeight for each premature stop codon in coding sequence
+
+The overall score is the sum of the squares of the rules' scores, divided by 100, and a sequence passes if this is
+below warn, fails if it is at least fail, and gets a warning otherwise.
+*/
+package qc
+
+import (
+	"errors"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/virus-evolution/gofasta/pkg/closest"
+	"github.com/virus-evolution/gofasta/pkg/fasta"
+	"github.com/virus-evolution/gofasta/pkg/snps"
+	"github.com/virus-evolution/gofasta/pkg/variants"
+)
+
+// qcResult is one sequence's qc metrics, and the score for each rule (-1 if the rule is disabled)
+type qcResult struct {
+	id               string
+	missing          int
+	missingScore     float64
+	mixed            int
+	mixedScore       float64
+	neighbour        string
+	private          []string
+	privateScore     float64
+	clusters         int
+	clustersScore    float64
+	frameshifts      []string
+	frameshiftsScore float64
+	stops            []string
+	stopsScore       float64
+	score            float64
+	status           string
+}
+
+func (r MissingDataRule) score(missing int) float64 {
+	if !r.Enabled {
+		return -1
+	}
+	excess := float64(missing) - r.ScoreBias
+	if excess < 0 {
+		excess = 0
+	}
+	return excess * 100 / r.Threshold
+}
+
+func (r MixedSitesRule) score(mixed int) float64 {
+	if !r.Enabled {
+		return -1
+	}
+	return float64(mixed) * 100 / r.Threshold
+}
+
+func (r PrivateMutationsRule) score(private int) float64 {
+	if !r.Enabled {
+		return -1
+	}
+	excess := float64(private) - r.Typical
+	if excess < 0 {
+		excess = 0
+	}
+	return excess * 100 / r.Cutoff
+}
+
+func (r SNPClustersRule) score(clusters int) float64 {
+	if !r.Enabled {
+		return -1
+	}
+	return float64(clusters) * r.ScoreWeight
+}
+
+func (r FrameShiftsRule) score(frameshifts int) float64 {
+	if !r.Enabled {
+		return -1
+	}
+	return float64(frameshifts) * r.ScoreWeight
+}
+
+func (r StopCodonsRule) score(stops int) float64 {
+	if !r.Enabled {
+		return -1
+	}
+	return float64(stops) * r.ScoreWeight
+}
+
+// siteCounts returns the number of missing sites in a query, which are Ns, ?s and the gaps at either end of it, and
+// the number of mixed sites, which are ambiguous nucleotides other than N. Only sites in the reference are counted
+func siteCounts(ref []byte, query fasta.EncodedRecord) (int, int) {
+
+	first, last := -1, -1
+	for i, nuc := range query.Seq {
+		if nuc != 244 {
+			if first == -1 {
+				first = i
+			}
+			last = i
+		}
+	}
+
+	missing, mixed := 0, 0
+	for i, nuc := range query.Seq {
+		if ref[i] == 244 {
+			continue
+		}
+		switch {
+		case nuc == 240 || nuc == 242:
+			missing++
+		case nuc == 244:
+			if i < first || i > last {
+				missing++
+			}
+		case nuc&8 != 8:
+			mixed++
+		}
+	}
+
+	return missing, mixed
+}
+
+// nearestNeighbour returns the index of the closest record to query in neighbours by snp distance, ignoring records
+// with the same ID as query, or -1 if there aren't any. Ties go to the first record
+func nearestNeighbour(query fasta.EncodedRecord, neighbours []fasta.EncodedRecord, distance func(query, target fasta.EncodedRecord) float64) int {
+	nearest := -1
+	var min float64
+	for i, n := range neighbours {
+		if n.ID == query.ID {
+			continue
+		}
+		d := distance(query, n)
+		if nearest == -1 || d < min {
+			nearest = i
+			min = d
+		}
+	}
+	return nearest
+}
+
+// countClusters returns the number of clusters of more than cutoff snps (at sorted positions) within window
+// nucleotides of each other. Overlapping windows are part of the same cluster
+func countClusters(positions []int, window, cutoff int) int {
+
+	clusters := 0
+	clusterEnd := -1
+
+	j := 0
+	for i, pos := range positions {
+		for pos-positions[j] >= window {
+			j++
+		}
+		if i-j+1 > cutoff {
+			if clusterEnd == -1 || positions[j] > clusterEnd {
+				clusters++
+			}
+			clusterEnd = pos
+		}
+	}
+
+	return clusters
+}
+
+// cdsFlags returns the indels that shift the frame of a CDS and the premature stop codons in a query's variants, as
+// formatted by variants.FormatCDSFlag, except for the stops that are ignored (as cds:residue, in lower case)
+func cdsFlags(vs []variants.Variant, cdsregions []variants.Region, ignored map[string]bool) ([]string, []string) {
+
+	shifts := make([]string, 0)
+	stops := make([]string, 0)
+
+	for _, f := range variants.GetCDSFlags(vs, cdsregions, 0, 0, true) {
+		switch {
+		case f.Flagtype == "frameshift":
+			shifts = append(shifts, variants.FormatCDSFlag(f))
+		case f.Flagtype == "stop" && !ignored[strings.ToLower(f.Feature)+":"+strconv.Itoa(f.Residue)]:
+			stops = append(stops, variants.FormatCDSFlag(f))
+		}
+	}
+
+	return shifts, stops
+}
+
+// status returns whether an overall score passes, gets a warning or fails
+func (c Config) status(score float64) string {
+	switch {
+	case score >= c.Fail:
+		return "fail"
+	case score >= c.Warn:
+		return "warn"
+	default:
+		return "pass"
+	}
+}
+
+// getQCResult scores one record against the rules in config
+func getQCResult(ref fasta.EncodedRecord, record fasta.EncodedRecord, neighbours []fasta.EncodedRecord, cdsregions []variants.Region, intregions []int, refToMSA, MSAToRef []int, ignored map[string]bool, config Config) (qcResult, error) {
+
+	distance, err := closest.Measure("snp")
+	if err != nil {
+		return qcResult{}, err
+	}
+
+	result := qcResult{id: record.ID}
+
+	result.missing, result.mixed = siteCounts(ref.Seq, record)
+	result.missingScore = config.MissingData.score(result.missing)
+	result.mixedScore = config.MixedSites.score(result.mixed)
+
+	var neighbour snps.Profile
+	if nearest := nearestNeighbour(record, neighbours, distance); nearest != -1 {
+		result.neighbour = neighbours[nearest].ID
+		neighbour = snps.ProfileFromAlignment(ref.Seq, neighbours[nearest].Seq)
+	}
+	private, _, _ := snps.Private(snps.ProfileFromAlignment(ref.Seq, record.Seq), neighbour)
+	result.private = private.SNPs
+	result.privateScore = config.PrivateMutations.score(len(result.private))
+
+	if config.SNPClusters.Enabled {
+		result.clusters = countClusters(private.Positions, config.SNPClusters.WindowSize, config.SNPClusters.ClusterCutOff)
+	}
+	result.clustersScore = config.SNPClusters.score(result.clusters)
+
+	AS, err := variants.GetVariantsPair(ref.Seq, record.Seq, ref.ID, record.ID, record.Idx, cdsregions, intregions, refToMSA, MSAToRef)
+	if err != nil {
+		return qcResult{}, err
+	}
+	result.frameshifts, result.stops = cdsFlags(AS.Vs, cdsregions, ignored)
+	result.frameshiftsScore = config.FrameShifts.score(len(result.frameshifts))
+	result.stopsScore = config.StopCodons.score(len(result.stops))
+
+	for _, s := range []float64{result.missingScore, result.mixedScore, result.privateScore, result.clustersScore, result.frameshiftsScore, result.stopsScore} {
+		if s > 0 {
+			result.score += s * s / 100
+		}
+	}
+	result.status = config.status(result.score)
+
+	return result, nil
+}
+
+// formatScore returns a rule's score, or nothing if the rule is disabled
+func formatScore(score float64) string {
+	if score < 0 {
+		return ""
+	}
+	return strconv.FormatFloat(score, 'f', 2, 64)
+}
+
+// writeQC writes every sequence's qc metrics and scores, in input order
+func writeQC(w io.Writer, results []qcResult) error {
+
+	_, err := w.Write([]byte("query,missing_data,missing_data_score,mixed_sites,mixed_sites_score,nearest_neighbour,private_mutations,private_mutations_score," +
+		"snp_clusters,snp_clusters_score,frameshifts,frameshifts_score,stop_codons,stop_codons_score,score,status\n"))
+	if err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		_, err = w.Write([]byte(r.id + "," +
+			strconv.Itoa(r.missing) + "," + formatScore(r.missingScore) + "," +
+			strconv.Itoa(r.mixed) + "," + formatScore(r.mixedScore) + "," +
+			r.neighbour + "," + strings.Join(r.private, "|") + "," + formatScore(r.privateScore) + "," +
+			strconv.Itoa(r.clusters) + "," + formatScore(r.clustersScore) + "," +
+			strings.Join(r.frameshifts, "|") + "," + formatScore(r.frameshiftsScore) + "," +
+			strings.Join(r.stops, "|") + "," + formatScore(r.stopsScore) + "," +
+			strconv.FormatFloat(r.score, 'f', 2, 64) + "," + r.status + "\n"))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// QC scores every record in a fasta-format alignment against the rules in config, and writes its metrics, the score
+// for each rule, an overall score and a pass/warn/fail status to out. The reference is the record in the alignment
+// with ID refID, or the sequence in the annotation if refID is "". Private mutations are relative to each record's
+// nearest neighbour (by snp distance) in neighboursIn, or in the alignment itself if neighboursIn is nil. The
+// alignment is held in memory
+func QC(msaIn io.Reader, refID string, annoIn io.Reader, annoSuffix string, neighboursIn io.Reader, config Config, out io.Writer, threads int) error {
+
+	if threads == 0 {
+		threads = runtime.NumCPU()
+	}
+
+	err := config.check()
+	if err != nil {
+		return err
+	}
+
+	records, err := fasta.LoadEncodeAlignment(msaIn, false, false, false)
+	if err != nil {
+		return err
+	}
+
+	var ref fasta.EncodedRecord
+	queries := make([]fasta.EncodedRecord, 0, len(records))
+	for _, record := range records {
+		if refID != "" && record.ID == refID {
+			ref = record
+			continue
+		}
+		queries = append(queries, record)
+	}
+	if refID != "" && len(ref.Seq) == 0 {
+		return errors.New("couldn't find --reference in --msa")
+	}
+
+	ref, cdsregions, intregions, err := variants.RegionsFromAnnotation(annoIn, annoSuffix, ref)
+	if err != nil {
+		return err
+	}
+	if len(ref.Seq) != len(records[0].Seq) {
+		return errors.New("the reference and --msa are not the same width")
+	}
+
+	refToMSA, MSAToRef := variants.GetMSAOffsets(ref.Seq)
+
+	neighbours := queries
+	if neighboursIn != nil {
+		neighbours, err = fasta.LoadEncodeAlignment(neighboursIn, false, false, false)
+		if err != nil {
+			return err
+		}
+		if len(neighbours[0].Seq) != len(ref.Seq) {
+			return errors.New("--neighbours and --msa are not the same width")
+		}
+	}
+
+	ignored := make(map[string]bool)
+	for _, s := range config.StopCodons.IgnoredStopCodons {
+		ignored[strings.ToLower(s)] = true
+	}
+
+	results := make([]qcResult, len(queries))
+	errs := make([]error, len(queries))
+
+	cIdx := make(chan int, len(queries))
+	for i := range queries {
+		cIdx <- i
+	}
+	close(cIdx)
+
+	cDone := fasta.StartWorkers(threads, func() {
+		for i := range cIdx {
+			results[i], errs[i] = getQCResult(ref, queries[i], neighbours, cdsregions, intregions, refToMSA, MSAToRef, ignored, config)
+		}
+	})
+
+	<-cDone
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return writeQC(out, results)
+}
@@ -0,0 +1,103 @@
+package qc
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+var msaData = []byte(`>ref
+CCCATGGCTGCTGCTGCTGCTGCTGCTGCTTAACCCCCCC
+>q1
+CCCATGGCTGCTGCTGCTGCTGCTGCTGCTTAACCCCCCC
+>q2
+CCCATGGCTTAGGCTGCTG-TGCTGATGCTTAACCCCCCC
+>q3
+---ATGGCTGCTGCNNNNNNTGRTGCTGYTTAACCCCTTC
+>q4
+CCCAGGACGGGTGCTGCTGCTGCTGCTGCTTAACCCCCCC
+`)
+
+var gffData = []byte(`##gff-version 3
+##sequence-region ref 1 40
+ref	RefSeq	region	1	40	.	+	.	ID=ref:1..40
+ref	RefSeq	gene	4	33	.	+	.	ID=gene1
+ref	RefSeq	CDS	4	33	.	+	0	ID=CDS-gene1;Parent=gene1;Name=gene1
+`)
+
+func TestQC(t *testing.T) {
+	config, err := ReadConfig(bytes.NewReader([]byte(`{
+	"missingData": {"scoreBias": 0, "threshold": 20},
+	"mixedSites": {"threshold": 4},
+	"privateMutations": {"typical": 0, "cutoff": 10},
+	"snpClusters": {"windowSize": 10, "clusterCutOff": 3}
+}`)))
+	if err != nil {
+		t.Error(err)
+	}
+
+	out := new(bytes.Buffer)
+
+	err = QC(bytes.NewReader(msaData), "ref", bytes.NewReader(gffData), "gff", nil, config, out, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `query,missing_data,missing_data_score,mixed_sites,mixed_sites_score,nearest_neighbour,private_mutations,private_mutations_score,snp_clusters,snp_clusters_score,frameshifts,frameshifts_score,stop_codons,stop_codons_score,score,status
+q1,0,0.00,0,0.00,q3,,0.00,0,0.00,,0.00,,0.00,0.00,pass
+q2,0,0.00,0,0.00,q1,G10T|C11A|T12G|C26A,40.00,0,0.00,frameshift:gene1:-1,75.00,stop:gene1:3:2,75.00,128.50,fail
+q3,9,45.00,2,50.00,q1,C38T|C39T,20.00,0,0.00,,0.00,,0.00,49.25,warn
+q4,0,0.00,0,0.00,q1,T5G|G7A|T9G|C11G,40.00,1,50.00,,0.00,,0.00,41.00,warn
+` {
+		t.Errorf("problem in TestQC()")
+		fmt.Println(out.String())
+	}
+}
+
+func TestQCDisabledRules(t *testing.T) {
+	config, err := ReadConfig(bytes.NewReader([]byte(`{
+	"frameShifts": {"enabled": false},
+	"stopCodons": {"ignoredStopCodons": ["GENE1:3"]}
+}`)))
+	if err != nil {
+		t.Error(err)
+	}
+
+	neighbours := []byte(`>n1
+CCCATGGCTTAGGCTGCTGCTGCTGATGCTTAACCCCCCC
+`)
+
+	out := new(bytes.Buffer)
+
+	err = QC(bytes.NewReader(msaData), "ref", bytes.NewReader(gffData), "gff", bytes.NewReader(neighbours), config, out, 1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `query,missing_data,missing_data_score,mixed_sites,mixed_sites_score,nearest_neighbour,private_mutations,private_mutations_score,snp_clusters,snp_clusters_score,frameshifts,frameshifts_score,stop_codons,stop_codons_score,score,status
+q1,0,0.00,0,0.00,n1,,0.00,0,0.00,,,,0.00,0.00,pass
+q2,0,0.00,0,0.00,n1,,0.00,0,0.00,frameshift:gene1:-1,,,0.00,0.00,pass
+q3,9,0.00,2,20.00,n1,C38T|C39T,0.00,0,0.00,,,,0.00,4.00,pass
+q4,0,0.00,0,0.00,n1,T5G|G7A|T9G|C11G,0.00,0,0.00,,,,0.00,0.00,pass
+` {
+		t.Errorf("problem in TestQCDisabledRules()")
+		fmt.Println(out.String())
+	}
+}
+
+func TestReadConfig(t *testing.T) {
+	_, err := ReadConfig(bytes.NewReader([]byte(`{"missingDataThreshold": 100}`)))
+	if err == nil {
+		t.Errorf("problem in TestReadConfig(): expected an error for an unknown field")
+	}
+
+	_, err = ReadConfig(bytes.NewReader([]byte(`{"warn": 100, "fail": 30}`)))
+	if err == nil {
+		t.Errorf("problem in TestReadConfig(): expected an error when fail < warn")
+	}
+
+	_, err = ReadConfig(bytes.NewReader([]byte(`{"stopCodons": {"ignoredStopCodons": ["ORF8"]}}`)))
+	if err == nil {
+		t.Errorf("problem in TestReadConfig(): expected an error for a badly formatted ignored stop codon")
+	}
+}
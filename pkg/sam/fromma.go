@@ -0,0 +1,413 @@
+package sam
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/virus-evolution/gofasta/pkg/fasta"
+	"github.com/virus-evolution/gofasta/pkg/variants"
+
+	"github.com/biogo/hts/bam"
+	biogosam "github.com/biogo/hts/sam"
+)
+
+/*
+Converting a fasta alignment to SAM/BAM
+
+Each query in the alignment is compared to the reference row, column by column:
+
+	reference base, query base - M (match or mismatch)
+	reference gap,  query base - I (insertion)
+	reference base, query gap  - D (deletion)
+	reference gap,  query gap  - nothing
+
+The alignment starts at the first column where the query and the reference both have a base, and ends at the last
+one. Query bases outside it are soft clipped. Internal runs of Ns in the query that are at least skipNs reference
+positions long (if skipNs > 0) are written as N (skipped reference) operations instead of matches, and their Ns are
+left out of SEQ, which reverses sam toMultiAlign's padding of skipped regions with Ns.
+*/
+
+// FromMultiAlignFormats are the ways that sam fromMultiAlign can write its output
+var FromMultiAlignFormats = []string{"sam", "bam"}
+
+// checkFromMultiAlignFormat returns an error if format is not one of FromMultiAlignFormats
+func checkFromMultiAlignFormat(format string) error {
+	for _, f := range FromMultiAlignFormats {
+		if format == f {
+			return nil
+		}
+	}
+	return errors.New("unknown output format: " + format + " (must be one of: " + strings.Join(FromMultiAlignFormats, ", ") + ")")
+}
+
+// fromMAResult is one query's SAM record. rec is nil for the reference row, which isn't written
+type fromMAResult struct {
+	idx int
+	rec *biogosam.Record
+}
+
+// recordWriter is a sam.Writer or a bam.Writer
+type recordWriter interface {
+	Write(r *biogosam.Record) error
+}
+
+// isQueryN asks if a query base is an N (missing data)
+func isQueryN(b byte) bool {
+	return b == 'N' || b == 'n'
+}
+
+// columnOps returns the cigar operation for each column of the alignment of query to ref, as a byte that is one of M,
+// I, D or S, or 0 if both sequences are gaps (or the query is a gap outside its alignment). It returns false if the
+// query and the reference don't share a base in any column
+func columnOps(ref, query []byte) ([]byte, bool) {
+
+	first, last := -1, -1
+	for i := range query {
+		if ref[i] != '-' && query[i] != '-' {
+			if first == -1 {
+				first = i
+			}
+			last = i
+		}
+	}
+
+	ops := make([]byte, len(query))
+	if first == -1 {
+		return ops, false
+	}
+
+	for i := range query {
+		switch {
+		case query[i] == '-':
+			if ref[i] != '-' && i > first && i < last {
+				ops[i] = 'D'
+			}
+		case i < first || i > last:
+			ops[i] = 'S'
+		case ref[i] == '-':
+			ops[i] = 'I'
+		default:
+			ops[i] = 'M'
+		}
+	}
+
+	return ops, true
+}
+
+// skipNs changes internal runs of match columns where the query is N into skipped (N) columns, if they cover at least
+// minLength reference positions. Deletions inside a run don't break it, but insertions do
+func skipNs(ops []byte, query []byte, minLength int) {
+
+	first, last := -1, -1
+	for i, op := range ops {
+		if op == 'M' {
+			if first == -1 {
+				first = i
+			}
+			last = i
+		}
+	}
+
+	for i := first + 1; i < last; i++ {
+		if ops[i] != 'M' || !isQueryN(query[i]) {
+			continue
+		}
+		end := i
+		for j := i; j < last; j++ {
+			if ops[j] == 'M' && isQueryN(query[j]) {
+				end = j
+			} else if ops[j] != 'D' && ops[j] != 0 {
+				break
+			}
+		}
+		length := 0
+		for j := i; j <= end; j++ {
+			if ops[j] == 'M' || ops[j] == 'D' {
+				length++
+			}
+		}
+		if length >= minLength {
+			for j := i; j <= end; j++ {
+				if ops[j] == 'M' || ops[j] == 'D' {
+					ops[j] = 'N'
+				}
+			}
+		}
+		i = end
+	}
+}
+
+// alignedRecordToSam returns the SAM record for one query in a fasta alignment, with its NM and MD tags. refSeq is the
+// reference's (only) entry in the header. Queries with no bases aligned to the reference are unmapped
+func alignedRecordToSam(ref, query fasta.Record, refSeq *biogosam.Reference, minNs int) (*biogosam.Record, error) {
+
+	refBytes := []byte(ref.Seq)
+	queryBytes := []byte(query.Seq)
+
+	ops, mapped := columnOps(refBytes, queryBytes)
+
+	if !mapped {
+		seq := make([]byte, 0, len(queryBytes))
+		for _, b := range queryBytes {
+			if b != '-' {
+				seq = append(seq, b)
+			}
+		}
+		// NewRecord won't make a record without SEQ, which a query that is all gaps has
+		return &biogosam.Record{Name: query.ID, Pos: -1, MatePos: -1, Flags: biogosam.Unmapped, Seq: biogosam.NewSeq(seq)}, nil
+	}
+
+	if minNs > 0 {
+		skipNs(ops, queryBytes, minNs)
+	}
+
+	cigar := make([]biogosam.CigarOp, 0)
+	seq := make([]byte, 0, len(queryBytes))
+
+	var md strings.Builder
+	matches := 0
+	inDeletion := false
+	nm := 0
+
+	pos := -1
+	refPos := 0
+
+	var lastOp byte
+	size := 0
+
+	for i, op := range ops {
+
+		if refBytes[i] != '-' {
+			if pos == -1 && op == 'M' {
+				pos = refPos
+			}
+			refPos++
+		}
+
+		if op == 0 {
+			continue
+		}
+
+		switch op {
+		case 'M':
+			if toUpper(queryBytes[i]) == toUpper(refBytes[i]) {
+				matches++
+			} else {
+				md.WriteString(strconv.Itoa(matches))
+				md.WriteByte(toUpper(refBytes[i]))
+				matches = 0
+				nm++
+			}
+			inDeletion = false
+			seq = append(seq, queryBytes[i])
+		case 'I':
+			nm++
+			inDeletion = false
+			seq = append(seq, queryBytes[i])
+		case 'D':
+			if !inDeletion {
+				md.WriteString(strconv.Itoa(matches))
+				md.WriteByte('^')
+				matches = 0
+				inDeletion = true
+			}
+			md.WriteByte(toUpper(refBytes[i]))
+			nm++
+		case 'S':
+			seq = append(seq, queryBytes[i])
+		case 'N':
+			inDeletion = false
+		}
+
+		if op != lastOp && size > 0 {
+			cigar = append(cigar, biogosam.NewCigarOp(cigarType(lastOp), size))
+			size = 0
+		}
+		lastOp = op
+		size++
+	}
+	if size > 0 {
+		cigar = append(cigar, biogosam.NewCigarOp(cigarType(lastOp), size))
+	}
+	md.WriteString(strconv.Itoa(matches))
+
+	NM, err := biogosam.NewAux(biogosam.NewTag("NM"), nm)
+	if err != nil {
+		return nil, err
+	}
+	MD, err := biogosam.NewAux(biogosam.NewTag("MD"), md.String())
+	if err != nil {
+		return nil, err
+	}
+
+	return biogosam.NewRecord(query.ID, refSeq, nil, pos, -1, 0, 255, cigar, seq, nil, []biogosam.Aux{NM, MD})
+}
+
+// cigarType returns the cigar operation type for one of the bytes that columnOps and skipNs use
+func cigarType(op byte) biogosam.CigarOpType {
+	switch op {
+	case 'I':
+		return biogosam.CigarInsertion
+	case 'D':
+		return biogosam.CigarDeletion
+	case 'N':
+		return biogosam.CigarSkipped
+	case 'S':
+		return biogosam.CigarSoftClipped
+	default:
+		return biogosam.CigarMatch
+	}
+}
+
+// getSamFromAlignment is a worker function that converts each query in a fasta alignment from a channel to SAM
+func getSamFromAlignment(ref fasta.Record, refSeq *biogosam.Reference, minNs int, cFR chan fasta.Record, cResults chan fromMAResult, cErr chan error) {
+
+	for FR := range cFR {
+		if FR.ID == ref.ID {
+			cResults <- fromMAResult{idx: FR.Idx}
+			continue
+		}
+		rec, err := alignedRecordToSam(ref, FR, refSeq, minNs)
+		if err != nil {
+			cErr <- errors.New(FR.ID + ": " + err.Error())
+			return
+		}
+		cResults <- fromMAResult{idx: FR.Idx, rec: rec}
+	}
+}
+
+// writeSamFromAlignment writes the SAM records to w, in input order, starting with the record with index start
+func writeSamFromAlignment(w recordWriter, start int, cResults chan fromMAResult, cErr chan error, cWriteDone chan bool) {
+
+	outputMap := make(map[int]fromMAResult)
+	counter := start
+
+	for result := range cResults {
+		outputMap[result.idx] = result
+		for {
+			r, ok := outputMap[counter]
+			if !ok {
+				break
+			}
+			if r.rec != nil {
+				err := w.Write(r.rec)
+				if err != nil {
+					cErr <- err
+					return
+				}
+			}
+			delete(outputMap, counter)
+			counter++
+		}
+	}
+
+	cWriteDone <- true
+}
+
+// FromMultiAlign converts a fasta alignment to SAM or BAM format (format is one of FromMultiAlignFormats), which is
+// the reverse of ToMultiAlign. Every query in msaIn is written as a record aligned to the record with ID refID, with
+// a cigar, NM and MD tags calculated from the columns of the alignment. If stdin is true, refID must be the first
+// record in the alignment; otherwise msaIn must be seekable. Runs of at least skipNs Ns within the query are written
+// as skipped regions, if skipNs > 0
+func FromMultiAlign(msaIn io.Reader, stdin bool, refID string, out io.Writer, format string, skipNs int, threads int) error {
+
+	err := checkFromMultiAlignFormat(format)
+	if err != nil {
+		return err
+	}
+	if skipNs < 0 {
+		return errors.New("--skip-ns must be >= 0")
+	}
+	if refID == "" {
+		return errors.New("no --reference was given")
+	}
+
+	var ref fasta.Record
+
+	encodedRef, err := variants.FindAndRewind(msaIn, stdin, refID)
+	if err != nil {
+		return err
+	}
+	ref = encodedRef.Decode()
+
+	cFR := make(chan fasta.Record, 50+threads)
+	cErr := make(chan error)
+	cReadDone := make(chan bool)
+
+	go fasta.StreamAlignment(msaIn, cFR, cErr, cReadDone)
+
+	// If we're reading from stdin, the reference has to be the first record
+	start := 0
+	if stdin {
+		ref, err = fasta.FirstRecord(cFR, cErr, cReadDone)
+		if err != nil {
+			return err
+		}
+		if ref.ID != refID {
+			return errors.New("--reference is not the first record in --msa")
+		}
+		start = 1
+	}
+
+	refLen := 0
+	for i := 0; i < len(ref.Seq); i++ {
+		if ref.Seq[i] != '-' {
+			refLen++
+		}
+	}
+
+	refSeq, err := biogosam.NewReference(ref.ID, "", "", refLen, nil, nil)
+	if err != nil {
+		return err
+	}
+	header, err := biogosam.NewHeader(nil, []*biogosam.Reference{refSeq})
+	if err != nil {
+		return err
+	}
+	header.Version = "1.6"
+	header.SortOrder = biogosam.Unsorted
+
+	var w recordWriter
+	var closer io.Closer
+
+	switch format {
+	case "bam":
+		bw, err := bam.NewWriter(out, header, threads)
+		if err != nil {
+			return err
+		}
+		w, closer = bw, bw
+	default:
+		sw, err := biogosam.NewWriter(out, header, biogosam.FlagDecimal)
+		if err != nil {
+			return err
+		}
+		w = sw
+	}
+
+	cResults := make(chan fromMAResult, 50+threads)
+	cWriteDone := make(chan bool)
+
+	go writeSamFromAlignment(w, start, cResults, cErr, cWriteDone)
+
+	cWaitGroupDone := fasta.StartWorkers(threads, func() {
+		getSamFromAlignment(ref, refSeq, skipNs, cFR, cResults, cErr)
+	})
+
+	err = fasta.WaitStages(cErr,
+		fasta.Stage{Done: cReadDone, Close: func() { close(cFR) }},
+		fasta.Stage{Done: cWaitGroupDone, Close: func() { close(cResults) }},
+		fasta.Stage{Done: cWriteDone},
+	)
+	if err != nil {
+		return err
+	}
+
+	if closer != nil {
+		return closer.Close()
+	}
+
+	return nil
+}
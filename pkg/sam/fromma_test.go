@@ -0,0 +1,83 @@
+package sam
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestFromMultiAlign(t *testing.T) {
+	msaData := []byte(`>q1
+----GTTCAAGT-CGTANNNNNGT
+>ref
+--ACGTAC--GTACGTACGTACGT
+>q2
+------------------------
+>q3
+CCTTGTAC--GTACGTACGTAC--
+`)
+
+	out := new(bytes.Buffer)
+
+	err := FromMultiAlign(bytes.NewReader(msaData), false, "ref", out, "sam", 0, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `@HD	VN:1.6	SO:unsorted
+@SQ	SN:ref	LN:20
+q1	0	ref	3	255	4M2I2M1D11M	*	0	0	GTTCAAGTCGTANNNNNGT	*	NM:i:9	MD:Z:2A3^A4C0G0T0A0C2
+q2	4	*	0	0	*	*	0	0	*	*
+q3	0	ref	1	255	2S18M	*	0	0	CCTTGTACGTACGTACGTAC	*	NM:i:2	MD:Z:0A0C16
+` {
+		t.Errorf("problem in TestFromMultiAlign()")
+		fmt.Println(out.String())
+	}
+
+	msaData = []byte(`>ref
+--ACGTAC--GTACGTACGTACGT
+>q1
+----GTTCAAGT-CGTANNNNNGT
+`)
+
+	out = new(bytes.Buffer)
+
+	err = FromMultiAlign(bytes.NewReader(msaData), true, "ref", out, "sam", 3, 1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `@HD	VN:1.6	SO:unsorted
+@SQ	SN:ref	LN:20
+q1	0	ref	3	255	4M2I2M1D4M5N2M	*	0	0	GTTCAAGTCGTAGT	*	NM:i:4	MD:Z:2A3^A6
+` {
+		t.Errorf("problem in TestFromMultiAlign()")
+		fmt.Println(out.String())
+	}
+
+	msaData = []byte(`>ref
+ACGTA-CGTACG
+>q1
+ACGT-T-GTACG
+`)
+
+	out = new(bytes.Buffer)
+
+	err = FromMultiAlign(bytes.NewReader(msaData), true, "ref", out, "sam", 0, 1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `@HD	VN:1.6	SO:unsorted
+@SQ	SN:ref	LN:11
+q1	0	ref	1	255	4M1D1I1D5M	*	0	0	ACGTTGTACG	*	NM:i:3	MD:Z:4^A0^C5
+` {
+		t.Errorf("problem in TestFromMultiAlign() (insertion inside a deletion)")
+		fmt.Println(out.String())
+	}
+
+	err = FromMultiAlign(bytes.NewReader(msaData), true, "q1", out, "sam", 0, 1)
+	if err == nil {
+		t.Errorf("problem in TestFromMultiAlign(): expected an error when the reference is not the first record")
+	}
+}
@@ -53,17 +53,13 @@ func getSamRecords(in io.Reader, chnl chan biogosam.Record, cdone chan bool, cer
 
 		} else {
 			// if this read is unmapped, then skip it.
-			// the third bit (== 4) in the sam flag is set if the read is unmapped,
-			// can use the rightshift method to check this:
-			if ((rec.Flags >> 2) & 1) == 1 {
+			if isUnmapped(*rec) {
 				os.Stderr.WriteString("skipping unmapped read: " + rec.Name + "\n")
 				continue
 			}
 
 			// if this mapping is secondary, then skip it.
-			// the 9th bit (== 256) in the sam flag is set if the mapping is secondary,
-			// can use the rightshift method to check this:
-			if ((rec.Flags >> 8) & 1) == 1 {
+			if isSecondary(*rec) {
 				os.Stderr.WriteString("ignoring secondary mapping: " + rec.Name + "\n")
 				continue
 			}
@@ -76,53 +72,71 @@ func getSamRecords(in io.Reader, chnl chan biogosam.Record, cdone chan bool, cer
 	cdone <- true
 }
 
-// getIndels parses sam records from a channel and passes info about insertions and deletions in each to separate
-// channels of insertion and deletion structs
-func getIndels(cSR chan biogosam.Record, cIns chan insOccurrence, cDel chan delOccurrence, cErr chan error) {
+// recordIndels returns the insertions and deletions in one sam record, in the order that they are in its cigar.
+// Their starts are the 0-based reference position that they precede (insertions) or begin at (deletions)
+func recordIndels(samLine biogosam.Record) ([]insOccurrence, []delOccurrence, error) {
 
 	lambda_dict := getCigarOperationMapNoInsertions()
 
-	var ins insOccurrence
-	var del delOccurrence
+	insertions := make([]insOccurrence, 0)
+	deletions := make([]delOccurrence, 0)
 
-	for samLine := range cSR {
+	QNAME := samLine.Name
 
-		QNAME := samLine.Name
+	POS := samLine.Pos
 
-		POS := samLine.Pos
+	if POS < 0 {
+		return insertions, deletions, errors.New("unmapped read")
+	}
 
-		if POS < 0 {
-			cErr <- errors.New("unmapped read")
-			return
+	SEQ := samLine.Seq.Expand()
+
+	CIGAR := samLine.Cigar
+
+	qstart := 0
+	rstart := POS
+
+	for _, op := range CIGAR {
+
+		operation := op.Type().String()
+		size := op.Len()
+
+		if operation == "I" {
+			insertions = append(insertions, insOccurrence{query: QNAME, start: rstart, seq: string(SEQ[qstart : qstart+size])})
 		}
 
-		SEQ := samLine.Seq.Expand()
+		if operation == "D" {
+			deletions = append(deletions, delOccurrence{query: QNAME, start: rstart, length: size})
+		}
 
-		CIGAR := samLine.Cigar
+		new_qstart, new_rstart, _ := lambda_dict[operation](qstart, rstart, size, SEQ)
 
-		qstart := 0
-		rstart := POS
+		qstart = new_qstart
+		rstart = new_rstart
 
-		for _, op := range CIGAR {
+	}
 
-			operation := op.Type().String()
-			size := op.Len()
+	return insertions, deletions, nil
+}
 
-			if operation == "I" {
-				ins = insOccurrence{query: QNAME, start: rstart, seq: string(SEQ[qstart : qstart+size])}
-				cIns <- ins
-			}
+// getIndels parses sam records from a channel and passes info about insertions and deletions in each to separate
+// channels of insertion and deletion structs
+func getIndels(cSR chan biogosam.Record, cIns chan insOccurrence, cDel chan delOccurrence, cErr chan error) {
 
-			if operation == "D" {
-				del = delOccurrence{query: QNAME, start: rstart, length: size}
-				cDel <- del
-			}
+	for samLine := range cSR {
 
-			new_qstart, new_rstart, _ := lambda_dict[operation](qstart, rstart, size, SEQ)
+		insertions, deletions, err := recordIndels(samLine)
+		if err != nil {
+			cErr <- err
+			return
+		}
 
-			qstart = new_qstart
-			rstart = new_rstart
+		for _, ins := range insertions {
+			cIns <- ins
+		}
 
+		for _, del := range deletions {
+			cDel <- del
 		}
 	}
 
@@ -0,0 +1,275 @@
+package sam
+
+import (
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/virus-evolution/gofasta/pkg/fasta"
+
+	biogosam "github.com/biogo/hts/sam"
+)
+
+/*
+Insertion-preserving alignments
+
+Every insertion relative to the reference that is found in any record is given its own columns in the output, which
+are placed immediately before the reference position that the insertion precedes. The number of columns at each
+insertion site is the length of the longest insertion there. Inserted bases are left-justified within these columns
+(they are not realigned against each other), and every other sequence is padded with gaps, or with Ns if it is
+missing data on both sides of the insertion site.
+*/
+
+// insSequence is one query's reference-length aligned sequence, plus its insertions keyed by the (0-based)
+// reference position that they precede
+type insSequence struct {
+	id         string
+	idx        int
+	seq        []byte
+	insertions map[int][]byte
+}
+
+// alignmentColumn is one column of an insertion-preserving alignment. refPos is the 0-based reference position
+// of the column if offset == 0, else the column is the offset-th (1-based) base of the insertion that precedes refPos
+type alignmentColumn struct {
+	refPos int
+	offset int
+}
+
+// blockToInsSequence is a worker function that takes items from a channel of sam block structs and writes
+// each query's aligned sequence and its insertions to a channel. If more than one of a query's records that are used by
+// policy have an insertion at the same site, the longest is kept for policy "n", else the one with the highest priority
+func blockToInsSequence(cSR chan samRecords, cIS chan insSequence, cMR chan mergeReport, cErr chan error, refLen int, pad bool, policy string, rescue *clipRescue) {
+
+	for group := range cSR {
+
+		rawseq, report, err := mergeBlock(group, refLen, policy, rescue)
+		if err != nil {
+			cErr <- err
+			return
+		}
+
+		var seq []byte
+		if pad {
+			seq = swapInNs(rawseq)
+		} else {
+			seq = swapInGapsNs(rawseq)
+		}
+
+		insertions := make(map[int][]byte)
+		for _, i := range orderRecords(group.records, policy) {
+			temp, _, err := recordIndels(group.records[i])
+			if err != nil {
+				cErr <- err
+				return
+			}
+			for _, ins := range temp {
+				existing, ok := insertions[ins.start]
+				if !ok || (policy == "n" && len(ins.seq) > len(existing)) {
+					insertions[ins.start] = []byte(ins.seq)
+				}
+			}
+		}
+
+		cIS <- insSequence{id: group.records[0].Name, idx: group.idx, seq: seq, insertions: insertions}
+		cMR <- report
+	}
+}
+
+// getAlignmentColumns returns the columns of the insertion-preserving alignment of a set of sequences
+func getAlignmentColumns(sequences []insSequence, refLen int) []alignmentColumn {
+
+	maxLengths := make(map[int]int)
+	for _, s := range sequences {
+		for pos, ins := range s.insertions {
+			if len(ins) > maxLengths[pos] {
+				maxLengths[pos] = len(ins)
+			}
+		}
+	}
+
+	columns := make([]alignmentColumn, 0, refLen)
+	// pos == refLen is for insertions after the last reference position
+	for pos := 0; pos <= refLen; pos++ {
+		for offset := 1; offset <= maxLengths[pos]; offset++ {
+			columns = append(columns, alignmentColumn{refPos: pos, offset: offset})
+		}
+		if pos < refLen {
+			columns = append(columns, alignmentColumn{refPos: pos})
+		}
+	}
+
+	return columns
+}
+
+// keepColumn asks whether a column is within the 1-based, inclusive trimming coordinates. Insertion columns are
+// kept if they are between two retained reference positions
+func keepColumn(c alignmentColumn, trimstart int, trimend int) bool {
+	if c.offset == 0 {
+		return c.refPos >= trimstart-1 && c.refPos < trimend
+	}
+	return c.refPos >= trimstart && c.refPos < trimend
+}
+
+// expandSequence places one sequence into the coordinates of an insertion-preserving alignment
+func expandSequence(s insSequence, columns []alignmentColumn, trim bool, pad bool, trimstart int, trimend int) []byte {
+
+	seq := make([]byte, 0, len(columns))
+
+	for _, c := range columns {
+		if trim && !keepColumn(c, trimstart, trimend) {
+			if pad {
+				seq = append(seq, 'N')
+			}
+			continue
+		}
+		if c.offset == 0 {
+			seq = append(seq, s.seq[c.refPos])
+			continue
+		}
+		if ins, ok := s.insertions[c.refPos]; ok && c.offset <= len(ins) {
+			seq = append(seq, ins[c.offset-1])
+			continue
+		}
+		// missing data on both sides of the insertion site is missing data inside it too
+		missing := (c.refPos == 0 || s.seq[c.refPos-1] == 'N') && (c.refPos == len(s.seq) || s.seq[c.refPos] == 'N')
+		if missing {
+			seq = append(seq, 'N')
+		} else {
+			seq = append(seq, '-')
+		}
+	}
+
+	return seq
+}
+
+// writeColumnMap writes the 1-based reference position of each (retained) column of an insertion-preserving alignment.
+// Insertion columns are given the position of the reference base that they follow, and their 1-based offset into the
+// insertion
+func writeColumnMap(w io.Writer, columns []alignmentColumn, trim bool, pad bool, trimstart int, trimend int) error {
+
+	_, err := w.Write([]byte("alignment_column,reference_position,insertion_offset\n"))
+	if err != nil {
+		return err
+	}
+
+	counter := 0
+	for _, c := range columns {
+		if trim && !pad && !keepColumn(c, trimstart, trimend) {
+			continue
+		}
+		counter++
+		refPos := c.refPos + 1
+		if c.offset > 0 {
+			refPos = c.refPos
+		}
+		_, err = w.Write([]byte(strconv.Itoa(counter) + "," + strconv.Itoa(refPos) + "," + strconv.Itoa(c.offset) + "\n"))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ToMultiAlignInsertions converts a SAM file containing pairwise alignments between assembled genomes to a multiple
+// alignment that retains insertions relative to the reference, by expanding the reference coordinates with a column
+// for every inserted base at each insertion site found in any record. The alignment is written in opts.Format, which
+// is one of fasta.Formats. If opts.ColumnMap is not nil, the reference position of every alignment column is written to
+// it. Each query's alignments are combined, and their soft clips rescued, as for ToMultiAlign.
+// All the sequences are held in memory, because every insertion must be known before any sequence can be written
+func ToMultiAlignInsertions(samIn io.Reader, out io.Writer, opts MultiAlignOptions) error {
+
+	threads := opts.Threads
+
+	cSR := make(chan samRecords, threads)
+	cReadDone := make(chan bool)
+
+	cSH := make(chan biogosam.Header)
+
+	cIS := make(chan insSequence, threads)
+	cCollectDone := make(chan bool)
+
+	cMR := make(chan mergeReport, threads)
+	cReportDone := make(chan bool)
+
+	cErr := make(chan error)
+
+	go groupSamRecords(samIn, cSH, cSR, cReadDone, cErr)
+
+	header := <-cSH
+	refLen := header.Refs()[0].Len()
+
+	trimstart, trimend, trim, rescue, err := checkMultiAlignOptions(opts, refLen)
+	if err != nil {
+		return err
+	}
+
+	cBlocksDone := fasta.StartWorkers(threads, func() {
+		blockToInsSequence(cSR, cIS, cMR, cErr, refLen, opts.Pad, opts.Policy, rescue)
+	})
+
+	sequences := make([]insSequence, 0)
+	go func() {
+		for s := range cIS {
+			sequences = append(sequences, s)
+		}
+		cCollectDone <- true
+	}()
+
+	reports := make([]mergeReport, 0)
+	go func() {
+		for r := range cMR {
+			reports = append(reports, r)
+		}
+		cReportDone <- true
+	}()
+
+	err = fasta.WaitStages(cErr,
+		fasta.Stage{Done: cReadDone, Close: func() {
+			close(cSR)
+			close(cSH)
+		}},
+		fasta.Stage{Done: cBlocksDone, Close: func() {
+			close(cIS)
+			close(cMR)
+		}},
+	)
+	if err != nil {
+		return err
+	}
+
+	<-cCollectDone
+	<-cReportDone
+
+	sort.Slice(sequences, func(i, j int) bool { return sequences[i].idx < sequences[j].idx })
+
+	columns := getAlignmentColumns(sequences, refLen)
+
+	if opts.ColumnMap != nil {
+		err = writeColumnMap(opts.ColumnMap, columns, trim, opts.Pad, trimstart, trimend)
+		if err != nil {
+			return err
+		}
+	}
+
+	cFR := make(chan fasta.Record)
+	cWriteDone := make(chan bool)
+
+	go fasta.WriteAlignmentFormat(cFR, out, opts.Format, opts.Wrap, false, nil, cErr, cWriteDone)
+
+	go func() {
+		for _, s := range sequences {
+			seq := expandSequence(s, columns, trim, opts.Pad, trimstart, trimend)
+			cFR <- fasta.Record{ID: s.id, Description: s.id, Seq: string(seq), Idx: s.idx}
+		}
+		close(cFR)
+	}()
+
+	err = fasta.WaitStages(cErr, fasta.Stage{Done: cWriteDone})
+	if err != nil {
+		return err
+	}
+
+	return writeMultiAlignReports(opts, reports)
+}
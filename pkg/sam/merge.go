@@ -0,0 +1,245 @@
+package sam
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	biogosam "github.com/biogo/hts/sam"
+)
+
+/*
+Supplementary alignments
+
+A query that is rearranged or chimeric relative to the reference is split by the aligner into a primary alignment
+and one or more supplementary alignments, which can overlap on the reference. They are combined into one sequence
+according to a policy:
+
+	n       - every alignment is used. Bases override gaps, and sites where the alignments have different bases are N
+	primary - only the primary alignment is used
+	mapq    - every alignment is used. At each site, the base (or deletion) is taken from the alignment with the highest
+	          MAPQ that covers it (ties go to input order)
+	score   - as mapq, but using the alignment score (the AS tag). Alignments without an AS tag are used last
+*/
+
+// MergePolicies are the ways that a query's primary and supplementary alignments can be combined
+var MergePolicies = []string{"n", "primary", "mapq", "score"}
+
+// checkMergePolicy returns an error if policy is not one of MergePolicies
+func checkMergePolicy(policy string) error {
+	for _, p := range MergePolicies {
+		if policy == p {
+			return nil
+		}
+	}
+	return errors.New("unknown merge policy: " + policy + " (must be one of: " + strings.Join(MergePolicies, ", ") + ")")
+}
+
+// mergeReport is what happened when one query's alignments were combined. conflicts is an array of 1-based inclusive
+// start-stop pairs, which are tracts of sites where the alignments have different bases
+type mergeReport struct {
+	id            string
+	idx           int
+	alignments    int
+	supplementary int
+	merged        int
+	conflictCount int
+	conflicts     []int
+	rescues       []clipRescueResult
+}
+
+// intTag returns the value of an integer tag in a record, and false if it doesn't have one
+func intTag(rec biogosam.Record, tag string) (int, bool) {
+	aux, ok := rec.Tag([]byte(tag))
+	if !ok {
+		return 0, false
+	}
+	fields := strings.Split(aux.String(), ":")
+	value, err := strconv.Atoi(fields[len(fields)-1])
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+// alignmentScore returns the value of a record's AS tag, and false if it doesn't have one
+func alignmentScore(rec biogosam.Record) (int, bool) {
+	return intTag(rec, "AS")
+}
+
+// orderRecords returns the indices of the records in a query's block that are used by policy, in order of priority
+func orderRecords(records []biogosam.Record, policy string) []int {
+
+	order := make([]int, len(records))
+	for i := range order {
+		order[i] = i
+	}
+
+	switch policy {
+	case "primary":
+		for i, rec := range records {
+			if !isSupplementary(rec) {
+				return []int{i}
+			}
+		}
+		return []int{0}
+	case "mapq":
+		sort.SliceStable(order, func(i, j int) bool {
+			return records[order[i]].MapQ > records[order[j]].MapQ
+		})
+	case "score":
+		sort.SliceStable(order, func(i, j int) bool {
+			si, oki := alignmentScore(records[order[i]])
+			sj, okj := alignmentScore(records[order[j]])
+			if oki != okj {
+				return oki
+			}
+			return si > sj
+		})
+	}
+
+	return order
+}
+
+// findConflicts returns the number of sites in a block of aligned records where more than one different base is
+// present, and the 1-based inclusive start-stop pairs of the tracts they are in
+func findConflicts(block [][]byte) (int, []int) {
+
+	count := 0
+	conflicts := make([]int, 0)
+	cont := false
+
+	for j := range block[0] {
+		var first byte
+		conflict := false
+		for i := range block {
+			if !isLetter(block[i][j]) {
+				continue
+			}
+			if first == 0 {
+				first = block[i][j]
+			} else if block[i][j] != first {
+				conflict = true
+				break
+			}
+		}
+		if conflict {
+			count++
+			if cont {
+				conflicts[len(conflicts)-1] = j + 1
+			} else {
+				conflicts = append(conflicts, j+1, j+1)
+				cont = true
+			}
+		} else {
+			cont = false
+		}
+	}
+
+	return count, conflicts
+}
+
+// mergeByPriority flattens a block of aligned records by taking each site from the first record in order that covers
+// it, with either a base or a deletion. Sites that no record covers are '*'
+func mergeByPriority(block [][]byte, order []int) []byte {
+
+	seq := make([]byte, len(block[0]))
+
+	for j := range seq {
+		seq[j] = '*'
+		for _, i := range order {
+			if block[i][j] != '*' {
+				seq[j] = block[i][j]
+				break
+			}
+		}
+	}
+
+	return seq
+}
+
+// mergeBlock combines one query's SAM records into a single aligned sequence (without insertions) according to
+// policy, and reports what it did. If rescue is not nil, soft-clipped bases at the ends of the query are rescued first
+func mergeBlock(group samRecords, refLen int, policy string, rescue *clipRescue) ([]byte, mergeReport, error) {
+
+	records := group.records
+
+	report := mergeReport{id: records[0].Name, idx: group.idx, alignments: len(records)}
+	for _, rec := range records {
+		if isSupplementary(rec) {
+			report.supplementary++
+		}
+	}
+
+	block := make([][]byte, len(records))
+	for i, line := range records {
+		temp, err := getOneLine(line, refLen, false)
+		if err != nil {
+			return []byte{}, report, err
+		}
+		block[i] = temp
+	}
+
+	if rescue != nil {
+		report.rescues = make([]clipRescueResult, 0)
+		for i, line := range records {
+			others := make([]biogosam.Record, 0, len(records)-1)
+			others = append(others, records[:i]...)
+			others = append(others, records[i+1:]...)
+			report.rescues = append(report.rescues, rescue.rescueSoftClips(line, block[i], others)...)
+		}
+	}
+
+	report.conflictCount, report.conflicts = findConflicts(block)
+
+	order := orderRecords(records, policy)
+	for _, i := range order {
+		if isSupplementary(records[i]) {
+			report.merged++
+		}
+	}
+
+	var seq []byte
+
+	switch {
+	case len(block) == 1:
+		seq = block[0]
+	case policy == "n":
+		seq = checkAndGetFlattenedSeq(block, report.id)
+	default:
+		seq = mergeByPriority(block, order)
+	}
+
+	return seq, report, nil
+}
+
+// writeMergeReport writes one line per query about how its alignments were combined, in input order
+func writeMergeReport(w io.Writer, reports []mergeReport) error {
+
+	sort.Slice(reports, func(i, j int) bool { return reports[i].idx < reports[j].idx })
+
+	_, err := w.Write([]byte("query,alignments,supplementary,merged,conflict_sites,conflict_regions\n"))
+	if err != nil {
+		return err
+	}
+
+	for _, r := range reports {
+		regions := make([]string, 0, len(r.conflicts)/2)
+		for i := 0; i < len(r.conflicts); i += 2 {
+			if r.conflicts[i] == r.conflicts[i+1] {
+				regions = append(regions, strconv.Itoa(r.conflicts[i]))
+			} else {
+				regions = append(regions, strconv.Itoa(r.conflicts[i])+"-"+strconv.Itoa(r.conflicts[i+1]))
+			}
+		}
+		_, err = w.Write([]byte(r.id + "," + strconv.Itoa(r.alignments) + "," + strconv.Itoa(r.supplementary) + "," +
+			strconv.Itoa(r.merged) + "," + strconv.Itoa(r.conflictCount) + "," + strings.Join(regions, "|") + "\n"))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
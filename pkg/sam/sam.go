@@ -28,6 +28,32 @@ type samRecords struct {
 	idx     int
 }
 
+// isUnmapped asks if the 3rd bit (== 4) in the sam flag is set
+func isUnmapped(rec biogosam.Record) bool {
+	return ((rec.Flags >> 2) & 1) == 1
+}
+
+// isReverse asks if the 5th bit (== 16) in the sam flag is set, i.e. if SEQ is the reverse complement of the query
+func isReverse(rec biogosam.Record) bool {
+	return ((rec.Flags >> 4) & 1) == 1
+}
+
+// isSecondary asks if the 9th bit (== 256) in the sam flag is set
+func isSecondary(rec biogosam.Record) bool {
+	return ((rec.Flags >> 8) & 1) == 1
+}
+
+// isSupplementary asks if the 12th bit (== 2048) in the sam flag is set
+func isSupplementary(rec biogosam.Record) bool {
+	return ((rec.Flags >> 11) & 1) == 1
+}
+
+// isLetter asks if an aligned site is a base (rather than a gap or unmapped)
+func isLetter(b byte) bool {
+	r, _ := utf8.DecodeRune([]byte{b})
+	return unicode.IsLetter(r)
+}
+
 // getOneLine processes one non-header line of a SAM file into an aligned sequence
 func getOneLine(samLine biogosam.Record, refLen int, includeInsertions bool) ([]byte, error) {
 
@@ -183,8 +209,7 @@ func getNucFromSite(s []byte, qname string, pos int) byte {
 	ss := getSetFromSlice(s)
 
 	for _, e := range ss {
-		r, _ := utf8.DecodeRune([]byte{e})
-		if unicode.IsLetter(r) {
+		if isLetter(e) {
 			check++
 		}
 	}
@@ -222,37 +247,6 @@ func checkAndGetFlattenedSeq(block [][]byte, qname string) []byte {
 	return seq
 }
 
-// getSeqFromBlock wraps the other functions in this file to get a sequence from one query's
-// SAM records - if there is only one line (only a primary mapping) it
-// returns that aligned sequence without needing to do any flattening
-func getSeqFromBlock(records []biogosam.Record, refLen int, includeInsertions bool) ([]byte, error) {
-
-	qname := records[0].Name
-
-	block := make([][]byte, len(records))
-	for i, _ := range block {
-		block[i] = make([]byte, refLen)
-	}
-
-	for i, line := range records {
-		temp, err := getOneLine(line, refLen, includeInsertions)
-		if err != nil {
-			return []byte{}, err
-		}
-		block[i] = temp
-	}
-
-	var seq []byte
-
-	if len(block) > 1 {
-		seq = checkAndGetFlattenedSeq(block, qname)
-	} else {
-		seq = block[0]
-	}
-
-	return seq, nil
-}
-
 // swapInNs replaces unmapped positions with Ns
 func swapInNs(seq []byte) []byte {
 	for i, L := range seq {
@@ -271,8 +265,7 @@ func swapInGapsNs(seq []byte) []byte {
 	var lastLetterIndx int
 
 	for i, L := range seq {
-		r, _ := utf8.DecodeRune([]byte{L})
-		if unicode.IsLetter(r) {
+		if isLetter(L) {
 
 			if firstLetter {
 				firstLetterIndx = i
@@ -331,8 +324,14 @@ func swapInGapsNs(seq []byte) []byte {
 // }
 
 // groupSamRecords yields blocks of sam records that correspond to the same query
-// sequence (to a channel)
+// sequence (to a channel). Unmapped reads and secondary mappings are skipped
 func groupSamRecords(sam io.Reader, cHeader chan biogosam.Header, chnl chan samRecords, cdone chan bool, cerr chan error) {
+	groupAllSamRecords(sam, cHeader, chnl, cdone, cerr, true)
+}
+
+// groupAllSamRecords yields blocks of sam records that correspond to the same query
+// sequence (to a channel), skipping unmapped reads and secondary mappings if skip
+func groupAllSamRecords(sam io.Reader, cHeader chan biogosam.Header, chnl chan samRecords, cdone chan bool, cerr chan error, skip bool) {
 
 	var err error
 
@@ -364,17 +363,13 @@ func groupSamRecords(sam io.Reader, cHeader chan biogosam.Header, chnl chan samR
 
 		} else {
 			// if this read is unmapped, then skip it.
-			// the third bit (== 4) in the sam flag is set if the read is unmapped,
-			// can use the rightshift method to check this:
-			if ((rec.Flags >> 2) & 1) == 1 {
+			if skip && isUnmapped(*rec) {
 				os.Stderr.WriteString("skipping unmapped read: " + rec.Name + "\n")
 				continue
 			}
 
 			// if this mapping is secondary, then skip it.
-			// the 9th bit (== 256) in the sam flag is set if the mapping is secondary,
-			// can use the rightshift method to check this:
-			if ((rec.Flags >> 8) & 1) == 1 {
+			if skip && isSecondary(*rec) {
 				os.Stderr.WriteString("ignoring secondary mapping: " + rec.Name + "\n")
 				continue
 			}
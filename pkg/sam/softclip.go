@@ -0,0 +1,221 @@
+package sam
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/virus-evolution/gofasta/pkg/fasta"
+
+	biogosam "github.com/biogo/hts/sam"
+)
+
+/*
+Soft-clip rescue
+
+An aligner will soft clip the end of a query that doesn't align well to the reference, which at the ends of a genome
+is often just a few mismatches (or a mismatching UTR) before the end of the reference. The clipped bases can be
+projected, without gaps, onto the reference positions beyond the end of the alignment (as far as the reference
+extends), and kept if the fraction of them that differ from the reference is small enough. Ns and other ambiguous
+bases aren't projected, and don't count towards the mismatch fraction.
+
+Only soft clips at the outermost ends of the query are rescued. When a query is split into more than one alignment,
+the bases that one alignment soft clips are often aligned by another, and these clips aren't projected (or reported).
+*/
+
+// clipRescue is the reference and the mismatch tolerance for rescuing soft-clipped bases
+type clipRescue struct {
+	ref         []byte
+	maxMismatch float64
+}
+
+// clipRescueResult is what happened to one soft clip. start and end are the 1-based inclusive reference positions that
+// it was projected onto
+type clipRescueResult struct {
+	id         string
+	side       string
+	clipped    int
+	projected  int
+	compared   int
+	mismatches int
+	rescued    bool
+	start      int
+	end        int
+}
+
+// loadRescueReference reads the reference for soft-clip rescue, which must be the one in the sam header
+func loadRescueReference(ref io.Reader, refLen int, maxMismatch float64) (*clipRescue, error) {
+	if maxMismatch < 0 || maxMismatch > 1 {
+		return nil, errors.New("the maximum mismatch fraction for soft-clip rescue must be between 0 and 1")
+	}
+	refs, err := fasta.LoadEncodeAlignment(ref, false, false, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(refs) != 1 {
+		return nil, errors.New("need one record in --reference")
+	}
+	seq := []byte(refs[0].Decode().Seq)
+	if len(seq) != refLen {
+		return nil, errors.New("--reference is not the same length as the reference in the sam header")
+	}
+	return &clipRescue{ref: seq, maxMismatch: maxMismatch}, nil
+}
+
+// isACGT asks if a nucleotide is an unambiguous base
+func isACGT(b byte) bool {
+	switch b {
+	case 'A', 'C', 'G', 'T', 'a', 'c', 'g', 't':
+		return true
+	}
+	return false
+}
+
+// toUpper returns the upper case version of a nucleotide
+func toUpper(b byte) byte {
+	if b >= 'a' && b <= 'z' {
+		return b - 32
+	}
+	return b
+}
+
+// projectClip projects the soft-clipped bases in clip onto the reference positions starting at refStart in one aligned
+// record, line, if they match the reference well enough. refStart can be negative, in which case the bases before the
+// start of the reference are ignored
+func (r *clipRescue) projectClip(line []byte, clip []byte, refStart int, result clipRescueResult) clipRescueResult {
+
+	result.clipped = len(clip)
+
+	from, to := 0, len(clip)
+	if refStart < 0 {
+		from = -refStart
+	}
+	if refStart+to > len(line) {
+		to = len(line) - refStart
+	}
+	if from >= to {
+		return result
+	}
+
+	result.projected = to - from
+	result.start = refStart + from + 1
+	result.end = refStart + to
+
+	for i := from; i < to; i++ {
+		q, ref := clip[i], r.ref[refStart+i]
+		if !isACGT(q) || !isACGT(ref) {
+			continue
+		}
+		result.compared++
+		if toUpper(q) != toUpper(ref) {
+			result.mismatches++
+		}
+	}
+
+	if result.compared == 0 || float64(result.mismatches)/float64(result.compared) > r.maxMismatch {
+		return result
+	}
+
+	result.rescued = true
+	for i := from; i < to; i++ {
+		if line[refStart+i] == '*' && isACGT(clip[i]) {
+			line[refStart+i] = clip[i]
+		}
+	}
+
+	return result
+}
+
+// clipCovered asks if any of the bases in a soft clip of size bases, which is before (if before) or after the part of
+// the query that rec aligns in the order of its cigar, are aligned by one of the query's other records
+func clipCovered(rec biogosam.Record, size int, before bool, others []biogosam.Record) bool {
+
+	// queryInterval is in the query's original orientation, which is the reverse of the cigar's on the - strand
+	if isReverse(rec) {
+		before = !before
+	}
+
+	start, end := queryInterval(rec)
+	if before {
+		start, end = start-size, start-1
+	} else {
+		start, end = end+1, end+size
+	}
+
+	for _, o := range others {
+		s, e := queryInterval(o)
+		if start <= e && s <= end {
+			return true
+		}
+	}
+
+	return false
+}
+
+// rescueSoftClips projects the soft-clipped bases at each end of one record onto the reference, in place in its
+// aligned sequence, line. Clips that any of the query's other records (others) align aren't at the ends of the query,
+// so they are left alone
+func (r *clipRescue) rescueSoftClips(rec biogosam.Record, line []byte, others []biogosam.Record) []clipRescueResult {
+
+	results := make([]clipRescueResult, 0)
+
+	SEQ := rec.Seq.Expand()
+	refLength, _ := rec.Cigar.Lengths()
+
+	// hard clips are outside soft clips, and don't consume SEQ
+	first, last := 0, len(rec.Cigar)-1
+	for first <= last && rec.Cigar[first].Type() == biogosam.CigarHardClipped {
+		first++
+	}
+	for last >= first && rec.Cigar[last].Type() == biogosam.CigarHardClipped {
+		last--
+	}
+
+	if first <= last && rec.Cigar[first].Type() == biogosam.CigarSoftClipped && !clipCovered(rec, rec.Cigar[first].Len(), true, others) {
+		size := rec.Cigar[first].Len()
+		if size <= len(SEQ) {
+			result := clipRescueResult{id: rec.Name, side: "start"}
+			results = append(results, r.projectClip(line, SEQ[:size], rec.Pos-size, result))
+		}
+	}
+
+	if last > first && rec.Cigar[last].Type() == biogosam.CigarSoftClipped && !clipCovered(rec, rec.Cigar[last].Len(), false, others) {
+		size := rec.Cigar[last].Len()
+		if size <= len(SEQ) {
+			result := clipRescueResult{id: rec.Name, side: "end"}
+			results = append(results, r.projectClip(line, SEQ[len(SEQ)-size:], rec.Pos+refLength, result))
+		}
+	}
+
+	return results
+}
+
+// writeRescueReport writes what happened to each soft clip, in input order
+func writeRescueReport(w io.Writer, reports []mergeReport) error {
+
+	sort.Slice(reports, func(i, j int) bool { return reports[i].idx < reports[j].idx })
+
+	_, err := w.Write([]byte("query,side,clipped,projected,compared,mismatches,rescued,ref_start,ref_end\n"))
+	if err != nil {
+		return err
+	}
+
+	for _, report := range reports {
+		for _, r := range report.rescues {
+			start, end := "", ""
+			if r.projected > 0 {
+				start = strconv.Itoa(r.start)
+				end = strconv.Itoa(r.end)
+			}
+			_, err = w.Write([]byte(r.id + "," + r.side + "," + strconv.Itoa(r.clipped) + "," + strconv.Itoa(r.projected) + "," +
+				strconv.Itoa(r.compared) + "," + strconv.Itoa(r.mismatches) + "," + strconv.FormatBool(r.rescued) + "," +
+				start + "," + end + "\n"))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,242 @@
+package sam
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/virus-evolution/gofasta/pkg/fasta"
+
+	biogosam "github.com/biogo/hts/sam"
+)
+
+// samStats is a summary of one query's records in a sam file. Everything except the record counts is calculated from
+// the primary and supplementary alignments only
+type samStats struct {
+	id            string
+	idx           int
+	primary       int
+	supplementary int
+	secondary     int
+	unmapped      int
+	refStart      int // 1-based first reference position in any alignment, 0 if there are none
+	refEnd        int // 1-based last reference position in any alignment
+	covered       int // number of reference positions in any alignment
+	refLen        int
+	clipStart     int // soft-clipped bases at the start of each alignment's cigar, summed
+	clipEnd       int // soft-clipped bases at the end of each alignment's cigar, summed
+	maxDel        int
+	maxIns        int
+	mapq          int // the MAPQ of the primary alignment, -1 if there isn't one
+	nm            int // the NM tags of the alignments, summed
+	hasNM         bool
+}
+
+// addAlignment adds one primary or supplementary alignment to a query's stats, walking its cigar with the same
+// operation map that is used to build aligned sequences. coverage is the reference positions in the query's alignments
+func (stats *samStats) addAlignment(rec biogosam.Record, coverage []bool) {
+
+	lambda_dict := getCigarOperationMapNoInsertions()
+
+	SEQ := rec.Seq.Expand()
+	// secondary and supplementary records can have no SEQ, but we only need the coordinates
+	if _, read := rec.Cigar.Lengths(); len(SEQ) < read {
+		SEQ = make([]byte, read)
+	}
+
+	qstart := 0
+	rstart := rec.Pos
+	aligned := false
+
+	for _, op := range rec.Cigar {
+
+		operation := op.Type().String()
+		size := op.Len()
+
+		new_qstart, new_rstart, _ := lambda_dict[operation](qstart, rstart, size, SEQ)
+
+		switch operation {
+		case "S":
+			if aligned {
+				stats.clipEnd += size
+			} else {
+				stats.clipStart += size
+			}
+		case "I":
+			if size > stats.maxIns {
+				stats.maxIns = size
+			}
+		case "D":
+			if size > stats.maxDel {
+				stats.maxDel = size
+			}
+		}
+
+		switch operation {
+		case "M", "=", "X", "D":
+			aligned = true
+			for i := rstart; i < new_rstart && i < len(coverage); i++ {
+				coverage[i] = true
+			}
+			if stats.refStart == 0 || rstart+1 < stats.refStart {
+				stats.refStart = rstart + 1
+			}
+			if new_rstart > stats.refEnd {
+				stats.refEnd = new_rstart
+			}
+		}
+
+		qstart = new_qstart
+		rstart = new_rstart
+	}
+
+	if nm, ok := intTag(rec, "NM"); ok {
+		stats.nm += nm
+		stats.hasNM = true
+	}
+}
+
+// getSamStats is a worker function that summarises each query's block of sam records from a channel
+func getSamStats(cSR chan samRecords, cStats chan samStats, refLen int) {
+
+	for group := range cSR {
+
+		stats := samStats{id: group.records[0].Name, idx: group.idx, refLen: refLen, mapq: -1}
+		coverage := make([]bool, refLen)
+
+		for _, rec := range group.records {
+			switch {
+			case isUnmapped(rec):
+				stats.unmapped++
+				continue
+			case isSecondary(rec):
+				stats.secondary++
+				continue
+			case isSupplementary(rec):
+				stats.supplementary++
+			default:
+				stats.primary++
+				stats.mapq = int(rec.MapQ)
+			}
+			stats.addAlignment(rec, coverage)
+		}
+
+		for _, c := range coverage {
+			if c {
+				stats.covered++
+			}
+		}
+
+		cStats <- stats
+	}
+}
+
+// formatSamStats returns one query's line of sam stats output
+func formatSamStats(stats samStats) string {
+
+	refStart, refEnd, mapq, nm := "", "", "", ""
+	if stats.refStart > 0 {
+		refStart = strconv.Itoa(stats.refStart)
+		refEnd = strconv.Itoa(stats.refEnd)
+	}
+	if stats.mapq >= 0 {
+		mapq = strconv.Itoa(stats.mapq)
+	}
+	if stats.hasNM {
+		nm = strconv.Itoa(stats.nm)
+	}
+
+	uncovered := 1.0
+	if stats.refLen > 0 {
+		uncovered = float64(stats.refLen-stats.covered) / float64(stats.refLen)
+	}
+
+	return stats.id + "," +
+		strconv.Itoa(stats.primary) + "," +
+		strconv.Itoa(stats.supplementary) + "," +
+		strconv.Itoa(stats.secondary) + "," +
+		strconv.Itoa(stats.unmapped) + "," +
+		refStart + "," +
+		refEnd + "," +
+		strconv.Itoa(stats.covered) + "," +
+		strconv.FormatFloat(uncovered, 'f', 6, 64) + "," +
+		strconv.Itoa(stats.clipStart) + "," +
+		strconv.Itoa(stats.clipEnd) + "," +
+		strconv.Itoa(stats.maxDel) + "," +
+		strconv.Itoa(stats.maxIns) + "," +
+		mapq + "," +
+		nm + "\n"
+}
+
+// writeSamStats writes each query's stats to file or stdout, in input order
+func writeSamStats(w io.Writer, cStats chan samStats, cErr chan error, cWriteDone chan bool) {
+
+	_, err := w.Write([]byte("query,primary,supplementary,secondary,unmapped,ref_start,ref_end,ref_covered,uncovered_fraction,soft_clip_start,soft_clip_end,largest_deletion,largest_insertion,mapq,nm\n"))
+	if err != nil {
+		cErr <- err
+		return
+	}
+
+	outputMap := make(map[int]samStats)
+	counter := 0
+
+	for stats := range cStats {
+		outputMap[stats.idx] = stats
+		for {
+			s, ok := outputMap[counter]
+			if !ok {
+				break
+			}
+			_, err = w.Write([]byte(formatSamStats(s)))
+			if err != nil {
+				cErr <- err
+				return
+			}
+			delete(outputMap, counter)
+			counter++
+		}
+	}
+
+	cWriteDone <- true
+}
+
+// Stats summarises the records for each query in a SAM file, to help diagnose problems with their alignments to the
+// reference before converting them to a multiple alignment. For every query, it writes the number of primary,
+// supplementary, secondary and unmapped records, and (from the primary and supplementary alignments) the span of the
+// reference that is aligned to, the number and fraction of reference positions that are and aren't aligned to,
+// the total number of soft-clipped bases at the start and end of the alignments, the largest deletion and insertion,
+// the primary alignment's MAPQ, and the total NM edit distance
+func Stats(samIn io.Reader, out io.Writer, threads int) error {
+
+	cSR := make(chan samRecords, threads)
+	cReadDone := make(chan bool)
+
+	cSH := make(chan biogosam.Header)
+
+	cStats := make(chan samStats, threads)
+	cWriteDone := make(chan bool)
+
+	cErr := make(chan error)
+
+	go groupAllSamRecords(samIn, cSH, cSR, cReadDone, cErr, false)
+
+	header := <-cSH
+	refLen := 0
+	if len(header.Refs()) > 0 {
+		refLen = header.Refs()[0].Len()
+	}
+
+	go writeSamStats(out, cStats, cErr, cWriteDone)
+
+	cStatsDone := fasta.StartWorkers(threads, func() {
+		getSamStats(cSR, cStats, refLen)
+	})
+
+	return fasta.WaitStages(cErr,
+		fasta.Stage{Done: cReadDone, Close: func() {
+			close(cSR)
+			close(cSH)
+		}},
+		fasta.Stage{Done: cStatsDone, Close: func() { close(cStats) }},
+		fasta.Stage{Done: cWriteDone},
+	)
+}
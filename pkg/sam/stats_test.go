@@ -0,0 +1,33 @@
+package sam
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	samData := []byte(`@SQ	SN:ref	LN:20
+q1	0	ref	3	60	2S5M2D3M1I4M3S	*	0	0	NNACGTAGCTAACGTNNN	*	NM:i:4
+q1	2048	ref	16	15	10H5M	*	0	0	ACGTA	*	NM:i:1
+q1	256	ref	1	0	5M	*	0	0	*	*
+q2	4	*	0	0	*	*	0	0	ACGTACGT	*
+q3	0	ref	1	60	20M	*	0	0	ACGTACGTACGTACGTACGT	*
+`)
+
+	out := new(bytes.Buffer)
+
+	err := Stats(bytes.NewReader(samData), out, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `query,primary,supplementary,secondary,unmapped,ref_start,ref_end,ref_covered,uncovered_fraction,soft_clip_start,soft_clip_end,largest_deletion,largest_insertion,mapq,nm
+q1,1,1,1,0,3,20,18,0.100000,2,3,2,1,60,5
+q2,0,0,0,1,,,0,1.000000,0,0,0,0,,
+q3,1,0,0,0,1,20,20,0.000000,0,0,0,0,60,
+` {
+		t.Errorf("problem in TestStats()")
+		fmt.Println(out.String())
+	}
+}
@@ -0,0 +1,105 @@
+package sam
+
+import (
+	"strconv"
+
+	"github.com/virus-evolution/gofasta/pkg/alphabet"
+
+	biogosam "github.com/biogo/hts/sam"
+)
+
+// getStrand returns the strand of the reference that a query's alignments are on: "+", "-", or "mixed" if some are on
+// each, which suggests an inversion
+func getStrand(records []biogosam.Record) string {
+	forward := false
+	reverse := false
+	for _, rec := range records {
+		if isReverse(rec) {
+			reverse = true
+		} else {
+			forward = true
+		}
+	}
+	switch {
+	case forward && reverse:
+		return "mixed"
+	case reverse:
+		return "-"
+	default:
+		return "+"
+	}
+}
+
+// queryInterval returns the 1-based inclusive start and end of the part of the query that is in one alignment, in
+// the query's original orientation
+func queryInterval(rec biogosam.Record) (int, int) {
+
+	leading := 0
+	aligned := 0
+	trailing := 0
+
+	for _, op := range rec.Cigar {
+		t := op.Type()
+		if t == biogosam.CigarSoftClipped || t == biogosam.CigarHardClipped {
+			if aligned == 0 {
+				leading += op.Len()
+			} else {
+				trailing += op.Len()
+			}
+			continue
+		}
+		if t.Consumes().Query == 1 {
+			aligned += op.Len()
+		}
+	}
+
+	if isReverse(rec) {
+		return trailing + 1, trailing + aligned
+	}
+	return leading + 1, leading + aligned
+}
+
+// querySpan returns the 1-based inclusive start and end of the part of the query that is in any of its alignments, in
+// the query's original orientation
+func querySpan(records []biogosam.Record) (int, int) {
+	start, end := queryInterval(records[0])
+	for _, rec := range records[1:] {
+		s, e := queryInterval(rec)
+		if s < start {
+			start = s
+		}
+		if e > end {
+			end = e
+		}
+	}
+	return start, end
+}
+
+// reverseComplementSeq returns the reverse complement of an aligned sequence. Characters without a complement
+// (e.g. '*') are kept as they are
+func reverseComplementSeq(seq []byte) []byte {
+	CA := alphabet.MakeCompArray()
+	rc := make([]byte, len(seq))
+	for i, nuc := range seq {
+		if CA[nuc] == 0 {
+			rc[len(seq)-1-i] = nuc
+		} else {
+			rc[len(seq)-1-i] = CA[nuc]
+		}
+	}
+	return rc
+}
+
+// orientPair puts a pairwise alignment in its query's original orientation (the reference is reverse complemented
+// if the query's primary alignment is on the reverse strand), and describes the query's strand and aligned span
+func orientPair(pair alignPair) alignPair {
+	if pair.reverse {
+		pair.ref = reverseComplementSeq(pair.ref)
+		pair.query = reverseComplementSeq(pair.query)
+		pair.refname = pair.refname + " strand=-"
+	} else {
+		pair.refname = pair.refname + " strand=+"
+	}
+	pair.queryname = pair.queryname + " strand=" + pair.strand + " query_start=" + strconv.Itoa(pair.queryStart) + " query_end=" + strconv.Itoa(pair.queryEnd)
+	return pair
+}
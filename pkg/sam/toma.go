@@ -3,16 +3,45 @@ package sam
 import (
 	"errors"
 	"io"
-	"sync"
 
 	"github.com/virus-evolution/gofasta/pkg/fasta"
 
 	biogosam "github.com/biogo/hts/sam"
 )
 
-// ToMultiAlign converts a SAM file containing pairwise alignments between assembled genomes to a fasta-format alignment.
-// Insertions relative to the reference are discarded, so all the sequences are the same (=reference) length
-func ToMultiAlign(samIn io.Reader, out io.Writer, wrap int, trimstart int, trimend int, pad bool, threads int) error {
+// MultiAlignOptions are the settings for ToMultiAlign and ToMultiAlignInsertions
+type MultiAlignOptions struct {
+	Format       string    // the output format, one of fasta.Formats
+	Wrap         int       // the line width of the output, or -1 not to wrap it
+	TrimStart    int       // the 1-based first reference position to keep, or -1 to keep from the start
+	TrimEnd      int       // the 1-based last reference position to keep, or -1 to keep to the end
+	Pad          bool      // replace trimmed sites with Ns instead of omitting them, and external deletions with Ns
+	Policy       string    // how to combine each query's alignments, one of MergePolicies
+	Report       io.Writer // if not nil, how each query's alignments were combined is written here
+	RescueRef    io.Reader // if not nil, the reference sequence, and soft clips are rescued against it
+	MaxMismatch  float64   // the largest fraction of a soft clip's bases that can differ from the reference
+	RescueReport io.Writer // if not nil, what happened to each soft clip is written here
+	ColumnMap    io.Writer // if not nil, ToMultiAlignInsertions writes the reference position of each column here
+	Threads      int
+}
+
+// DefaultMultiAlignOptions returns the settings for an untrimmed, unwrapped fasta-format alignment, with each query's
+// alignments merged by policy "n" and no soft clips rescued
+func DefaultMultiAlignOptions() MultiAlignOptions {
+	return MultiAlignOptions{Format: "fasta", Wrap: -1, TrimStart: -1, TrimEnd: -1, Policy: "n", MaxMismatch: 0.1, Threads: 1}
+}
+
+// ToMultiAlign converts a SAM file containing pairwise alignments between assembled genomes to a multiple alignment.
+// Insertions relative to the reference are discarded, so all the sequences are the same (=reference) length.
+// The alignment is written in opts.Format, which is one of fasta.Formats. Each query's primary and supplementary
+// alignments are combined according to opts.Policy, which is one of MergePolicies, and if opts.Report is not nil a line
+// about how this was done is written to it for every query. If opts.RescueRef (the reference sequence) is not nil,
+// soft-clipped bases at the ends of each query are projected onto the reference beyond them, and kept if no more than
+// opts.MaxMismatch of them differ from the reference. What happened to each soft clip is written to opts.RescueReport if
+// it is not nil
+func ToMultiAlign(samIn io.Reader, out io.Writer, opts MultiAlignOptions) error {
+
+	threads := opts.Threads
 
 	cSR := make(chan samRecords, threads)
 	cReadDone := make(chan bool)
@@ -22,71 +51,100 @@ func ToMultiAlign(samIn io.Reader, out io.Writer, wrap int, trimstart int, trime
 	cFR := make(chan fasta.Record)
 	cWriteDone := make(chan bool)
 
-	cErr := make(chan error)
+	cMR := make(chan mergeReport, threads)
+	cReportDone := make(chan bool)
 
-	cWaitGroupDone := make(chan bool)
+	cErr := make(chan error)
 
 	go groupSamRecords(samIn, cSH, cSR, cReadDone, cErr)
 
 	header := <-cSH
 	refLen := header.Refs()[0].Len()
 
-	trimstart, trimend, trim, err := checkArgs(refLen, trimstart, trimend)
+	trimstart, trimend, trim, rescue, err := checkMultiAlignOptions(opts, refLen)
 	if err != nil {
 		return err
 	}
 
-	if wrap > 0 {
-		go fasta.WriteWrapAlignment(cFR, out, wrap, cErr, cWriteDone)
-	} else {
-		go fasta.WriteAlignment(cFR, out, cErr, cWriteDone)
-	}
-
-	var wg sync.WaitGroup
-	wg.Add(threads)
-
-	for n := 0; n < threads; n++ {
-		go func() {
-			blockToRecord(cSR, cFR, cErr, refLen, trim, pad, trimstart, trimend, false)
-			wg.Done()
-		}()
-	}
+	go fasta.WriteAlignmentFormat(cFR, out, opts.Format, opts.Wrap, false, nil, cErr, cWriteDone)
 
+	reports := make([]mergeReport, 0)
 	go func() {
-		wg.Wait()
-		cWaitGroupDone <- true
+		for r := range cMR {
+			reports = append(reports, r)
+		}
+		cReportDone <- true
 	}()
 
-	for n := 1; n > 0; {
-		select {
-		case err := <-cErr:
-			return err
-		case <-cReadDone:
+	cBlocksDone := fasta.StartWorkers(threads, func() {
+		blockToRecord(cSR, cFR, cMR, cErr, refLen, trim, opts.Pad, trimstart, trimend, opts.Policy, rescue)
+	})
+
+	err = fasta.WaitStages(cErr,
+		fasta.Stage{Done: cReadDone, Close: func() {
 			close(cSR)
 			close(cSH)
-			n--
-		}
+		}},
+		fasta.Stage{Done: cBlocksDone, Close: func() {
+			close(cFR)
+			close(cMR)
+		}},
+		fasta.Stage{Done: cWriteDone},
+	)
+	if err != nil {
+		return err
 	}
 
-	for n := 1; n > 0; {
-		select {
-		case err := <-cErr:
-			return err
-		case <-cWaitGroupDone:
-			close(cFR)
-			n--
+	<-cReportDone
+
+	return writeMultiAlignReports(opts, reports)
+}
+
+// checkMultiAlignOptions sanity checks the settings for a multiple alignment, given the length of the reference
+// sequence. It returns the trimming coordinates (and whether to trim), and the reference for soft-clip rescue if there
+// is one
+func checkMultiAlignOptions(opts MultiAlignOptions, refLen int) (int, int, bool, *clipRescue, error) {
+
+	trimstart, trimend, trim, err := checkArgs(refLen, opts.TrimStart, opts.TrimEnd)
+	if err != nil {
+		return 0, 0, false, nil, err
+	}
+
+	err = fasta.CheckFormat(opts.Format)
+	if err != nil {
+		return 0, 0, false, nil, err
+	}
+
+	err = checkMergePolicy(opts.Policy)
+	if err != nil {
+		return 0, 0, false, nil, err
+	}
+
+	var rescue *clipRescue
+	if opts.RescueRef != nil {
+		rescue, err = loadRescueReference(opts.RescueRef, refLen, opts.MaxMismatch)
+		if err != nil {
+			return 0, 0, false, nil, err
 		}
 	}
 
-	for n := 1; n > 0; {
-		select {
-		case err := <-cErr:
+	return trimstart, trimend, trim, rescue, nil
+}
+
+// writeMultiAlignReports writes the merge and soft-clip rescue reports, if they were asked for
+func writeMultiAlignReports(opts MultiAlignOptions, reports []mergeReport) error {
+
+	if opts.Report != nil {
+		err := writeMergeReport(opts.Report, reports)
+		if err != nil {
 			return err
-		case <-cWriteDone:
-			n--
 		}
 	}
 
+	if opts.RescueReport != nil {
+		return writeRescueReport(opts.RescueReport, reports)
+	}
+
 	return nil
 }
 
@@ -122,17 +180,19 @@ func checkArgs(refLen int, trimstart int, trimend int) (int, int, bool, error) {
 
 // blockToRecord is a worker function that takes items from a channel of sam block structs (with indices)
 // and writes the corresponding fasta records to a channel
-func blockToRecord(ch_in chan samRecords, ch_out chan fasta.Record, ch_err chan error,
-	refLen int, trim bool, pad bool, trimstart int, trimend int, includeInsertions bool) {
+func blockToRecord(ch_in chan samRecords, ch_out chan fasta.Record, ch_report chan mergeReport, ch_err chan error,
+	refLen int, trim bool, pad bool, trimstart int, trimend int, policy string, rescue *clipRescue) {
 
 	for group := range ch_in {
 
 		id := group.records[0].Name
-		rawseq, err := getSeqFromBlock(group.records, refLen, includeInsertions)
+		rawseq, report, err := mergeBlock(group, refLen, policy, rescue)
 		if err != nil {
 			ch_err <- err
+			return
 		}
 		ch_out <- getRecord(rawseq, id, group.idx, trim, pad, trimstart, trimend)
+		ch_report <- report
 	}
 	return
 }
@@ -2,6 +2,7 @@ package sam
 
 import (
 	"bytes"
+	"fmt"
 	"testing"
 )
 
@@ -34,7 +35,7 @@ England/PLYM-1FCEE8A/2021	0	MN908947.3	55	60	54S21974M6D6213M6D17M1D1563M69S	*	0
 
 	out := new(bytes.Buffer)
 
-	err := ToMultiAlign(sam, out, -1, -1, -1, false, 2)
+	err := ToMultiAlign(sam, out, MultiAlignOptions{Format: "fasta", Wrap: -1, TrimStart: -1, TrimEnd: -1, Policy: "n", Threads: 2})
 	if err != nil {
 		t.Error(err)
 	}
@@ -94,7 +95,7 @@ England/PLYM-1FCEE8A/2021	0	MN908947.3	55	60	54S21974M6D6213M6D17M1D1563M69S	*	0
 
 	out := new(bytes.Buffer)
 
-	err := ToMultiAlign(sam, out, 80, -1, -1, false, 2)
+	err := ToMultiAlign(sam, out, MultiAlignOptions{Format: "fasta", Wrap: 80, TrimStart: -1, TrimEnd: -1, Policy: "n", Threads: 2})
 	if err != nil {
 		t.Error(err)
 	}
@@ -3854,3 +3855,219 @@ ACAGTGAACAATGCTAGGGAGAGCTGCCTATATGGAAGAGCCCTAATGTGTAAAATTAATTTTAGTAGTGCTAT------
 	}
 
 }
+
+func TestToMultiAlignInsertions(t *testing.T) {
+	samData := []byte(`@SQ	SN:ref	LN:12
+s1	0	ref	1	60	4M2I8M	*	0	0	ACGTTTACGTACGT	*
+s2	0	ref	1	60	4M1I8M	*	0	0	ACGTGACGTACGT	*
+s3	0	ref	3	60	10M	*	0	0	GTACGTACGT	*
+s4	0	ref	1	60	12M	*	0	0	ACGNNNNNACGT	*
+`)
+
+	out := new(bytes.Buffer)
+	columnMap := new(bytes.Buffer)
+
+	err := ToMultiAlignInsertions(bytes.NewReader(samData), out, MultiAlignOptions{Format: "fasta", Wrap: -1, TrimStart: -1, TrimEnd: -1, Policy: "n", ColumnMap: columnMap, Threads: 2})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `>s1
+ACGTTTACGTACGT
+>s2
+ACGTG-ACGTACGT
+>s3
+--GT--ACGTACGT
+>s4
+ACGNNNNNNNACGT
+` {
+		t.Errorf("problem in TestToMultiAlignInsertions()")
+		fmt.Println(out.String())
+	}
+
+	if columnMap.String() != `alignment_column,reference_position,insertion_offset
+1,1,0
+2,2,0
+3,3,0
+4,4,0
+5,4,1
+6,4,2
+7,5,0
+8,6,0
+9,7,0
+10,8,0
+11,9,0
+12,10,0
+13,11,0
+14,12,0
+` {
+		t.Errorf("problem in TestToMultiAlignInsertions() (column map)")
+		fmt.Println(columnMap.String())
+	}
+
+	// trimming is in reference coordinates, and keeps the insertion between positions 4 and 5
+	out = new(bytes.Buffer)
+	columnMap = new(bytes.Buffer)
+
+	err = ToMultiAlignInsertions(bytes.NewReader(samData), out, MultiAlignOptions{Format: "fasta", Wrap: -1, TrimStart: 4, TrimEnd: 6, Policy: "n", ColumnMap: columnMap, Threads: 2})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `>s1
+TTTAC
+>s2
+TG-AC
+>s3
+T--AC
+>s4
+NNNNN
+` {
+		t.Errorf("problem in TestToMultiAlignInsertions() (trimmed)")
+		fmt.Println(out.String())
+	}
+
+	if columnMap.String() != `alignment_column,reference_position,insertion_offset
+1,4,0
+2,4,1
+3,4,2
+4,5,0
+5,6,0
+` {
+		t.Errorf("problem in TestToMultiAlignInsertions() (trimmed column map)")
+		fmt.Println(columnMap.String())
+	}
+}
+
+func TestToMultiAlignMerge(t *testing.T) {
+	samData := []byte(`@SQ	SN:ref	LN:10
+q1	0	ref	1	60	6M4S	*	0	0	ACGTACGGGG	*	AS:i:6
+q1	2048	ref	5	20	4H6M	*	0	0	TTGGGG	*	AS:i:10
+q2	0	ref	1	60	10M	*	0	0	ACGTACGTAC	*	AS:i:10
+q3	0	ref	1	60	4M2D4M	*	0	0	ACGTGGGG	*	AS:i:8
+q3	2048	ref	5	20	2M	*	0	0	TT	*	AS:i:2
+`)
+
+	// a deletion in the alignment with the highest priority isn't filled in by one with a lower priority
+	expected := map[string]string{
+		"n":       ">q1\nACGTNNGGGG\n>q2\nACGTACGTAC\n>q3\nACGTTTGGGG\n",
+		"primary": ">q1\nACGTAC----\n>q2\nACGTACGTAC\n>q3\nACGT--GGGG\n",
+		"mapq":    ">q1\nACGTACGGGG\n>q2\nACGTACGTAC\n>q3\nACGT--GGGG\n",
+		"score":   ">q1\nACGTTTGGGG\n>q2\nACGTACGTAC\n>q3\nACGT--GGGG\n",
+	}
+
+	for _, policy := range MergePolicies {
+		out := new(bytes.Buffer)
+		err := ToMultiAlign(bytes.NewReader(samData), out, MultiAlignOptions{Format: "fasta", Wrap: -1, TrimStart: -1, TrimEnd: -1, Policy: policy, Threads: 2})
+		if err != nil {
+			t.Error(err)
+		}
+		if out.String() != expected[policy] {
+			t.Errorf("problem in TestToMultiAlignMerge() (" + policy + ")")
+			fmt.Println(out.String())
+		}
+	}
+
+	out := new(bytes.Buffer)
+	report := new(bytes.Buffer)
+	err := ToMultiAlign(bytes.NewReader(samData), out, MultiAlignOptions{Format: "fasta", Wrap: -1, TrimStart: -1, TrimEnd: -1, Policy: "primary", Report: report, Threads: 2})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if report.String() != `query,alignments,supplementary,merged,conflict_sites,conflict_regions
+q1,2,1,0,2,5-6
+q2,1,0,0,0,
+q3,2,1,0,0,
+` {
+		t.Errorf("problem in TestToMultiAlignMerge() (report)")
+		fmt.Println(report.String())
+	}
+
+	err = ToMultiAlign(bytes.NewReader(samData), out, MultiAlignOptions{Format: "fasta", Wrap: -1, TrimStart: -1, TrimEnd: -1, Policy: "best", Threads: 2})
+	if err == nil {
+		t.Errorf("problem in TestToMultiAlignMerge(): expected an error for an unknown policy")
+	}
+}
+
+func TestToMultiAlignRescueClips(t *testing.T) {
+	refData := []byte(`>ref
+ACGTACGTAACCGGTTACGT
+`)
+	samData := []byte(`@SQ	SN:ref	LN:20
+q1	0	ref	4	60	3S10M4S	*	0	0	ACGTACGTAACCGCCCC	*
+q2	0	ref	3	60	5S12M	*	0	0	GGGATGTACGTAACCGG	*
+`)
+
+	out := new(bytes.Buffer)
+	report := new(bytes.Buffer)
+
+	err := ToMultiAlign(bytes.NewReader(samData), out, MultiAlignOptions{Format: "fasta", Wrap: -1, TrimStart: -1, TrimEnd: -1, Policy: "n", RescueRef: bytes.NewReader(refData), MaxMismatch: 0.5, RescueReport: report, Threads: 2})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `>q1
+ACGTACGTAACCG-------
+>q2
+ATGTACGTAACCGG------
+` {
+		t.Errorf("problem in TestToMultiAlignRescueClips()")
+		fmt.Println(out.String())
+	}
+
+	if report.String() != `query,side,clipped,projected,compared,mismatches,rescued,ref_start,ref_end
+q1,start,3,3,3,0,true,1,3
+q1,end,4,4,4,4,false,14,17
+q2,start,5,2,2,1,true,1,2
+` {
+		t.Errorf("problem in TestToMultiAlignRescueClips() (report)")
+		fmt.Println(report.String())
+	}
+
+	// with a stricter tolerance, only the perfectly matching soft clip is rescued
+	out = new(bytes.Buffer)
+
+	err = ToMultiAlign(bytes.NewReader(samData), out, MultiAlignOptions{Format: "fasta", Wrap: -1, TrimStart: -1, TrimEnd: -1, Policy: "n", RescueRef: bytes.NewReader(refData), MaxMismatch: 0.1, Threads: 2})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `>q1
+ACGTACGTAACCG-------
+>q2
+--GTACGTAACCGG------
+` {
+		t.Errorf("problem in TestToMultiAlignRescueClips() (strict)")
+		fmt.Println(out.String())
+	}
+
+	// in a chimeric query, the bases that the primary alignment soft clips at its end are aligned by the
+	// supplementary alignment, so only the clip at the start of the query is rescued
+	chimericData := []byte(`@SQ	SN:ref	LN:20
+q1	0	ref	3	60	2S6M6S	*	0	0	ACGTACGTTTACGT	*
+q1	2048	ref	15	60	8H6M	*	0	0	TTACGT	*
+`)
+
+	out = new(bytes.Buffer)
+	report = new(bytes.Buffer)
+
+	err = ToMultiAlign(bytes.NewReader(chimericData), out, MultiAlignOptions{Format: "fasta", Wrap: -1, TrimStart: -1, TrimEnd: -1, Policy: "n", RescueRef: bytes.NewReader(refData), MaxMismatch: 1, RescueReport: report, Threads: 2})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `>q1
+ACGTACGTNNNNNNTTACGT
+` {
+		t.Errorf("problem in TestToMultiAlignRescueClips() (chimeric)")
+		fmt.Println(out.String())
+	}
+
+	if report.String() != `query,side,clipped,projected,compared,mismatches,rescued,ref_start,ref_end
+q1,start,2,2,2,0,true,1,2
+` {
+		t.Errorf("problem in TestToMultiAlignRescueClips() (chimeric report)")
+		fmt.Println(report.String())
+	}
+}
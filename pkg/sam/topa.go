@@ -2,12 +2,10 @@ package sam
 
 import (
 	"errors"
-	"fmt"
 	"io"
 	"os"
 	"path"
 	"sort"
-	"strings"
 	"sync"
 
 	"github.com/virus-evolution/gofasta/pkg/fasta"
@@ -22,6 +20,11 @@ type alignPair struct {
 	refname   string
 	queryname string
 	idx       int // for retaining input order in the output
+
+	strand     string // the strand of the query's alignments ("+", "-" or "mixed")
+	reverse    bool   // whether the query's primary alignment is on the reverse strand
+	queryStart int    // the 1-based start of the query's aligned span, in its original orientation
+	queryEnd   int    // the 1-based end of the query's aligned span, in its original orientation
 }
 
 // alignPairs is for passing groups of alignPair around with an index which is used to retain input
@@ -242,6 +245,7 @@ func blockToPairwiseAlignment(cSR chan samRecords, cPair chan alignPair, cErr ch
 			pair.refname = string(group.records[0].Ref.Name())
 			pair.queryname = group.records[0].Name
 			pair.idx = group.idx
+			setStrandInfo(&pair, group.records)
 			cPair <- pair
 
 		} else {
@@ -261,6 +265,7 @@ func blockToPairwiseAlignment(cSR chan samRecords, cPair chan alignPair, cErr ch
 			pair.queryname = group.records[0].Name
 			pair.refname = string(group.records[0].Ref.Name())
 			pair.idx = group.idx
+			setStrandInfo(&pair, group.records)
 
 			cPair <- pair
 		}
@@ -269,6 +274,13 @@ func blockToPairwiseAlignment(cSR chan samRecords, cPair chan alignPair, cErr ch
 	return
 }
 
+// setStrandInfo records the strand and the aligned span of a query's alignments in its pairwise alignment
+func setStrandInfo(pair *alignPair, records []biogosam.Record) {
+	pair.strand = getStrand(records)
+	pair.reverse = isReverse(records[orderRecords(records, "primary")[0]])
+	pair.queryStart, pair.queryEnd = querySpan(records)
+}
+
 // get the number of bases to add to convert each reference position to MSA coordinates
 func getRefOffset(refseq []byte) []int {
 
@@ -344,76 +356,37 @@ func wrap(old string, wrap int) string {
 }
 
 // writePairwiseAlignment writes the pairwise alignments between reference and queries to a directory, p, one fasta
-// file per query
-func writePairwiseAlignment(p string, w int, cPair chan alignPair, cWriteDone chan bool, cErr chan error, omitRef bool) {
+// file per query. If queryOrientation, each alignment is written in its query's original orientation
+func writePairwiseAlignment(p string, w int, cPair chan alignPair, cWriteDone chan bool, cErr chan error, omitRef bool, queryOrientation bool) {
 
-	_ = path.Join()
-
-	var err error
+	err := os.MkdirAll(p, 0755)
+	if err != nil {
+		cErr <- err
+		return
+	}
 
-	if p == "stdout" {
-		for AP := range cPair {
-			if !omitRef {
-				_, err = fmt.Fprintln(os.Stdout, ">"+AP.refname)
-				if err != nil {
-					cErr <- err
-				}
-				_, err = fmt.Fprint(os.Stdout, wrap(string(AP.ref), w))
-				if err != nil {
-					cErr <- err
-				}
-			}
-			_, err = fmt.Fprintln(os.Stdout, ">"+AP.queryname)
-			if err != nil {
-				cErr <- err
-			}
-			_, err = fmt.Fprint(os.Stdout, wrap(string(AP.query), w))
-			if err != nil {
-				cErr <- err
-			}
+	for AP := range cPair {
+		name := pairFileName(AP.queryname)
+		if queryOrientation {
+			AP = orientPair(AP)
 		}
-	} else {
-		os.MkdirAll(p, 0755)
-
-		for AP := range cPair {
-			// forward slashes are illegal in unix filenames (so is ascii NUL ?)
-			des := strings.ReplaceAll(AP.queryname, "/", "_")
-			// unix filenames must be <= 255 chars, (account for ".fasta")
-			if len(des) > 249 {
-				fmt.Fprintf(os.Stderr, "Filename too long, truncating \"%s\" to: \"%s\"\n", des, des[0:249])
-				des = des[0:249]
-			}
-			f, err := os.Create(path.Join(p, des+".fasta"))
-			if err != nil {
-				cErr <- err
-			}
-			if !omitRef {
-				_, err = f.WriteString(">" + AP.refname + "\n")
-				if err != nil {
-					cErr <- err
-				}
-				_, err = f.WriteString(wrap(string(AP.ref), w))
-				if err != nil {
-					cErr <- err
-				}
-			}
-			_, err = f.WriteString(">" + AP.queryname + "\n")
-			if err != nil {
-				cErr <- err
-			}
-			_, err = f.WriteString(wrap(string(AP.query), w))
-			if err != nil {
-				cErr <- err
-			}
-			f.Close()
+		err = os.WriteFile(path.Join(p, name), formatPair(AP, w, omitRef), 0644)
+		if err != nil {
+			cErr <- err
+			return
 		}
 	}
+
 	cWriteDone <- true
 }
 
 // ToPairAlign converts a SAM file containing pairwise alignments between assembled genomes into pairwise fasta-format alignments,
-// optionally including the reference sequence and insertions relative to it, optionally trimmed to coordinates in (degapped-)reference space
-func ToPairAlign(samIn, ref io.Reader, outpath string, wrap int, trimStart int, trimEnd int, omitRef bool, omitIns bool, threads int) error {
+// optionally including the reference sequence and insertions relative to it, optionally trimmed to coordinates in (degapped-)reference space.
+// If queryOrientation, alignments whose query's primary mapping is on the reverse strand are reverse complemented, so that
+// the query is in its original orientation, and the strand and aligned span of each query are written in its description.
+// The alignments are written in outFormat, which is one of PairAlignFormats: "dir" writes one file per query to the directory
+// outpath, and the others write a single file to outpath, or to stdout if outpath is "" or "stdout"
+func ToPairAlign(samIn, ref io.Reader, outpath string, outFormat string, wrap int, trimStart int, trimEnd int, omitRef bool, omitIns bool, queryOrientation bool, threads int) error {
 
 	// NB probably uncomment the below and use it for checks (e.g. for
 	// reference length)
@@ -440,6 +413,28 @@ func ToPairAlign(samIn, ref io.Reader, outpath string, wrap int, trimStart int,
 		return err
 	}
 
+	err = checkPairAlignFormat(outFormat)
+	if err != nil {
+		return err
+	}
+
+	var out io.Writer
+	switch {
+	case outFormat == "dir":
+		if outpath == "" || outpath == "stdout" {
+			return errors.New("an output directory is needed to write one file per query")
+		}
+	case outpath == "" || outpath == "stdout":
+		out = os.Stdout
+	default:
+		f, err := os.Create(outpath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
 	cSR := make(chan samRecords, threads)
 	cSH := make(chan biogosam.Header)
 
@@ -455,7 +450,11 @@ func ToPairAlign(samIn, ref io.Reader, outpath string, wrap int, trimStart int,
 
 	_ = <-cSH
 
-	go writePairwiseAlignment(outpath, wrap, cPairTrim, cWriteDone, cErr, omitRef)
+	if outFormat == "dir" {
+		go writePairwiseAlignment(outpath, wrap, cPairTrim, cWriteDone, cErr, omitRef, queryOrientation)
+	} else {
+		go writePairwiseStream(out, outFormat, wrap, cPairTrim, cWriteDone, cErr, omitRef, queryOrientation)
+	}
 
 	var wgAlign sync.WaitGroup
 	wgAlign.Add(threads)
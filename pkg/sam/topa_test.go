@@ -0,0 +1,132 @@
+package sam
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestToPairAlignQueryOrientation(t *testing.T) {
+	refData := []byte(`>ref
+ACGTTGCAAA
+`)
+	samData := []byte(`@SQ	SN:ref	LN:10
+q1	16	ref	1	60	2S8M	*	0	0	TTACGTTGCA	*
+q2	0	ref	1	60	10M	*	0	0	ACGTTGCAAT	*
+`)
+
+	outpath := t.TempDir()
+
+	err := ToPairAlign(bytes.NewReader(samData), bytes.NewReader(refData), outpath, "dir", -1, -1, -1, false, false, true, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	q1, err := os.ReadFile(path.Join(outpath, "q1.fasta"))
+	if err != nil {
+		t.Error(err)
+	}
+	if string(q1) != `>ref strand=-
+TTTGCAACGT
+>q1 strand=- query_start=1 query_end=8
+NNTGCAACGT
+` {
+		t.Errorf("problem in TestToPairAlignQueryOrientation()")
+		fmt.Println(string(q1))
+	}
+
+	q2, err := os.ReadFile(path.Join(outpath, "q2.fasta"))
+	if err != nil {
+		t.Error(err)
+	}
+	if string(q2) != `>ref strand=+
+ACGTTGCAAA
+>q2 strand=+ query_start=1 query_end=10
+ACGTTGCAAT
+` {
+		t.Errorf("problem in TestToPairAlignQueryOrientation()")
+		fmt.Println(string(q2))
+	}
+}
+
+func TestToPairAlignSingleFile(t *testing.T) {
+	refData := []byte(`>ref
+ACGTTGCAAA
+`)
+	samData := []byte(`@SQ	SN:ref	LN:10
+England/q1	0	ref	3	60	8M	*	0	0	GTTGCAAA	*
+England/q2	0	ref	1	60	10M	*	0	0	ACGTTGCAAT	*
+`)
+
+	outfile := path.Join(t.TempDir(), "pairs.fasta")
+
+	err := ToPairAlign(bytes.NewReader(samData), bytes.NewReader(refData), outfile, "fasta", -1, -1, -1, true, false, false, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	fastaOut, err := os.ReadFile(outfile)
+	if err != nil {
+		t.Error(err)
+	}
+	if string(fastaOut) != `>England/q1
+NNGTTGCAAA
+>England/q2
+ACGTTGCAAT
+` {
+		t.Errorf("problem in TestToPairAlignSingleFile() (fasta)")
+		fmt.Println(string(fastaOut))
+	}
+
+	outfile = path.Join(t.TempDir(), "pairs.tar.gz")
+
+	err = ToPairAlign(bytes.NewReader(samData), bytes.NewReader(refData), outfile, "tar.gz", -1, -1, -1, false, false, false, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	f, err := os.Open(outfile)
+	if err != nil {
+		t.Error(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Error(err)
+	}
+	tr := tar.NewReader(gz)
+
+	archiveOut := new(bytes.Buffer)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Error(err)
+			break
+		}
+		archiveOut.WriteString(hdr.Name + "\n")
+		io.Copy(archiveOut, tr)
+	}
+
+	if archiveOut.String() != `England_q1.fasta
+>ref
+ACGTTGCAAA
+>England/q1
+NNGTTGCAAA
+England_q2.fasta
+>ref
+ACGTTGCAAA
+>England/q2
+ACGTTGCAAT
+` {
+		t.Errorf("problem in TestToPairAlignSingleFile() (tar.gz)")
+		fmt.Println(archiveOut.String())
+	}
+}
@@ -0,0 +1,171 @@
+package sam
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// PairAlignFormats are the ways that sam toPairAlign can write its output: one fasta file per query in a directory, all
+// the pairwise alignments in a single (interleaved) fasta file, or one fasta file per query in an archive
+var PairAlignFormats = []string{"dir", "fasta", "tar", "tar.gz", "zip"}
+
+// checkPairAlignFormat returns an error if format is not one of PairAlignFormats
+func checkPairAlignFormat(format string) error {
+	for _, f := range PairAlignFormats {
+		if format == f {
+			return nil
+		}
+	}
+	return errors.New("unknown output format: " + format + " (must be one of: " + strings.Join(PairAlignFormats, ", ") + ")")
+}
+
+// pairFileName returns the name of the fasta file that a query's pairwise alignment is written to
+func pairFileName(queryname string) string {
+	// forward slashes are illegal in unix filenames (so is ascii NUL ?)
+	des := strings.ReplaceAll(queryname, "/", "_")
+	// unix filenames must be <= 255 chars, (account for ".fasta")
+	if len(des) > 249 {
+		fmt.Fprintf(os.Stderr, "Filename too long, truncating \"%s\" to: \"%s\"\n", des, des[0:249])
+		des = des[0:249]
+	}
+	return des + ".fasta"
+}
+
+// formatPair returns a pairwise alignment in fasta format, optionally without the reference
+func formatPair(AP alignPair, w int, omitRef bool) []byte {
+	var sb strings.Builder
+	if !omitRef {
+		sb.WriteString(">" + AP.refname + "\n")
+		sb.WriteString(wrap(string(AP.ref), w))
+	}
+	sb.WriteString(">" + AP.queryname + "\n")
+	sb.WriteString(wrap(string(AP.query), w))
+	return []byte(sb.String())
+}
+
+// pairWriter writes pairwise alignments to one stream, either as a single fasta file or as entries in an archive
+type pairWriter interface {
+	add(queryname string, data []byte) error
+	close() error
+}
+
+// fastaPairWriter writes every pairwise alignment to the same fasta file, one after the other
+type fastaPairWriter struct {
+	w io.Writer
+}
+
+func (f *fastaPairWriter) add(queryname string, data []byte) error {
+	_, err := f.w.Write(data)
+	return err
+}
+
+func (f *fastaPairWriter) close() error {
+	return nil
+}
+
+// tarPairWriter writes each pairwise alignment to its own file in a tar archive, which is optionally gzipped
+type tarPairWriter struct {
+	gz      *gzip.Writer
+	tw      *tar.Writer
+	modTime time.Time
+}
+
+func (t *tarPairWriter) add(queryname string, data []byte) error {
+	err := t.tw.WriteHeader(&tar.Header{Name: pairFileName(queryname), Mode: 0644, Size: int64(len(data)), ModTime: t.modTime, Typeflag: tar.TypeReg})
+	if err != nil {
+		return err
+	}
+	_, err = t.tw.Write(data)
+	return err
+}
+
+func (t *tarPairWriter) close() error {
+	err := t.tw.Close()
+	if err != nil {
+		return err
+	}
+	if t.gz != nil {
+		return t.gz.Close()
+	}
+	return nil
+}
+
+// zipPairWriter writes each pairwise alignment to its own (compressed) file in a zip archive
+type zipPairWriter struct {
+	zw      *zip.Writer
+	modTime time.Time
+}
+
+func (z *zipPairWriter) add(queryname string, data []byte) error {
+	f, err := z.zw.CreateHeader(&zip.FileHeader{Name: pairFileName(queryname), Method: zip.Deflate, Modified: z.modTime})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+func (z *zipPairWriter) close() error {
+	return z.zw.Close()
+}
+
+// newPairWriter returns a pairWriter for one of the single-stream PairAlignFormats
+func newPairWriter(w io.Writer, format string) pairWriter {
+	switch format {
+	case "tar":
+		return &tarPairWriter{tw: tar.NewWriter(w), modTime: time.Now()}
+	case "tar.gz":
+		gz := gzip.NewWriter(w)
+		return &tarPairWriter{gz: gz, tw: tar.NewWriter(gz), modTime: time.Now()}
+	case "zip":
+		return &zipPairWriter{zw: zip.NewWriter(w), modTime: time.Now()}
+	default:
+		return &fastaPairWriter{w: w}
+	}
+}
+
+// writePairwiseStream writes the pairwise alignments between reference and queries to a single stream in format,
+// in input order. Inside archives, each query's alignment has the same file name that it would have in a directory
+func writePairwiseStream(out io.Writer, format string, w int, cPair chan alignPair, cWriteDone chan bool, cErr chan error, omitRef bool, queryOrientation bool) {
+
+	pw := newPairWriter(out, format)
+
+	outputMap := make(map[int]alignPair)
+	counter := 0
+
+	for pair := range cPair {
+		outputMap[pair.idx] = pair
+		for {
+			AP, ok := outputMap[counter]
+			if !ok {
+				break
+			}
+			queryname := AP.queryname
+			if queryOrientation {
+				AP = orientPair(AP)
+			}
+			err := pw.add(queryname, formatPair(AP, w, omitRef))
+			if err != nil {
+				cErr <- err
+				return
+			}
+			delete(outputMap, counter)
+			counter++
+		}
+	}
+
+	err := pw.close()
+	if err != nil {
+		cErr <- err
+		return
+	}
+
+	cWriteDone <- true
+}
@@ -3,22 +3,28 @@ package sam
 import (
 	"errors"
 	"io"
-	"os"
 	"sync"
 
 	biogosam "github.com/biogo/hts/sam"
 	"github.com/virus-evolution/gofasta/pkg/encoding"
 	"github.com/virus-evolution/gofasta/pkg/fasta"
-	"github.com/virus-evolution/gofasta/pkg/genbank"
-	"github.com/virus-evolution/gofasta/pkg/gff"
 	"github.com/virus-evolution/gofasta/pkg/variants"
 )
 
 // Variants annotates amino acid, insertion, deletion, and nucleotide (anything
 // outside of codons with an amino acid change) mutations relative to a reference
 // sequence from pairwise alignments in sam format. Genome annotations are
-// derived from a annotation file in genbank or gff version 3 format
-func Variants(samIn, refIn io.Reader, refFromFile bool, annoIn io.Reader, annoSuffix string, out io.Writer, start, end int, aggregate bool, threshold float64, appendSNP bool, threads int) error {
+// derived from a annotation file in genbank or gff version 3 format. If strand, the strand of the reference that each
+// query aligned to ("+", "-", or "mixed" if its alignments are on both) is written in its own column. If cdsFlags,
+// premature stops, stop losses and frameshifts in each CDS are written in their own column
+func Variants(samIn, refIn io.Reader, refFromFile bool, annoIn io.Reader, annoSuffix string, out io.Writer, start, end int, aggregate bool, threshold float64, appendSNP bool, strand bool, cdsFlags bool, threads int) error {
+
+	if strand && aggregate {
+		return errors.New("can't report each query's strand when aggregating variants")
+	}
+	if cdsFlags && aggregate {
+		return errors.New("can't report each query's CDS flags when aggregating variants")
+	}
 
 	var ref fasta.EncodedRecord
 	if refFromFile {
@@ -32,56 +38,9 @@ func Variants(samIn, refIn io.Reader, refFromFile bool, annoIn io.Reader, annoSu
 		ref = refs[0]
 	}
 
-	var cdsregions []variants.Region
-	var intregions []int
-	switch annoSuffix {
-	case "gb":
-		gb, err := genbank.ReadGenBank(annoIn)
-		if err != nil {
-			return err
-		}
-		if !refFromFile {
-			temp := fasta.Record{Seq: string(gb.ORIGIN), ID: "annotation_fasta"}
-			ref, err = temp.Encode()
-			if err != nil {
-				return err
-			}
-			os.Stderr.WriteString("using --annotation fasta as reference\n")
-		}
-		refLenDegapped := len(ref.Decode().Degap().Seq)
-		cdsregions, intregions, err = variants.RegionsFromGenbank(gb, refLenDegapped)
-		if err != nil {
-			return err
-		}
-	case "gff":
-		gff, err := gff.ReadGFF(annoIn)
-		if err != nil {
-			return err
-		}
-		if !refFromFile {
-			switch len(gff.FASTA) {
-			case 0:
-				return errors.New("couldn't find a reference sequence in the gff and none was provided to --reference")
-			case 1:
-				var encodedrefseq []byte
-				for _, v := range gff.FASTA {
-					encodedrefseq = make([]byte, len(v.Seq))
-					EA := encoding.MakeEncodingArray()
-					for i := range v.Seq {
-						encodedrefseq[i] = EA[v.Seq[i]]
-					}
-					ref = fasta.EncodedRecord{ID: "annotation_fasta", Seq: encodedrefseq}
-				}
-				os.Stderr.WriteString("using --annotation fasta as reference\n")
-			default:
-				return errors.New("more that one sequence in gff ##FASTA section")
-			}
-		}
-		refSeqDegapped := ref.Decode().Degap().Seq
-		cdsregions, intregions, err = variants.RegionsFromGFF(gff, refSeqDegapped)
-		if err != nil {
-			return err
-		}
+	ref, cdsregions, intregions, err := variants.RegionsFromAnnotation(annoIn, annoSuffix, ref)
+	if err != nil {
+		return err
 	}
 
 	cErr := make(chan error)
@@ -102,7 +61,7 @@ func Variants(samIn, refIn io.Reader, refFromFile bool, annoIn io.Reader, annoSu
 	case true:
 		go variants.AggregateWriteVariants(out, start, end, appendSNP, threshold, ref.ID, cVariants, cWriteDone, cErr)
 	case false:
-		go variants.WriteVariants(out, start, end, false, appendSNP, ref.ID, cVariants, cWriteDone, cErr)
+		go variants.WriteVariants(out, start, end, false, appendSNP, strand, cdsFlags, ref.ID, cVariants, cWriteDone, cErr)
 	}
 
 	go groupSamRecords(samIn, cSH, cSR, cReadDone, cErr)
@@ -124,7 +83,7 @@ func Variants(samIn, refIn io.Reader, refFromFile bool, annoIn io.Reader, annoSu
 
 	for n := 0; n < threads; n++ {
 		go func() {
-			getVariantsSam(cdsregions, intregions, cPairAlign, cVariants, cErr)
+			getVariantsSam(cdsregions, intregions, start, end, cPairAlign, cVariants, cErr)
 			wgVariants.Done()
 		}()
 	}
@@ -185,7 +144,7 @@ func Variants(samIn, refIn io.Reader, refFromFile bool, annoIn io.Reader, annoSu
 // getVariantsSam gets the mutations for each pairwise alignment from a channel
 // at a time, and passes them to a channel of annotated variants, given an array
 // of annotated genome regions
-func getVariantsSam(cdsregions []variants.Region, intregions []int, cAlignPair chan alignPair, cVariants chan variants.AnnoStructs, cErr chan error) {
+func getVariantsSam(cdsregions []variants.Region, intregions []int, start, end int, cAlignPair chan alignPair, cVariants chan variants.AnnoStructs, cErr chan error) {
 
 	EA := encoding.MakeEncodingArray()
 
@@ -207,6 +166,9 @@ func getVariantsSam(cdsregions []variants.Region, intregions []int, cAlignPair c
 			break
 		}
 
+		AS.Strand = pair.strand
+		AS.Flags = variants.GetCDSFlags(AS.Vs, cdsregions, start, end, false)
+
 		// and we're done
 		cVariants <- AS
 	}
@@ -28,7 +28,7 @@ aa:ORF7a:A8K	0	MN908947.3	1	60	29903M	*	0	0	ATTAAAGGTTTATACCTTCCCAGGTAACAAACCAAC
 
 	out := new(bytes.Buffer)
 
-	err := Variants(sam, ref, true, genbank, "gb", out, -1, -1, false, 0.0, false, 1)
+	err := Variants(sam, ref, true, genbank, "gb", out, -1, -1, false, 0.0, false, false, false, 1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -48,7 +48,7 @@ aa:ORF7a:A8K,aa:ORF7a:A8K
 
 	out = new(bytes.Buffer)
 
-	err = Variants(sam, ref, false, genbank, "gb", out, -1, -1, false, 0.0, false, 1)
+	err = Variants(sam, ref, false, genbank, "gb", out, -1, -1, false, 0.0, false, false, false, 1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -69,7 +69,7 @@ aa:ORF7a:A8K,aa:ORF7a:A8K
 
 	out = new(bytes.Buffer)
 
-	err = Variants(sam, ref, true, gff, "gff", out, -1, -1, false, 0.0, false, 1)
+	err = Variants(sam, ref, true, gff, "gff", out, -1, -1, false, 0.0, false, false, false, 1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -89,7 +89,7 @@ aa:ORF7a:A8K,aa:ORF7a:A8K
 
 	out = new(bytes.Buffer)
 
-	err = Variants(sam, ref, false, gff, "gff", out, -1, -1, false, 0.0, false, 1)
+	err = Variants(sam, ref, false, gff, "gff", out, -1, -1, false, 0.0, false, false, false, 1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -103,6 +103,30 @@ aa:ORF7a:A8K,aa:ORF7a:A8K
 		fmt.Println(string(out.Bytes()))
 		t.Errorf("problem in TestVariants()")
 	}
+
+	// the strand column, with one alignment flagged as being on the reverse strand
+	samStrand := bytes.Replace(samData, []byte("nuc:A5560T\t0\t"), []byte("nuc:A5560T\t16\t"), 1)
+
+	ref = bytes.NewReader(refData)
+	sam = bytes.NewReader(samStrand)
+	gff = bytes.NewReader(gffData)
+
+	out = new(bytes.Buffer)
+
+	err = Variants(sam, ref, true, gff, "gff", out, -1, -1, false, 0.0, false, true, false, 1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(out.Bytes()) != `query,strand,mutations
+del:5792:5,+,del:5792:5
+ins:26646:4,+,ins:26646:4
+nuc:A5560T,-,nuc:A5560T
+aa:ORF7a:A8K,+,aa:ORF7a:A8K
+` {
+		fmt.Println(string(out.Bytes()))
+		t.Errorf("problem in TestVariants() (strand)")
+	}
 }
 
 func TestVariantsAppendSNP(t *testing.T) {
@@ -127,7 +151,7 @@ aa:ORF7a:A8K	0	MN908947.3	1	60	29903M	*	0	0	ATTAAAGGTTTATACCTTCCCAGGTAACAAACCAAC
 
 	out := new(bytes.Buffer)
 
-	err := Variants(sam, ref, true, genbank, "gb", out, -1, -1, false, 0.0, true, 1)
+	err := Variants(sam, ref, true, genbank, "gb", out, -1, -1, false, 0.0, true, false, false, 1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -167,7 +191,7 @@ aa:ORF7a:A8K3	0	MN908947.3	1	60	29903M	*	0	0	ATTAAAGGTTTATACCTTCCCAGGTAACAAACCAA
 
 	out := new(bytes.Buffer)
 
-	err := Variants(sam, ref, true, genbank, "gb", out, -1, -1, true, 0.0, false, 1)
+	err := Variants(sam, ref, true, genbank, "gb", out, -1, -1, true, 0.0, false, false, false, 1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -207,7 +231,7 @@ aa:ORF7a:A8K3	0	MN908947.3	1	60	29903M	*	0	0	ATTAAAGGTTTATACCTTCCCAGGTAACAAACCAA
 
 	out := new(bytes.Buffer)
 
-	err := Variants(sam, ref, true, genbank, "gb", out, -1, -1, true, 0.0, true, 1)
+	err := Variants(sam, ref, true, genbank, "gb", out, -1, -1, true, 0.0, true, false, false, 1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -247,7 +271,7 @@ aa:ORF7a:A8K3	0	MN908947.3	1	60	29903M	*	0	0	ATTAAAGGTTTATACCTTCCCAGGTAACAAACCAA
 
 	out := new(bytes.Buffer)
 
-	err := Variants(sam, ref, true, genbank, "gb", out, -1, -1, true, 0.5, false, 1)
+	err := Variants(sam, ref, true, genbank, "gb", out, -1, -1, true, 0.5, false, false, false, 1)
 	if err != nil {
 		t.Error(err)
 	}
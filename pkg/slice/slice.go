@@ -0,0 +1,189 @@
+/*
+Package slice implements functionality to extract the columns of a multiple
+sequence alignment in fasta format that correspond to a region of a reference
+sequence, given either reference coordinates or a named feature from a genome
+annotation. The extracted region can optionally be translated to protein.
+*/
+package slice
+
+import (
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/virus-evolution/gofasta/pkg/fasta"
+	"github.com/virus-evolution/gofasta/pkg/variants"
+)
+
+// getFeature returns the protein-coding region whose name matches feature
+func getFeature(cdsregions []variants.Region, feature string) (variants.Region, error) {
+	for _, r := range cdsregions {
+		if r.Name == feature {
+			return r, nil
+		}
+	}
+	names := make([]string, 0)
+	for _, r := range cdsregions {
+		names = append(names, r.Name)
+	}
+	return variants.Region{}, errors.New("couldn't find --feature " + feature + " in the annotation (choose from: " + strings.Join(names, ", ") + ")")
+}
+
+// checkArgs sanity checks the slicing coordinates, given the length of the (degapped) reference sequence
+func checkArgs(refLen int, start int, end int) (int, int, error) {
+
+	if start == -1 {
+		start = 1
+	}
+	if end == -1 {
+		end = refLen
+	}
+
+	if start > refLen || start < 1 {
+		return 0, 0, errors.New("error parsing --start coordinate. --start must be > 0 && <= length(reference)")
+	}
+	if end > refLen || end < 1 {
+		return 0, 0, errors.New("error parsing --end coordinate. --end must be > 0 && <= length(reference)")
+	}
+	if start > end {
+		return 0, 0, errors.New("error parsing slicing coordinates: --start must be <= --end")
+	}
+
+	return start, end, nil
+}
+
+// sliceRecord returns a new Record that contains the alignment columns between (and including) the
+// columns that correspond to reference positions start and end
+func sliceRecord(record fasta.Record, start, end int, refToMSA []int) fasta.Record {
+	msaStart := (start - 1) + refToMSA[start-1]
+	msaEnd := end + refToMSA[end-1]
+	return fasta.Record{ID: record.ID, Description: record.Description, Seq: record.Seq[msaStart:msaEnd], Idx: record.Idx}
+}
+
+// sliceRecords is a worker function that slices (and optionally translates) each fasta record from
+// a channel, and passes the result to another channel
+func sliceRecords(start, end int, translate bool, region variants.Region, refToMSA []int, width int, cFR chan fasta.Record, cOut chan fasta.Record, cErr chan error) {
+	for record := range cFR {
+		if len(record.Seq) != width {
+			cErr <- errors.New("Gapped reference sequence and alignment are not the same width")
+			break
+		}
+		if translate {
+			cOut <- fasta.Record{ID: record.ID, Description: record.Description, Seq: variants.TranslateRegion(record.Seq, region, refToMSA), Idx: record.Idx}
+		} else {
+			cOut <- sliceRecord(record, start, end, refToMSA)
+		}
+	}
+}
+
+// Slice extracts the alignment columns that correspond to a region of the reference sequence from every record
+// in a fasta-format alignment. The region is defined by 1-based inclusive reference coordinates or by the name of a
+// protein-coding feature in a genbank or gff version 3 format annotation. Gaps in the reference sequence are accounted
+// for, so that the coordinates are always in degapped reference space. If translate is true, the region is translated
+// to protein and a protein alignment is written instead.
+func Slice(msaIn io.Reader, stdin bool, refID string, annoIn io.Reader, annoSuffix string, feature string, start, end int, translate bool, out io.Writer, wrap int, threads int) error {
+
+	var first fasta.Record
+
+	ref, err := variants.FindAndRewind(msaIn, stdin, refID)
+	if err != nil {
+		return err
+	}
+
+	cFR := make(chan fasta.Record, 50+threads)
+	cErr := make(chan error)
+	cFRDone := make(chan bool)
+
+	go fasta.StreamAlignment(msaIn, cFR, cErr, cFRDone)
+
+	firstTaken := false
+
+	// If we're reading from stdin, the reference has to be the first record. If we don't have a reference at all,
+	// we use the first record to get the width of the alignment, which must be in reference coordinates
+	if (stdin && refID != "") || (refID == "" && annoSuffix == "") {
+		first, err = fasta.FirstRecord(cFR, cErr, cFRDone)
+		if err != nil {
+			return err
+		}
+		firstTaken = true
+		if refID != "" {
+			if first.ID != refID {
+				return errors.New("--reference is not the first record in --msa")
+			}
+			ref, err = first.Encode()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// the region to translate, if translate is true
+	region := variants.Region{Strand: 1}
+
+	if annoSuffix != "" {
+		var cdsregions []variants.Region
+		ref, cdsregions, _, err = variants.RegionsFromAnnotation(annoIn, annoSuffix, ref)
+		if err != nil {
+			return err
+		}
+		if feature != "" {
+			region, err = getFeature(cdsregions, feature)
+			if err != nil {
+				return err
+			}
+			start = region.Start
+			end = region.Stop
+		}
+	}
+
+	var refToMSA []int
+	var width int
+	if len(ref.Seq) > 0 {
+		refToMSA, _ = variants.GetMSAOffsets(ref.Seq)
+		width = len(ref.Seq)
+	} else {
+		// no reference, so the alignment is already in reference coordinates
+		refToMSA = make([]int, len(first.Seq))
+		width = len(first.Seq)
+	}
+
+	start, end, err = checkArgs(len(refToMSA), start, end)
+	if err != nil {
+		return err
+	}
+
+	if translate && len(region.Positions) == 0 {
+		if (end-start+1)%3 != 0 {
+			return errors.New("can't --translate a region whose length (" + strconv.Itoa(end-start+1) + ") is not a multiple of three")
+		}
+		for p := start; p <= end; p++ {
+			region.Positions = append(region.Positions, p)
+		}
+	}
+
+	cOut := make(chan fasta.Record, 50+threads)
+	cWriteDone := make(chan bool)
+
+	if wrap > 0 {
+		go fasta.WriteWrapAlignment(cOut, out, wrap, cErr, cWriteDone)
+	} else {
+		go fasta.WriteAlignment(cOut, out, cErr, cWriteDone)
+	}
+
+	cSliceDone := fasta.StartWorkers(threads, func() {
+		sliceRecords(start, end, translate, region, refToMSA, width, cFR, cOut, cErr)
+	})
+
+	// the first record still needs to go to the output
+	cReadDone := cFRDone
+	if firstTaken {
+		cReadDone = fasta.AllDone(cFRDone, fasta.Requeue(cFR, first))
+	}
+
+	return fasta.WaitStages(cErr,
+		fasta.Stage{Done: cReadDone, Close: func() { close(cFR) }},
+		fasta.Stage{Done: cSliceDone, Close: func() { close(cOut) }},
+		fasta.Stage{Done: cWriteDone},
+	)
+}
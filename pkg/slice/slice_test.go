@@ -0,0 +1,147 @@
+package slice
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestSlice(t *testing.T) {
+	msaData := []byte(`>reference
+ACGTAATGATGATGTAG-AAAAAA
+>seq1
+ATGTATTGATGATGTAG-AAAATA
+>seq2
+ACGTA---ATGATGTAG-AAAAAA
+>seq3
+ACGTAATGATGATGTAGCAAAAAA
+`)
+
+	msa := bytes.NewReader(msaData)
+	out := new(bytes.Buffer)
+
+	err := Slice(msa, false, "reference", nil, "", "", 15, 20, false, out, -1, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(out.Bytes()) != `>reference
+TAG-AAA
+>seq1
+TAG-AAA
+>seq2
+TAG-AAA
+>seq3
+TAGCAAA
+` {
+		t.Errorf("problem in TestSlice()")
+		fmt.Println(string(out.Bytes()))
+	}
+
+	// without a reference, the alignment is assumed to be in reference coordinates
+	msa = bytes.NewReader(msaData)
+	out = new(bytes.Buffer)
+
+	err = Slice(msa, false, "", nil, "", "", 2, 4, false, out, -1, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(out.Bytes()) != `>reference
+CGT
+>seq1
+TGT
+>seq2
+CGT
+>seq3
+CGT
+` {
+		t.Errorf("problem in TestSlice() (no reference)")
+		fmt.Println(string(out.Bytes()))
+	}
+}
+
+func TestSliceFeature(t *testing.T) {
+	msaData := []byte(`>reference
+ACGTAATGATGATGTAG-AAAAAA
+>seq1
+ACGTATTGATGATGTAG-AAAAAA
+>seq2
+ACGTAATG---ATGTAG-AAAAAA
+>seq3
+ACGTAATGANGATGTAGCAAAAAA
+`)
+
+	msa := bytes.NewReader(msaData)
+	anno := bytes.NewReader(genbankData)
+	out := new(bytes.Buffer)
+
+	err := Slice(msa, false, "reference", anno, "gb", "gene1", -1, -1, false, out, -1, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(out.Bytes()) != `>reference
+ATGATGATGTAG
+>seq1
+TTGATGATGTAG
+>seq2
+ATG---ATGTAG
+>seq3
+ATGANGATGTAG
+` {
+		t.Errorf("problem in TestSliceFeature()")
+		fmt.Println(string(out.Bytes()))
+	}
+
+	msa = bytes.NewReader(msaData)
+	anno = bytes.NewReader(genbankData)
+	out = new(bytes.Buffer)
+
+	err = Slice(msa, false, "reference", anno, "gb", "gene1", -1, -1, true, out, -1, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(out.Bytes()) != `>reference
+MMM*
+>seq1
+LMM*
+>seq2
+M-M*
+>seq3
+MXM*
+` {
+		t.Errorf("problem in TestSliceFeature() (translate)")
+		fmt.Println(string(out.Bytes()))
+	}
+
+	msa = bytes.NewReader(msaData)
+	anno = bytes.NewReader(genbankData)
+	out = new(bytes.Buffer)
+
+	err = Slice(msa, false, "reference", anno, "gb", "gene2", -1, -1, false, out, -1, 2)
+	if err == nil {
+		t.Errorf("expected an error in TestSliceFeature() (missing feature)")
+	}
+}
+
+var genbankData []byte
+
+func init() {
+	genbankData = []byte(`LOCUS       TEST               23 bp ss-RNA     linear   VRL 21-MAR-1987
+FEATURES             Location/Qualifiers
+		source          1..23
+						/organism="Not a real organism"
+		5'UTR           1..5
+		gene            6..17
+						/gene="gene1"
+		CDS             6..17
+						/gene="gene1"
+						/codon_start=1
+						/translation="MMM"
+		3'UTR           18..23
+ORIGIN
+		1 acgtaatgat gatgtagaaa aaa
+`)
+}
@@ -0,0 +1,150 @@
+package snps
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/virus-evolution/gofasta/pkg/encoding"
+)
+
+// A Profile is one sequence's snps relative to a reference, formatted as in gofasta updown list (e.g. C3037T) and in
+// position order, their 1-based positions (in the same order), and the 1-based inclusive start-stop pairs of its tracts of sites that aren't
+// A, T, G or C, in position order
+type Profile struct {
+	SNPs      []string
+	Positions []int
+	Ambs      []int
+}
+
+// ProfileFromAlignment returns the Profile of an encoded sequence that is aligned to an encoded reference. Columns that
+// are gaps in the reference are skipped, and positions are in (degapped) reference coordinates
+func ProfileFromAlignment(ref, seq []byte) Profile {
+
+	DA := encoding.MakeDecodingArray()
+
+	p := Profile{SNPs: make([]string, 0), Positions: make([]int, 0), Ambs: make([]int, 0)}
+
+	pos := 0
+	for i := range ref {
+		if ref[i] == 244 {
+			continue
+		}
+		pos++
+		switch {
+		case seq[i]&8 != 8:
+			if len(p.Ambs) > 0 && p.Ambs[len(p.Ambs)-1] == pos-1 {
+				p.Ambs[len(p.Ambs)-1] = pos
+			} else {
+				p.Ambs = append(p.Ambs, pos, pos)
+			}
+		case ref[i]&seq[i] < 16:
+			p.SNPs = append(p.SNPs, DA[ref[i]]+strconv.Itoa(pos)+DA[seq[i]])
+			p.Positions = append(p.Positions, pos)
+		}
+	}
+
+	return p
+}
+
+// isMasked asks if pos is in one of a Profile's tracts of ambiguities
+func isMasked(pos int, ambs []int) bool {
+	// the index of the first tract that ends at or after pos
+	i := sort.Search(len(ambs)/2, func(i int) bool { return ambs[i*2+1] >= pos })
+	return i < len(ambs)/2 && ambs[i*2] <= pos
+}
+
+// compare walks the snps of two Profiles (which must be in position order) together. For each snp that is only in a,
+// aOnly is called with its index; likewise bOnly for b; shared is called with the indices of snps that are in both; and
+// masked is called with the position of each snp in either sequence that is at an ambiguity in the other
+func compare(a, b Profile, aOnly, bOnly func(int), shared func(int, int), masked func(int)) {
+	i, j := 0, 0
+	for i < len(a.SNPs) || j < len(b.SNPs) {
+		switch {
+		case j == len(b.SNPs) || (i < len(a.SNPs) && a.Positions[i] < b.Positions[j]):
+			if isMasked(a.Positions[i], b.Ambs) {
+				masked(a.Positions[i])
+			} else {
+				aOnly(i)
+			}
+			i++
+		case i == len(a.SNPs) || b.Positions[j] < a.Positions[i]:
+			if isMasked(b.Positions[j], a.Ambs) {
+				masked(b.Positions[j])
+			} else {
+				bOnly(j)
+			}
+			j++
+		default:
+			// both sequences have a snp at this site, so neither is ambiguous here
+			if a.SNPs[i] == b.SNPs[j] {
+				shared(i, j)
+			} else {
+				aOnly(i)
+				bOnly(j)
+			}
+			i++
+			j++
+		}
+	}
+}
+
+// Private compares the snps of two sequences that are aligned to the same reference. It returns the snps that are
+// only in a and those that are only in b (as Profiles without ambiguities), and the sorted positions of snps in either
+// sequence that are masked by an ambiguity in the other. These are the Q, T and ambiguous bins of the table that
+// gofasta updown uses to find the direction between a query and a target
+func Private(a, b Profile) (Profile, Profile, []int) {
+
+	aOnly := Profile{SNPs: make([]string, 0), Positions: make([]int, 0)}
+	bOnly := Profile{SNPs: make([]string, 0), Positions: make([]int, 0)}
+	masked := make([]int, 0)
+
+	compare(a, b,
+		func(i int) {
+			aOnly.SNPs = append(aOnly.SNPs, a.SNPs[i])
+			aOnly.Positions = append(aOnly.Positions, a.Positions[i])
+		},
+		func(j int) {
+			bOnly.SNPs = append(bOnly.SNPs, b.SNPs[j])
+			bOnly.Positions = append(bOnly.Positions, b.Positions[j])
+		},
+		func(i, j int) {},
+		func(pos int) { masked = append(masked, pos) },
+	)
+
+	return aOnly, bOnly, masked
+}
+
+// Distance returns the SNP-distance between two sequences that are aligned to the same reference, which is the number
+// of sites where both have certain nucleotides that differ (as closest's snp measure counts it for aligned sequences,
+// except that partially ambiguous nucleotides are always masked here). It also returns the fraction of all the sites
+// with a snp in either sequence that are masked by an ambiguity in the other
+func Distance(a, b Profile) (int, float32) {
+
+	distance, sites, masked := 0, 0, 0
+	last := 0
+
+	// a site where the sequences have different snps is counted once
+	differ := func(pos int) {
+		if pos != last {
+			distance++
+			sites++
+		}
+		last = pos
+	}
+
+	compare(a, b,
+		func(i int) { differ(a.Positions[i]) },
+		func(j int) { differ(b.Positions[j]) },
+		func(i, j int) { sites++ },
+		func(pos int) {
+			masked++
+			sites++
+		},
+	)
+
+	if sites == 0 {
+		return 0, 0
+	}
+
+	return distance, float32(masked) / float32(sites)
+}
@@ -0,0 +1,62 @@
+package snps
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/virus-evolution/gofasta/pkg/fasta"
+)
+
+func TestPrivate(t *testing.T) {
+	ref, _ := fasta.Record{ID: "ref", Seq: "ATGA-TGATG"}.Encode()
+	query, _ := fasta.Record{ID: "query", Seq: "TTNA-TGACG"}.Encode()
+	target, _ := fasta.Record{ID: "target", Seq: "TNCA-TGTTN"}.Encode()
+
+	q := ProfileFromAlignment(ref.Seq, query.Seq)
+	tt := ProfileFromAlignment(ref.Seq, target.Seq)
+
+	if !reflect.DeepEqual(q, Profile{SNPs: []string{"A1T", "T8C"}, Positions: []int{1, 8}, Ambs: []int{3, 3}}) {
+		t.Errorf("problem in TestPrivate() (profile)")
+		fmt.Println(q)
+	}
+
+	qOnly, tOnly, masked := Private(q, tt)
+
+	if !reflect.DeepEqual(qOnly, Profile{SNPs: []string{"T8C"}, Positions: []int{8}}) {
+		t.Errorf("problem in TestPrivate() (query)")
+		fmt.Println(qOnly)
+	}
+	if !reflect.DeepEqual(tOnly, Profile{SNPs: []string{"A7T"}, Positions: []int{7}}) {
+		t.Errorf("problem in TestPrivate() (target)")
+		fmt.Println(tOnly)
+	}
+	if !reflect.DeepEqual(masked, []int{3}) {
+		t.Errorf("problem in TestPrivate() (masked)")
+		fmt.Println(masked)
+	}
+}
+
+func TestDistance(t *testing.T) {
+	ref, _ := fasta.Record{ID: "ref", Seq: "ATGA-TGATG"}.Encode()
+	query, _ := fasta.Record{ID: "query", Seq: "TTNA-TGACG"}.Encode()
+	target, _ := fasta.Record{ID: "target", Seq: "TNCA-TGTCN"}.Encode()
+
+	q := ProfileFromAlignment(ref.Seq, query.Seq)
+	tt := ProfileFromAlignment(ref.Seq, target.Seq)
+
+	// A1T and T8C are shared, G3C is masked, and A7T differs
+	distance, ambiguous := Distance(q, tt)
+	if distance != 1 || ambiguous != 0.25 {
+		t.Errorf("problem in TestDistance()")
+		fmt.Println(distance, ambiguous)
+	}
+
+	// different snps at the same site count once
+	other, _ := fasta.Record{ID: "other", Seq: "TTGA-TGAAG"}.Encode()
+	distance, ambiguous = Distance(q, ProfileFromAlignment(ref.Seq, other.Seq))
+	if distance != 1 || ambiguous != 0 {
+		t.Errorf("problem in TestDistance() (same site)")
+		fmt.Println(distance, ambiguous)
+	}
+}
@@ -0,0 +1,312 @@
+package snps
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/virus-evolution/gofasta/pkg/encoding"
+	"github.com/virus-evolution/gofasta/pkg/fasta"
+)
+
+// spectrumBases is the order that bases are indexed in, and that substitution types are written in
+const spectrumBases = "ACGT"
+
+// baseIndex returns the index of an unambiguous nucleotide in spectrumBases, or -1
+func baseIndex(b string) int {
+	if len(b) != 1 {
+		return -1
+	}
+	return strings.Index(spectrumBases, b)
+}
+
+// spectrumIndex returns the index of a substitution type in a spectrum array. Without context, the
+// array has 16 elements (ref, alt). With context, it has 256 elements (5' base, ref, alt, 3' base).
+// Elements where ref == alt are never filled
+func spectrumIndex(five, ref, alt, three int, context bool) int {
+	if context {
+		return ((five*4+ref)*4+alt)*4 + three
+	}
+	return ref*4 + alt
+}
+
+// spectrumLabels returns the name of each substitution type in a spectrum array, in the order they are written
+// out, and their indices in the array
+func spectrumLabels(context bool) ([]string, []int) {
+	labels := make([]string, 0)
+	indices := make([]int, 0)
+	for ref := 0; ref < 4; ref++ {
+		for alt := 0; alt < 4; alt++ {
+			if ref == alt {
+				continue
+			}
+			sub := spectrumBases[ref:ref+1] + ">" + spectrumBases[alt:alt+1]
+			if !context {
+				labels = append(labels, sub)
+				indices = append(indices, spectrumIndex(0, ref, alt, 0, false))
+				continue
+			}
+			for five := 0; five < 4; five++ {
+				for three := 0; three < 4; three++ {
+					labels = append(labels, spectrumBases[five:five+1]+"["+sub+"]"+spectrumBases[three:three+1])
+					indices = append(indices, spectrumIndex(five, ref, alt, three, true))
+				}
+			}
+		}
+	}
+	return labels, indices
+}
+
+// referenceContext returns, for each column of the (possibly gapped) reference, the index of the nearest
+// ungapped reference base to the 5' and 3' side of it, or -1 if there is none or if that base is ambiguous.
+func referenceContext(refSeq []byte) ([]int, []int) {
+	DA := encoding.MakeDecodingArray()
+
+	fives := make([]int, len(refSeq))
+	threes := make([]int, len(refSeq))
+
+	last := -1
+	for i := range refSeq {
+		fives[i] = last
+		if DA[refSeq[i]] != "-" {
+			last = baseIndex(DA[refSeq[i]])
+		}
+	}
+	last = -1
+	for i := len(refSeq) - 1; i >= 0; i-- {
+		threes[i] = last
+		if DA[refSeq[i]] != "-" {
+			last = baseIndex(DA[refSeq[i]])
+		}
+	}
+
+	return fives, threes
+}
+
+// referenceComposition counts the number of occurrences of each base (or trinucleotide, if context) in the
+// reference, indexed as in spectrumIndex, so that each substitution type can be normalised by the number of sites
+// where it could have happened
+func referenceComposition(refSeq []byte, fives, threes []int, context bool) []int {
+	DA := encoding.MakeDecodingArray()
+
+	size := 16
+	if context {
+		size = 256
+	}
+	composition := make([]int, size)
+
+	for i, nuc := range refSeq {
+		ref := baseIndex(DA[nuc])
+		if ref == -1 {
+			continue
+		}
+		if context && (fives[i] == -1 || threes[i] == -1) {
+			continue
+		}
+		for alt := 0; alt < 4; alt++ {
+			if alt != ref {
+				composition[spectrumIndex(fives[i], ref, alt, threes[i], context)]++
+			}
+		}
+	}
+
+	return composition
+}
+
+// countSpectrum tallies one record's SNPs (as produced by getSNPs) into a spectrum array. SNPs where the
+// reference or the query base is ambiguous are ignored, as are SNPs with context that is unknown in the reference
+func countSpectrum(snps []string, fives, threes []int, context bool, counts []int) error {
+	for _, snp := range snps {
+		ref := baseIndex(snp[0:1])
+		alt := baseIndex(snp[len(snp)-1:])
+		if ref == -1 || alt == -1 {
+			continue
+		}
+		pos, err := strconv.Atoi(snp[1 : len(snp)-1])
+		if err != nil {
+			return err
+		}
+		if context && (fives[pos-1] == -1 || threes[pos-1] == -1) {
+			continue
+		}
+		counts[spectrumIndex(fives[pos-1], ref, alt, threes[pos-1], context)]++
+	}
+	return nil
+}
+
+// writeSpectrum collects the SNPs for each record from a channel, and writes the counts of each substitution
+// type as a tidy tsv, either per record (in input order) or summed over each group (or over the whole alignment,
+// if there are no groups)
+func writeSpectrum(w io.Writer, fives, threes []int, context bool, perSequence bool, groups map[string]string, composition []int, cSNPs chan snpLine, cErr chan error, cWriteDone chan bool) {
+
+	labels, indices := spectrumLabels(context)
+
+	size := 16
+	if context {
+		size = 256
+	}
+
+	grouped := groups != nil
+
+	columns := make([]string, 0)
+	if perSequence {
+		columns = append(columns, "query")
+	}
+	if grouped {
+		columns = append(columns, "group")
+	}
+	columns = append(columns, "substitution", "count")
+	if composition != nil {
+		columns = append(columns, "sites", "normalised")
+	}
+
+	_, err := w.Write([]byte(strings.Join(columns, "\t") + "\n"))
+	if err != nil {
+		cErr <- err
+		return
+	}
+
+	writeCounts := func(prefix []string, counts []int) error {
+		for i, label := range labels {
+			fields := append(append(make([]string, 0), prefix...), label, strconv.Itoa(counts[indices[i]]))
+			if composition != nil {
+				sites := composition[indices[i]]
+				normalised := 0.0
+				if sites > 0 {
+					normalised = float64(counts[indices[i]]) / float64(sites)
+				}
+				fields = append(fields, strconv.Itoa(sites), strconv.FormatFloat(normalised, 'f', 9, 64))
+			}
+			_, err := w.Write([]byte(strings.Join(fields, "\t") + "\n"))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	groupOf := func(id string) string {
+		if g, ok := groups[id]; ok && g != "" {
+			return g
+		}
+		return "NA"
+	}
+
+	outputMap := make(map[int]snpLine)
+	groupCounts := make(map[string][]int)
+	counter := 0
+
+	for SL := range cSNPs {
+		outputMap[SL.idx] = SL
+		for {
+			if record, ok := outputMap[counter]; ok {
+				counts := make([]int, size)
+				err = countSpectrum(record.snps, fives, threes, context, counts)
+				if err != nil {
+					cErr <- err
+					return
+				}
+				if perSequence {
+					prefix := []string{record.queryname}
+					if grouped {
+						prefix = append(prefix, groupOf(record.queryname))
+					}
+					err = writeCounts(prefix, counts)
+					if err != nil {
+						cErr <- err
+						return
+					}
+				} else {
+					g := ""
+					if grouped {
+						g = groupOf(record.queryname)
+					}
+					if _, ok := groupCounts[g]; !ok {
+						groupCounts[g] = make([]int, size)
+					}
+					for i := range counts {
+						groupCounts[g][i] += counts[i]
+					}
+				}
+				delete(outputMap, counter)
+				counter++
+			} else {
+				break
+			}
+		}
+	}
+
+	if !perSequence {
+		if !grouped && len(groupCounts) == 0 {
+			groupCounts[""] = make([]int, size)
+		}
+		order := make([]string, 0)
+		for g := range groupCounts {
+			order = append(order, g)
+		}
+		sort.Strings(order)
+		for _, g := range order {
+			prefix := []string{}
+			if grouped {
+				prefix = append(prefix, g)
+			}
+			err = writeCounts(prefix, groupCounts[g])
+			if err != nil {
+				cErr <- err
+				return
+			}
+		}
+	}
+
+	cWriteDone <- true
+}
+
+// Spectrum counts the substitutions between each record in a fasta-format alignment and a reference sequence by type:
+// the 12 directional types (e.g. C>T), or the 192 types with trinucleotide context from the reference (e.g. A[C>T]G)
+// if context is true. Only substitutions between unambiguous nucleotides are counted. Counts are written as a tidy tsv,
+// per sequence if perSequence is true, and summed within groups if groups (a map of sequence ID to group) is not nil,
+// otherwise summed over the whole alignment. If normalise is true, each count is also divided by the number of sites
+// in the reference where that substitution type could occur (i.e. the frequency of its base or trinucleotide)
+func Spectrum(ref, alignment io.Reader, context bool, perSequence bool, groups map[string]string, normalise bool, w io.Writer, threads int) error {
+
+	refs, err := fasta.LoadEncodeAlignment(ref, false, false, false)
+	if err != nil {
+		return err
+	}
+	if len(refs) > 1 {
+		return errors.New("more than one record in --reference")
+	}
+	refSeq := refs[0].Seq
+
+	fives, threes := referenceContext(refSeq)
+
+	var composition []int
+	if normalise {
+		composition = referenceComposition(refSeq, fives, threes, context)
+	}
+
+	cErr := make(chan error)
+
+	cFR := make(chan fasta.EncodedRecord)
+	cFRDone := make(chan bool)
+
+	cSNPs := make(chan snpLine, threads)
+
+	cWriteDone := make(chan bool)
+
+	go fasta.StreamEncodeAlignment(alignment, cFR, cErr, cFRDone, false, false, false)
+
+	go writeSpectrum(w, fives, threes, context, perSequence, groups, composition, cSNPs, cErr, cWriteDone)
+
+	cSNPsDone := fasta.StartWorkers(threads, func() {
+		getSNPs(refSeq, cFR, cSNPs, cErr)
+	})
+
+	return fasta.WaitStages(cErr,
+		fasta.Stage{Done: cFRDone, Close: func() { close(cFR) }},
+		fasta.Stage{Done: cSNPsDone, Close: func() { close(cSNPs) }},
+		fasta.Stage{Done: cWriteDone},
+	)
+}
@@ -0,0 +1,96 @@
+package snps
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+var spectrumRefData = []byte(`>ref
+ACGTACGT
+`)
+
+var spectrumQueryData = []byte(`>Query1
+ATGTACGT
+>Query2
+ACGTATGN
+>Query3
+ACGAACGT
+`)
+
+func TestSpectrum(t *testing.T) {
+	ref := bytes.NewReader(spectrumRefData)
+	query := bytes.NewReader(spectrumQueryData)
+	out := new(bytes.Buffer)
+
+	err := Spectrum(ref, query, false, false, nil, true, out, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `substitution	count	sites	normalised
+A>C	0	2	0.000000000
+A>G	0	2	0.000000000
+A>T	0	2	0.000000000
+C>A	0	2	0.000000000
+C>G	0	2	0.000000000
+C>T	2	2	1.000000000
+G>A	0	2	0.000000000
+G>C	0	2	0.000000000
+G>T	0	2	0.000000000
+T>A	1	2	0.500000000
+T>C	0	2	0.000000000
+T>G	0	2	0.000000000
+` {
+		t.Errorf("problem in TestSpectrum()")
+		fmt.Println(out.String())
+	}
+}
+
+func TestSpectrumGroups(t *testing.T) {
+	ref := bytes.NewReader(spectrumRefData)
+	query := bytes.NewReader(spectrumQueryData)
+	out := new(bytes.Buffer)
+
+	groups := map[string]string{"Query1": "g1", "Query2": "g1"}
+
+	err := Spectrum(ref, query, true, false, groups, false, out, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1+2*192 {
+		t.Errorf("wrong number of lines in TestSpectrumGroups(): %d", len(lines))
+	}
+	for _, line := range []string{"g1\tA[C>T]G\t2", "NA\tG[T>A]A\t1", "NA\tA[C>T]G\t0"} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("problem in TestSpectrumGroups(): missing line %q", line)
+		}
+	}
+}
+
+func TestSpectrumPerSequence(t *testing.T) {
+	ref := bytes.NewReader(spectrumRefData)
+	query := bytes.NewReader(spectrumQueryData)
+	out := new(bytes.Buffer)
+
+	err := Spectrum(ref, query, true, true, nil, false, out, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1+3*192 {
+		t.Errorf("wrong number of lines in TestSpectrumPerSequence(): %d", len(lines))
+	}
+	if lines[0] != "query\tsubstitution\tcount" || !strings.HasPrefix(lines[1], "Query1\t") || !strings.HasPrefix(lines[1+2*192], "Query3\t") {
+		t.Errorf("problem in TestSpectrumPerSequence()")
+	}
+	for _, line := range []string{"Query1\tA[C>T]G\t1", "Query2\tA[C>T]G\t1", "Query3\tG[T>A]A\t1", "Query3\tA[C>T]G\t0"} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("problem in TestSpectrumPerSequence(): missing line %q", line)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+/*
+Package translate implements functionality to translate every record in a
+multiple sequence alignment in fasta format to protein, one alignment per
+protein-coding feature in a genome annotation.
+*/
+package translate
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/virus-evolution/gofasta/pkg/fasta"
+	"github.com/virus-evolution/gofasta/pkg/variants"
+)
+
+// translatedRecord is a struct for the translations of one fasta record, one
+// for each protein-coding region, and an index which is used to retain input
+// order in the output
+type translatedRecord struct {
+	id           string
+	translations []string
+	idx          int
+}
+
+// translateRecords is a worker function that translates every protein-coding region for
+// each fasta record from a channel, and passes the result to another channel
+func translateRecords(cdsregions []variants.Region, refToMSA []int, width int, cFR chan fasta.Record, cTR chan translatedRecord, cErr chan error) {
+	for record := range cFR {
+		if len(record.Seq) != width {
+			cErr <- errors.New("Gapped reference sequence and alignment are not the same width")
+			break
+		}
+		TR := translatedRecord{id: record.ID, idx: record.Idx, translations: make([]string, len(cdsregions))}
+		for i, r := range cdsregions {
+			TR.translations[i] = variants.TranslateRegion(record.Seq, r, refToMSA)
+		}
+		cTR <- TR
+	}
+}
+
+// regionFileNames returns one file name for each protein-coding region, based on the region's name.
+// Regions with the same name are numbered to tell them apart
+func regionFileNames(cdsregions []variants.Region) []string {
+	names := make([]string, len(cdsregions))
+	seen := make(map[string]int)
+	for i, r := range cdsregions {
+		// forward slashes are illegal in unix filenames
+		name := strings.ReplaceAll(r.Name, "/", "_")
+		seen[name]++
+		if seen[name] > 1 {
+			name = fmt.Sprintf("%s_%d", name, seen[name])
+		}
+		names[i] = name + ".fasta"
+	}
+	return names
+}
+
+// splitTranslations passes the translation of each protein-coding region of each record to that region's channel of
+// fasta records, or joins the translations together and passes them to the first channel if concatenate is true
+func splitTranslations(concatenate bool, cTR chan translatedRecord, cFRs []chan fasta.Record, cSplitDone chan bool) {
+	for TR := range cTR {
+		if concatenate {
+			cFRs[0] <- fasta.Record{ID: TR.id, Description: TR.id, Seq: strings.Join(TR.translations, ""), Idx: TR.idx}
+			continue
+		}
+		for i, t := range TR.translations {
+			cFRs[i] <- fasta.Record{ID: TR.id, Description: TR.id, Seq: t, Idx: TR.idx}
+		}
+	}
+	cSplitDone <- true
+}
+
+// Translate translates every record in a fasta-format alignment to protein, given the protein-coding
+// regions in a genbank or gff version 3 format annotation of the reference sequence. Joins and features
+// on the reverse strand are respected, insertions relative to the reference are ignored, codons with gaps
+// are translated as '-' and codons that can't be resolved to a single amino acid are translated as 'X'.
+// One protein alignment is written per region, to a directory, outpath, unless concatenate is true,
+// in which case the translations of all the regions are joined together and written to out.
+func Translate(msaIn io.Reader, stdin bool, refID string, annoIn io.Reader, annoSuffix string, out io.Writer, outpath string, concatenate bool, wrap int, threads int) error {
+
+	var first fasta.Record
+
+	ref, err := variants.FindAndRewind(msaIn, stdin, refID)
+	if err != nil {
+		return err
+	}
+
+	cFR := make(chan fasta.Record, 50+threads)
+	cErr := make(chan error)
+	cFRDone := make(chan bool)
+
+	go fasta.StreamAlignment(msaIn, cFR, cErr, cFRDone)
+
+	firstTaken := false
+
+	if stdin && refID != "" {
+		first, err = fasta.FirstRecord(cFR, cErr, cFRDone)
+		if err != nil {
+			return err
+		}
+		firstTaken = true
+		if first.ID != refID {
+			return errors.New("--reference is not the first record in --msa")
+		}
+		ref, err = first.Encode()
+		if err != nil {
+			return err
+		}
+	}
+
+	ref, cdsregions, _, err := variants.RegionsFromAnnotation(annoIn, annoSuffix, ref)
+	if err != nil {
+		return err
+	}
+	if len(cdsregions) == 0 {
+		return errors.New("couldn't find any protein-coding regions in the --annotation")
+	}
+
+	refToMSA, _ := variants.GetMSAOffsets(ref.Seq)
+
+	ws := make([]io.Writer, 0)
+	files := make([]*os.File, 0)
+	buffers := make([]*bufio.Writer, 0)
+	if concatenate {
+		ws = append(ws, out)
+	} else {
+		err = os.MkdirAll(outpath, 0755)
+		if err != nil {
+			return err
+		}
+		for _, name := range regionFileNames(cdsregions) {
+			f, err := os.Create(path.Join(outpath, name))
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			b := bufio.NewWriter(f)
+			files = append(files, f)
+			buffers = append(buffers, b)
+			ws = append(ws, b)
+		}
+	}
+
+	cTR := make(chan translatedRecord, 50+threads)
+	cSplitDone := make(chan bool)
+
+	cFRs := make([]chan fasta.Record, len(ws))
+	cWriteDones := make([]chan bool, len(ws))
+	for i, w := range ws {
+		cFRs[i] = make(chan fasta.Record, 50+threads)
+		cWriteDones[i] = make(chan bool)
+		if wrap > 0 {
+			go fasta.WriteWrapAlignment(cFRs[i], w, wrap, cErr, cWriteDones[i])
+		} else {
+			go fasta.WriteAlignment(cFRs[i], w, cErr, cWriteDones[i])
+		}
+	}
+
+	go splitTranslations(concatenate, cTR, cFRs, cSplitDone)
+
+	cTranslateDone := fasta.StartWorkers(threads, func() {
+		translateRecords(cdsregions, refToMSA, len(ref.Seq), cFR, cTR, cErr)
+	})
+
+	// the reference still needs to go to the output if we took it from the stream
+	cReadDone := cFRDone
+	if firstTaken {
+		cReadDone = fasta.AllDone(cFRDone, fasta.Requeue(cFR, first))
+	}
+
+	err = fasta.WaitStages(cErr,
+		fasta.Stage{Done: cReadDone, Close: func() { close(cFR) }},
+		fasta.Stage{Done: cTranslateDone, Close: func() { close(cTR) }},
+		fasta.Stage{Done: cSplitDone, Close: func() {
+			for _, c := range cFRs {
+				close(c)
+			}
+		}},
+		fasta.Stage{Done: fasta.AllDone(cWriteDones...)},
+	)
+	if err != nil {
+		return err
+	}
+
+	for i := range files {
+		err = buffers[i].Flush()
+		if err != nil {
+			return err
+		}
+		err = files[i].Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package translate
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestTranslate(t *testing.T) {
+	msaData := []byte(`>reference
+ACGTAATGATGATGTAG-AAAAAA
+>seq1
+ACGTATTGATGATGTAG-AAAAGA
+>seq2
+ACGTAATG---ATGTAG-AAAAAA
+>seq3
+ACGTAATGANGATGTAGCAAAAAA
+`)
+
+	msa := bytes.NewReader(msaData)
+	anno := bytes.NewReader(genbankData)
+	out := new(bytes.Buffer)
+
+	err := Translate(msa, false, "reference", anno, "gb", out, "", true, -1, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(out.Bytes()) != `>reference
+MM*FF
+>seq1
+LM*SF
+>seq2
+MM*FF
+>seq3
+MM*FF
+` {
+		t.Errorf("problem in TestTranslate()")
+		fmt.Println(string(out.Bytes()))
+	}
+
+	msa = bytes.NewReader(msaData)
+	anno = bytes.NewReader(genbankData)
+	outpath := t.TempDir()
+
+	err = Translate(msa, false, "reference", anno, "gb", nil, outpath, false, -1, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	gene1, err := os.ReadFile(path.Join(outpath, "gene1.fasta"))
+	if err != nil {
+		t.Error(err)
+	}
+	if string(gene1) != `>reference
+MM*
+>seq1
+LM*
+>seq2
+MM*
+>seq3
+MM*
+` {
+		t.Errorf("problem in TestTranslate() (gene1)")
+		fmt.Println(string(gene1))
+	}
+
+	gene2, err := os.ReadFile(path.Join(outpath, "gene2.fasta"))
+	if err != nil {
+		t.Error(err)
+	}
+	if string(gene2) != `>reference
+FF
+>seq1
+SF
+>seq2
+FF
+>seq3
+FF
+` {
+		t.Errorf("problem in TestTranslate() (gene2)")
+		fmt.Println(string(gene2))
+	}
+}
+
+func TestTranslateGapsAndAmbiguity(t *testing.T) {
+	msaData := []byte(`>reference
+ACGTAATGATGATGTAG-AAAAAA
+>gaps
+ACGTAA-GATGATGTAG--AAAAA
+>ambiguous
+ACGTAATGATGNTGTAG-AAAAAA
+>resolved
+ACGTAATGATGATGTAG-AAARAA
+`)
+
+	msa := bytes.NewReader(msaData)
+	anno := bytes.NewReader(genbankData)
+	out := new(bytes.Buffer)
+
+	err := Translate(msa, false, "reference", anno, "gb", out, "", true, -1, 1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if string(out.Bytes()) != `>reference
+MM*FF
+>gaps
+-M*F-
+>ambiguous
+MX*FF
+>resolved
+MM*FF
+` {
+		t.Errorf("problem in TestTranslateGapsAndAmbiguity()")
+		fmt.Println(string(out.Bytes()))
+	}
+}
+
+var genbankData []byte
+
+func init() {
+	genbankData = []byte(`LOCUS       TEST               23 bp ss-RNA     linear   VRL 21-MAR-1987
+FEATURES             Location/Qualifiers
+		source          1..23
+						/organism="Not a real organism"
+		5'UTR           1..5
+		gene            join(6..8,12..17)
+						/gene="gene1"
+		CDS             join(6..8,12..17)
+						/gene="gene1"
+						/codon_start=1
+						/translation="MM"
+		gene            complement(18..23)
+						/gene="gene2"
+		CDS             complement(18..23)
+						/gene="gene2"
+						/codon_start=1
+						/translation="FF"
+ORIGIN
+		1 acgtaatgat gatgtagaaa aaa
+`)
+}
@@ -0,0 +1,68 @@
+package tree
+
+import (
+	"bufio"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// A Node is a node in a rooted tree. Leaves have no children, and internal nodes can have names too (for sampled
+// ancestors, for example). Length is the length of the branch to the node's parent, which is ignored for the root
+type Node struct {
+	Name     string
+	Length   float64
+	Children []*Node
+}
+
+// newickName quotes a node name if it contains any characters that have a meaning in Newick format
+func newickName(name string) string {
+	if strings.ContainsAny(name, " \t()[]':;,") {
+		return "'" + strings.ReplaceAll(name, "'", "''") + "'"
+	}
+	return name
+}
+
+// WriteNewick writes a tree in Newick format, followed by a newline. It doesn't recurse, so it can write trees that
+// are very deep
+func WriteNewick(w io.Writer, root *Node) error {
+
+	bw := bufio.NewWriter(w)
+
+	type frame struct {
+		node *Node
+		next int // the index of the next child to write
+	}
+
+	stack := []frame{{node: root}}
+
+	for len(stack) > 0 {
+		f := &stack[len(stack)-1]
+		n := f.node
+
+		if f.next < len(n.Children) {
+			if f.next == 0 {
+				bw.WriteString("(")
+			} else {
+				bw.WriteString(",")
+			}
+			child := n.Children[f.next]
+			f.next++
+			stack = append(stack, frame{node: child})
+			continue
+		}
+
+		if len(n.Children) > 0 {
+			bw.WriteString(")")
+		}
+		bw.WriteString(newickName(n.Name))
+		if len(stack) > 1 {
+			bw.WriteString(":" + strconv.FormatFloat(n.Length, 'f', -1, 64))
+		}
+		stack = stack[:len(stack)-1]
+	}
+
+	bw.WriteString(";\n")
+
+	return bw.Flush()
+}
@@ -0,0 +1,185 @@
+package tree
+
+import (
+	"errors"
+)
+
+// adjacency is one end of a branch in an unrooted tree
+type adjacency struct {
+	to     int
+	length float64
+}
+
+// neighbourJoining builds an unrooted tree from a distance matrix, by neighbour-joining (Saitou N, Nei M. The
+// neighbor-joining method: a new method for reconstructing phylogenetic trees. Mol Biol Evol. 1987 Jul;4(4):406-25.
+// doi: 10.1093/oxfordjournals.molbev.a040454) or, if bionj is true, by BIONJ (Gascuel O. BIONJ: an improved version
+// of the NJ algorithm based on a simple model of sequence data. Mol Biol Evol. 1997 Jul;14(7):685-95. doi:
+// 10.1093/oxfordjournals.molbev.a025808). It returns the tree as an adjacency list, in which nodes 0 to n-1 are the
+// leaves (in the same order as the matrix) and the rest are internal. Ties are broken by input order, so the topology
+// is reproducible, and negative branch lengths are set to zero
+func neighbourJoining(d [][]float64, bionj bool) ([][]adjacency, error) {
+
+	n := len(d)
+	if n < 2 {
+		return nil, errors.New("need at least two sequences to make a tree")
+	}
+
+	size := 2*n - 2
+	if n == 2 {
+		size = 2
+	}
+	adj := make([][]adjacency, size)
+
+	link := func(a, b int, length float64) {
+		if length < 0 {
+			length = 0
+		}
+		adj[a] = append(adj[a], adjacency{to: b, length: length})
+		adj[b] = append(adj[b], adjacency{to: a, length: length})
+	}
+
+	if n == 2 {
+		link(0, 1, d[0][1])
+		return adj, nil
+	}
+
+	// working copies of the distance (and variance, for BIONJ) matrices, with room for the internal nodes
+	D := make([][]float64, size)
+	V := make([][]float64, size)
+	for i := range D {
+		D[i] = make([]float64, size)
+		V[i] = make([]float64, size)
+		if i < n {
+			copy(D[i], d[i])
+			copy(V[i], d[i])
+		}
+	}
+
+	active := make([]int, n)
+	for i := range active {
+		active[i] = i
+	}
+
+	S := make([]float64, size)
+	next := n
+
+	for len(active) > 3 {
+		r := float64(len(active))
+
+		for _, a := range active {
+			S[a] = 0
+			for _, b := range active {
+				S[a] += D[a][b]
+			}
+		}
+
+		bi, bj := -1, -1
+		var bestQ float64
+		for x := 0; x < len(active); x++ {
+			for y := x + 1; y < len(active); y++ {
+				a, b := active[x], active[y]
+				q := (r-2)*D[a][b] - S[a] - S[b]
+				if bi == -1 || q < bestQ {
+					bestQ = q
+					bi, bj = x, y
+				}
+			}
+		}
+
+		i, j := active[bi], active[bj]
+
+		li := D[i][j]/2 + (S[i]-S[j])/(2*(r-2))
+		lj := D[i][j] - li
+
+		lambda := 0.5
+		if bionj && V[i][j] > 0 {
+			sum := 0.0
+			for _, k := range active {
+				if k != i && k != j {
+					sum += V[j][k] - V[i][k]
+				}
+			}
+			lambda = 0.5 + sum/(2*(r-2)*V[i][j])
+			if lambda < 0 {
+				lambda = 0
+			} else if lambda > 1 {
+				lambda = 1
+			}
+		}
+
+		u := next
+		next++
+
+		for _, k := range active {
+			if k == i || k == j {
+				continue
+			}
+			D[u][k] = lambda*(D[i][k]-li) + (1-lambda)*(D[j][k]-lj)
+			D[k][u] = D[u][k]
+			V[u][k] = lambda*V[i][k] + (1-lambda)*V[j][k] - lambda*(1-lambda)*V[i][j]
+			V[k][u] = V[u][k]
+		}
+
+		// keep the branch lengths non-negative, but their sum the same where possible
+		if li < 0 {
+			lj += li
+			li = 0
+		} else if lj < 0 {
+			li += lj
+			lj = 0
+		}
+		link(u, i, li)
+		link(u, j, lj)
+
+		remaining := make([]int, 0, len(active)-1)
+		for x, a := range active {
+			if x != bi && x != bj {
+				remaining = append(remaining, a)
+			}
+		}
+		active = append(remaining, u)
+	}
+
+	// join the last three nodes to a central node
+	a, b, c := active[0], active[1], active[2]
+	u := next
+	link(u, a, (D[a][b]+D[a][c]-D[b][c])/2)
+	link(u, b, (D[a][b]+D[b][c]-D[a][c])/2)
+	link(u, c, (D[a][c]+D[b][c]-D[a][b])/2)
+
+	return adj, nil
+}
+
+// subtree converts the part of an unrooted tree that is reached from node v without going through node from into a
+// rooted tree
+func subtree(adj [][]adjacency, names []string, v, from int, length float64) *Node {
+	node := &Node{Length: length}
+	if v < len(names) {
+		node.Name = names[v]
+	}
+	for _, a := range adj[v] {
+		if a.to == from {
+			continue
+		}
+		node.Children = append(node.Children, subtree(adj, names, a.to, v, a.length))
+	}
+	return node
+}
+
+// rootTree converts an unrooted tree to a rooted one. If root is -1, the tree is drawn from its last internal node, so
+// the base is a polytomy. Otherwise it is rooted at leaf root: the root of the tree is at the end of that leaf's branch,
+// so the leaf hangs off it with length zero and everything else descends from it
+func rootTree(adj [][]adjacency, names []string, root int) *Node {
+	if root == -1 {
+		if len(names) == 2 {
+			half := adj[0][0].length / 2
+			return &Node{Children: []*Node{{Name: names[0], Length: half}, {Name: names[1], Length: half}}}
+		}
+		return subtree(adj, names, len(adj)-1, -1, 0)
+	}
+	a := adj[root][0]
+	return &Node{Children: []*Node{
+		{Name: names[root], Length: 0},
+		subtree(adj, names, a.to, root, a.length),
+	}}
+}
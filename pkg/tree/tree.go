@@ -0,0 +1,67 @@
+/*
+Package tree implements functionality to build phylogenetic trees from a
+multiple sequence alignment in fasta format by distance-based methods, and
+to write trees in Newick format.
+*/
+package tree
+
+import (
+	"errors"
+	"io"
+
+	"github.com/virus-evolution/gofasta/pkg/closest"
+	"github.com/virus-evolution/gofasta/pkg/fasta"
+)
+
+// Tree calculates the genetic distance (by any of closest's measures: raw, snp or tn93) between every pair of
+// sequences in a fasta-format alignment, builds a tree from them by neighbour-joining (method "nj") or BIONJ (method
+// "bionj"), and writes it in Newick format. If root is the ID of a sequence in the alignment, the tree is rooted on
+// that sequence, otherwise it is drawn from an arbitrary internal node. The distances are calculated in parallel
+func Tree(msaIn io.Reader, measure, method string, root string, out io.Writer, threads int) error {
+
+	switch method {
+	case "nj", "bionj":
+	default:
+		return errors.New("unknown tree --method: " + method + " (choose one of nj or bionj)")
+	}
+
+	records, err := fasta.LoadEncodeAlignment(msaIn, false, true, false)
+	if err != nil {
+		return err
+	}
+	if len(records) < 2 {
+		return errors.New("need at least two sequences in --msa to make a tree")
+	}
+
+	names := make([]string, len(records))
+	seen := make(map[string]bool, len(records))
+	rootIdx := -1
+	for i, record := range records {
+		if len(record.Seq) != len(records[0].Seq) {
+			return errors.New("sequences in --msa are not all the same length: is it an alignment?")
+		}
+		if seen[record.ID] {
+			return errors.New("duplicate sequence ID in --msa: " + record.ID)
+		}
+		seen[record.ID] = true
+		names[i] = record.ID
+		if root != "" && record.ID == root {
+			rootIdx = i
+		}
+	}
+	if root != "" && rootIdx == -1 {
+		return errors.New("couldn't find --root " + root + " in --msa")
+	}
+
+	d, err := closest.DistanceMatrix(records, measure, threads)
+	if err != nil {
+		return err
+	}
+
+	adj, err := neighbourJoining(d, method == "bionj")
+	if err != nil {
+		return err
+	}
+
+	return WriteNewick(out, rootTree(adj, names, rootIdx))
+}
@@ -0,0 +1,82 @@
+package tree
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+var treeData = []byte(`>ref
+AAAAAAAAAA
+>s1
+CCAAAAAAAA
+>s2
+CCGAAAAAAA
+>s3
+AAAATAAAAA
+>s4
+AAAATTAAAA
+`)
+
+func TestTreeNJ(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := Tree(bytes.NewReader(treeData), "snp", "nj", "", out, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != "(s3:0,s4:1,(ref:0,(s1:0,s2:1):2):1);\n" {
+		t.Errorf("problem in TestTreeNJ()")
+		fmt.Println(out.String())
+	}
+}
+
+func TestTreeBIONJRooted(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := Tree(bytes.NewReader(treeData), "snp", "bionj", "ref", out, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != "(ref:0,((s1:0,s2:1):2,(s3:0,s4:1):1):0);\n" {
+		t.Errorf("problem in TestTreeBIONJRooted()")
+		fmt.Println(out.String())
+	}
+}
+
+func TestTreeTwo(t *testing.T) {
+	out := new(bytes.Buffer)
+	err := Tree(bytes.NewReader([]byte(">a\nACGT\n>b\nACTT\n")), "snp", "nj", "", out, 1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != "(a:0.5,b:0.5);\n" {
+		t.Errorf("problem in TestTreeTwo()")
+		fmt.Println(out.String())
+	}
+
+	err = Tree(bytes.NewReader([]byte(">a\nACGT\n")), "snp", "nj", "", out, 1)
+	if err == nil {
+		t.Errorf("expected an error for a single sequence in TestTreeTwo()")
+	}
+}
+
+func TestWriteNewick(t *testing.T) {
+	root := &Node{Name: "root", Children: []*Node{
+		{Name: "a b", Length: 1.5},
+		{Name: "it's", Length: 0},
+		{Length: 2, Children: []*Node{{Name: "c", Length: 0.25}}},
+	}}
+
+	out := new(bytes.Buffer)
+	err := WriteNewick(out, root)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != "('a b':1.5,'it''s':0,(c:0.25):2)root;\n" {
+		t.Errorf("problem in TestWriteNewick()")
+		fmt.Println(out.String())
+	}
+}
@@ -0,0 +1,413 @@
+package updown
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/virus-evolution/gofasta/pkg/closest"
+	"github.com/virus-evolution/gofasta/pkg/fasta"
+	"github.com/virus-evolution/gofasta/pkg/snps"
+)
+
+/*
+SNP-threshold clustering
+
+Two sequences are linked if the SNP-distance between them is at most the threshold, and clusters are the connected
+components of the resulting graph (single-linkage). The distance is snps.Distance, i.e. the number of sites where both
+sequences have certain nucleotides that differ (so it is the same as closest's snpDistance), and pairs with too many
+consequential ambiguities aren't linked.
+
+Clusters that are smaller than minSize are not given IDs. When there is a previous run's output, each cluster inherits
+the ID of the previous cluster that it shares the most members with, so IDs are stable as new sequences are added. If
+two previous clusters are merged, the bigger one's ID is kept. Clusters that don't share any members with a previous
+cluster get new IDs, numbered on from the highest previous ID. If the previous run's edges are provided too, the
+distances between pairs of sequences that were both in the previous run aren't recalculated. The edges file records the
+thresholds it was made with, because a larger threshold would need the longer links that it leaves out, and a different
+ambiguity threshold would link a different set of pairs.
+*/
+
+// clusterPrefix is prepended to the number of each cluster to make its ID
+const clusterPrefix = "cluster_"
+
+// clusterEdge is a link between two sequences (indices into the array of updownLines) that are within the threshold
+type clusterEdge struct {
+	i, j     int
+	distance int
+}
+
+// readPreviousClusters reads the cluster membership tsv from a previous run, and returns a map of sequence ID to
+// cluster ID (which is empty for sequences that weren't in a cluster)
+func readPreviousClusters(in io.Reader) (map[string]string, error) {
+	r := csv.NewReader(in)
+	r.Comma = '\t'
+	r.FieldsPerRecord = -1
+
+	previous := make(map[string]string)
+
+	header := true
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header {
+			if len(record) < 2 || record[0] != "sequence" || record[1] != "cluster" {
+				return nil, errors.New("bad header when parsing previous clusters: is this file the output of gofasta cluster?")
+			}
+			header = false
+			continue
+		}
+		if len(record) < 2 {
+			return nil, errors.New("bad line in previous clusters: " + strings.Join(record, "\t"))
+		}
+		previous[record[0]] = record[1]
+	}
+
+	return previous, nil
+}
+
+// edgesThresholdPrefix and edgesThreshPrefix start the fields of the first line of an edges file, which records the
+// SNP-distance threshold and the ambiguity threshold that it was made with, e.g. "#threshold=2\tthreshold-pair=0.1"
+const (
+	edgesThresholdPrefix = "#threshold="
+	edgesThreshPrefix    = "threshold-pair="
+)
+
+// formatEdgesSettings returns the first line of an edges file
+func formatEdgesSettings(threshold int, thresh float32) string {
+	return edgesThresholdPrefix + strconv.Itoa(threshold) + "\t" + edgesThreshPrefix + strconv.FormatFloat(float64(thresh), 'f', -1, 32) + "\n"
+}
+
+// parseEdgesSettings returns the SNP-distance threshold and the ambiguity threshold from the first line of an edges file
+func parseEdgesSettings(line string) (int, float32, error) {
+	fields := strings.Split(strings.TrimSpace(line), "\t")
+	if len(fields) != 2 || !strings.HasPrefix(fields[0], edgesThresholdPrefix) || !strings.HasPrefix(fields[1], edgesThreshPrefix) {
+		return 0, 0, errors.New("bad header when parsing previous edges: is this file the output of gofasta cluster?")
+	}
+	threshold, err := strconv.Atoi(strings.TrimPrefix(fields[0], edgesThresholdPrefix))
+	if err != nil {
+		return 0, 0, errors.New("bad threshold in previous edges: " + fields[0])
+	}
+	thresh, err := strconv.ParseFloat(strings.TrimPrefix(fields[1], edgesThreshPrefix), 32)
+	if err != nil {
+		return 0, 0, errors.New("bad threshold-pair in previous edges: " + fields[1])
+	}
+	return threshold, float32(thresh), nil
+}
+
+// readPreviousEdges reads the edge list tsv from a previous run, and returns the edges that are within threshold and
+// between sequences in index. It is an error if threshold is larger than the threshold of the previous run, since
+// links that are longer than that weren't written, or if thresh isn't the ambiguity threshold of the previous run,
+// since its edges were filtered by that
+func readPreviousEdges(in io.Reader, index map[string]int, threshold int, thresh float32) ([]clusterEdge, error) {
+	br := bufio.NewReader(in)
+
+	first, err := br.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	previousThreshold, previousThresh, err := parseEdgesSettings(first)
+	if err != nil {
+		return nil, err
+	}
+	if threshold > previousThreshold {
+		return nil, errors.New("--threshold (" + strconv.Itoa(threshold) + ") is larger than the threshold that the previous edges were made with (" + strconv.Itoa(previousThreshold) + ")")
+	}
+	if thresh != previousThresh {
+		return nil, errors.New("--threshold-pair (" + strconv.FormatFloat(float64(thresh), 'f', -1, 32) + ") is not the threshold-pair that the previous edges were made with (" + strconv.FormatFloat(float64(previousThresh), 'f', -1, 32) + ")")
+	}
+
+	r := csv.NewReader(br)
+	r.Comma = '\t'
+
+	edges := make([]clusterEdge, 0)
+
+	header := true
+	for {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header {
+			if len(record) != 3 || record[0] != "sequence1" || record[1] != "sequence2" || record[2] != "distance" {
+				return nil, errors.New("bad header when parsing previous edges: is this file the output of gofasta cluster?")
+			}
+			header = false
+			continue
+		}
+		d, err := strconv.Atoi(record[2])
+		if err != nil {
+			return nil, err
+		}
+		i, ok1 := index[record[0]]
+		j, ok2 := index[record[1]]
+		if !ok1 || !ok2 || d > threshold {
+			continue
+		}
+		if i > j {
+			i, j = j, i
+		}
+		edges = append(edges, clusterEdge{i: i, j: j, distance: d})
+	}
+
+	return edges, nil
+}
+
+// findEdges compares pairs of sequences in parallel and returns those that are within threshold SNPs of each other, in
+// input order. Pairs where both sequences are old (i.e. were compared in a previous run) are skipped
+func findEdges(lines []updownLine, old []bool, threshold int, thresh float32, threads int) []clusterEdge {
+
+	perLine := make([][]clusterEdge, len(lines))
+
+	cIdx := make(chan int, threads)
+
+	cDone := fasta.StartWorkers(threads, func() {
+		for i := range cIdx {
+			e := make([]clusterEdge, 0)
+			for j := i + 1; j < len(lines); j++ {
+				if old[i] && old[j] {
+					continue
+				}
+				distance, ambiguous := snps.Distance(profile(lines[i]), profile(lines[j]))
+				if ambiguous <= thresh && distance <= threshold {
+					e = append(e, clusterEdge{i: i, j: j, distance: distance})
+				}
+			}
+			perLine[i] = e
+		}
+	})
+
+	for i := range lines {
+		cIdx <- i
+	}
+	close(cIdx)
+
+	<-cDone
+
+	edges := make([]clusterEdge, 0)
+	for _, e := range perLine {
+		edges = append(edges, e...)
+	}
+
+	return edges
+}
+
+// clusterNumber returns the number in a cluster ID, or -1 if it isn't in the format that we write
+func clusterNumber(id string) int {
+	if !strings.HasPrefix(id, clusterPrefix) {
+		return -1
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(id, clusterPrefix))
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// nameClusters returns the ID of the cluster of each sequence (or "" if it is not in a cluster of at least minSize), given
+// the root of each sequence's set, carrying over IDs from a previous run where possible
+func nameClusters(lines []updownLine, roots []int, minSize int, previous map[string]string) []string {
+
+	members := make(map[int][]int)
+	order := make([]int, 0)
+	for i, r := range roots {
+		if _, ok := members[r]; !ok {
+			order = append(order, r)
+		}
+		members[r] = append(members[r], i)
+	}
+
+	type claim struct {
+		root    int
+		id      string
+		overlap int
+	}
+
+	maxID := 0
+	for _, id := range previous {
+		if n := clusterNumber(id); n > maxID {
+			maxID = n
+		}
+	}
+
+	claims := make([]claim, 0)
+	for _, r := range order {
+		if len(members[r]) < minSize {
+			continue
+		}
+		counts := make(map[string]int)
+		for _, i := range members[r] {
+			if id := previous[lines[i].id]; id != "" {
+				counts[id]++
+			}
+		}
+		for id, c := range counts {
+			claims = append(claims, claim{root: r, id: id, overlap: c})
+		}
+	}
+
+	sort.SliceStable(claims, func(i, j int) bool {
+		if claims[i].overlap != claims[j].overlap {
+			return claims[i].overlap > claims[j].overlap
+		}
+		ni, nj := clusterNumber(claims[i].id), clusterNumber(claims[j].id)
+		if ni != nj {
+			return ni < nj
+		}
+		if claims[i].id != claims[j].id {
+			return claims[i].id < claims[j].id
+		}
+		return claims[i].root < claims[j].root
+	})
+
+	names := make(map[int]string)
+	used := make(map[string]bool)
+	for _, c := range claims {
+		if _, ok := names[c.root]; ok || used[c.id] {
+			continue
+		}
+		names[c.root] = c.id
+		used[c.id] = true
+	}
+
+	for _, r := range order {
+		if len(members[r]) < minSize {
+			continue
+		}
+		if _, ok := names[r]; !ok {
+			maxID++
+			names[r] = clusterPrefix + strconv.Itoa(maxID)
+		}
+	}
+
+	ids := make([]string, len(lines))
+	for i, r := range roots {
+		ids[i] = names[r]
+	}
+
+	return ids
+}
+
+// writeClusters writes the cluster membership of every sequence as a tsv, in input order
+func writeClusters(w io.Writer, lines []updownLine, ids []string, previous map[string]string) error {
+	_, err := w.Write([]byte("sequence\tcluster\tprevious_cluster\n"))
+	if err != nil {
+		return err
+	}
+	for i, l := range lines {
+		_, err = w.Write([]byte(l.id + "\t" + ids[i] + "\t" + previous[l.id] + "\n"))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeEdges writes the links between sequences as a tsv, after a line that records the thresholds they were found with
+func writeEdges(w io.Writer, lines []updownLine, edges []clusterEdge, threshold int, thresh float32) error {
+	_, err := w.Write([]byte(formatEdgesSettings(threshold, thresh) + "sequence1\tsequence2\tdistance\n"))
+	if err != nil {
+		return err
+	}
+	for _, e := range edges {
+		_, err = w.Write([]byte(lines[e.i].id + "\t" + lines[e.j].id + "\t" + strconv.Itoa(e.distance) + "\n"))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Cluster does single-linkage clustering of the sequences in query (either the csv output of gofasta updown list, or a
+// fasta-format alignment, given a reference) at a SNP-distance threshold, and writes the cluster that each sequence
+// belongs to as a tsv to clustersOut, and (if edgesOut is not nil) the pairs of sequences that are linked as a tsv to
+// edgesOut. Clusters with fewer than minSize members are not reported. If previousClusters (and optionally previousEdges)
+// are the outputs of a previous run, cluster IDs are carried over from it
+func Cluster(query, reference io.Reader, q_in_type string, threshold int, thresh float32, minSize int, previousClusters, previousEdges io.Reader, clustersOut, edgesOut io.Writer, threads int) error {
+
+	if threshold < 0 {
+		return errors.New("--threshold must be >= 0")
+	}
+	if minSize < 1 {
+		return errors.New("--min-size must be > 0")
+	}
+	if previousEdges != nil && previousClusters == nil {
+		return errors.New("previous edges can only be used with previous clusters")
+	}
+
+	lines, err := loadLines(query, reference, q_in_type)
+	if err != nil {
+		return err
+	}
+
+	index := make(map[string]int, len(lines))
+	for i, l := range lines {
+		if _, ok := index[l.id]; ok {
+			return errors.New("duplicate sequence ID in --query: " + l.id)
+		}
+		index[l.id] = i
+	}
+
+	previous := make(map[string]string)
+	if previousClusters != nil {
+		previous, err = readPreviousClusters(previousClusters)
+		if err != nil {
+			return err
+		}
+	}
+
+	// only skip comparisons between old sequences if we have their edges
+	old := make([]bool, len(lines))
+	edges := make([]clusterEdge, 0)
+	if previousEdges != nil {
+		for i, l := range lines {
+			_, old[i] = previous[l.id]
+		}
+		edges, err = readPreviousEdges(previousEdges, index, threshold, thresh)
+		if err != nil {
+			return err
+		}
+	}
+
+	edges = append(edges, findEdges(lines, old, threshold, thresh, threads)...)
+
+	sort.SliceStable(edges, func(a, b int) bool {
+		return edges[a].i < edges[b].i || (edges[a].i == edges[b].i && edges[a].j < edges[b].j)
+	})
+
+	components := closest.NewDisjointSet(len(lines))
+	for _, e := range edges {
+		components.Union(e.i, e.j)
+	}
+	roots := make([]int, len(lines))
+	for i := range lines {
+		roots[i] = components.Find(i)
+	}
+
+	ids := nameClusters(lines, roots, minSize, previous)
+
+	err = writeClusters(clustersOut, lines, ids, previous)
+	if err != nil {
+		return err
+	}
+
+	if edgesOut != nil {
+		err = writeEdges(edgesOut, lines, edges, threshold, thresh)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
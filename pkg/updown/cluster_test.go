@@ -0,0 +1,129 @@
+package updown
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestCluster(t *testing.T) {
+	refData := []byte(`>ref
+AAAAAAAAAA
+`)
+	queryData := []byte(`>s1
+CAAAAAAAAA
+>s2
+CCAAAAAAAA
+>s3
+AAAAAAAGGG
+>s4
+AAAAAAATGG
+>s5
+TTTTAAAAAA
+`)
+
+	ref := bytes.NewReader(refData)
+	query := bytes.NewReader(queryData)
+	clusters := new(bytes.Buffer)
+	edges := new(bytes.Buffer)
+
+	err := Cluster(query, ref, "fasta", 1, 0.1, 2, nil, nil, clusters, edges, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if clusters.String() != "sequence\tcluster\tprevious_cluster\n"+
+		"s1\tcluster_1\t\n"+
+		"s2\tcluster_1\t\n"+
+		"s3\tcluster_2\t\n"+
+		"s4\tcluster_2\t\n"+
+		"s5\t\t\n" {
+		t.Errorf("problem in TestCluster() (clusters)")
+		fmt.Println(clusters.String())
+	}
+
+	if edges.String() != "#threshold=1\tthreshold-pair=0.1\n"+
+		"sequence1\tsequence2\tdistance\n"+
+		"s1\ts2\t1\n"+
+		"s3\ts4\t1\n" {
+		t.Errorf("problem in TestCluster() (edges)")
+		fmt.Println(edges.String())
+	}
+
+	// an incremental update, with cluster IDs from a previous run
+	previousClusters := []byte("sequence\tcluster\tprevious_cluster\n" +
+		"s1\tcluster_7\t\n" +
+		"s2\tcluster_7\t\n" +
+		"s3\tcluster_2\t\n" +
+		"s4\tcluster_2\t\n" +
+		"s5\t\t\n")
+
+	newData := append(queryData, []byte(`>s6
+CCCAAAAAAA
+>s7
+TTTTTAAAAA
+`)...)
+
+	ref = bytes.NewReader(refData)
+	query = bytes.NewReader(newData)
+	clusters = new(bytes.Buffer)
+	newEdges := new(bytes.Buffer)
+
+	err = Cluster(query, ref, "fasta", 1, 0.1, 2, bytes.NewReader(previousClusters), bytes.NewReader(edges.Bytes()), clusters, newEdges, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if clusters.String() != "sequence\tcluster\tprevious_cluster\n"+
+		"s1\tcluster_7\tcluster_7\n"+
+		"s2\tcluster_7\tcluster_7\n"+
+		"s3\tcluster_2\tcluster_2\n"+
+		"s4\tcluster_2\tcluster_2\n"+
+		"s5\tcluster_8\t\n"+
+		"s6\tcluster_7\t\n"+
+		"s7\tcluster_8\t\n" {
+		t.Errorf("problem in TestCluster() (incremental clusters)")
+		fmt.Println(clusters.String())
+	}
+
+	if newEdges.String() != "#threshold=1\tthreshold-pair=0.1\n"+
+		"sequence1\tsequence2\tdistance\n"+
+		"s1\ts2\t1\n"+
+		"s2\ts6\t1\n"+
+		"s3\ts4\t1\n"+
+		"s5\ts7\t1\n" {
+		t.Errorf("problem in TestCluster() (incremental edges)")
+		fmt.Println(newEdges.String())
+	}
+
+	// the previous edges don't include links longer than 1 SNP, so a larger threshold can't use them
+	ref = bytes.NewReader(refData)
+	query = bytes.NewReader(newData)
+	err = Cluster(query, ref, "fasta", 2, 0.1, 2, bytes.NewReader(previousClusters), bytes.NewReader(edges.Bytes()), new(bytes.Buffer), nil, 2)
+	if err == nil {
+		t.Errorf("problem in TestCluster() (larger threshold)")
+	}
+
+	// the previous edges were filtered by a different ambiguity threshold, so they can't be mixed with new ones
+	ref = bytes.NewReader(refData)
+	query = bytes.NewReader(newData)
+	err = Cluster(query, ref, "fasta", 1, 0.2, 2, bytes.NewReader(previousClusters), bytes.NewReader(edges.Bytes()), new(bytes.Buffer), nil, 2)
+	if err == nil {
+		t.Errorf("problem in TestCluster() (different threshold-pair)")
+	}
+}
+
+func TestNameClustersMerge(t *testing.T) {
+	lines := []updownLine{{id: "a"}, {id: "b"}, {id: "c"}, {id: "d"}}
+	roots := []int{0, 0, 0, 0}
+	previous := map[string]string{"a": "cluster_3", "b": "cluster_1", "c": "cluster_1"}
+
+	ids := nameClusters(lines, roots, 2, previous)
+	for _, id := range ids {
+		if id != "cluster_1" {
+			t.Errorf("problem in TestNameClustersMerge()")
+			fmt.Println(ids)
+			break
+		}
+	}
+}
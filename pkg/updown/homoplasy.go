@@ -0,0 +1,246 @@
+package updown
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/virus-evolution/gofasta/pkg/fasta"
+)
+
+/*
+Recurrent mutations
+
+If a SNP arose once, at an ancestral node X, every sequence that carries it descends from X, and any sampled sequence
+that is a direct ancestor (the "up" bin from whichWay) of a carrier, but which doesn't carry the SNP itself, must be
+an ancestor of X. So the SNPs of a carrier's non-carrying ancestors (its background for that SNP) should all be
+present in every other carrier. Two carriers whose backgrounds are not mutually consistent in this way must have
+acquired the SNP independently (ignoring reversions).
+
+We estimate the number of independent origins of each SNP by grouping its carriers greedily, most ancestral (fewest
+SNPs) first, into origins whose members are all consistent with each other. This is quadratic in the number of
+sequences, because every sequence's ancestors have to be found.
+*/
+
+// bgSNP is one SNP in a carrier's background, with its position for checking against ambiguities
+type bgSNP struct {
+	snp string
+	pos int
+}
+
+// homoplasyResult is the estimated origins of one SNP, each of which is a list of indices of the carriers that
+// belong to it (most ancestral first)
+type homoplasyResult struct {
+	snp     string
+	pos     int
+	count   int
+	origins [][]int
+}
+
+// loadLines reads every sequence in query (either the csv output of gofasta updown list, or a fasta-format alignment, given
+// a reference) to an array of updownLines, in input order
+func loadLines(query, reference io.Reader, q_in_type string) ([]updownLine, error) {
+
+	var lines []updownLine
+	var err error
+
+	switch q_in_type {
+	case "csv":
+		lines, err = readCSVToUDLList(query)
+		if err != nil {
+			return nil, err
+		}
+	case "fasta":
+		temp, err := fasta.LoadEncodeAlignment(reference, false, false, false)
+		if err != nil {
+			return nil, err
+		}
+		if len(temp) > 1 {
+			return nil, errors.New("More than one record in --reference")
+		}
+		lines, err = fastaToUDLList(query, temp[0].Seq)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.New("couldn't tell if --query was a .csv or a .fasta file")
+	}
+
+	// fastaToUDLList doesn't guarantee input order
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].idx < lines[j].idx })
+	for i := range lines {
+		lines[i].snpCount = len(lines[i].snps)
+	}
+
+	return lines, nil
+}
+
+// findAncestors returns, for each sequence in lines, the indices of the other sequences that whichWay puts in
+// its "up" bin, i.e. that are its likely direct ancestors
+func findAncestors(lines []updownLine, thresh float32, threads int) [][]int {
+
+	ancestors := make([][]int, len(lines))
+
+	cIdx := make(chan int, threads)
+
+	cDone := fasta.StartWorkers(threads, func() {
+		for i := range cIdx {
+			a := make([]int, 0)
+			for j := range lines {
+				if i == j {
+					continue
+				}
+				direction, distance := whichWay(lines[i], lines[j], thresh)
+				if distance != -1 && direction == 1 {
+					a = append(a, j)
+				}
+			}
+			ancestors[i] = a
+		}
+	})
+
+	for i := range lines {
+		cIdx <- i
+	}
+	close(cIdx)
+
+	<-cDone
+
+	return ancestors
+}
+
+// background returns the SNPs of the ancestors of carrier that don't carry snp (and aren't ambiguous at its position)
+func background(lines []updownLine, ancestors []int, snp string, pos int) []bgSNP {
+	seen := make(map[string]bool)
+	bg := make([]bgSNP, 0)
+	for _, a := range ancestors {
+		if isSiteAmb(pos, lines[a].ambs) || snpOverlapBinarySearch(lines[a].snpsSorted, snp) {
+			continue
+		}
+		for i, s := range lines[a].snps {
+			if !seen[s] {
+				seen[s] = true
+				bg = append(bg, bgSNP{snp: s, pos: lines[a].snpsPos[i]})
+			}
+		}
+	}
+	return bg
+}
+
+// consistent returns true if every SNP in bg is present in (or is at an ambiguous site in) l
+func consistent(bg []bgSNP, l updownLine) bool {
+	for _, b := range bg {
+		if !snpOverlapBinarySearch(l.snpsSorted, b.snp) && !isSiteAmb(b.pos, l.ambs) {
+			return false
+		}
+	}
+	return true
+}
+
+// estimateOrigins groups the carriers of one SNP into the estimated independent origins of that SNP
+func estimateOrigins(lines []updownLine, ancestors [][]int, snp string, pos int, carriers []int) [][]int {
+
+	sort.SliceStable(carriers, func(i, j int) bool {
+		return lines[carriers[i]].snpCount < lines[carriers[j]].snpCount
+	})
+
+	bgs := make(map[int][]bgSNP, len(carriers))
+	for _, c := range carriers {
+		bgs[c] = background(lines, ancestors[c], snp, pos)
+	}
+
+	origins := make([][]int, 0)
+	for _, c := range carriers {
+		placed := false
+		for i, origin := range origins {
+			ok := true
+			for _, m := range origin {
+				if !consistent(bgs[c], lines[m]) || !consistent(bgs[m], lines[c]) {
+					ok = false
+					break
+				}
+			}
+			if ok {
+				origins[i] = append(origins[i], c)
+				placed = true
+				break
+			}
+		}
+		if !placed {
+			origins = append(origins, []int{c})
+		}
+	}
+
+	return origins
+}
+
+// writeHomoplasies writes the recurrent SNPs to file or stdout, with one example sequence (the most ancestral) per origin
+func writeHomoplasies(w io.Writer, lines []updownLine, results []homoplasyResult) error {
+	_, err := w.Write([]byte("SNP,count,origins,sizes,examples\n"))
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		sizes := make([]string, len(r.origins))
+		examples := make([]string, len(r.origins))
+		for i, origin := range r.origins {
+			sizes[i] = strconv.Itoa(len(origin))
+			examples[i] = lines[origin[0]].id
+		}
+		_, err = w.Write([]byte(r.snp + "," + strconv.Itoa(r.count) + "," + strconv.Itoa(len(r.origins)) + "," + strings.Join(sizes, "|") + "," + strings.Join(examples, "|") + "\n"))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Homoplasies finds SNPs that are likely to have arisen more than once in a set of sequences (candidate homoplasies), using
+// the pseudo-tree relationships between them, and writes each SNP that is present in at least minCount sequences and
+// has at least minOrigins estimated independent origins, along with the number and size of its origins and an example
+// sequence from each.
+func Homoplasies(query, reference io.Reader, out io.Writer, q_in_type string, thresh float32, minCount int, minOrigins int, threads int) error {
+
+	if minCount < 1 || minOrigins < 1 {
+		return errors.New("--min-count and --min-origins must be > 0")
+	}
+
+	lines, err := loadLines(query, reference, q_in_type)
+	if err != nil {
+		return err
+	}
+
+	ancestors := findAncestors(lines, thresh, threads)
+
+	carriers := make(map[string][]int)
+	positions := make(map[string]int)
+	for i, l := range lines {
+		for j, snp := range l.snps {
+			carriers[snp] = append(carriers[snp], i)
+			positions[snp] = l.snpsPos[j]
+		}
+	}
+
+	results := make([]homoplasyResult, 0)
+	for snp, c := range carriers {
+		if len(c) < minCount {
+			continue
+		}
+		origins := estimateOrigins(lines, ancestors, snp, positions[snp], c)
+		if len(origins) < minOrigins {
+			continue
+		}
+		results = append(results, homoplasyResult{snp: snp, pos: positions[snp], count: len(c), origins: origins})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if len(results[i].origins) != len(results[j].origins) {
+			return len(results[i].origins) > len(results[j].origins)
+		}
+		return results[i].pos < results[j].pos || (results[i].pos == results[j].pos && results[i].snp < results[j].snp)
+	})
+
+	return writeHomoplasies(out, lines, results)
+}
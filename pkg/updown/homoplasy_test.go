@@ -0,0 +1,87 @@
+package updown
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestHomoplasies(t *testing.T) {
+	refData := []byte(`>ref
+AAAAAAAAAA
+`)
+	queryData := []byte(`>P1
+CAAAAAAAAA
+>A
+CAAAGAAAAA
+>P2
+ACAAAAAAAA
+>B
+ACAAGAAAAA
+>B2
+ACAAGATAAA
+>X
+AAAAAAATGA
+>Y
+AAAAAAAAGT
+`)
+
+	ref := bytes.NewReader(refData)
+	query := bytes.NewReader(queryData)
+	out := new(bytes.Buffer)
+
+	err := Homoplasies(query, ref, out, "fasta", 0.1, 2, 2, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `SNP,count,origins,sizes,examples
+A5G,3,2,1|2,A|B
+` {
+		t.Errorf("problem in TestHomoplasies()")
+		fmt.Println(out.String())
+	}
+
+	ref = bytes.NewReader(refData)
+	query = bytes.NewReader(queryData)
+	out = new(bytes.Buffer)
+
+	err = Homoplasies(query, ref, out, "fasta", 0.1, 2, 1, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `SNP,count,origins,sizes,examples
+A5G,3,2,1|2,A|B
+A1C,2,1,2,P1
+A2C,3,1,3,P2
+A9G,2,1,2,X
+` {
+		t.Errorf("problem in TestHomoplasies() (min-origins 1)")
+		fmt.Println(out.String())
+	}
+}
+
+func TestHomoplasiesAmbiguous(t *testing.T) {
+	// B's background SNP (A2C) is at an ambiguous site in A, so they are consistent with a single origin
+	queryData := []byte(`query,SNPs,ambiguities,SNPcount,ambcount
+P2,A2C,,1,0
+A,A5G,2,1,1
+B,A2C|A5G,,2,0
+`)
+
+	out := new(bytes.Buffer)
+
+	err := Homoplasies(bytes.NewReader(queryData), nil, out, "csv", 0.5, 2, 1, 1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `SNP,count,origins,sizes,examples
+A2C,2,1,2,P2
+A5G,2,1,2,A
+` {
+		t.Errorf("problem in TestHomoplasiesAmbiguous()")
+		fmt.Println(out.String())
+	}
+}
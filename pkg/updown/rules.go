@@ -0,0 +1,284 @@
+package updown
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/virus-evolution/gofasta/pkg/metadata"
+)
+
+// binNames are the names of the bins, in the order of whichWay's directions
+var binNames = [4]string{"same", "up", "down", "side"}
+
+// A TieBreak is a metadata column that is used to order targets that are the same SNP-distance from a query
+type TieBreak struct {
+	Column     string
+	Descending bool
+}
+
+// TargetRules are the rules for choosing neighbours from the targets in TopRanking, using per-target metadata. Targets
+// can be filtered on a range of dates and on the values of any columns, including their IDs (which is how they are
+// ignored). Within each bin, ties for SNP-distance are broken by TieBreaks before the number of ambiguities, and each bin
+// can contain at most Quota targets with the same value of QuotaColumn. The zero value has no effect
+type TargetRules struct {
+	Metadata    metadata.Table
+	DateColumn  string
+	DateFrom    string              // YYYY-MM-DD, inclusive
+	DateTo      string              // YYYY-MM-DD, inclusive
+	Include     map[string][]string // a target must have one of these values in each of these columns
+	Exclude     map[string][]string // a target mustn't have any of these values in any of these columns
+	TieBreaks   [4][]TieBreak       // for the same, up, down and side bins respectively
+	QuotaColumn string
+	Quota       int // 0 means no quota
+}
+
+// parseColumnValues parses specifications like "country=UK,Ireland" to a map of column name to values
+func parseColumnValues(specs []string, m metadata.Table, flag string) (map[string][]string, error) {
+	values := make(map[string][]string)
+	for _, spec := range specs {
+		split := strings.SplitN(spec, "=", 2)
+		if len(split) != 2 || split[0] == "" {
+			return nil, errors.New("couldn't parse " + flag + " " + spec + " (it should look like column=value1,value2)")
+		}
+		if !m.HasColumn(split[0]) {
+			return nil, errors.New("couldn't find " + flag + " column \"" + split[0] + "\" in metadata header")
+		}
+		values[split[0]] = append(values[split[0]], strings.Split(split[1], ",")...)
+	}
+	return values, nil
+}
+
+// parseTieBreaks parses specifications like "date:desc" (for every bin) or "up=date:desc" (for one bin) to the tie-breaks
+// for each bin. Specifications for one bin replace the ones for every bin
+func parseTieBreaks(specs []string, m metadata.Table) ([4][]TieBreak, error) {
+	var all []TieBreak
+	var perBin [4][]TieBreak
+	for _, spec := range specs {
+		bin := -1
+		if split := strings.SplitN(spec, "=", 2); len(split) == 2 {
+			for i, name := range binNames {
+				if split[0] == name {
+					bin = i
+				}
+			}
+			if bin == -1 {
+				return perBin, errors.New("couldn't parse --tie-break " + spec + " (the bin should be one of same, up, down or side)")
+			}
+			spec = split[1]
+		}
+		tb := TieBreak{Column: spec}
+		if i := strings.LastIndex(spec, ":"); i != -1 {
+			switch spec[i+1:] {
+			case "asc":
+				tb.Column = spec[:i]
+			case "desc":
+				tb.Column = spec[:i]
+				tb.Descending = true
+			}
+		}
+		if !m.HasColumn(tb.Column) {
+			return perBin, errors.New("couldn't find --tie-break column \"" + tb.Column + "\" in metadata header")
+		}
+		if bin == -1 {
+			all = append(all, tb)
+		} else {
+			perBin[bin] = append(perBin[bin], tb)
+		}
+	}
+	for i := range perBin {
+		if len(perBin[i]) == 0 {
+			perBin[i] = all
+		}
+	}
+	return perBin, nil
+}
+
+// idColumn returns the name of the column that has the target IDs, which is the first column of the metadata (or "" if
+// there is none). Filters on it work for targets that have no metadata
+func (r TargetRules) idColumn() string {
+	if len(r.Metadata.Header) == 0 {
+		return ""
+	}
+	return r.Metadata.Header[0]
+}
+
+// NewTargetRules checks and parses the command-line options for metadata-aware target selection. ignore is a list of
+// target IDs, which is added to exclude as a filter on the ID column. include and exclude are specifications like
+// "country=UK,Ireland", and tieBreaks are specifications like "date:desc" or "up=date:desc". m must have rows for the
+// targets if any of the options other than ignore are used
+func NewTargetRules(m metadata.Table, ignore []string, dateColumn, dateFrom, dateTo string, include, exclude, tieBreaks []string, quotaColumn string, quota int) (TargetRules, error) {
+
+	var err error
+	rules := TargetRules{Metadata: m, DateColumn: dateColumn, DateFrom: dateFrom, DateTo: dateTo, QuotaColumn: quotaColumn, Quota: quota}
+
+	usesMetadata := dateFrom != "" || dateTo != "" || len(include) > 0 || len(exclude) > 0 || len(tieBreaks) > 0 || quota > 0
+	if usesMetadata && m.Rows == nil {
+		return rules, errors.New("filters, tie-breaks and quotas need --metadata")
+	}
+
+	for _, d := range []string{dateFrom, dateTo} {
+		if d == "" {
+			continue
+		}
+		if _, err = time.Parse("2006-01-02", d); err != nil {
+			return rules, errors.New("couldn't parse date " + d + " (it should be YYYY-MM-DD)")
+		}
+		if !m.HasColumn(dateColumn) {
+			return rules, errors.New("couldn't find --date-column \"" + dateColumn + "\" in metadata header")
+		}
+	}
+
+	rules.Include, err = parseColumnValues(include, m, "--include")
+	if err != nil {
+		return rules, err
+	}
+	rules.Exclude, err = parseColumnValues(exclude, m, "--exclude")
+	if err != nil {
+		return rules, err
+	}
+	if len(ignore) > 0 {
+		rules.Exclude[rules.idColumn()] = append(rules.Exclude[rules.idColumn()], ignore...)
+	}
+
+	rules.TieBreaks, err = parseTieBreaks(tieBreaks, m)
+	if err != nil {
+		return rules, err
+	}
+
+	if quota < 0 {
+		return rules, errors.New("--quota must be >= 0")
+	}
+	if quota > 0 && !m.HasColumn(quotaColumn) {
+		return rules, errors.New("couldn't find --quota-column \"" + quotaColumn + "\" in metadata header")
+	}
+
+	return rules, nil
+}
+
+// filters returns true if any rule filters targets on their metadata
+func (r TargetRules) filters() bool {
+	return r.DateFrom != "" || r.DateTo != "" || len(r.Include) > 0 || len(r.Exclude) > 0
+}
+
+// keep returns true if a target passes all the filters. Targets without metadata only pass if the only filters are on
+// the ID column, and targets without a complete date only pass if there is no date range
+func (r TargetRules) keep(id string) bool {
+	if !r.filters() {
+		return true
+	}
+
+	row, ok := r.Metadata.Rows[id]
+	if !ok {
+		if r.DateFrom != "" || r.DateTo != "" {
+			return false
+		}
+		for column := range r.Include {
+			if column != r.idColumn() {
+				return false
+			}
+		}
+		for column := range r.Exclude {
+			if column != r.idColumn() {
+				return false
+			}
+		}
+		row = map[string]string{r.idColumn(): id}
+	}
+
+	if r.DateFrom != "" || r.DateTo != "" {
+		date := strings.TrimSpace(row[r.DateColumn])
+		if _, err := time.Parse("2006-01-02", date); err != nil {
+			return false
+		}
+		// YYYY-MM-DD dates compare correctly as strings
+		if (r.DateFrom != "" && date < r.DateFrom) || (r.DateTo != "" && date > r.DateTo) {
+			return false
+		}
+	}
+
+	for column, values := range r.Include {
+		if !stringInArray(row[column], values) {
+			return false
+		}
+	}
+
+	for column, values := range r.Exclude {
+		if stringInArray(row[column], values) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compareValues compares two metadata values, numerically if they are both numbers and otherwise as strings. It
+// returns -1, 0 or 1
+func compareValues(a, b string) int {
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+// less returns the function that orders targets within a bin: by SNP-distance, then by the bin's tie-breaks (targets
+// with missing values go last), then by number of ambiguities
+func (r TargetRules) less(bin int) func(a, b resultsStruct) bool {
+	tieBreaks := r.TieBreaks[bin]
+	return func(a, b resultsStruct) bool {
+		if a.distance != b.distance {
+			return a.distance < b.distance
+		}
+		for _, tb := range tieBreaks {
+			va, vb := a.meta[tb.Column], b.meta[tb.Column]
+			switch {
+			case va == vb:
+				continue
+			case va == "":
+				return false
+			case vb == "":
+				return true
+			}
+			c := compareValues(va, vb)
+			if c == 0 {
+				continue
+			}
+			if tb.Descending {
+				return c > 0
+			}
+			return c < 0
+		}
+		return a.ambCount < b.ambCount
+	}
+}
+
+// category returns the value of the quota column for a target
+func (r TargetRules) category(rs resultsStruct) string {
+	return rs.meta[r.QuotaColumn]
+}
+
+// applyQuota returns the targets in a sorted catchment, keeping at most Quota with each value of QuotaColumn
+func (r TargetRules) applyQuota(catchment []resultsStruct) []resultsStruct {
+	if r.Quota == 0 {
+		return catchment
+	}
+	counts := make(map[string]int)
+	kept := make([]resultsStruct, 0, len(catchment))
+	for _, rs := range catchment {
+		c := r.category(rs)
+		if counts[c] < r.Quota {
+			kept = append(kept, rs)
+			counts[c]++
+		}
+	}
+	return kept
+}
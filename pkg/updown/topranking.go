@@ -17,6 +17,7 @@ import (
 	"strings"
 
 	"github.com/virus-evolution/gofasta/pkg/fasta"
+	"github.com/virus-evolution/gofasta/pkg/snps"
 )
 
 /*
@@ -171,22 +172,29 @@ func whichWay(q, t updownLine, thresh float32) (int, int) {
 	return direction, distance
 }
 
+// profile returns the snps and ambiguities of an updownLine, for comparing with snps.Private and snps.Distance
+func profile(l updownLine) snps.Profile {
+	return snps.Profile{SNPs: l.snps, Positions: l.snpsPos, Ambs: l.ambs}
+}
+
 // a resultsStruct contains information about the relationship between one query and one target.
 type resultsStruct struct {
-	qname    string // name of the query
-	qidx     int    // query's position in the input file
-	tname    string // name of the target
-	distance int    // snp distance between query and target
-	ambCount int    // number of non-ATGC characters in the target
+	qname    string            // name of the query
+	qidx     int               // query's position in the input file
+	tname    string            // name of the target
+	distance int               // snp distance between query and target
+	ambCount int               // number of non-ATGC characters in the target
+	target   updownLine        // the target's snps and ambiguities, for writing its private snps
+	meta     map[string]string // the target's metadata, if there is any
 }
 
 // an updownCatchmentSubStruct contains an array of resultsStructs which are the current closest neighbours of one query
-// in one direction. The fields maxDist and minAmbig are used when deciding whether or not the next target should replace
-// the last item in catchment
+// in one direction. The field worst is used when deciding whether or not the next target should replace the last item
+// in catchment, and counts is the number of targets in catchment in each quota category
 type updownCatchmentSubStruct struct {
 	catchment []resultsStruct
-	maxDist   int
-	minAmbig  int
+	worst     resultsStruct
+	counts    map[string]int
 	// nDists    int
 }
 
@@ -201,16 +209,65 @@ type updownCatchmentStruct struct {
 	// minDistArray [4]int
 }
 
-// rearrangeCatchment sorts the catchment slice in an updownCatchmentSubStruct, pops the last item from it, and updates
-// the distance and ambiguity fields in preparation for deciding what to do with the next target. It should only be
-// called when the updownCatchmentSubStruct is over its capacity (catchmentSize)
-func rearrangeCatchment(nS *updownCatchmentSubStruct, catchmentSize int) {
+// rearrangeCatchment sorts the catchment slice in an updownCatchmentSubStruct by less, pops the last item from it, and
+// updates the worst field in preparation for deciding what to do with the next target. It should only be called when
+// the updownCatchmentSubStruct is over its capacity (catchmentSize)
+func rearrangeCatchment(nS *updownCatchmentSubStruct, catchmentSize int, less func(a, b resultsStruct) bool, rules TargetRules) {
 	sort.SliceStable(nS.catchment, func(i, j int) bool {
-		return nS.catchment[i].distance < nS.catchment[j].distance || (nS.catchment[i].distance == nS.catchment[j].distance && nS.catchment[i].ambCount < nS.catchment[j].ambCount)
+		return less(nS.catchment[i], nS.catchment[j])
 	})
+	if nS.counts != nil {
+		for _, rs := range nS.catchment[catchmentSize:] {
+			nS.counts[rules.category(rs)]--
+		}
+	}
 	nS.catchment = nS.catchment[0:catchmentSize]
-	nS.maxDist = nS.catchment[catchmentSize-1].distance
-	nS.minAmbig = nS.catchment[catchmentSize-1].ambCount
+	nS.worst = nS.catchment[catchmentSize-1]
+}
+
+// addToCatchment adds a target to a bin if it is one of the best catchmentSize targets so far. If the bin has a quota,
+// and it already has the quota of targets in the target's category, the target replaces the worst of them instead, if
+// it is better
+func addToCatchment(nS *updownCatchmentSubStruct, rs resultsStruct, catchmentSize int, less func(a, b resultsStruct) bool, rules TargetRules) {
+
+	if rules.Quota > 0 {
+		if nS.counts == nil {
+			nS.counts = make(map[string]int)
+		}
+		c := rules.category(rs)
+		if nS.counts[c] >= rules.Quota {
+			worst := -1
+			for i, x := range nS.catchment {
+				if rules.category(x) == c && (worst == -1 || less(nS.catchment[worst], x)) {
+					worst = i
+				}
+			}
+			if less(rs, nS.catchment[worst]) {
+				nS.catchment[worst] = rs
+				if len(nS.catchment) == catchmentSize {
+					rearrangeCatchment(nS, catchmentSize, less, rules)
+				}
+			}
+			return
+		}
+	}
+
+	switch {
+	case len(nS.catchment) < catchmentSize:
+		nS.catchment = append(nS.catchment, rs)
+		if nS.counts != nil {
+			nS.counts[rules.category(rs)]++
+		}
+		if len(nS.catchment) == catchmentSize {
+			rearrangeCatchment(nS, catchmentSize, less, rules)
+		}
+	case less(rs, nS.worst):
+		nS.catchment = append(nS.catchment, rs)
+		if nS.counts != nil {
+			nS.counts[rules.category(rs)]++
+		}
+		rearrangeCatchment(nS, catchmentSize, less, rules)
+	}
 }
 
 // // how many different snp distances are represented in this struct
@@ -288,8 +345,8 @@ func pushCatchment2Catchment(pC pushCatchmentSubStruct) updownCatchmentSubStruct
 }
 
 // stringInArray returns true/false s is present in the slice sa
-// NB - could use binary search here but this function is only ever called to check whether a sequence ID is
-// present in a list of IDs to ignore, which is likely always going to be small (?!), so slice iteration seems reasonable?
+// NB - could use binary search here but this function is only ever called to check whether a metadata value is
+// present in a list of values to filter on, which is likely always going to be small (?!), so slice iteration seems reasonable?
 func stringInArray(s string, sa []string) bool {
 	for i := range sa {
 		if s == sa[i] {
@@ -302,7 +359,7 @@ func stringInArray(s string, sa []string) bool {
 // findUpDownCatchmentPushDistance does all the work for one query, by iterating over targets as they arrive and assigning then to
 // the correct bins based on the results of whichWay. It maintains a set of pushCatchmentSubStructs in case the bins are empty under
 // the user-defined snp distance thresholds from the command line, to push the distances out to.
-func findUpDownCatchmentPushDistance(q updownLine, ignore []string, sizeArray [4]int, pushDist int, thresh float32, cIn chan updownLine, cOut chan updownCatchmentStruct) {
+func findUpDownCatchmentPushDistance(q updownLine, rules TargetRules, sizeArray [4]int, pushDist int, thresh float32, cIn chan updownLine, cOut chan updownCatchmentStruct) {
 
 	var rs resultsStruct
 	var distance int
@@ -318,13 +375,6 @@ func findUpDownCatchmentPushDistance(q updownLine, ignore []string, sizeArray [4
 	// then we iterate over all the targets
 	for target := range cIn {
 
-		// skip ignored sequences
-		if len(ignore) > 0 {
-			if stringInArray(target.id, ignore) {
-				continue
-			}
-		}
-
 		// return direction values of 0,1,2,3 = same,up,down,side respectively
 		// distance is SNP-distance (int)
 		direction, distance = whichWay(q, target, thresh)
@@ -336,13 +386,13 @@ func findUpDownCatchmentPushDistance(q updownLine, ignore []string, sizeArray [4
 
 		switch direction {
 		case 0: // same
-			rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance}
+			rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance, target: target, meta: rules.Metadata.Rows[target.id]}
 			same.catchment = append(same.catchment, rs)
 		case 1: // up
 			// is the distance lower or are there not enough distances yet:
 			if distance <= pushup.maxDist || pushup.nDists < pushDist {
 				// the results struct:
-				rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance}
+				rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance, target: target, meta: rules.Metadata.Rows[target.id]}
 				// slot it in:
 				refactorPushCatchment(&pushup, rs, pushDist)
 			}
@@ -350,7 +400,7 @@ func findUpDownCatchmentPushDistance(q updownLine, ignore []string, sizeArray [4
 			// is the distance lower or are there not enough distances yet:
 			if distance <= pushdown.maxDist || pushdown.nDists < pushDist {
 				// the results struct:
-				rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance}
+				rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance, target: target, meta: rules.Metadata.Rows[target.id]}
 				// slot it in:
 				refactorPushCatchment(&pushdown, rs, pushDist)
 			}
@@ -358,7 +408,7 @@ func findUpDownCatchmentPushDistance(q updownLine, ignore []string, sizeArray [4
 			// is the distance lower or are there not enough distances yet:
 			if distance <= pushside.maxDist || pushside.nDists < pushDist {
 				// the results struct:
-				rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance}
+				rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance, target: target, meta: rules.Metadata.Rows[target.id]}
 				// slot it in:
 				refactorPushCatchment(&pushside, rs, pushDist)
 			}
@@ -367,21 +417,22 @@ func findUpDownCatchmentPushDistance(q updownLine, ignore []string, sizeArray [4
 
 	neighbours := updownCatchmentStruct{qname: q.id, qidx: q.idx}
 	neighbours.same = same
-
 	neighbours.up = pushCatchment2Catchment(pushup)
-	sort.SliceStable(neighbours.up.catchment, func(i, j int) bool {
-		return neighbours.up.catchment[i].distance < neighbours.up.catchment[j].distance || (neighbours.up.catchment[i].distance == neighbours.up.catchment[j].distance && neighbours.up.catchment[i].ambCount < neighbours.up.catchment[j].ambCount)
-	})
-
 	neighbours.down = pushCatchment2Catchment(pushdown)
-	sort.SliceStable(neighbours.down.catchment, func(i, j int) bool {
-		return neighbours.down.catchment[i].distance < neighbours.down.catchment[j].distance || (neighbours.down.catchment[i].distance == neighbours.down.catchment[j].distance && neighbours.down.catchment[i].ambCount < neighbours.down.catchment[j].ambCount)
-	})
-
 	neighbours.side = pushCatchment2Catchment(pushside)
-	sort.SliceStable(neighbours.side.catchment, func(i, j int) bool {
-		return neighbours.side.catchment[i].distance < neighbours.side.catchment[j].distance || (neighbours.side.catchment[i].distance == neighbours.side.catchment[j].distance && neighbours.side.catchment[i].ambCount < neighbours.side.catchment[j].ambCount)
-	})
+
+	// the polytomy is only sorted if there are rules to sort it by
+	bins := [4]*updownCatchmentSubStruct{&neighbours.same, &neighbours.up, &neighbours.down, &neighbours.side}
+	for i, bin := range bins {
+		if i == 0 && len(rules.TieBreaks[0]) == 0 && rules.Quota == 0 {
+			continue
+		}
+		less := rules.less(i)
+		sort.SliceStable(bin.catchment, func(a, b int) bool {
+			return less(bin.catchment[a], bin.catchment[b])
+		})
+		bin.catchment = rules.applyQuota(bin.catchment)
+	}
 
 	// TO DO - balance the neighbours here if sizeArray is given? Would need a balance function specific to pushing
 
@@ -391,7 +442,7 @@ func findUpDownCatchmentPushDistance(q updownLine, ignore []string, sizeArray [4
 // findUpDownCatchment does all the work for one query, by iterating over targets as they arrive and assigning then to
 // the correct bins based on the results of whichWay. It can't do any pushing if any bins are empty after all targets
 // have been processed.
-func findUpDownCatchment(q updownLine, ignore []string, sizeArray [4]int, nofill bool, distArray [4]int, thresh float32, cIn chan updownLine, cOut chan updownCatchmentStruct) {
+func findUpDownCatchment(q updownLine, rules TargetRules, sizeArray [4]int, nofill bool, distArray [4]int, thresh float32, cIn chan updownLine, cOut chan updownCatchmentStruct) {
 
 	neighbours := updownCatchmentStruct{qname: q.id, qidx: q.idx}
 	neighbours.same = updownCatchmentSubStruct{catchment: make([]resultsStruct, 0)}
@@ -399,6 +450,13 @@ func findUpDownCatchment(q updownLine, ignore []string, sizeArray [4]int, nofill
 	neighbours.down = updownCatchmentSubStruct{catchment: make([]resultsStruct, 0)}
 	neighbours.side = updownCatchmentSubStruct{catchment: make([]resultsStruct, 0)}
 
+	// the bins in the order of whichWay's directions, and the function to order each one by
+	bins := [4]*updownCatchmentSubStruct{&neighbours.same, &neighbours.up, &neighbours.down, &neighbours.side}
+	var lessArray [4]func(a, b resultsStruct) bool
+	for i := range lessArray {
+		lessArray[i] = rules.less(i)
+	}
+
 	var rs resultsStruct
 	var distance int
 	var direction int
@@ -420,13 +478,6 @@ func findUpDownCatchment(q updownLine, ignore []string, sizeArray [4]int, nofill
 	// then we iterate over all the targets
 	for target := range cIn {
 
-		// skip ignored sequences
-		if len(ignore) > 0 {
-			if stringInArray(target.id, ignore) {
-				continue
-			}
-		}
-
 		// return direction values of 0,1,2,3 = same,up,down,side respectively
 		// distance is SNP-distance (int)
 		direction, distance = whichWay(q, target, thresh)
@@ -441,104 +492,17 @@ func findUpDownCatchment(q updownLine, ignore []string, sizeArray [4]int, nofill
 			continue
 		}
 
-		switch direction {
-		case 0: // same
-			if len(neighbours.same.catchment) < sizetotal {
-				rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance}
-				neighbours.same.catchment = append(neighbours.same.catchment, rs)
-
-				if len(neighbours.same.catchment) == sizetotal {
-					rearrangeCatchment(&neighbours.same, sizetotal)
-				}
-
-			} else if distance < neighbours.same.maxDist {
-				rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance}
-				neighbours.same.catchment = append(neighbours.same.catchment, rs)
-				rearrangeCatchment(&neighbours.same, sizetotal)
-
-			} else if distance == neighbours.same.maxDist && target.ambCount < neighbours.same.minAmbig {
-				rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance}
-				neighbours.same.catchment = append(neighbours.same.catchment, rs)
-				rearrangeCatchment(&neighbours.same, sizetotal)
-			}
-		case 1: // up
-			if len(neighbours.up.catchment) < sizetotal {
-				rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance}
-				neighbours.up.catchment = append(neighbours.up.catchment, rs)
-
-				if len(neighbours.up.catchment) == sizetotal {
-					rearrangeCatchment(&neighbours.up, sizetotal)
-				}
-
-			} else if distance < neighbours.up.maxDist {
-				rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance}
-				neighbours.up.catchment = append(neighbours.up.catchment, rs)
-				rearrangeCatchment(&neighbours.up, sizetotal)
-
-			} else if distance == neighbours.up.maxDist && target.ambCount < neighbours.up.minAmbig {
-				rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance}
-				neighbours.up.catchment = append(neighbours.up.catchment, rs)
-				rearrangeCatchment(&neighbours.up, sizetotal)
-			}
-		case 2: // down
-			if len(neighbours.down.catchment) < sizetotal {
-				rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance}
-				neighbours.down.catchment = append(neighbours.down.catchment, rs)
-
-				if len(neighbours.down.catchment) == sizetotal {
-					rearrangeCatchment(&neighbours.down, sizetotal)
-				}
-
-			} else if distance < neighbours.down.maxDist {
-				rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance}
-				neighbours.down.catchment = append(neighbours.down.catchment, rs)
-				rearrangeCatchment(&neighbours.down, sizetotal)
-
-			} else if distance == neighbours.down.maxDist && target.ambCount < neighbours.down.minAmbig {
-				rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance}
-				neighbours.down.catchment = append(neighbours.down.catchment, rs)
-				rearrangeCatchment(&neighbours.down, sizetotal)
-			}
-		case 3: // side
-			if len(neighbours.side.catchment) < sizetotal {
-				rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance}
-				neighbours.side.catchment = append(neighbours.side.catchment, rs)
-
-				if len(neighbours.side.catchment) == sizetotal {
-					rearrangeCatchment(&neighbours.side, sizetotal)
-				}
-
-			} else if distance < neighbours.side.maxDist {
-				rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance}
-				neighbours.side.catchment = append(neighbours.side.catchment, rs)
-				rearrangeCatchment(&neighbours.side, sizetotal)
-
-			} else if distance == neighbours.side.maxDist && target.ambCount < neighbours.side.minAmbig {
-				rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance}
-				neighbours.side.catchment = append(neighbours.side.catchment, rs)
-				rearrangeCatchment(&neighbours.side, sizetotal)
-			}
-		}
-	}
-
-	if len(neighbours.same.catchment) < sizetotal && len(neighbours.same.catchment) > 0 {
-		rearrangeCatchment(&neighbours.same, len(neighbours.same.catchment))
-	}
-	if len(neighbours.up.catchment) < sizetotal && len(neighbours.up.catchment) > 0 {
-		rearrangeCatchment(&neighbours.up, len(neighbours.up.catchment))
-	}
-	if len(neighbours.down.catchment) < sizetotal && len(neighbours.down.catchment) > 0 {
-		rearrangeCatchment(&neighbours.down, len(neighbours.down.catchment))
-	}
-	if len(neighbours.side.catchment) < sizetotal && len(neighbours.side.catchment) > 0 {
-		rearrangeCatchment(&neighbours.side, len(neighbours.side.catchment))
+		rs = resultsStruct{tname: target.id, ambCount: target.ambCount, distance: distance, target: target, meta: rules.Metadata.Rows[target.id]}
+		addToCatchment(bins[direction], rs, sizetotal, lessArray[direction], rules)
 	}
 
 	var sizeObserved [4]int
-	sizeObserved[0] = len(neighbours.same.catchment)
-	sizeObserved[1] = len(neighbours.up.catchment)
-	sizeObserved[2] = len(neighbours.down.catchment)
-	sizeObserved[3] = len(neighbours.side.catchment)
+	for i, bin := range bins {
+		if len(bin.catchment) < sizetotal && len(bin.catchment) > 0 {
+			rearrangeCatchment(bin, len(bin.catchment), lessArray[i], rules)
+		}
+		sizeObserved[i] = len(bin.catchment)
+	}
 
 	size := balance(sizetotal, sizeArray, sizeObserved, nofill)
 
@@ -551,7 +515,7 @@ func findUpDownCatchment(q updownLine, ignore []string, sizeArray [4]int, nofill
 }
 
 // splitInput fans each target out over the array of queries
-func splitInput(queries []updownLine, ignore []string, sizeArray [4]int, nofill bool, distArray [4]int, threshpair float32, threshtarg int,
+func splitInput(queries []updownLine, rules TargetRules, sizeArray [4]int, nofill bool, distArray [4]int, threshpair float32, threshtarg int,
 	pushDistance int, cIn chan updownLine, cOut chan updownCatchmentStruct, cErr chan error, cSplitDone chan bool) {
 
 	nQ := len(queries)
@@ -565,15 +529,15 @@ func splitInput(queries []updownLine, ignore []string, sizeArray [4]int, nofill
 	for i, q := range queries {
 		switch {
 		case pushDistance > 0:
-			go findUpDownCatchmentPushDistance(q, ignore, sizeArray, pushDistance, threshpair, QChanArray[i], cOut)
+			go findUpDownCatchmentPushDistance(q, rules, sizeArray, pushDistance, threshpair, QChanArray[i], cOut)
 		default:
-			go findUpDownCatchment(q, ignore, sizeArray, nofill, distArray, threshpair, QChanArray[i], cOut)
+			go findUpDownCatchment(q, rules, sizeArray, nofill, distArray, threshpair, QChanArray[i], cOut)
 		}
 
 	}
 
 	for udL := range cIn {
-		if udL.ambCount > threshtarg {
+		if udL.ambCount > threshtarg || !rules.keep(udL.id) {
 			continue
 		}
 		for i, _ := range QChanArray {
@@ -740,6 +704,35 @@ func writeUpdownTable(w io.Writer, results []updownCatchmentStruct) error {
 	return nil
 }
 
+// writeUpdownPrivate writes the output in table format, including SNP-distances and, for each query-neighbour pair, the
+// snps that are private to the query and to the target, and the sites where snps are masked by ambiguities
+func writeUpdownPrivate(w io.Writer, queries []updownLine, results []updownCatchmentStruct) error {
+	_, err := w.Write([]byte("query,direction,distance,target,query_snps,target_snps,masked_sites\n"))
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		q := queries[result.qidx]
+		for i, catchment := range [][]resultsStruct{result.same.catchment, result.up.catchment, result.down.catchment, result.side.catchment} {
+			direction := []string{"same", "up", "down", "side"}[i]
+			for _, neighbour := range catchment {
+				qOnly, tOnly, masked := snps.Private(profile(q), profile(neighbour.target))
+				maskedStrings := make([]string, len(masked))
+				for j := range masked {
+					maskedStrings[j] = strconv.Itoa(masked[j])
+				}
+				_, err = w.Write([]byte(strings.Join([]string{result.qname, direction, strconv.Itoa(neighbour.distance), neighbour.tname,
+					strings.Join(qOnly.SNPs, "|"), strings.Join(tOnly.SNPs, "|"), strings.Join(maskedStrings, "|")}, ",") + "\n"))
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
 // true false every item in s == 0
 func allZero(s []int) bool {
 	for _, n := range s {
@@ -843,9 +836,11 @@ func sum4(a [4]int) int {
 
 // TopRanking finds pseudo-tree-aware catchments for query sequences, given a large database of target sequences, the closest
 // of which should be returned in the output. Targets are split into bins depending on whether they are likely direct ancestors of,
-// direct descendants of, polyphyletic with, or exactly the same as, the query
-func TopRanking(query, target, reference io.Reader, out io.Writer, table bool,
-	q_in_type, t_in_type string, ignoreArray []string,
+// direct descendants of, polyphyletic with, or exactly the same as, the query. rules filters the targets and breaks ties between
+// them using their metadata. If private is true, the output is a long-form table which includes the snps that are private to
+// the query and to each neighbour, and the sites that are masked by ambiguities
+func TopRanking(query, target, reference io.Reader, out io.Writer, table bool, private bool,
+	q_in_type, t_in_type string, rules TargetRules,
 	sizetotal int, sizeup int, sizedown int, sizeside int, sizesame int,
 	distall int, distup int, distdown int, distside int,
 	threshpair float32, threshtarg int, nofill bool, distpush int) error {
@@ -900,7 +895,7 @@ func TopRanking(query, target, reference io.Reader, out io.Writer, table bool,
 		go readFastaToUDLChan(target, refSeq, cudL, cErr, cReadDone)
 	}
 
-	go splitInput(queries, ignoreArray,
+	go splitInput(queries, rules,
 		sizeArray, nofill, distArray, threshpair, threshtarg, distpush,
 		cudL, cResults, cErr, cSplitDone)
 
@@ -928,7 +923,13 @@ func TopRanking(query, target, reference io.Reader, out io.Writer, table bool,
 		QResultsArray[result.qidx] = result
 	}
 
-	if table {
+	if private {
+		queriesByIdx := make([]updownLine, nQ)
+		for _, q := range queries {
+			queriesByIdx[q.idx] = q
+		}
+		err = writeUpdownPrivate(out, queriesByIdx, QResultsArray)
+	} else if table {
 		err = writeUpdownTable(out, QResultsArray)
 	} else {
 		err = writeUpDownCatchment(out, QResultsArray)
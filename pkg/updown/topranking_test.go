@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"testing"
+
+	"github.com/virus-evolution/gofasta/pkg/metadata"
 )
 
 func TestTopRanking1(t *testing.T) {
@@ -35,7 +37,7 @@ ATGCTT
 	table := false
 	qtype := "fasta"
 	ttype := "fasta"
-	ignoreArray := make([]string, 0)
+	rules := TargetRules{}
 	TRsizetotal := 5
 	TRsizeup := 0
 	TRsizedown := 0
@@ -50,8 +52,8 @@ ATGCTT
 	TRnofill := false
 	TRdistpush := 0
 
-	err := TopRanking(query, target, ref, out, table,
-		qtype, ttype, ignoreArray,
+	err := TopRanking(query, target, ref, out, table, false,
+		qtype, ttype, rules,
 		TRsizetotal, TRsizeup, TRsizedown, TRsizeside, TRsizesame,
 		TRdistall, TRdistup, TRdistdown, TRdistside,
 		TRthresholdpair, TRthresholdtarget, TRnofill, TRdistpush)
@@ -87,8 +89,8 @@ Query1,TargetSame1,TargetUp2;TargetUp1,TargetDown1,TargetSide2
 	ttype = "csv"
 	out = new(bytes.Buffer)
 
-	err = TopRanking(queryList, targetList, ref, out, table,
-		qtype, ttype, ignoreArray,
+	err = TopRanking(queryList, targetList, ref, out, table, false,
+		qtype, ttype, rules,
 		TRsizetotal, TRsizeup, TRsizedown, TRsizeside, TRsizesame,
 		TRdistall, TRdistup, TRdistdown, TRdistside,
 		TRthresholdpair, TRthresholdtarget, TRnofill, TRdistpush)
@@ -130,7 +132,7 @@ ATGCTT
 	table := false
 	qtype := "fasta"
 	ttype := "fasta"
-	ignoreArray := make([]string, 0)
+	rules := TargetRules{}
 	TRsizetotal := 0
 	TRsizeup := 1
 	TRsizedown := 1
@@ -145,8 +147,8 @@ ATGCTT
 	TRnofill := false
 	TRdistpush := 0
 
-	err := TopRanking(query, target, ref, out, table,
-		qtype, ttype, ignoreArray,
+	err := TopRanking(query, target, ref, out, table, false,
+		qtype, ttype, rules,
 		TRsizetotal, TRsizeup, TRsizedown, TRsizeside, TRsizesame,
 		TRdistall, TRdistup, TRdistdown, TRdistside,
 		TRthresholdpair, TRthresholdtarget, TRnofill, TRdistpush)
@@ -182,8 +184,8 @@ Query1,TargetSame1,TargetUp2,TargetDown1,TargetSide2
 	ttype = "csv"
 	out = new(bytes.Buffer)
 
-	err = TopRanking(queryList, targetList, ref, out, table,
-		qtype, ttype, ignoreArray,
+	err = TopRanking(queryList, targetList, ref, out, table, false,
+		qtype, ttype, rules,
 		TRsizetotal, TRsizeup, TRsizedown, TRsizeside, TRsizesame,
 		TRdistall, TRdistup, TRdistdown, TRdistside,
 		TRthresholdpair, TRthresholdtarget, TRnofill, TRdistpush)
@@ -225,7 +227,7 @@ ATGCTT
 	table := false
 	qtype := "fasta"
 	ttype := "fasta"
-	ignoreArray := make([]string, 0)
+	rules := TargetRules{}
 	TRsizetotal := 0
 	TRsizeup := 0
 	TRsizedown := 0
@@ -240,8 +242,8 @@ ATGCTT
 	TRnofill := false
 	TRdistpush := 2
 
-	err := TopRanking(query, target, ref, out, table,
-		qtype, ttype, ignoreArray,
+	err := TopRanking(query, target, ref, out, table, false,
+		qtype, ttype, rules,
 		TRsizetotal, TRsizeup, TRsizedown, TRsizeside, TRsizesame,
 		TRdistall, TRdistup, TRdistdown, TRdistside,
 		TRthresholdpair, TRthresholdtarget, TRnofill, TRdistpush)
@@ -277,8 +279,8 @@ Query1,TargetSame1,TargetUp2;TargetUp1,TargetDown1,TargetSide2;TargetSide1
 	ttype = "csv"
 	out = new(bytes.Buffer)
 
-	err = TopRanking(queryList, targetList, ref, out, table,
-		qtype, ttype, ignoreArray,
+	err = TopRanking(queryList, targetList, ref, out, table, false,
+		qtype, ttype, rules,
 		TRsizetotal, TRsizeup, TRsizedown, TRsizeside, TRsizesame,
 		TRdistall, TRdistup, TRdistdown, TRdistside,
 		TRthresholdpair, TRthresholdtarget, TRnofill, TRdistpush)
@@ -322,7 +324,7 @@ ATNATC
 	table := false
 	qtype := "fasta"
 	ttype := "fasta"
-	ignoreArray := make([]string, 0)
+	rules := TargetRules{}
 	TRsizetotal := 0
 	TRsizeup := 2
 	TRsizedown := 2
@@ -337,8 +339,8 @@ ATNATC
 	TRnofill := false
 	TRdistpush := 0
 
-	err := TopRanking(query, target, ref, out, table,
-		qtype, ttype, ignoreArray,
+	err := TopRanking(query, target, ref, out, table, false,
+		qtype, ttype, rules,
 		TRsizetotal, TRsizeup, TRsizedown, TRsizeside, TRsizesame,
 		TRdistall, TRdistup, TRdistdown, TRdistside,
 		TRthresholdpair, TRthresholdtarget, TRnofill, TRdistpush)
@@ -374,8 +376,8 @@ Query1,TargetSame1,TargetUp2;TargetUp1,TargetDown1,TargetSide3;TargetSide1
 	ttype = "csv"
 	out = new(bytes.Buffer)
 
-	err = TopRanking(queryList, targetList, ref, out, table,
-		qtype, ttype, ignoreArray,
+	err = TopRanking(queryList, targetList, ref, out, table, false,
+		qtype, ttype, rules,
 		TRsizetotal, TRsizeup, TRsizedown, TRsizeside, TRsizesame,
 		TRdistall, TRdistup, TRdistdown, TRdistside,
 		TRthresholdpair, TRthresholdtarget, TRnofill, TRdistpush)
@@ -419,7 +421,7 @@ ATNATC
 	table := false
 	qtype := "fasta"
 	ttype := "fasta"
-	ignoreArray := make([]string, 0)
+	rules := TargetRules{}
 	TRsizetotal := 100
 	TRsizeup := 0
 	TRsizedown := 0
@@ -434,8 +436,8 @@ ATNATC
 	TRnofill := false
 	TRdistpush := 0
 
-	err := TopRanking(query, target, ref, out, table,
-		qtype, ttype, ignoreArray,
+	err := TopRanking(query, target, ref, out, table, false,
+		qtype, ttype, rules,
 		TRsizetotal, TRsizeup, TRsizedown, TRsizeside, TRsizesame,
 		TRdistall, TRdistup, TRdistdown, TRdistside,
 		TRthresholdpair, TRthresholdtarget, TRnofill, TRdistpush)
@@ -471,8 +473,8 @@ Query1,TargetSame1,TargetUp2;TargetUp1,TargetDown1,TargetSide1
 	ttype = "csv"
 	out = new(bytes.Buffer)
 
-	err = TopRanking(queryList, targetList, ref, out, table,
-		qtype, ttype, ignoreArray,
+	err = TopRanking(queryList, targetList, ref, out, table, false,
+		qtype, ttype, rules,
 		TRsizetotal, TRsizeup, TRsizedown, TRsizeside, TRsizesame,
 		TRdistall, TRdistup, TRdistdown, TRdistside,
 		TRthresholdpair, TRthresholdtarget, TRnofill, TRdistpush)
@@ -514,7 +516,7 @@ ATGCTT
 	table := false
 	qtype := "fasta"
 	ttype := "fasta"
-	ignoreArray := make([]string, 0)
+	rules := TargetRules{}
 	TRsizetotal := 0
 	TRsizeup := 0
 	TRsizedown := 0
@@ -529,8 +531,8 @@ ATGCTT
 	TRnofill := false
 	TRdistpush := 0
 
-	err := TopRanking(query, target, ref, out, table,
-		qtype, ttype, ignoreArray,
+	err := TopRanking(query, target, ref, out, table, false,
+		qtype, ttype, rules,
 		TRsizetotal, TRsizeup, TRsizedown, TRsizeside, TRsizesame,
 		TRdistall, TRdistup, TRdistdown, TRdistside,
 		TRthresholdpair, TRthresholdtarget, TRnofill, TRdistpush)
@@ -565,8 +567,8 @@ Query1,TargetSame1_dist0,TargetUp2_dist1,TargetDown1_dist1,
 	ttype = "csv"
 	out = new(bytes.Buffer)
 
-	err = TopRanking(queryList, targetList, ref, out, table,
-		qtype, ttype, ignoreArray,
+	err = TopRanking(queryList, targetList, ref, out, table, false,
+		qtype, ttype, rules,
 		TRsizetotal, TRsizeup, TRsizedown, TRsizeside, TRsizesame,
 		TRdistall, TRdistup, TRdistdown, TRdistside,
 		TRthresholdpair, TRthresholdtarget, TRnofill, TRdistpush)
@@ -610,7 +612,7 @@ ATGCTT
 	table := false
 	qtype := "fasta"
 	ttype := "fasta"
-	ignoreArray := make([]string, 0)
+	rules := TargetRules{}
 	TRsizetotal := 0
 	TRsizeup := 0
 	TRsizedown := 0
@@ -625,8 +627,8 @@ ATGCTT
 	TRnofill := false
 	TRdistpush := 2
 
-	err := TopRanking(query, target, ref, out, table,
-		qtype, ttype, ignoreArray,
+	err := TopRanking(query, target, ref, out, table, false,
+		qtype, ttype, rules,
 		TRsizetotal, TRsizeup, TRsizedown, TRsizeside, TRsizesame,
 		TRdistall, TRdistup, TRdistdown, TRdistside,
 		TRthresholdpair, TRthresholdtarget, TRnofill, TRdistpush)
@@ -661,8 +663,8 @@ Query1,TargetSame1_dist0,TargetUp2_dist1;TargetUp1_dist2,TargetDown1_dist1,Targe
 	ttype = "csv"
 	out = new(bytes.Buffer)
 
-	err = TopRanking(queryList, targetList, ref, out, table,
-		qtype, ttype, ignoreArray,
+	err = TopRanking(queryList, targetList, ref, out, table, false,
+		qtype, ttype, rules,
 		TRsizetotal, TRsizeup, TRsizedown, TRsizeside, TRsizesame,
 		TRdistall, TRdistup, TRdistdown, TRdistside,
 		TRthresholdpair, TRthresholdtarget, TRnofill, TRdistpush)
@@ -704,7 +706,7 @@ ATGCTT
 	table := true
 	qtype := "fasta"
 	ttype := "fasta"
-	ignoreArray := make([]string, 0)
+	rules := TargetRules{}
 	TRsizetotal := 5
 	TRsizeup := 0
 	TRsizedown := 0
@@ -719,8 +721,8 @@ ATGCTT
 	TRnofill := false
 	TRdistpush := 0
 
-	err := TopRanking(query, target, ref, out, table,
-		qtype, ttype, ignoreArray,
+	err := TopRanking(query, target, ref, out, table, false,
+		qtype, ttype, rules,
 		TRsizetotal, TRsizeup, TRsizedown, TRsizeside, TRsizesame,
 		TRdistall, TRdistup, TRdistdown, TRdistside,
 		TRthresholdpair, TRthresholdtarget, TRnofill, TRdistpush)
@@ -759,8 +761,8 @@ Query1,side,2,TargetSide2
 	ttype = "csv"
 	out = new(bytes.Buffer)
 
-	err = TopRanking(queryList, targetList, ref, out, table,
-		qtype, ttype, ignoreArray,
+	err = TopRanking(queryList, targetList, ref, out, table, false,
+		qtype, ttype, rules,
 		TRsizetotal, TRsizeup, TRsizedown, TRsizeside, TRsizesame,
 		TRdistall, TRdistup, TRdistdown, TRdistside,
 		TRthresholdpair, TRthresholdtarget, TRnofill, TRdistpush)
@@ -772,3 +774,183 @@ Query1,side,2,TargetSide2
 		t.Errorf("problem in TestTopRankingTable1(csv)")
 	}
 }
+
+func TestTopRankingPrivate(t *testing.T) {
+	refData := []byte(`>ref
+ATGATG
+`)
+	queryData := []byte(
+		`>Query1
+ATTATT
+`)
+
+	targetData := []byte(`>TargetUp1
+ATGATG
+>TargetSame1
+ATTATT
+>TargetDown1
+ATTACT
+>TargetUp2
+ATGATT
+>TargetSide1
+CCCCCC
+>TargetSide2
+ATGCTT
+`)
+	ref := bytes.NewReader(refData)
+	query := bytes.NewReader(queryData)
+	target := bytes.NewReader(targetData)
+	out := new(bytes.Buffer)
+	table := false
+	qtype := "fasta"
+	ttype := "fasta"
+	rules := TargetRules{}
+	TRsizetotal := 5
+	TRsizeup := 0
+	TRsizedown := 0
+	TRsizeside := 0
+	TRsizesame := 0
+	TRdistall := 0
+	TRdistup := 0
+	TRdistdown := 0
+	TRdistside := 0
+	TRthresholdpair := float32(0.1)
+	TRthresholdtarget := 10000
+	TRnofill := false
+	TRdistpush := 0
+
+	err := TopRanking(query, target, ref, out, table, true,
+		qtype, ttype, rules,
+		TRsizetotal, TRsizeup, TRsizedown, TRsizeside, TRsizesame,
+		TRdistall, TRdistup, TRdistdown, TRdistside,
+		TRthresholdpair, TRthresholdtarget, TRnofill, TRdistpush)
+	if err != nil {
+		t.Error(err)
+	}
+
+	desiredResult := `query,direction,distance,target,query_snps,target_snps,masked_sites
+Query1,same,0,TargetSame1,,,
+Query1,up,1,TargetUp2,G3T,,
+Query1,up,2,TargetUp1,G3T|G6T,,
+Query1,down,1,TargetDown1,,T5C,
+Query1,side,2,TargetSide2,G3T,A4C,
+`
+	if string(out.Bytes()) != desiredResult {
+		t.Errorf("problem in TestTopRankingPrivate()")
+		fmt.Println(string(out.Bytes()))
+	}
+}
+
+func TestTopRankingMetadata(t *testing.T) {
+	queryData := []byte(`query,SNPs,ambiguities,SNPcount,ambcount
+Query1,A1C|A2C,,2,0
+`)
+	targetData := []byte(`query,SNPs,ambiguities,SNPcount,ambcount
+Same1,A1C|A2C,,2,0
+Same2,A1C|A2C,,2,0
+Same3,A1C|A2C,,2,0
+Up1,A1C,,1,0
+Up2,A2C,,1,0
+Down1,A1C|A2C|A3C,,3,0
+Side1,A1C|A5C,,2,0
+`)
+	metadataData := []byte(`id,country,date
+Same1,UK,2021-01-01
+Same2,FR,2021-05-01
+Same3,UK,2021-06-01
+Up1,UK,2020-01-01
+Up2,UK,2021-01-01
+Down1,FR,2019-12-01
+`)
+
+	m, err := metadata.Read(bytes.NewReader(metadataData), "")
+	if err != nil {
+		t.Error(err)
+	}
+
+	rules, err := NewTargetRules(m, []string{}, "date", "2020-01-01", "", []string{}, []string{}, []string{"date:desc"}, "", 0)
+	if err != nil {
+		t.Error(err)
+	}
+
+	out := new(bytes.Buffer)
+	err = TopRanking(bytes.NewReader(queryData), bytes.NewReader(targetData), nil, out, true, false,
+		"csv", "csv", rules,
+		0, 0, 0, 0, 0,
+		5, 0, 0, 0,
+		float32(0.1), 10000, false, 0)
+	if err != nil {
+		t.Error(err)
+	}
+
+	desiredResult := `query,direction,distance,target
+Query1,same,0,Same3
+Query1,same,0,Same2
+Query1,same,0,Same1
+Query1,up,1,Up2
+Query1,up,1,Up1
+`
+	if out.String() != desiredResult {
+		t.Errorf("problem in TestTopRankingMetadata(date)")
+		fmt.Println(out.String())
+	}
+
+	rules, err = NewTargetRules(m, []string{"Up2"}, "date", "", "", []string{"country=UK,FR"}, []string{}, []string{"date:desc", "up=date"}, "country", 1)
+	if err != nil {
+		t.Error(err)
+	}
+
+	out = new(bytes.Buffer)
+	err = TopRanking(bytes.NewReader(queryData), bytes.NewReader(targetData), nil, out, true, false,
+		"csv", "csv", rules,
+		0, 0, 0, 0, 0,
+		5, 0, 0, 0,
+		float32(0.1), 10000, false, 0)
+	if err != nil {
+		t.Error(err)
+	}
+
+	desiredResult = `query,direction,distance,target
+Query1,same,0,Same3
+Query1,same,0,Same2
+Query1,up,1,Up1
+Query1,down,1,Down1
+`
+	if out.String() != desiredResult {
+		t.Errorf("problem in TestTopRankingMetadata(quota)")
+		fmt.Println(out.String())
+	}
+
+	_, err = NewTargetRules(metadata.Table{}, []string{}, "date", "2020-01-01", "", []string{}, []string{}, []string{}, "", 0)
+	if err == nil {
+		t.Errorf("expected an error for a date filter without metadata in TestTopRankingMetadata()")
+	}
+
+	// --ignore is an exclude filter on the ID, which doesn't need metadata
+	rules, err = NewTargetRules(metadata.Table{}, []string{"Same1", "Up1"}, "date", "", "", []string{}, []string{}, []string{}, "", 0)
+	if err != nil {
+		t.Error(err)
+	}
+
+	out = new(bytes.Buffer)
+	err = TopRanking(bytes.NewReader(queryData), bytes.NewReader(targetData), nil, out, true, false,
+		"csv", "csv", rules,
+		0, 0, 0, 0, 0,
+		5, 0, 0, 0,
+		float32(0.1), 10000, false, 0)
+	if err != nil {
+		t.Error(err)
+	}
+
+	desiredResult = `query,direction,distance,target
+Query1,same,0,Same2
+Query1,same,0,Same3
+Query1,up,1,Up2
+Query1,down,1,Down1
+Query1,side,2,Side1
+`
+	if out.String() != desiredResult {
+		t.Errorf("problem in TestTopRankingMetadata(ignore)")
+		fmt.Println(out.String())
+	}
+}
@@ -0,0 +1,212 @@
+package updown
+
+import (
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/virus-evolution/gofasta/pkg/tree"
+)
+
+/*
+Pseudo-trees
+
+Sequences with the same SNPs are collapsed into haplotypes, and each haplotype is placed as a child of a sampled
+haplotype whose SNPs are a subset of its own (i.e. one that would be in its "up" bin from whichWay), or of the
+reference if there is none. Haplotypes are added in order of their number of SNPs, so every possible parent is already
+in the tree when a haplotype is added. Each one is placed by descending from the root: at each node, we move to the
+child whose SNPs are a subset of the new haplotype's (the child with the most SNPs, with ties broken by input order),
+and stop when there isn't one. The children of each node are indexed by their first SNP that isn't in the node, so only
+the children that could be a subset are checked, and the whole tree can be built in roughly linear time.
+
+This is greedy, so a haplotype can be placed under a less derived ancestor than the best available one, and
+ambiguities are ignored (an ambiguous site is treated as the reference allele), so it is only a pseudo-phylogeny.
+*/
+
+// hapNode is a haplotype in a pseudo-tree
+type hapNode struct {
+	members  []int   // indices of the sequences in this haplotype, in input order
+	snps     []int32 // all this haplotype's snps, as sorted indices into an array of unique snps
+	private  []int32 // the snps that aren't in this haplotype's parent
+	parent   *hapNode
+	children map[int32][]*hapNode // keyed by each child's first private snp
+}
+
+// isSubset asks if every snp in a is also in b. Both must be sorted
+func isSubset(a, b []int32) bool {
+	j := 0
+	for _, snp := range a {
+		for j < len(b) && b[j] < snp {
+			j++
+		}
+		if j == len(b) || b[j] != snp {
+			return false
+		}
+		j++
+	}
+	return true
+}
+
+// placeHaplotype adds h to the pseudo-tree under root, below the most derived haplotype that greedy descent finds
+// whose snps are a subset of h's
+func placeHaplotype(root, h *hapNode) {
+
+	// the snps of h that aren't in the current node. Only children keyed by one of these can be a subset of h
+	remaining := make([]int32, len(h.snps))
+	copy(remaining, h.snps)
+
+	node := root
+	for {
+		var best *hapNode
+		consider := func(c *hapNode) {
+			if len(c.snps) >= len(h.snps) || !isSubset(c.private, remaining) {
+				return
+			}
+			if best == nil || len(c.snps) > len(best.snps) || (len(c.snps) == len(best.snps) && c.members[0] < best.members[0]) {
+				best = c
+			}
+		}
+		// look up whichever is smaller: the node's children or h's remaining snps
+		if len(node.children) < len(remaining) {
+			for _, cs := range node.children {
+				for _, c := range cs {
+					consider(c)
+				}
+			}
+		} else {
+			for _, snp := range remaining {
+				for _, c := range node.children[snp] {
+					consider(c)
+				}
+			}
+		}
+		if best == nil {
+			break
+		}
+		node = best
+		// node.private is a subset of remaining, and both are sorted
+		temp := remaining[:0]
+		j := 0
+		for _, snp := range remaining {
+			if j < len(node.private) && node.private[j] == snp {
+				j++
+				continue
+			}
+			temp = append(temp, snp)
+		}
+		remaining = temp
+	}
+
+	h.parent = node
+	h.private = remaining
+	if node.children == nil {
+		node.children = make(map[int32][]*hapNode)
+	}
+	node.children[h.private[0]] = append(node.children[h.private[0]], h)
+}
+
+// buildPseudoTree collapses the sequences in lines into haplotypes and assembles them into a pseudo-tree. It returns
+// the haplotypes in the order they were added, starting with the root (the reference, which has no members unless
+// there are sequences with no snps)
+func buildPseudoTree(lines []updownLine) []*hapNode {
+
+	root := &hapNode{members: make([]int, 0), snps: make([]int32, 0)}
+
+	// comparing snps as integers is much faster than as strings
+	snpIDs := make(map[string]int32)
+
+	haplotypes := make([]*hapNode, 0)
+	index := make(map[string]*hapNode)
+	for i, l := range lines {
+		if len(l.snpsSorted) == 0 {
+			root.members = append(root.members, i)
+			continue
+		}
+		key := strings.Join(l.snpsSorted, "|")
+		if h, ok := index[key]; ok {
+			h.members = append(h.members, i)
+			continue
+		}
+		snps := make([]int32, len(l.snpsSorted))
+		for j, snp := range l.snpsSorted {
+			id, ok := snpIDs[snp]
+			if !ok {
+				id = int32(len(snpIDs))
+				snpIDs[snp] = id
+			}
+			snps[j] = id
+		}
+		sort.Slice(snps, func(a, b int) bool { return snps[a] < snps[b] })
+		h := &hapNode{members: []int{i}, snps: snps}
+		index[key] = h
+		haplotypes = append(haplotypes, h)
+	}
+
+	// a stable sort by number of snps keeps input order within each size, so that ties are broken by it
+	sortBySize(haplotypes)
+
+	for _, h := range haplotypes {
+		placeHaplotype(root, h)
+	}
+
+	return append([]*hapNode{root}, haplotypes...)
+}
+
+// sortBySize sorts haplotypes by number of snps, keeping input order for haplotypes with the same number. It is a
+// counting sort, because there can be millions of haplotypes but only a few hundred distinct sizes
+func sortBySize(haplotypes []*hapNode) {
+	buckets := make(map[int][]*hapNode)
+	max := 0
+	for _, h := range haplotypes {
+		buckets[len(h.snps)] = append(buckets[len(h.snps)], h)
+		if len(h.snps) > max {
+			max = len(h.snps)
+		}
+	}
+	i := 0
+	for size := 0; size <= max; size++ {
+		for _, h := range buckets[size] {
+			haplotypes[i] = h
+			i++
+		}
+	}
+}
+
+// pseudoTreeToNodes converts a pseudo-tree (in the order it was built, root first) to a tree.Node. Each haplotype is a
+// node named after its first sequence, so parents are internal, sampled ancestors. The haplotype's other sequences
+// hang off it as zero-length tips, ahead of its descendants, and branch lengths are numbers of snps
+func pseudoTreeToNodes(lines []updownLine, haplotypes []*hapNode) *tree.Node {
+	nodes := make(map[*hapNode]*tree.Node, len(haplotypes))
+	for _, h := range haplotypes {
+		n := &tree.Node{}
+		if h.parent != nil {
+			n.Length = float64(len(h.private))
+			p := nodes[h.parent]
+			p.Children = append(p.Children, n)
+		}
+		if len(h.members) > 0 {
+			n.Name = lines[h.members[0]].id
+			for _, m := range h.members[1:] {
+				n.Children = append(n.Children, &tree.Node{Name: lines[m].id})
+			}
+		}
+		nodes[h] = n
+	}
+	return nodes[haplotypes[0]]
+}
+
+// PseudoTree assembles the sequences in query (either the csv output of gofasta updown list, or a fasta-format alignment,
+// given a reference) into an approximate tree that is rooted on the reference, using the parent/child relationships between
+// their snps, and writes it in Newick format with branch lengths in snps. Identical sequences are in polytomies, and
+// sequences that are the likely ancestors of other sequences are named internal nodes
+func PseudoTree(query, reference io.Reader, out io.Writer, q_in_type string) error {
+
+	lines, err := loadLines(query, reference, q_in_type)
+	if err != nil {
+		return err
+	}
+
+	haplotypes := buildPseudoTree(lines)
+
+	return tree.WriteNewick(out, pseudoTreeToNodes(lines, haplotypes))
+}
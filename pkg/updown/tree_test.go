@@ -0,0 +1,64 @@
+package updown
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+func TestPseudoTree(t *testing.T) {
+	refData := []byte(`>ref
+AAAAAAAAAA
+`)
+	queryData := []byte(`>R
+AAAAAAAAAA
+>P1
+CAAAAAAAAA
+>A
+CAAAGAAAAA
+>A2
+CAAAGAAAAA
+>B
+CAAAGATAAA
+>X
+AAAAAAATGA
+>C
+CCAAAAAAAA
+`)
+
+	ref := bytes.NewReader(refData)
+	query := bytes.NewReader(queryData)
+	out := new(bytes.Buffer)
+
+	err := PseudoTree(query, ref, out, "fasta")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != "(((A2:0,B:1)A:1,C:1)P1:1,X:2)R;\n" {
+		t.Errorf("problem in TestPseudoTree()")
+		fmt.Println(out.String())
+	}
+}
+
+func TestPseudoTreeNoRoot(t *testing.T) {
+	queryData := []byte(`query,SNPs,ambiguities,SNPcount,ambcount
+A,A1C|A5G,,2,0
+B,A5G,,1,0
+C,A9T,,1,0
+D,A1C|A2T|A5G,,3,0
+`)
+
+	query := bytes.NewReader(queryData)
+	out := new(bytes.Buffer)
+
+	err := PseudoTree(query, nil, out, "csv")
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != "(((D:1)A:1)B:1,C:1);\n" {
+		t.Errorf("problem in TestPseudoTreeNoRoot()")
+		fmt.Println(out.String())
+	}
+}
@@ -0,0 +1,127 @@
+package variants
+
+import (
+	"strconv"
+)
+
+// A CDSFlag is a change to the structure of one CDS in a query: a premature stop codon, the loss of the stop codon,
+// or indels whose net length shifts the frame
+type CDSFlag struct {
+	Flagtype string // one of {stop,stoploss,frameshift}
+	Feature  string // the name of the CDS
+	Residue  int    // (1-based) amino acid location of the premature stop, or of the lost stop
+	Length   int    // for stop, the length of the truncated protein. For frameshift, the net length of the indels
+}
+
+// stopCodonPositions returns the (1-based) reference positions of a CDS's stop codon, or nil if its translation
+// doesn't end in one
+func stopCodonPositions(r Region) []int {
+	if len(r.Translation) == 0 || r.Translation[len(r.Translation)-1] != '*' || len(r.Positions) < 3 {
+		return nil
+	}
+	return r.Positions[len(r.Positions)-3:]
+}
+
+// GetCDSFlags returns the structural changes to each CDS in one query's variants, in the order of cdsregions:
+//
+//	stop       - the first amino acid change to a stop codon before the end of the CDS
+//	stoploss   - a change of the CDS's stop codon to an amino acid, or a deletion that overlaps it
+//	frameshift - insertions in the CDS and deletions of its nucleotides whose net length isn't a multiple of three
+//
+// If each is true, every amino acid change to a stop codon is flagged instead of the first one, and every indel whose
+// length in the CDS isn't a multiple of three is flagged as its own frameshift (with its length) instead of the net
+// length of them all, so that they can be counted.
+//
+// Only the variants that are between start and end are used, as for the mutations that WriteVariants writes (all of
+// them are used unless both start and end are > 0). Amino acids are only called in the reference frame, so a stop codon
+// that is only read in the shifted frame downstream of an indel is not flagged - just the frameshift is
+func GetCDSFlags(vs []Variant, cdsregions []Region, start, end int, each bool) []CDSFlag {
+
+	flags := make([]CDSFlag, 0)
+
+	for _, r := range cdsregions {
+
+		stops := make([]CDSFlag, 0)
+		shifts := make([]CDSFlag, 0)
+		stoploss := false
+		net := 0
+
+		stopCodon := stopCodonPositions(r)
+
+		for _, v := range vs {
+			if !inWindow(v, start, end) {
+				continue
+			}
+			switch v.Changetype {
+			case "aa":
+				if v.Feature != r.Name {
+					continue
+				}
+				if v.QueAl == "*" && v.RefAl != "*" {
+					stop := CDSFlag{Flagtype: "stop", Feature: r.Name, Residue: v.Residue, Length: v.Residue - 1}
+					switch {
+					case each || len(stops) == 0:
+						stops = append(stops, stop)
+					case v.Residue < stops[0].Residue:
+						stops[0] = stop
+					}
+				}
+				if v.RefAl == "*" && v.QueAl != "*" {
+					stoploss = true
+				}
+			case "ins":
+				if v.Position >= r.Start && v.Position < r.Stop {
+					net += v.Length
+					if each && v.Length%3 != 0 {
+						shifts = append(shifts, CDSFlag{Flagtype: "frameshift", Feature: r.Name, Length: v.Length})
+					}
+				}
+			case "del":
+				first, last := v.Position, v.Position+v.Length-1
+				for _, pos := range stopCodon {
+					if pos >= first && pos <= last {
+						stoploss = true
+					}
+				}
+				if first < r.Start {
+					first = r.Start
+				}
+				if last > r.Stop {
+					last = r.Stop
+				}
+				if last >= first {
+					net -= last - first + 1
+					if each && (last-first+1)%3 != 0 {
+						shifts = append(shifts, CDSFlag{Flagtype: "frameshift", Feature: r.Name, Length: -(last - first + 1)})
+					}
+				}
+			}
+		}
+
+		flags = append(flags, stops...)
+		if stoploss {
+			flags = append(flags, CDSFlag{Flagtype: "stoploss", Feature: r.Name, Residue: len(r.Translation)})
+		}
+		switch {
+		case each:
+			flags = append(flags, shifts...)
+		case net%3 != 0:
+			flags = append(flags, CDSFlag{Flagtype: "frameshift", Feature: r.Name, Length: net})
+		}
+	}
+
+	return flags
+}
+
+// FormatCDSFlag returns a string representation of a single CDS flag, the format of which varies given its type:
+// stop:ORF8:27:26, stoploss:ORF8:122 and frameshift:ORF8:-1
+func FormatCDSFlag(f CDSFlag) string {
+	switch f.Flagtype {
+	case "stop":
+		return "stop:" + f.Feature + ":" + strconv.Itoa(f.Residue) + ":" + strconv.Itoa(f.Length)
+	case "stoploss":
+		return "stoploss:" + f.Feature + ":" + strconv.Itoa(f.Residue)
+	default:
+		return "frameshift:" + f.Feature + ":" + strconv.Itoa(f.Length)
+	}
+}
@@ -0,0 +1,229 @@
+package variants
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/virus-evolution/gofasta/pkg/fasta"
+)
+
+// A Constellation is a named set of mutations, as in a scorpio-style constellation json file
+type Constellation struct {
+	Label     string
+	Mutations []NamedMutation
+}
+
+// constellationJSON is the part of a scorpio-style constellation file that we use
+type constellationJSON struct {
+	Label string   `json:"label"`
+	Name  string   `json:"name"`
+	Sites []string `json:"sites"`
+}
+
+// constellationLine is one query's alt, ref, ambiguous and other counts for each constellation, with an index
+// which is used to retain input order in the output
+type constellationLine struct {
+	Queryname string
+	Idx       int
+	Counts    [][4]int // indexed by constellation, then by MutationAlt etc.
+}
+
+// normaliseSite converts scorpio's shorthand for sites to the format returned by FormatVariant: "snp:C3037T" becomes
+// "nuc:C3037T" and "s:D614G" becomes "aa:s:D614G"
+func normaliseSite(site string) string {
+	fields := strings.Split(site, ":")
+	switch strings.ToLower(fields[0]) {
+	case "nuc", "aa", "del", "ins":
+		return strings.ToLower(fields[0]) + site[len(fields[0]):]
+	case "snp":
+		return "nuc" + site[len(fields[0]):]
+	}
+	if len(fields) == 2 {
+		return "aa:" + site
+	}
+	return site
+}
+
+// ReadConstellations reads a scorpio-style constellation json file (either one constellation object, or an array
+// of them), and parses the "sites" of each. Sites must be in the format returned by FormatVariant (including indels),
+// or scorpio's shorthand for amino acid changes (e.g. "s:N501Y"), amino acid deletions (e.g. "s:HV69-") and snps
+// (e.g. "snp:C3267T")
+func ReadConstellations(in io.Reader, cdsregions []Region) ([]Constellation, error) {
+
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return nil, err
+	}
+
+	raw := make([]constellationJSON, 0)
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(data, &raw)
+	} else {
+		var c constellationJSON
+		err = json.Unmarshal(data, &c)
+		raw = append(raw, c)
+	}
+	if err != nil {
+		return nil, errors.New("couldn't parse constellation json: " + err.Error())
+	}
+
+	constellations := make([]Constellation, 0, len(raw))
+	for _, r := range raw {
+		label := r.Label
+		if label == "" {
+			label = r.Name
+		}
+		if label == "" {
+			return nil, errors.New("constellation has no label")
+		}
+		if len(r.Sites) == 0 {
+			return nil, errors.New("constellation " + label + " has no sites")
+		}
+		c := Constellation{Label: label, Mutations: make([]NamedMutation, 0, len(r.Sites))}
+		for _, site := range r.Sites {
+			nm, err := ParseMutation(normaliseSite(site), cdsregions)
+			if err != nil {
+				return nil, errors.New("in constellation " + label + ": " + err.Error())
+			}
+			c.Mutations = append(c.Mutations, nm)
+		}
+		constellations = append(constellations, c)
+	}
+
+	return constellations, nil
+}
+
+// getConstellations is a worker function that annotates the mutations in each fasta record from a channel, calls
+// each constellation's mutations in it, and passes the counts to another channel
+func getConstellations(ref fasta.EncodedRecord, constellations []Constellation, cdsregions []Region, intregions []int, offsetRefCoord []int, offsetMSACoord []int, cMSA chan fasta.EncodedRecord, cConstellations chan constellationLine, cErr chan error) {
+
+	for record := range cMSA {
+
+		if len(record.Seq) != len(offsetMSACoord) {
+			cErr <- errors.New("Gapped reference sequence and alignment are not the same width")
+			break
+		}
+
+		AS, err := GetVariantsPair(ref.Seq, record.Seq, ref.ID, record.ID, record.Idx, cdsregions, intregions, offsetRefCoord, offsetMSACoord)
+		if err != nil {
+			cErr <- err
+			break
+		}
+
+		set, err := VariantSet(AS)
+		if err != nil {
+			cErr <- err
+			break
+		}
+
+		CL := constellationLine{Queryname: record.ID, Idx: record.Idx, Counts: make([][4]int, len(constellations))}
+		for i, c := range constellations {
+			for _, nm := range c.Mutations {
+				CL.Counts[i][CallMutation(nm, set, ref.Seq, record.Seq, offsetRefCoord)]++
+			}
+		}
+
+		cConstellations <- CL
+	}
+}
+
+// WriteConstellations writes each query's counts for each constellation to file or stdout, one row per query
+// per constellation. matched_fraction is the proportion of the constellation's sites that the query has the
+// mutation at
+func WriteConstellations(w io.Writer, constellations []Constellation, firstmissing bool, refID string, cConstellations chan constellationLine, cWriteDone chan bool, cErr chan error) {
+
+	outputMap := make(map[int]constellationLine)
+
+	counter := 0
+	if firstmissing {
+		counter = 1
+	}
+
+	_, err := w.Write([]byte("query,constellation,sites,alt,ref,ambiguous,other,matched_fraction\n"))
+	if err != nil {
+		cErr <- err
+		return
+	}
+
+	for line := range cConstellations {
+		outputMap[line.Idx] = line
+
+		for {
+			if CL, ok := outputMap[counter]; ok {
+
+				if CL.Queryname != refID {
+					for i, c := range constellations {
+						counts := CL.Counts[i]
+						fraction := float64(counts[MutationAlt]) / float64(len(c.Mutations))
+						_, err = w.Write([]byte(CL.Queryname + "," + c.Label + "," + strconv.Itoa(len(c.Mutations)) + "," +
+							strconv.Itoa(counts[MutationAlt]) + "," + strconv.Itoa(counts[MutationRef]) + "," +
+							strconv.Itoa(counts[MutationAmbiguous]) + "," + strconv.Itoa(counts[MutationOther]) + "," +
+							strconv.FormatFloat(fraction, 'f', 4, 64) + "\n"))
+						if err != nil {
+							cErr <- err
+							return
+						}
+					}
+				}
+
+				delete(outputMap, counter)
+				counter++
+			} else {
+				break
+			}
+		}
+	}
+
+	cWriteDone <- true
+}
+
+// Constellations annotates mutations relative to a reference for every record in a fasta-format alignment, as Variants
+// does, and reports for each record and each constellation in constellationsIn (scorpio-style json files) the number of
+// the constellation's sites where the record has the mutation (alt), the reference allele (ref), ambiguous or missing
+// data (ambiguous) or something else (other), and the fraction of sites that match
+func Constellations(msaIn io.Reader, stdin bool, refID string, annoIn io.Reader, annoSuffix string, constellationsIn []io.Reader, out io.Writer, threads int) error {
+
+	cMSA := make(chan fasta.EncodedRecord, 50+threads)
+	cErr := make(chan error)
+	cMSADone := make(chan bool)
+
+	ref, firstmissing, err := StreamWithReference(msaIn, stdin, refID, false, cMSA, cErr, cMSADone)
+	if err != nil {
+		return err
+	}
+
+	ref, cdsregions, intregions, err := RegionsFromAnnotation(annoIn, annoSuffix, ref)
+	if err != nil {
+		return err
+	}
+
+	refToMSA, MSAToRef := GetMSAOffsets(ref.Seq)
+
+	constellations := make([]Constellation, 0)
+	for _, in := range constellationsIn {
+		cs, err := ReadConstellations(in, cdsregions)
+		if err != nil {
+			return err
+		}
+		constellations = append(constellations, cs...)
+	}
+
+	cConstellations := make(chan constellationLine, 50+threads)
+	cWriteDone := make(chan bool)
+
+	go WriteConstellations(out, constellations, firstmissing, ref.ID, cConstellations, cWriteDone, cErr)
+
+	cConstellationsDone := fasta.StartWorkers(threads, func() {
+		getConstellations(ref, constellations, cdsregions, intregions, refToMSA, MSAToRef, cMSA, cConstellations, cErr)
+	})
+
+	return fasta.WaitStages(cErr,
+		fasta.Stage{Done: cMSADone, Close: func() { close(cMSA) }},
+		fasta.Stage{Done: cConstellationsDone, Close: func() { close(cConstellations) }},
+		fasta.Stage{Done: cWriteDone},
+	)
+}
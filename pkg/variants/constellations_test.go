@@ -0,0 +1,149 @@
+package variants
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/virus-evolution/gofasta/pkg/genbank"
+)
+
+func TestConstellations(t *testing.T) {
+	msaData := []byte(`>reference
+ACGTAATGATGATGTAGAAAAAA
+>q1
+ACGTAATGCTGATGTAGAATAAA
+>q2
+ACTTAATGATG---TAGAANAAA
+`)
+
+	genbankData := []byte(`LOCUS       TEST               23 bp ss-RNA     linear   VRL 21-MAR-1987
+FEATURES             Location/Qualifiers
+		source          1..23
+						/organism="Not a real organism"
+		gene            6..17
+						/gene="gene1"
+		CDS             6..17
+						/gene="gene1"
+						/codon_start=1
+						/translation="MMM"
+ORIGIN
+		1 acgtaatgat gatgtagaaa aaa
+`)
+
+	c1 := []byte(`{
+	"label": "c1",
+	"description": "a test constellation",
+	"sites": ["gene1:M2L", "snp:A20T", "del:12:3", "nuc:G3C"]
+}`)
+
+	c2 := []byte(`[{"name": "c2", "sites": ["aa:GENE1:M1I"]}]`)
+
+	msa := bytes.NewReader(msaData)
+	anno := bytes.NewReader(genbankData)
+	out := new(bytes.Buffer)
+
+	err := Constellations(msa, false, "reference", anno, "gb", []io.Reader{bytes.NewReader(c1), bytes.NewReader(c2)}, out, 2)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if out.String() != `query,constellation,sites,alt,ref,ambiguous,other,matched_fraction
+q1,c1,4,2,2,0,0,0.5000
+q1,c2,1,0,1,0,0,0.0000
+q2,c1,4,1,1,1,1,0.2500
+q2,c2,1,0,1,0,0,0.0000
+` {
+		t.Errorf("problem in TestConstellations()")
+		fmt.Println(out.String())
+	}
+}
+
+func TestReadConstellations(t *testing.T) {
+	_, err := ReadConstellations(bytes.NewReader([]byte(`{"label": "c1", "sites": ["s:HV69-"]}`)), []Region{})
+	if err == nil {
+		t.Errorf("expected an error in TestReadConstellations() (unknown feature)")
+	}
+
+	_, err = ReadConstellations(bytes.NewReader([]byte(`{"label": "c1", "sites": []}`)), []Region{})
+	if err == nil {
+		t.Errorf("expected an error in TestReadConstellations() (no sites)")
+	}
+
+	cs, err := ReadConstellations(bytes.NewReader([]byte(`{"label": "c1", "sites": ["del:11288:9", "ins:2028:3"]}`)), []Region{})
+	if err != nil {
+		t.Error(err)
+	}
+	if len(cs) != 1 || len(cs[0].Mutations) != 2 || cs[0].Mutations[0].Representation != "del:11288:9" || len(cs[0].Mutations[0].Sites) != 9 {
+		t.Errorf("problem in TestReadConstellations()")
+	}
+}
+
+func TestReadConstellationsScorpio(t *testing.T) {
+	gb, err := genbank.ReadGenBank(bytes.NewReader(genbankData))
+	if err != nil {
+		t.Error(err)
+	}
+	cdsregions, _, err := RegionsFromGenbank(gb, len(gb.ORIGIN))
+	if err != nil {
+		t.Error(err)
+	}
+
+	// the sites of scorpio's Alpha (B.1.1.7-like) constellation
+	alpha := []byte(`{
+	"label": "Alpha (B.1.1.7-like)",
+	"description": "Alpha lineage defining mutations",
+	"type": "variant",
+	"tags": ["B.1.1.7", "VOC-20DEC-01"],
+	"sites": [
+		"orf1ab:T1001I",
+		"orf1ab:A1708D",
+		"orf1ab:I2230T",
+		"del:11288:9",
+		"s:HV69-",
+		"s:Y144-",
+		"s:N501Y",
+		"s:A570D",
+		"s:P681H",
+		"s:T716I",
+		"s:S982A",
+		"s:D1118H",
+		"orf8:Q27*",
+		"orf8:R52I",
+		"orf8:Y73C",
+		"n:D3L",
+		"n:S235F"
+	]
+}`)
+
+	cs, err := ReadConstellations(bytes.NewReader(alpha), cdsregions)
+	if err != nil {
+		t.Error(err)
+	}
+
+	reps := make([]string, 0)
+	for _, nm := range cs[0].Mutations {
+		reps = append(reps, nm.Representation)
+	}
+
+	desiredResult := []string{"aa:orf1ab:T1001I", "aa:orf1ab:A1708D", "aa:orf1ab:I2230T", "del:11288:9", "del:21767:6",
+		"del:21992:3", "aa:S:N501Y", "aa:S:A570D", "aa:S:P681H", "aa:S:T716I", "aa:S:S982A", "aa:S:D1118H",
+		"aa:ORF8:Q27*", "aa:ORF8:R52I", "aa:ORF8:Y73C", "aa:N:D3L", "aa:N:S235F"}
+
+	if !reflect.DeepEqual(reps, desiredResult) {
+		t.Errorf("problem in TestReadConstellationsScorpio()")
+		fmt.Println(reps)
+	}
+
+	if !reflect.DeepEqual(cs[0].Mutations[4].Sites, []int{21767, 21768, 21769, 21770, 21771, 21772}) {
+		t.Errorf("problem in TestReadConstellationsScorpio() (sites)")
+		fmt.Println(cs[0].Mutations[4].Sites)
+	}
+
+	_, err = ReadConstellations(bytes.NewReader([]byte(`{"label": "c1", "sites": ["s:HV1274-"]}`)), cdsregions)
+	if err == nil {
+		t.Errorf("expected an error in TestReadConstellationsScorpio() (residue outside feature)")
+	}
+}
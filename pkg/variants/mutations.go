@@ -0,0 +1,228 @@
+package variants
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/virus-evolution/gofasta/pkg/alphabet"
+	"github.com/virus-evolution/gofasta/pkg/encoding"
+)
+
+// The possible states of a query sequence at a named mutation, as returned by CallMutation
+const (
+	MutationAlt       = iota // the query has the mutation
+	MutationRef              // the query has the reference allele
+	MutationAmbiguous        // the query has ambiguous or missing data at the mutation's site(s)
+	MutationOther            // the query has something that is neither the mutation nor the reference allele
+)
+
+// A NamedMutation is a mutation parsed from its representation in the format returned by FormatVariant (e.g.
+// "nuc:C3037T", "aa:S:D614G", "del:11288:9"), along with the (1-based) reference positions that a query must have
+// certain nucleotides at for the mutation to be called
+type NamedMutation struct {
+	Representation string
+	Variant        Variant
+	Sites          []int
+	Strand         int // the strand of the feature, for amino acid changes
+}
+
+// ParseMutation parses a mutation in the format returned by FormatVariant. cdsregions are needed to find the codon
+// positions of amino acid changes
+func ParseMutation(s string, cdsregions []Region) (NamedMutation, error) {
+
+	bad := errors.New("couldn't parse mutation: " + s)
+
+	fields := strings.Split(s, ":")
+
+	nm := NamedMutation{Representation: s}
+
+	switch fields[0] {
+	case "nuc":
+		if len(fields) != 2 || len(fields[1]) < 3 {
+			return NamedMutation{}, bad
+		}
+		pos, err := strconv.Atoi(fields[1][1 : len(fields[1])-1])
+		if err != nil {
+			return NamedMutation{}, bad
+		}
+		nm.Variant = Variant{Changetype: "nuc", RefAl: fields[1][0:1], QueAl: fields[1][len(fields[1])-1:], Position: pos}
+		nm.Sites = []int{pos}
+
+	case "aa":
+		if len(fields) != 3 || len(fields[2]) < 3 {
+			return NamedMutation{}, bad
+		}
+		region, name, err := findFeature(fields[1], cdsregions)
+		if err != nil {
+			return NamedMutation{}, errors.New(err.Error() + " for mutation: " + s)
+		}
+		if name != fields[1] {
+			// the representation has to match the annotation (e.g. "S" for "s")
+			nm.Representation = "aa:" + name + ":" + fields[2]
+		}
+		if strings.HasSuffix(fields[2], "-") {
+			return parseAADeletion(s, fields[2], region)
+		}
+		residue, err := strconv.Atoi(fields[2][1 : len(fields[2])-1])
+		if err != nil {
+			return NamedMutation{}, bad
+		}
+		if residue < 1 || residue*3 > len(region.Positions) {
+			return NamedMutation{}, errors.New("residue is outside feature " + fields[1] + " for mutation: " + s)
+		}
+		nm.Sites = region.Positions[(residue-1)*3 : residue*3]
+		nm.Strand = region.Strand
+		nm.Variant = Variant{Changetype: "aa", Feature: region.Name, RefAl: fields[2][0:1], QueAl: fields[2][len(fields[2])-1:], Residue: residue, Position: nm.Sites[0]}
+
+	case "del", "ins":
+		if len(fields) != 3 {
+			return NamedMutation{}, bad
+		}
+		pos, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return NamedMutation{}, bad
+		}
+		length, err := strconv.Atoi(fields[2])
+		if err != nil || length < 1 {
+			return NamedMutation{}, bad
+		}
+		nm.Variant = Variant{Changetype: fields[0], Position: pos, Length: length}
+		if fields[0] == "del" {
+			for p := pos; p < pos+length; p++ {
+				nm.Sites = append(nm.Sites, p)
+			}
+		} else {
+			nm.Sites = []int{pos}
+		}
+
+	default:
+		return NamedMutation{}, bad
+	}
+
+	for _, p := range nm.Sites {
+		if p < 1 {
+			return NamedMutation{}, errors.New("position is out of range for mutation: " + s)
+		}
+	}
+
+	return nm, nil
+}
+
+// findFeature returns the protein-coding region called name, falling back to a case-insensitive match (e.g. "s" for
+// "S"), and the name that it has in the annotation
+func findFeature(name string, cdsregions []Region) (Region, string, error) {
+	for _, r := range cdsregions {
+		if r.Name == name {
+			return r, r.Name, nil
+		}
+	}
+	for _, r := range cdsregions {
+		if strings.EqualFold(r.Name, name) {
+			return r, r.Name, nil
+		}
+	}
+	return Region{}, "", errors.New("couldn't find feature " + name + " in the annotation")
+}
+
+// parseAADeletion parses scorpio's shorthand for the deletion of one or more amino acids (e.g. "HV69-", for the
+// deletion of H69 and V70), and returns it as the deletion of their codons in the format returned by FormatVariant
+// (e.g. "del:21767:6"). The deletion is called at the first position of the first codon, so a query whose deletion
+// has been aligned elsewhere (in a repeat, say) will not have it
+func parseAADeletion(s string, change string, region Region) (NamedMutation, error) {
+
+	bad := errors.New("couldn't parse mutation: " + s)
+
+	i := 0
+	for i < len(change) && (change[i] < '0' || change[i] > '9') {
+		i++
+	}
+	refAAs := change[:i]
+	if len(refAAs) == 0 {
+		return NamedMutation{}, bad
+	}
+	residue, err := strconv.Atoi(change[i : len(change)-1])
+	if err != nil {
+		return NamedMutation{}, bad
+	}
+	last := residue + len(refAAs) - 1
+	if residue < 1 || last*3 > len(region.Positions) {
+		return NamedMutation{}, errors.New("residue is outside feature " + region.Name + " for mutation: " + s)
+	}
+
+	sites := make([]int, len(refAAs)*3)
+	copy(sites, region.Positions[(residue-1)*3:last*3])
+	sort.Ints(sites)
+	if sites[len(sites)-1]-sites[0] != len(sites)-1 {
+		return NamedMutation{}, errors.New("deleted codons are not contiguous in the reference for mutation: " + s)
+	}
+
+	v := Variant{Changetype: "del", Position: sites[0], Length: len(sites)}
+	rep, err := FormatVariant(v, false)
+	if err != nil {
+		return NamedMutation{}, err
+	}
+
+	return NamedMutation{Representation: rep, Variant: v, Sites: sites}, nil
+}
+
+// VariantSet returns the representations (as returned by FormatVariant) of every mutation in AS, for looking up
+// named mutations in
+func VariantSet(AS AnnoStructs) (map[string]bool, error) {
+	set := make(map[string]bool, len(AS.Vs))
+	for _, v := range AS.Vs {
+		rep, err := FormatVariant(v, false)
+		if err != nil {
+			return set, err
+		}
+		set[rep] = true
+	}
+	return set, nil
+}
+
+// CallMutation returns the state of a query at a named mutation: MutationAlt if it is one of the query's mutations (in
+// set, as returned by VariantSet), MutationAmbiguous if any of the mutation's sites are not certain nucleotides in the query,
+// MutationRef if the query has the reference allele, and MutationOther otherwise. query and ref are encoded and aligned, and
+// refToMSA converts reference to alignment coordinates
+func CallMutation(nm NamedMutation, set map[string]bool, ref, query []byte, refToMSA []int) int {
+
+	if set[nm.Representation] {
+		return MutationAlt
+	}
+
+	for _, p := range nm.Sites {
+		if p > len(refToMSA) {
+			return MutationAmbiguous
+		}
+		if query[(p-1)+refToMSA[p-1]]&8 != 8 {
+			return MutationAmbiguous
+		}
+	}
+
+	switch nm.Variant.Changetype {
+	case "nuc":
+		p := nm.Sites[0]
+		if query[(p-1)+refToMSA[p-1]] == ref[(p-1)+refToMSA[p-1]] {
+			return MutationRef
+		}
+		return MutationOther
+	case "aa":
+		codon := make([]byte, 3)
+		for i, p := range nm.Sites {
+			codon[i] = query[(p-1)+refToMSA[p-1]]
+		}
+		DA := encoding.MakeDecodingArray()
+		decoded := DA[codon[0]] + DA[codon[1]] + DA[codon[2]]
+		if nm.Strand == -1 {
+			decoded = alphabet.Complement(decoded)
+		}
+		if alphabet.TranslateAligned(decoded) == nm.Variant.RefAl {
+			return MutationRef
+		}
+		return MutationOther
+	default:
+		// all the sites are certain nucleotides, so they aren't deleted. We don't look any further for insertions
+		return MutationRef
+	}
+}
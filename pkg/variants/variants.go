@@ -54,11 +54,17 @@ type Variant struct {
 // order in the output
 type AnnoStructs struct {
 	Queryname string
+	Strand    string // the strand of the reference that the query aligned to, if known
 	Vs        []Variant
+	Flags     []CDSFlag // premature stops, stop losses and frameshifts in each CDS
 	Idx       int
 }
 
-func Variants(msaIn io.Reader, stdin bool, refID string, annoIn io.Reader, annoSuffix string, out io.Writer, start int, end int, aggregate bool, threshold float64, appendSNP bool, threads int) error {
+func Variants(msaIn io.Reader, stdin bool, refID string, annoIn io.Reader, annoSuffix string, out io.Writer, start int, end int, aggregate bool, threshold float64, appendSNP bool, cdsFlags bool, threads int) error {
+
+	if cdsFlags && aggregate {
+		return errors.New("can't report each query's CDS flags when aggregating variants")
+	}
 
 	var (
 		ref fasta.EncodedRecord
@@ -71,7 +77,7 @@ func Variants(msaIn io.Reader, stdin bool, refID string, annoIn io.Reader, annoS
 		switch x := msaIn.(type) {
 		case *os.File:
 			if !stdin {
-				ref, err = findReference(msaIn, refID)
+				ref, err = FindReference(msaIn, refID)
 				if err != nil {
 					return err
 				}
@@ -81,7 +87,7 @@ func Variants(msaIn io.Reader, stdin bool, refID string, annoIn io.Reader, annoS
 				}
 			}
 		case *bytes.Reader:
-			ref, err = findReference(msaIn, refID)
+			ref, err = FindReference(msaIn, refID)
 			if err != nil {
 				return err
 			}
@@ -114,102 +120,14 @@ func Variants(msaIn io.Reader, stdin bool, refID string, annoIn io.Reader, annoS
 		}
 	}
 
-	var (
-		cdsregions         []Region
-		intregions         []int
-		refToMSA, MSAToRef []int
-	)
-
-	switch annoSuffix {
-	case "gb":
-		gb, err := genbank.ReadGenBank(annoIn)
-		if err != nil {
-			return err
-		}
-
-		// get the reference from the genbank source if required
-		if len(ref.Seq) == 0 {
-			EA := encoding.MakeEncodingArray()
-			encodedrefseq := make([]byte, len(gb.ORIGIN))
-			for i := range gb.ORIGIN {
-				encodedrefseq[i] = EA[gb.ORIGIN[i]]
-			}
-			ref = fasta.EncodedRecord{ID: "annotation_fasta", Seq: encodedrefseq}
-			os.Stderr.WriteString("using --annotation fasta as reference\n")
-		}
-
-		refLenDegapped := len(ref.Decode().Degap().Seq)
-
-		// get a list of CDS + intergenic regions from the genbank file
-		cdsregions, intregions, err = RegionsFromGenbank(gb, refLenDegapped)
-		if err != nil {
-			return err
-		}
-
-		// get the offsets accounting for insertions relative to the reference
-		refToMSA, MSAToRef = GetMSAOffsets(ref.Seq)
-
-		// check that the reference sequence is in the same coordinates as the annotation
-		if len(refToMSA) != len(gb.ORIGIN) {
-			return errors.New("the degapped reference sequence (" + ref.ID + ") is not the same length as the genbank annotation")
-		}
-
-	case "gff":
-		gff, err := gff.ReadGFF(annoIn)
-		if err != nil {
-			return err
-		}
-
-		// get the reference from the gff FASTA if required
-		if len(ref.Seq) == 0 {
-			switch len(gff.FASTA) {
-			case 0:
-				return errors.New("couldn't find a reference sequence in the --msa or the gff")
-			case 1:
-				var encodedrefseq []byte
-				for _, v := range gff.FASTA {
-					encodedrefseq = make([]byte, len(v.Seq))
-					EA := encoding.MakeEncodingArray()
-					for i := range v.Seq {
-						encodedrefseq[i] = EA[v.Seq[i]]
-					}
-					ref = fasta.EncodedRecord{ID: "annotation_fasta", Seq: encodedrefseq}
-				}
-				os.Stderr.WriteString("using --annotation fasta as reference\n")
-			default:
-				return errors.New("more than one sequence in gff ##FASTA section")
-			}
-
-		}
-
-		// get the offsets accounting for insertions relative to the reference
-		refToMSA, MSAToRef = GetMSAOffsets(ref.Seq)
-
-		refSeqDegapped := ref.Decode().Degap().Seq
-
-		// check that the reference sequence is in the same coordinates as the annotation, if the gff
-		// file has a ##sequence-region line
-		if len(gff.SequenceRegions) > 1 {
-			return errors.New("more than one sequence-region in gff header")
-		} else if len(gff.SequenceRegions) == 1 {
-			for key := range gff.SequenceRegions {
-				region := key
-				if len(refSeqDegapped) != gff.SequenceRegions[region].End {
-					return errors.New("the degapped reference sequence (" + ref.ID + ") is not the same length as the gff annotation")
-				}
-			}
-		}
-
-		// get a list of CDS + intergenic regions from the gff file
-		cdsregions, intregions, err = RegionsFromGFF(gff, refSeqDegapped)
-		if err != nil {
-			return err
-		}
-
-	default:
-		return errors.New("couldn't tell if --annotation was a .gb or a .gff file")
+	ref, cdsregions, intregions, err := RegionsFromAnnotation(annoIn, annoSuffix, ref)
+	if err != nil {
+		return err
 	}
 
+	// get the offsets accounting for insertions relative to the reference
+	refToMSA, MSAToRef := GetMSAOffsets(ref.Seq)
+
 	cVariants := make(chan AnnoStructs, 50+threads)
 	cVariantsDone := make(chan bool)
 	cWriteDone := make(chan bool)
@@ -218,7 +136,7 @@ func Variants(msaIn io.Reader, stdin bool, refID string, annoIn io.Reader, annoS
 	case true:
 		go AggregateWriteVariants(out, start, end, appendSNP, threshold, ref.ID, cVariants, cWriteDone, cErr)
 	case false:
-		go WriteVariants(out, start, end, firstmissing, appendSNP, ref.ID, cVariants, cWriteDone, cErr)
+		go WriteVariants(out, start, end, firstmissing, appendSNP, false, cdsFlags, ref.ID, cVariants, cWriteDone, cErr)
 	}
 
 	var wgVariants sync.WaitGroup
@@ -226,7 +144,7 @@ func Variants(msaIn io.Reader, stdin bool, refID string, annoIn io.Reader, annoS
 
 	for n := 0; n < threads; n++ {
 		go func() {
-			getVariants(ref, cdsregions, intregions, refToMSA, MSAToRef, cMSA, cVariants, cErr)
+			getVariants(ref, cdsregions, intregions, refToMSA, MSAToRef, start, end, cMSA, cVariants, cErr)
 			wgVariants.Done()
 		}()
 	}
@@ -268,10 +186,10 @@ func Variants(msaIn io.Reader, stdin bool, refID string, annoIn io.Reader, annoS
 	return nil
 }
 
-// findReference gets the reference sequence from the msa if it is in there.
+// FindReference gets the reference sequence from the msa if it is in there.
 // If it isn't, we will try get it from the annotation (in which case there can
 // be no insertions relative to the reference in the msa)
-func findReference(msaIn io.Reader, referenceID string) (fasta.EncodedRecord, error) {
+func FindReference(msaIn io.Reader, referenceID string) (fasta.EncodedRecord, error) {
 
 	var err error
 
@@ -361,6 +279,144 @@ func findReference(msaIn io.Reader, referenceID string) (fasta.EncodedRecord, er
 	return refRec, nil
 }
 
+// FindAndRewind finds the reference, refID, in an alignment that isn't being read from stdin, then moves the
+// reader back to the beginning of the alignment so that it can be streamed (we are scanning through it twice).
+// If refID is empty or the alignment is read from stdin, it returns an empty record
+func FindAndRewind(msaIn io.Reader, stdin bool, refID string) (fasta.EncodedRecord, error) {
+
+	if refID == "" || stdin {
+		return fasta.EncodedRecord{}, nil
+	}
+
+	x, ok := msaIn.(io.Seeker)
+	if !ok {
+		return fasta.EncodedRecord{}, errors.New("couldn't rewind --msa after finding the --reference")
+	}
+	ref, err := FindReference(msaIn, refID)
+	if err != nil {
+		return fasta.EncodedRecord{}, err
+	}
+	_, err = x.Seek(0, io.SeekStart)
+	if err != nil {
+		return fasta.EncodedRecord{}, err
+	}
+
+	return ref, nil
+}
+
+// StreamWithReference streams the encoded records of a fasta-format alignment to cMSA, as
+// fasta.StreamEncodeAlignment does, and returns its reference, refID. If the alignment is read from stdin,
+// the reference has to be its first record, which is taken from the stream and firstmissing is true.
+// Otherwise the reference is found by FindAndRewind
+func StreamWithReference(msaIn io.Reader, stdin bool, refID string, atgc bool, cMSA chan fasta.EncodedRecord, cErr chan error, cMSADone chan bool) (fasta.EncodedRecord, bool, error) {
+
+	ref, err := FindAndRewind(msaIn, stdin, refID)
+	if err != nil {
+		return ref, false, err
+	}
+
+	go fasta.StreamEncodeAlignment(msaIn, cMSA, cErr, cMSADone, false, atgc, false)
+
+	if !stdin || refID == "" {
+		return ref, false, nil
+	}
+
+	ref, err = fasta.FirstRecord(cMSA, cErr, cMSADone)
+	if err != nil {
+		return ref, false, err
+	}
+	if ref.ID != refID {
+		return ref, false, errors.New("--reference is not the first record in --msa")
+	}
+
+	return ref, true, nil
+}
+
+// RegionsFromAnnotation parses a genbank or gff version 3 format annotation to get the protein-coding
+// and intergenic regions of the genome. If ref has no sequence, the reference is taken from the annotation
+// instead. The reference that was used is returned along with the regions.
+func RegionsFromAnnotation(annoIn io.Reader, annoSuffix string, ref fasta.EncodedRecord) (fasta.EncodedRecord, []Region, []int, error) {
+
+	var (
+		cdsregions []Region
+		intregions []int
+	)
+
+	EA := encoding.MakeEncodingArray()
+
+	switch annoSuffix {
+	case "gb":
+		gb, err := genbank.ReadGenBank(annoIn)
+		if err != nil {
+			return ref, cdsregions, intregions, err
+		}
+
+		if len(ref.Seq) == 0 {
+			encodedrefseq := make([]byte, len(gb.ORIGIN))
+			for i := range gb.ORIGIN {
+				encodedrefseq[i] = EA[gb.ORIGIN[i]]
+			}
+			ref = fasta.EncodedRecord{ID: "annotation_fasta", Seq: encodedrefseq}
+			os.Stderr.WriteString("using --annotation fasta as reference\n")
+		}
+
+		refLenDegapped := len(ref.Decode().Degap().Seq)
+		if refLenDegapped != len(gb.ORIGIN) {
+			return ref, cdsregions, intregions, errors.New("the degapped reference sequence (" + ref.ID + ") is not the same length as the genbank annotation")
+		}
+
+		cdsregions, intregions, err = RegionsFromGenbank(gb, refLenDegapped)
+		if err != nil {
+			return ref, cdsregions, intregions, err
+		}
+
+	case "gff":
+		gff, err := gff.ReadGFF(annoIn)
+		if err != nil {
+			return ref, cdsregions, intregions, err
+		}
+
+		if len(ref.Seq) == 0 {
+			switch len(gff.FASTA) {
+			case 0:
+				return ref, cdsregions, intregions, errors.New("couldn't find a reference sequence in the --msa or the gff")
+			case 1:
+				for _, v := range gff.FASTA {
+					encodedrefseq := make([]byte, len(v.Seq))
+					for i := range v.Seq {
+						encodedrefseq[i] = EA[v.Seq[i]]
+					}
+					ref = fasta.EncodedRecord{ID: "annotation_fasta", Seq: encodedrefseq}
+				}
+				os.Stderr.WriteString("using --annotation fasta as reference\n")
+			default:
+				return ref, cdsregions, intregions, errors.New("more than one sequence in gff ##FASTA section")
+			}
+		}
+
+		refSeqDegapped := ref.Decode().Degap().Seq
+
+		if len(gff.SequenceRegions) > 1 {
+			return ref, cdsregions, intregions, errors.New("more than one sequence-region in gff header")
+		}
+		for key := range gff.SequenceRegions {
+			if len(refSeqDegapped) != gff.SequenceRegions[key].End {
+				return ref, cdsregions, intregions, errors.New("the degapped reference sequence (" + ref.ID + ") is not the same length as the gff annotation")
+			}
+		}
+
+		cdsregions, intregions, err = RegionsFromGFF(gff, refSeqDegapped)
+		if err != nil {
+			return ref, cdsregions, intregions, err
+		}
+
+	default:
+		return ref, cdsregions, intregions, errors.New("couldn't tell if --annotation was a .gb or a .gff file")
+	}
+
+	return ref, cdsregions, intregions, nil
+}
+
 func RegionsFromGFF(anno gff.GFF, refSeqDegapped string) ([]Region, []int, error) {
 
 	IDed := make(map[string][]gff.Feature)
@@ -629,10 +685,24 @@ func GetMSAOffsets(refseq []byte) ([]int, []int) {
 	return refToMSA, MSAToRef
 }
 
+// TranslateRegion returns the translation of the nucleotides in an aligned sequence at the positions of one
+// protein-coding region, as by alphabet.TranslateAligned. Alignment columns that are insertions relative to the
+// reference are ignored
+func TranslateRegion(seq string, region Region, refToMSA []int) string {
+	nuc := make([]byte, len(region.Positions))
+	for i, p := range region.Positions {
+		nuc[i] = seq[(p-1)+refToMSA[p-1]]
+	}
+	if region.Strand == -1 {
+		return alphabet.TranslateAligned(alphabet.Complement(string(nuc)))
+	}
+	return alphabet.TranslateAligned(string(nuc))
+}
+
 // getVariants annotates mutations between query and reference sequences, one
 // fasta record at a time. It reads each fasta record from a channel and passes
 // all its mutations grouped together in one struct to another channel.
-func getVariants(ref fasta.EncodedRecord, cdsregions []Region, intregions []int, offsetRefCoord []int, offsetMSACoord []int, cMSA chan fasta.EncodedRecord, cVariants chan AnnoStructs, cErr chan error) {
+func getVariants(ref fasta.EncodedRecord, cdsregions []Region, intregions []int, offsetRefCoord []int, offsetMSACoord []int, start, end int, cMSA chan fasta.EncodedRecord, cVariants chan AnnoStructs, cErr chan error) {
 
 	for record := range cMSA {
 
@@ -647,6 +717,8 @@ func getVariants(ref fasta.EncodedRecord, cdsregions []Region, intregions []int,
 			break
 		}
 
+		AS.Flags = GetCDSFlags(AS.Vs, cdsregions, start, end, false)
+
 		cVariants <- AS
 	}
 }
@@ -726,8 +798,17 @@ func FormatVariant(v Variant, appendSNP bool) (string, error) {
 	return s, nil
 }
 
+// inWindow asks if a variant's position is in the 1-based, inclusive region between start and end that output is
+// restricted to. Every variant is in it unless both start and end are > 0
+func inWindow(v Variant, start, end int) bool {
+	if start > 0 && end > 0 {
+		return v.Position >= start && v.Position <= end
+	}
+	return true
+}
+
 // WriteVariants writes each query's mutations to file or stdout
-func WriteVariants(w io.Writer, start, end int, firstmissing bool, appendSNP bool, refID string, cVariants chan AnnoStructs, cWriteDone chan bool, cErr chan error) {
+func WriteVariants(w io.Writer, start, end int, firstmissing bool, appendSNP bool, strand bool, cdsFlags bool, refID string, cVariants chan AnnoStructs, cWriteDone chan bool, cErr chan error) {
 
 	outputMap := make(map[int]AnnoStructs)
 
@@ -742,7 +823,14 @@ func WriteVariants(w io.Writer, start, end int, firstmissing bool, appendSNP boo
 	var err error
 	var sa []string
 
-	_, err = w.Write([]byte("query,mutations\n"))
+	header := "query,mutations"
+	if strand {
+		header = "query,strand,mutations"
+	}
+	if cdsFlags {
+		header += ",cds_flags"
+	}
+	_, err = w.Write([]byte(header + "\n"))
 	if err != nil {
 		cErr <- err
 		return
@@ -760,17 +848,19 @@ func WriteVariants(w io.Writer, start, end int, firstmissing bool, appendSNP boo
 					continue
 				}
 
-				_, err = w.Write([]byte(VL.Queryname + ","))
+				if strand {
+					_, err = w.Write([]byte(VL.Queryname + "," + VL.Strand + ","))
+				} else {
+					_, err = w.Write([]byte(VL.Queryname + ","))
+				}
 				if err != nil {
 					cErr <- err
 					return
 				}
 				sa = make([]string, 0)
 				for _, v := range VL.Vs {
-					if start > 0 && end > 0 {
-						if v.Position < start || v.Position > end {
-							continue
-						}
+					if !inWindow(v, start, end) {
+						continue
 					}
 					newVar, err := FormatVariant(v, appendSNP)
 					if err != nil {
@@ -779,7 +869,15 @@ func WriteVariants(w io.Writer, start, end int, firstmissing bool, appendSNP boo
 					}
 					sa = append(sa, newVar)
 				}
-				_, err = w.Write([]byte(strings.Join(sa, "|") + "\n"))
+				if cdsFlags {
+					flags := make([]string, 0, len(VL.Flags))
+					for _, f := range VL.Flags {
+						flags = append(flags, FormatCDSFlag(f))
+					}
+					_, err = w.Write([]byte(strings.Join(sa, "|") + "," + strings.Join(flags, "|") + "\n"))
+				} else {
+					_, err = w.Write([]byte(strings.Join(sa, "|") + "\n"))
+				}
 				if err != nil {
 					cErr <- err
 					return
@@ -819,10 +917,8 @@ func AggregateWriteVariants(w io.Writer, start, end int, appendSNP bool, thresho
 		}
 		counter++
 		for _, v := range AS.Vs {
-			if start > 0 && end > 0 {
-				if v.Position < start || v.Position > end {
-					continue
-				}
+			if !inWindow(v, start, end) {
+				continue
 			}
 			rep, err := FormatVariant(v, appendSNP)
 			if err != nil {
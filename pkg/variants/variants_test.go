@@ -23,7 +23,7 @@ ATTAAAGGTTTATACCTTCCCAGGTAACAAACCAACCAACTTTCGATCTCTTGTAGATCTGTTCTCTAAACGAACTTTAA
 
 	out := new(bytes.Buffer)
 
-	err := Variants(msa, false, "", genbankReader, "gb", out, -1, -1, false, 0.0, false, 1)
+	err := Variants(msa, false, "", genbankReader, "gb", out, -1, -1, false, 0.0, false, false, 1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -56,7 +56,7 @@ ATTAAAGGTTTATACCTTCCCAGGTAACAAACCAACCAACTTTCGATCTCTTGTAGATCTGTTCTCTAAACGAACTTTAA
 
 	out = new(bytes.Buffer)
 
-	err = Variants(msaRef, false, "MN908947.3", genbankReader, "gb", out, -1, -1, false, 0.0, false, 1)
+	err = Variants(msaRef, false, "MN908947.3", genbankReader, "gb", out, -1, -1, false, 0.0, false, false, 1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -76,7 +76,7 @@ aa:ORF7a:A8K,aa:ORF7a:A8K
 
 	out = new(bytes.Buffer)
 
-	err = Variants(msa, false, "", gffReader, "gff", out, -1, -1, false, 0.0, false, 1)
+	err = Variants(msa, false, "", gffReader, "gff", out, -1, -1, false, 0.0, false, false, 1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -109,7 +109,7 @@ ATTAAAGGTTTATACCTTCCCAGGTAACAAACCAACCAACTTTCGATCTCTTGTAGATCTGTTCTCTAAACGAACTTTAA
 
 	out := new(bytes.Buffer)
 
-	err := Variants(msa, false, "", genbankReader, "gb", out, -1, -1, false, 0.0, true, 1)
+	err := Variants(msa, false, "", genbankReader, "gb", out, -1, -1, false, 0.0, true, false, 1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -129,7 +129,7 @@ aa:ORF7a:A8K,aa:ORF7a:A8K(nuc:G27415A;nuc:C27416A;nuc:A27417G)
 
 	out = new(bytes.Buffer)
 
-	err = Variants(msa, false, "", gffReader, "gff", out, -1, -1, false, 0.0, true, 1)
+	err = Variants(msa, false, "", gffReader, "gff", out, -1, -1, false, 0.0, true, false, 1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -166,7 +166,7 @@ ATTAAAGGTTTATACCTTCCCAGGTAACAAACCAACCAACTTTCGATCTCTTGTAGATCTGTTCTCTAAACGAACTTTAA
 
 	out := new(bytes.Buffer)
 
-	err := Variants(msa, false, "", genbankReader, "gb", out, -1, -1, true, 0.0, false, 1)
+	err := Variants(msa, false, "", genbankReader, "gb", out, -1, -1, true, 0.0, false, false, 1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -185,7 +185,7 @@ aa:ORF7a:A8K,0.500000000
 
 	out = new(bytes.Buffer)
 
-	err = Variants(msa, false, "", gffReader, "gff", out, -1, -1, true, 0.0, false, 1)
+	err = Variants(msa, false, "", gffReader, "gff", out, -1, -1, true, 0.0, false, false, 1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -221,7 +221,7 @@ ATTAAAGGTTTATACCTTCCCAGGTAACAAACCAACCAACTTTCGATCTCTTGTAGATCTGTTCTCTAAACGAACTTTAA
 
 	out := new(bytes.Buffer)
 
-	err := Variants(msa, false, "", genbankReader, "gb", out, -1, -1, true, 0.0, true, 1)
+	err := Variants(msa, false, "", genbankReader, "gb", out, -1, -1, true, 0.0, true, false, 1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -240,7 +240,7 @@ aa:ORF7a:A8K(nuc:G27415A;nuc:C27416A;nuc:A27417G),0.500000000
 
 	out = new(bytes.Buffer)
 
-	err = Variants(msa, false, "", gffReader, "gff", out, -1, -1, true, 0.0, true, 1)
+	err = Variants(msa, false, "", gffReader, "gff", out, -1, -1, true, 0.0, true, false, 1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -276,7 +276,7 @@ ATTAAAGGTTTATACCTTCCCAGGTAACAAACCAACCAACTTTCGATCTCTTGTAGATCTGTTCTCTAAACGAACTTTAA
 
 	out := new(bytes.Buffer)
 
-	err := Variants(msa, false, "", genbankReader, "gb", out, -1, -1, true, 0.5, false, 1)
+	err := Variants(msa, false, "", genbankReader, "gb", out, -1, -1, true, 0.5, false, false, 1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -293,7 +293,7 @@ aa:ORF7a:A8K,0.500000000
 
 	out = new(bytes.Buffer)
 
-	err = Variants(msa, false, "", gffReader, "gff", out, -1, -1, true, 0.5, false, 1)
+	err = Variants(msa, false, "", gffReader, "gff", out, -1, -1, true, 0.5, false, false, 1)
 	if err != nil {
 		t.Error(err)
 	}
@@ -115,25 +115,25 @@ CCCCCCCCC
 
 	msaReader := bytes.NewReader(msaData)
 
-	ref, err := findReference(msaReader, "MN908947.3")
+	ref, err := FindReference(msaReader, "MN908947.3")
 	if err != nil {
 		t.Error(err)
 	}
 
 	desiredResult := fasta.EncodedRecord{ID: "MN908947.3", Description: "MN908947.3", Seq: []byte{136, 24, 72, 136, 24, 72, 136, 24, 72}, Idx: 0}
 	if !reflect.DeepEqual(ref, desiredResult) {
-		t.Errorf("problem in TestfindReference")
+		t.Errorf("problem in TestFindReference")
 	}
 
 	msaReader = bytes.NewReader(msaData)
-	ref, err = findReference(msaReader, "nottheref")
+	ref, err = FindReference(msaReader, "nottheref")
 	if err != nil {
 		t.Error(err)
 	}
 
 	desiredResult = fasta.EncodedRecord{ID: "nottheref", Description: "nottheref hey", Seq: []byte{136, 136, 136, 136, 136, 136, 136, 136, 136}, Idx: 1}
 	if !reflect.DeepEqual(ref, desiredResult) {
-		t.Errorf("problem in TestfindReference")
+		t.Errorf("problem in TestFindReference")
 	}
 }
 
@@ -148,7 +148,7 @@ ATGAATGAA-G
 
 	msaReader := bytes.NewReader(msaData)
 
-	ref, err := findReference(msaReader, "MN908947.3")
+	ref, err := FindReference(msaReader, "MN908947.3")
 	if err != nil {
 		t.Error(err)
 	}
@@ -197,7 +197,7 @@ ACGTAATGATGAG-TAG-TAAA-T
 	}
 
 	msaReader := bytes.NewReader(msaData)
-	ref, err := findReference(msaReader, "reference")
+	ref, err := FindReference(msaReader, "reference")
 	if err != nil {
 		t.Error(err)
 	}
@@ -292,7 +292,7 @@ ACGTAATGATGAG-TAG-TAAA-T
 	}
 
 	msaReader := bytes.NewReader(msaData)
-	ref, err := findReference(msaReader, "reference")
+	ref, err := FindReference(msaReader, "reference")
 	if err != nil {
 		t.Error(err)
 	}
@@ -410,3 +410,110 @@ somefakething	RefSeq	three_prime_UTR	19	23	.	+	.	ID=somefakething:18..23
 ttttttctacatcatcattacgt
 `)
 }
+
+func TestGetCDSFlags(t *testing.T) {
+	cdsregions := []Region{
+		{Whichtype: "protein-coding", Name: "gene1", Strand: 1, Start: 6, Stop: 17, Translation: "MMM*", Positions: []int{6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17}},
+		{Whichtype: "protein-coding", Name: "gene2", Strand: 1, Start: 21, Stop: 32, Translation: "MMM*", Positions: []int{21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31, 32}},
+	}
+
+	vs := []Variant{
+		{Changetype: "aa", Feature: "gene1", RefAl: "M", QueAl: "*", Residue: 3},
+		{Changetype: "aa", Feature: "gene1", RefAl: "M", QueAl: "*", Residue: 2},
+		{Changetype: "ins", Position: 8, Length: 2},
+		{Changetype: "del", Position: 30, Length: 4},
+	}
+
+	flags := GetCDSFlags(vs, cdsregions, 0, 0, false)
+
+	desiredResult := []CDSFlag{
+		{Flagtype: "stop", Feature: "gene1", Residue: 2, Length: 1},
+		{Flagtype: "frameshift", Feature: "gene1", Length: 2},
+		{Flagtype: "stoploss", Feature: "gene2", Residue: 4},
+	}
+
+	if !reflect.DeepEqual(flags, desiredResult) {
+		t.Errorf("problem in TestGetCDSFlags()")
+		fmt.Println(flags)
+	}
+
+	formatted := make([]string, 0)
+	for _, f := range flags {
+		formatted = append(formatted, FormatCDSFlag(f))
+	}
+	if !reflect.DeepEqual(formatted, []string{"stop:gene1:2:1", "frameshift:gene1:2", "stoploss:gene2:4"}) {
+		t.Errorf("problem in TestGetCDSFlags()")
+		fmt.Println(formatted)
+	}
+
+	// every stop and every frame-shifting indel is flagged on its own
+	flags = GetCDSFlags(append(vs, Variant{Changetype: "del", Position: 12, Length: 1}), cdsregions, 0, 0, true)
+
+	desiredResult = []CDSFlag{
+		{Flagtype: "stop", Feature: "gene1", Residue: 3, Length: 2},
+		{Flagtype: "stop", Feature: "gene1", Residue: 2, Length: 1},
+		{Flagtype: "frameshift", Feature: "gene1", Length: 2},
+		{Flagtype: "frameshift", Feature: "gene1", Length: -1},
+		{Flagtype: "stoploss", Feature: "gene2", Residue: 4},
+	}
+
+	if !reflect.DeepEqual(flags, desiredResult) {
+		t.Errorf("problem in TestGetCDSFlags() (each)")
+		fmt.Println(flags)
+	}
+
+	// only the variants between start and end are flagged
+	flags = GetCDSFlags([]Variant{
+		{Changetype: "aa", Feature: "gene1", RefAl: "M", QueAl: "*", Position: 9, Residue: 2},
+		{Changetype: "ins", Position: 8, Length: 2},
+		{Changetype: "del", Position: 30, Length: 4},
+	}, cdsregions, 9, 29, false)
+
+	if !reflect.DeepEqual(flags, []CDSFlag{{Flagtype: "stop", Feature: "gene1", Residue: 2, Length: 1}}) {
+		t.Errorf("problem in TestGetCDSFlags() (start and end)")
+		fmt.Println(flags)
+	}
+}
+
+func TestGetCDSFlagsFrameshiftStop(t *testing.T) {
+	// deleting the G of gene1's second codon makes its third codon read TGA in the shifted frame, but
+	// that stop isn't called as an amino acid change, so only the frameshift is flagged
+	msaData := []byte(`>reference
+ACGTAATGATGATGTAG-AAAAAA
+>seq1
+ACGTAAT-ATGATGTAG-AAAAAA
+`)
+
+	gb, err := genbank.ReadGenBank(bytes.NewReader(genbankDataShort))
+	if err != nil {
+		t.Error(err)
+	}
+
+	ref, err := FindReference(bytes.NewReader(msaData), "reference")
+	if err != nil {
+		t.Error(err)
+	}
+	refToMSA, MSAToRef := GetMSAOffsets(ref.Seq)
+
+	cdsregions, intregions, err := RegionsFromGenbank(gb, len(ref.Decode().Degap().Seq))
+	if err != nil {
+		t.Error(err)
+	}
+
+	queries, err := fasta.LoadEncodeAlignment(bytes.NewReader(msaData), false, false, false)
+	if err != nil {
+		t.Error(err)
+	}
+
+	AS, err := GetVariantsPair(ref.Seq, queries[1].Seq, "reference", queries[1].ID, 1, cdsregions, intregions, refToMSA, MSAToRef)
+	if err != nil {
+		t.Error(err)
+	}
+
+	flags := GetCDSFlags(AS.Vs, cdsregions, 0, 0, false)
+
+	if !reflect.DeepEqual(flags, []CDSFlag{{Flagtype: "frameshift", Feature: "gene1", Length: -1}}) {
+		t.Errorf("problem in TestGetCDSFlagsFrameshiftStop()")
+		fmt.Println(flags)
+	}
+}